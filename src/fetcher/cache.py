@@ -0,0 +1,137 @@
+"""Response caches for the Steam library fetcher
+
+The fetcher talks to the cache through the ResponseCache protocol, so the in-memory
+cache (lost on restart) and the database-backed cache (survives restarts and full
+re-syncs) are interchangeable.
+"""
+
+import json
+import logging
+import threading
+import time
+from collections.abc import Callable
+from typing import Any, Protocol
+
+from shared.database import ApiCacheEntry, get_db, get_db_transaction
+
+logger = logging.getLogger(__name__)
+
+
+class ResponseCache(Protocol):
+    """Key/value cache for API responses"""
+
+    def get(self, key: str) -> Any | None:
+        """Return the cached value, or None if missing or expired"""
+
+    def set(self, key: str, value: Any, ttl: int) -> None:
+        """Store a value for ttl seconds"""
+
+
+class MemoryCache:
+    """Process-local cache, cleared when the fetcher exits"""
+
+    def __init__(self):
+        self._entries: dict[str, tuple[float, Any]] = {}
+
+    def get(self, key: str) -> Any | None:
+        entry = self._entries.get(key)
+        if not entry:
+            return None
+        expires_at, value = entry
+        if expires_at < time.time():
+            del self._entries[key]
+            return None
+        return value
+
+    def set(self, key: str, value: Any, ttl: int) -> None:
+        self._entries[key] = (time.time() + ttl, value)
+
+
+class NullCache:
+    """Cache that stores nothing, for running with response caching disabled"""
+
+    def get(self, key: str) -> Any | None:
+        return None
+
+    def set(self, key: str, value: Any, ttl: int) -> None:
+        pass
+
+
+class DatabaseCache:
+    """Cache stored in the api_cache table of the library database"""
+
+    def get(self, key: str) -> Any | None:
+        try:
+            with get_db() as session:
+                entry = session.get(ApiCacheEntry, key)
+                if not entry or entry.expires_at < int(time.time()):
+                    return None
+                return json.loads(entry.value)
+        except Exception as e:
+            logger.debug(f"Cache read failed for {key}: {e}")
+            return None
+
+    def set(self, key: str, value: Any, ttl: int) -> None:
+        try:
+            with get_db_transaction() as session:
+                session.merge(ApiCacheEntry(key=key, value=json.dumps(value), expires_at=int(time.time()) + ttl))
+        except Exception as e:
+            logger.debug(f"Cache write failed for {key}: {e}")
+
+    def prune(self) -> int:
+        """Delete expired entries, returning how many were removed"""
+        with get_db_transaction() as session:
+            return session.query(ApiCacheEntry).filter(ApiCacheEntry.expires_at < int(time.time())).delete()
+
+
+class _Call:
+    """A request in flight, shared by every caller asking for the same key"""
+
+    def __init__(self):
+        self.done = threading.Event()
+        self.result: Any = None
+        self.error: BaseException | None = None
+
+
+class SingleFlight:
+    """Coalesce concurrent calls with the same key so only one of them does the work"""
+
+    def __init__(self):
+        self._lock = threading.Lock()
+        self._calls: dict[str, _Call] = {}
+
+    def do(self, key: str, fn: Callable[[], Any]) -> Any:
+        """Run fn, or wait for and share the result of an identical call already running"""
+        with self._lock:
+            call = self._calls.get(key)
+            leader = call is None
+            if leader:
+                call = self._calls[key] = _Call()
+
+        if not leader:
+            call.done.wait()
+            if call.error:
+                raise call.error
+            return call.result
+
+        try:
+            call.result = fn()
+            return call.result
+        except BaseException as e:
+            call.error = e
+            raise
+        finally:
+            with self._lock:
+                del self._calls[key]
+            call.done.set()
+
+
+def create_cache(backend: str) -> ResponseCache:
+    """Build a cache for the given backend name ("memory", "database", or "none")"""
+    if backend == "database":
+        return DatabaseCache()
+    if backend == "none":
+        return NullCache()
+    if backend != "memory":
+        logger.warning(f"Unknown cache backend '{backend}', using memory")
+    return MemoryCache()
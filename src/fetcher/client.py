@@ -0,0 +1,233 @@
+"""Steam client interface and an in-memory mock for tests
+
+SteamClient lists the Steam API calls the sync workflows in SteamLibraryFetcher
+depend on. MockSteamClient implements it from canned data, so tests can run
+fetch_library_data and friends without an API key or network access:
+
+    mock = MockSteamClient(owned_games={STEAM_ID: [{"appid": 620, "name": "Portal 2"}]})
+    fetcher = mock.install(SteamLibraryFetcher("test"))
+    fetcher.fetch_library_data(STEAM_ID)
+"""
+
+from collections.abc import Iterator
+from typing import Protocol, runtime_checkable
+
+
+@runtime_checkable
+class SteamClient(Protocol):
+    """Steam API calls used by the library and friends sync workflows"""
+
+    def iter_owned_games(self, steam_id: str) -> Iterator[dict]: ...
+
+    def get_owned_games(self, steam_id: str) -> list[dict]: ...
+
+    def get_owned_game(self, steam_id: str, appid: int) -> dict | None: ...
+
+    def get_app_list(self, if_modified_since: int = 0, max_results: int = 50000) -> list[dict]: ...
+
+    def get_app_details(self, appid: int, cc: str | None = None, language: str | None = None) -> dict | None: ...
+
+    def get_app_reviews(self, appid: int, language: str = "all") -> dict | None: ...
+
+    def get_app_review_texts(self, appid: int, max_reviews: int = 20, review_filter: str = "all", language: str | None = None) -> list[dict]: ...
+
+    def get_app_tags(self, appid: int) -> list[str] | None: ...
+
+    def get_app_prices(self, app_ids: list[int], cc: str | None = None, batch_size: int = 100) -> dict[int, dict]: ...
+
+    def get_dlc_details(self, dlc_app_ids: list[int]) -> list[dict]: ...
+
+    def get_steamspy_details(self, appid: int) -> dict | None: ...
+
+    def get_deck_compatibility(self, appid: int) -> str | None: ...
+
+    def get_schema_for_game(self, appid: int) -> list[dict] | None: ...
+
+    def get_global_achievement_percentages(self, appid: int) -> dict[str, float]: ...
+
+    def get_news_for_app(self, appid: int, count: int = 5, maxlength: int = 1000) -> list[dict]: ...
+
+    def get_workshop_items(self, appid: int, count: int = 10) -> list[dict]: ...
+
+    def get_player_summaries(self, steam_ids: str) -> list[dict]: ...
+
+    def get_player_summaries_batch(self, steam_ids: list[str], batch_size: int = 100) -> list[dict]: ...
+
+    def get_player_bans(self, steam_ids: str) -> list[dict]: ...
+
+    def get_player_badges(self, steam_id: str) -> dict | None: ...
+
+    def get_steam_level(self, steam_id: str) -> int | None: ...
+
+    def get_friend_list(self, steam_id: str) -> list[dict]: ...
+
+    def get_wishlist(self, steam_id: str, page_size: int = 100) -> list[dict]: ...
+
+    def get_user_stats_for_game(self, steam_id: str, appid: int) -> dict | None: ...
+
+    def get_player_achievements(self, steam_id: str, appid: int) -> list[dict] | None: ...
+
+    def get_shared_library_apps(self, steam_id: str) -> list[dict]: ...
+
+    def get_owned_store_apps(self, steam_id: str) -> set[int] | None: ...
+
+    def resolve_vanity_url(self, vanity: str) -> str | None: ...
+
+
+# Methods MockSteamClient.install() replaces on a fetcher
+CLIENT_METHODS = [name for name in SteamClient.__dict__ if name.startswith(("get_", "iter_", "resolve_"))]
+
+
+class MockSteamClient:
+    """SteamClient backed by canned responses
+
+    Every call is recorded in `calls` as (method, args) so tests can assert on
+    what the sync workflow requested. Anything not configured returns an empty
+    result, mirroring how the real client reports failures.
+    """
+
+    def __init__(self, owned_games: dict[str, list[dict]] | None = None, app_details: dict[int, dict] | None = None, reviews: dict[int, dict] | None = None, review_texts: dict[int, list[dict]] | None = None, tags: dict[int, list[str]] | None = None, prices: dict[int, dict] | None = None, players: dict[str, dict] | None = None, bans: dict[str, dict] | None = None, badges: dict[str, dict] | None = None, friends: dict[str, list[dict]] | None = None, wishlists: dict[str, list[dict]] | None = None, news: dict[int, list[dict]] | None = None, workshop: dict[int, list[dict]] | None = None, shared_apps: dict[str, list[dict]] | None = None, owned_store_apps: dict[str, set[int]] | None = None, vanity_urls: dict[str, str] | None = None, app_list: list[dict] | None = None, player_achievements: dict[tuple[str, int], list[dict]] | None = None):
+        self.owned_games = owned_games or {}
+        self.app_details = app_details or {}
+        self.reviews = reviews or {}
+        self.review_texts = review_texts or {}
+        self.tags = tags or {}
+        self.prices = prices or {}
+        self.players = players or {}
+        self.bans = bans or {}
+        self.badges = badges or {}
+        self.friends = friends or {}
+        self.wishlists = wishlists or {}
+        self.news = news or {}
+        self.workshop = workshop or {}
+        self.shared_apps = shared_apps or {}
+        self.owned_store_apps = owned_store_apps or {}
+        self.vanity_urls = vanity_urls or {}
+        self.app_list = app_list or []
+        self.player_achievements = player_achievements or {}
+        self.calls: list[tuple[str, tuple]] = []
+
+    def install(self, fetcher):
+        """Route the fetcher's Steam API calls to this mock and return the fetcher"""
+        for name in CLIENT_METHODS:
+            setattr(fetcher, name, getattr(self, name))
+        return fetcher
+
+    def _record(self, method: str, *args):
+        self.calls.append((method, args))
+
+    def called(self, method: str) -> list[tuple]:
+        """Return the arguments of every recorded call to method"""
+        return [args for name, args in self.calls if name == method]
+
+    def iter_owned_games(self, steam_id: str) -> Iterator[dict]:
+        self._record("iter_owned_games", steam_id)
+        yield from self.owned_games.get(steam_id, [])
+
+    def get_owned_games(self, steam_id: str) -> list[dict]:
+        self._record("get_owned_games", steam_id)
+        return list(self.owned_games.get(steam_id, []))
+
+    def get_owned_game(self, steam_id: str, appid: int) -> dict | None:
+        self._record("get_owned_game", steam_id, appid)
+        return next((game for game in self.owned_games.get(steam_id, []) if game.get("appid") == appid), None)
+
+    def get_app_list(self, if_modified_since: int = 0, max_results: int = 50000) -> list[dict]:
+        self._record("get_app_list", if_modified_since)
+        return [app for app in self.app_list if app.get("last_modified", 0) > if_modified_since][:max_results]
+
+    def get_app_details(self, appid: int, cc: str | None = None, language: str | None = None) -> dict | None:
+        self._record("get_app_details", appid, cc, language)
+        return self.app_details.get(appid)
+
+    def get_app_reviews(self, appid: int, language: str = "all") -> dict | None:
+        self._record("get_app_reviews", appid, language)
+        return self.reviews.get(appid)
+
+    def get_app_review_texts(self, appid: int, max_reviews: int = 20, review_filter: str = "all", language: str | None = None) -> list[dict]:
+        self._record("get_app_review_texts", appid, max_reviews)
+        return self.review_texts.get(appid, [])[:max_reviews]
+
+    def get_app_tags(self, appid: int) -> list[str] | None:
+        self._record("get_app_tags", appid)
+        return self.tags.get(appid)
+
+    def get_app_prices(self, app_ids: list[int], cc: str | None = None, batch_size: int = 100) -> dict[int, dict]:
+        self._record("get_app_prices", tuple(app_ids), cc)
+        return {appid: self.prices[appid] for appid in app_ids if appid in self.prices}
+
+    def get_dlc_details(self, dlc_app_ids: list[int]) -> list[dict]:
+        self._record("get_dlc_details", tuple(dlc_app_ids))
+        return [{"steam_appid": appid, **self.app_details[appid]} for appid in dlc_app_ids if appid in self.app_details]
+
+    def get_steamspy_details(self, appid: int) -> dict | None:
+        self._record("get_steamspy_details", appid)
+        return None
+
+    def get_deck_compatibility(self, appid: int) -> str | None:
+        self._record("get_deck_compatibility", appid)
+        return None
+
+    def get_schema_for_game(self, appid: int) -> list[dict] | None:
+        self._record("get_schema_for_game", appid)
+        return None
+
+    def get_global_achievement_percentages(self, appid: int) -> dict[str, float]:
+        self._record("get_global_achievement_percentages", appid)
+        return {}
+
+    def get_news_for_app(self, appid: int, count: int = 5, maxlength: int = 1000) -> list[dict]:
+        self._record("get_news_for_app", appid)
+        return self.news.get(appid, [])[:count]
+
+    def get_workshop_items(self, appid: int, count: int = 10) -> list[dict]:
+        self._record("get_workshop_items", appid)
+        return self.workshop.get(appid, [])[:count]
+
+    def get_player_summaries(self, steam_ids: str) -> list[dict]:
+        self._record("get_player_summaries", steam_ids)
+        return [self.players[steam_id] for steam_id in steam_ids.split(",") if steam_id in self.players]
+
+    def get_player_summaries_batch(self, steam_ids: list[str], batch_size: int = 100) -> list[dict]:
+        self._record("get_player_summaries_batch", tuple(steam_ids))
+        return [self.players[steam_id] for steam_id in steam_ids if steam_id in self.players]
+
+    def get_player_bans(self, steam_ids: str) -> list[dict]:
+        self._record("get_player_bans", steam_ids)
+        return [self.bans[steam_id] for steam_id in steam_ids.split(",") if steam_id in self.bans]
+
+    def get_player_badges(self, steam_id: str) -> dict | None:
+        self._record("get_player_badges", steam_id)
+        return self.badges.get(steam_id)
+
+    def get_steam_level(self, steam_id: str) -> int | None:
+        self._record("get_steam_level", steam_id)
+        return (self.badges.get(steam_id) or {}).get("player_level")
+
+    def get_friend_list(self, steam_id: str) -> list[dict]:
+        self._record("get_friend_list", steam_id)
+        return self.friends.get(steam_id, [])
+
+    def get_wishlist(self, steam_id: str, page_size: int = 100) -> list[dict]:
+        self._record("get_wishlist", steam_id)
+        return self.wishlists.get(steam_id, [])
+
+    def get_user_stats_for_game(self, steam_id: str, appid: int) -> dict | None:
+        self._record("get_user_stats_for_game", steam_id, appid)
+        return None
+
+    def get_player_achievements(self, steam_id: str, appid: int) -> list[dict] | None:
+        self._record("get_player_achievements", steam_id, appid)
+        return self.player_achievements.get((steam_id, appid))
+
+    def get_shared_library_apps(self, steam_id: str) -> list[dict]:
+        self._record("get_shared_library_apps", steam_id)
+        return self.shared_apps.get(steam_id, [])
+
+    def get_owned_store_apps(self, steam_id: str) -> set[int] | None:
+        self._record("get_owned_store_apps", steam_id)
+        return self.owned_store_apps.get(steam_id)
+
+    def resolve_vanity_url(self, vanity: str) -> str | None:
+        self._record("resolve_vanity_url", vanity)
+        return self.vanity_urls.get(vanity)
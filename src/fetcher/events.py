@@ -0,0 +1,97 @@
+"""Sync progress events for the Steam library fetcher
+
+The fetcher publishes an event as each game is saved and as the sync moves between
+phases. Components that want progress (a JSON-lines log, a webhook, a dashboard)
+subscribe to a channel instead of polling the database:
+
+    channel = fetcher.events.subscribe()
+    follow(channel, lambda event: print(event["type"], event.get("app_id")))
+
+Publishing never blocks the sync: a subscriber that falls too far behind loses
+events rather than slowing Steam requests down.
+"""
+
+import json
+import logging
+import queue
+import sys
+import threading
+import time
+from collections.abc import Callable
+
+logger = logging.getLogger(__name__)
+
+
+class SyncEvents:
+    """Fan-out of progress events to any number of subscriber channels"""
+
+    def __init__(self):
+        self._lock = threading.Lock()
+        self._channels: list[queue.Queue] = []
+
+    def subscribe(self, maxsize: int = 1000) -> queue.Queue:
+        """Open a channel that receives every event published from now on, then None once closed"""
+        channel = queue.Queue(maxsize=maxsize)
+        with self._lock:
+            self._channels.append(channel)
+        return channel
+
+    def unsubscribe(self, channel: queue.Queue):
+        """Stop delivering events to a channel"""
+        with self._lock:
+            if channel in self._channels:
+                self._channels.remove(channel)
+
+    def publish(self, event_type: str, **data):
+        """Send an event to every subscriber"""
+        event = {"type": event_type, "timestamp": time.time(), **data}
+        with self._lock:
+            channels = list(self._channels)
+        for channel in channels:
+            try:
+                channel.put_nowait(event)
+            except queue.Full:
+                logger.debug(f"Dropping {event_type} event for a slow subscriber")
+
+    def close(self):
+        """Tell every subscriber that no more events will arrive"""
+        with self._lock:
+            channels, self._channels = self._channels, []
+        for channel in channels:
+            # Make room for the sentinel so followers always terminate
+            while True:
+                try:
+                    channel.put_nowait(None)
+                    break
+                except queue.Full:
+                    try:
+                        channel.get_nowait()
+                    except queue.Empty:
+                        pass
+
+
+def follow(channel: queue.Queue, handler: Callable[[dict], None]) -> threading.Thread:
+    """Call handler for each event on a background thread until the channel is closed"""
+
+    def run():
+        while (event := channel.get()) is not None:
+            try:
+                handler(event)
+            except Exception as e:
+                logger.error(f"Sync event handler failed: {e}")
+
+    thread = threading.Thread(target=run, name="sync-events", daemon=True)
+    thread.start()
+    return thread
+
+
+def json_lines_writer(path: str) -> Callable[[dict], None]:
+    """Handler that appends each event as one JSON line to path, or stdout for '-'"""
+    stream = sys.stdout if path == "-" else open(path, "a", encoding="utf-8")
+
+    def write(event: dict):
+        stream.write(json.dumps(event) + "\n")
+        stream.flush()
+
+    return write
+
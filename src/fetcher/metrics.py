@@ -0,0 +1,131 @@
+"""Steam API usage and library sync metrics for the fetcher
+
+The fetcher runs as a short-lived job, so metrics are accumulated in memory during a
+sync and added to cumulative counters in the api_metrics table when it finishes. Gauges
+describing the last sync replace their stored value instead. The MCP server renders
+that table in Prometheus text format at /metrics. Request counts are also added to
+the api_usage table, which the server checks against its daily request budget.
+"""
+
+import json
+import logging
+import re
+import threading
+import time
+from collections import defaultdict
+from urllib.parse import urlparse
+
+from shared.database import ApiMetric, ApiUsage, api_usage_day, get_db_transaction
+
+logger = logging.getLogger(__name__)
+
+# Upper bounds (seconds) of the request latency histogram buckets
+LATENCY_BUCKETS = (0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0)
+
+# Upper bounds (seconds) of the library sync duration histogram buckets
+SYNC_DURATION_BUCKETS = (60, 300, 900, 1800, 3600, 7200, 14400, 28800)
+
+VERSION_SEGMENT = re.compile(r"/v\d+/?$")
+
+
+def endpoint_label(url: str) -> str:
+    """Turn a request URL into a low-cardinality endpoint label, e.g. IPlayerService/GetOwnedGames"""
+    parsed = urlparse(url)
+    path = VERSION_SEGMENT.sub("", parsed.path).strip("/")
+    # Store pages embed the app ID in the path (/app/620/)
+    path = re.sub(r"/\d+$", "", path)
+    if parsed.hostname == "api.steampowered.com":
+        return path
+    return f"{parsed.hostname}/{path}"
+
+
+class ApiMetrics:
+    """In-memory counters for one sync run"""
+
+    def __init__(self):
+        self.values: dict[tuple[str, str], float] = defaultdict(float)
+        self.gauges: dict[tuple[str, str], float] = {}
+        self.sync_games = 0
+        # Requests not yet added to the day's usage, and requests made by the current sync
+        self.requests = 0
+        self.sync_requests = 0
+        self._lock = threading.Lock()
+
+    def _inc(self, name: str, labels: dict, amount: float = 1.0):
+        with self._lock:
+            self.values[(name, json.dumps(labels, sort_keys=True))] += amount
+
+    def _set(self, name: str, labels: dict, value: float):
+        with self._lock:
+            self.gauges[(name, json.dumps(labels, sort_keys=True))] = value
+
+    def _observe(self, name: str, labels: dict, value: float, buckets: tuple):
+        for bucket in buckets:
+            if value <= bucket:
+                self._inc(f"{name}_bucket", {**labels, "le": str(bucket)})
+        self._inc(f"{name}_bucket", {**labels, "le": "+Inf"})
+        self._inc(f"{name}_sum", labels, value)
+        self._inc(f"{name}_count", labels)
+
+    def observe_request(self, url: str, status_code: int, seconds: float):
+        """Count a completed HTTP request and record its latency"""
+        endpoint = endpoint_label(url)
+        with self._lock:
+            self.requests += 1
+            self.sync_requests += 1
+        self._inc("steam_api_requests_total", {"endpoint": endpoint, "status": str(status_code)})
+        if status_code >= 400:
+            self._inc("steam_api_errors_total", {"endpoint": endpoint, "status": str(status_code)})
+        self._observe("steam_api_request_duration_seconds", {"endpoint": endpoint}, seconds, LATENCY_BUCKETS)
+
+    def observe_cache(self, url: str, hit: bool):
+        """Count a response cache lookup"""
+        self._inc("steam_api_cache_lookups_total", {"endpoint": endpoint_label(url), "result": "hit" if hit else "miss"})
+
+    def observe_sync_started(self, scope: str):
+        """Count a library sync starting"""
+        self.sync_games = 0
+        self.sync_requests = 0
+        self._inc("steam_library_syncs_started_total", {"scope": scope})
+
+    def observe_game(self, status: str):
+        """Count a game written by a library sync (saved, fallback, or failed)"""
+        self.sync_games += 1
+        self._inc("steam_library_games_processed_total", {"status": status})
+
+    def observe_sync_finished(self, scope: str, outcome: str, seconds: float):
+        """Record a library sync ending as completed, failed, or cancelled"""
+        if outcome == "completed":
+            self._inc("steam_library_syncs_completed_total", {"scope": scope})
+        else:
+            self._inc("steam_library_syncs_failed_total", {"scope": scope, "reason": "cancelled" if outcome == "cancelled" else "error"})
+        self._observe("steam_library_sync_duration_seconds", {"outcome": outcome}, seconds, SYNC_DURATION_BUCKETS)
+        self._set("steam_library_last_sync_games_per_second", {}, self.sync_games / seconds if seconds > 0 else 0.0)
+        self._set("steam_library_last_sync_timestamp_seconds", {"outcome": outcome}, time.time())
+
+    def flush(self):
+        """Add this run's counters to the persistent totals, store its gauges, and reset"""
+        if not self.values and not self.gauges and not self.requests:
+            return
+
+        try:
+            with get_db_transaction() as session:
+                for (name, labels), value in self.values.items():
+                    metric = session.get(ApiMetric, (name, labels))
+                    if metric:
+                        metric.value += value
+                    else:
+                        session.add(ApiMetric(name=name, labels=labels, value=value))
+                for (name, labels), value in self.gauges.items():
+                    session.merge(ApiMetric(name=name, labels=labels, value=value))
+                if self.requests:
+                    usage = session.get(ApiUsage, api_usage_day())
+                    if usage:
+                        usage.requests += self.requests
+                    else:
+                        session.add(ApiUsage(day=api_usage_day(), requests=self.requests))
+            self.values.clear()
+            self.gauges.clear()
+            self.requests = 0
+        except Exception as e:
+            logger.error(f"Failed to save API metrics: {e}")
@@ -0,0 +1,104 @@
+"""Notifications about finished syncs and wishlist sales
+
+A notifier delivers a short message to one channel. The fetcher turns sync progress
+events into notifications with notification_handler:
+
+    notifiers = notifiers_from_env()
+    follow(fetcher.events.subscribe(), notification_handler(notifiers))
+
+The channels are Discord and plain JSON webhooks (used for --sale-webhook); another
+one only needs a send() method.
+"""
+
+import json
+import logging
+import os
+import urllib.request
+from collections.abc import Callable, Collection
+from typing import Protocol
+
+logger = logging.getLogger(__name__)
+
+# Embed colors by notification level
+DISCORD_COLORS = {"success": 0x2ECC71, "error": 0xE74C3C, "info": 0x3498DB}
+
+
+class Notifier(Protocol):
+    """A channel that notifications can be sent to"""
+
+    def send(self, title: str, message: str, level: str = "info", event: dict | None = None):
+        """Deliver a notification; event is the sync event it was built from, for channels that forward data"""
+
+
+class DiscordNotifier:
+    """Posts notifications to a Discord channel through an incoming webhook"""
+
+    def __init__(self, webhook_url: str, username: str = "Steam Librarian", timeout: float = 10):
+        self.webhook_url = webhook_url
+        self.username = username
+        self.timeout = timeout
+
+    def send(self, title: str, message: str, level: str = "info", event: dict | None = None):
+        payload = {"username": self.username, "embeds": [{"title": title, "description": message, "color": DISCORD_COLORS.get(level, DISCORD_COLORS["info"])}]}
+        # Discord's CDN blocks urllib's default User-Agent
+        request = urllib.request.Request(self.webhook_url, data=json.dumps(payload).encode(), headers={"Content-Type": "application/json", "User-Agent": "steam-librarian"}, method="POST")
+        with urllib.request.urlopen(request, timeout=self.timeout):
+            pass
+
+
+class WebhookNotifier:
+    """POSTs notifications as JSON: the event's fields plus title, message, and level"""
+
+    def __init__(self, url: str, timeout: float = 10):
+        self.url = url
+        self.timeout = timeout
+
+    def send(self, title: str, message: str, level: str = "info", event: dict | None = None):
+        payload = {**(event or {}), "title": title, "message": message, "level": level}
+        request = urllib.request.Request(self.url, data=json.dumps(payload).encode(), headers={"Content-Type": "application/json"}, method="POST")
+        with urllib.request.urlopen(request, timeout=self.timeout):
+            pass
+
+
+def notifiers_from_env() -> list[Notifier]:
+    """Notifiers configured through environment variables"""
+    notifiers = []
+    if webhook_url := os.getenv("DISCORD_WEBHOOK_URL"):
+        notifiers.append(DiscordNotifier(webhook_url))
+    return notifiers
+
+
+def sync_notification(event: dict) -> tuple[str, str, str] | None:
+    """Title, message, and level for events worth a notification, None for the rest"""
+    steam_id = event.get("steam_id")
+    if event["type"] == "sync_completed":
+        failed = event.get("failed_games", 0)
+        message = f"Synced {event.get('processed_games', 0)} of {event.get('total_games', 0)} games for {steam_id}"
+        if failed:
+            message += f" ({failed} with limited data)"
+        return "Library sync completed", message, "success"
+    if event["type"] == "sync_failed":
+        return "Sync failed", f"Sync for {steam_id} failed: {event.get('error')}", "error"
+    if event["type"] == "sync_cancelled":
+        return "Sync cancelled", f"Sync for {steam_id} was cancelled; data saved so far has been kept", "info"
+    if event["type"] == "wishlist_sale":
+        return "Wishlist sale", f"{event.get('name')} is {event.get('discount_percent')}% off at {event.get('formatted_price')}", "info"
+    return None
+
+
+def notification_handler(notifiers: list[Notifier], event_types: Collection[str] | None = None) -> Callable[[dict], None]:
+    """Event handler that sends notifications to every notifier, for events of event_types (default: all)"""
+
+    def notify(event: dict):
+        if event_types is not None and event["type"] not in event_types:
+            return
+        notification = sync_notification(event)
+        if not notification:
+            return
+        for notifier in notifiers:
+            try:
+                notifier.send(*notification, event=event)
+            except Exception as e:
+                logger.error(f"Failed to send {event['type']} notification via {type(notifier).__name__}: {e}")
+
+    return notify
@@ -0,0 +1,95 @@
+"""Record/replay ("VCR") support for the fetcher's Steam HTTP traffic
+
+Recording saves every real response as a JSON fixture; replaying serves those
+fixtures instead of touching the network, so sync and MCP flows can be exercised
+in tests without a live API key. Fixtures are keyed on method, URL, and query
+parameters with API keys and access tokens stripped, so recordings are safe to commit.
+"""
+
+import hashlib
+import json
+import logging
+import re
+from datetime import timedelta
+from pathlib import Path
+from urllib.parse import parse_qsl, urlencode, urlparse
+
+import requests
+from requests.adapters import BaseAdapter, HTTPAdapter
+from requests.structures import CaseInsensitiveDict
+
+logger = logging.getLogger(__name__)
+
+# Query parameters that must never be written to fixtures
+SECRET_PARAMS = {"key", "access_token"}
+
+# Response headers worth keeping in fixtures (caching and rate-limit behavior depends on them)
+RECORDED_HEADERS = ("Content-Type", "ETag", "Last-Modified", "Retry-After")
+
+
+class ReplayMissError(requests.ConnectionError):
+    """No fixture was recorded for a request made in replay mode"""
+
+
+def fixture_key(request: requests.PreparedRequest) -> tuple[str, str]:
+    """Return (file name, canonical request) identifying a request's fixture"""
+    parsed = urlparse(request.url)
+    query = sorted((k, v) for k, v in parse_qsl(parsed.query, keep_blank_values=True) if k not in SECRET_PARAMS)
+    canonical = f"{request.method} {parsed.scheme}://{parsed.netloc}{parsed.path}?{urlencode(query)}"
+    digest = hashlib.sha256(canonical.encode()).hexdigest()[:16]
+    slug = re.sub(r"[^A-Za-z0-9]+", "_", parsed.path).strip("_")[-60:] or "root"
+    return f"{slug}_{digest}.json", canonical
+
+
+class RecordingAdapter(HTTPAdapter):
+    """Send requests normally and save each response as a fixture"""
+
+    def __init__(self, fixture_dir: str):
+        super().__init__()
+        self.fixture_dir = Path(fixture_dir)
+        self.fixture_dir.mkdir(parents=True, exist_ok=True)
+
+    def send(self, request, **kwargs):
+        response = super().send(request, **kwargs)
+        name, canonical = fixture_key(request)
+        fixture = {"request": canonical, "status_code": response.status_code, "headers": {h: response.headers[h] for h in RECORDED_HEADERS if h in response.headers}, "body": response.text}
+        (self.fixture_dir / name).write_text(json.dumps(fixture, indent=2), encoding="utf-8")
+        logger.debug(f"Recorded {canonical} -> {name}")
+        return response
+
+
+class ReplayAdapter(BaseAdapter):
+    """Serve recorded fixtures instead of making network requests"""
+
+    def __init__(self, fixture_dir: str):
+        super().__init__()
+        self.fixture_dir = Path(fixture_dir)
+
+    def send(self, request, **kwargs):
+        name, canonical = fixture_key(request)
+        path = self.fixture_dir / name
+        if not path.exists():
+            raise ReplayMissError(f"No recorded response for {canonical} ({name})", request=request)
+
+        fixture = json.loads(path.read_text(encoding="utf-8"))
+        response = requests.Response()
+        response.status_code = fixture["status_code"]
+        response.headers = CaseInsensitiveDict(fixture.get("headers", {}))
+        response._content = fixture.get("body", "").encode("utf-8")
+        response._content_consumed = True
+        response.encoding = "utf-8"
+        response.url = request.url
+        response.request = request
+        response.elapsed = timedelta(0)
+        return response
+
+    def close(self):
+        pass
+
+
+def install_vcr(session: requests.Session, mode: str, fixture_dir: str):
+    """Mount a recording ("record") or replaying ("replay") adapter on a session"""
+    adapter = RecordingAdapter(fixture_dir) if mode == "record" else ReplayAdapter(fixture_dir)
+    session.mount("http://", adapter)
+    session.mount("https://", adapter)
+    logger.info(f"VCR {mode} mode using fixtures in {fixture_dir}")
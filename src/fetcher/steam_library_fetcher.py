@@ -13,51 +13,201 @@ Fetches detailed information about games in a Steam user's library including:
 
 Usage:
     Set environment variables in .env file:
-    - STEAM_ID: Your Steam ID
+    - STEAM_ID: Your Steam ID (64-bit ID, profile URL, or custom URL name)
     - STEAM_API_KEY: Your Steam API key
 
     Run: python steam_library_fetcher.py
 """
 
 import argparse
+import html
+import json
 import logging
 import os
+import re
+import signal
 import sys
+import threading
 import time
+from collections import deque
+from collections.abc import Callable, Iterator
+from concurrent.futures import Future, ThreadPoolExecutor
 from datetime import datetime
 
 import requests
 from dotenv import load_dotenv
+from sqlalchemy import func
 
 sys.path.append(os.path.dirname(os.path.dirname(os.path.abspath(__file__))))
 
 from fetcher import __version__
+from fetcher.cache import DatabaseCache, MemoryCache, ResponseCache, SingleFlight, create_cache
+from fetcher.events import SyncEvents, follow, json_lines_writer
+from fetcher.metrics import ApiMetrics
+from fetcher.notifications import WebhookNotifier, notification_handler, notifiers_from_env
+from fetcher.recorder import install_vcr
+from fetcher.throttle import AdaptiveThrottle, FetchCancelled, RetryingSession, parse_retry_after
 from shared.database import (
+    DLC,
+    SYNC_SCOPES,
+    Achievement,
+    ArchivedUserGame,
     Category,
     Developer,
+    FriendGame,
     Game,
+    GameLocalization,
+    GameNews,
     GameReview,
     Genre,
+    PriceHistory,
     Publisher,
+    ReviewText,
+    SteamApp,
+    SyncCheckpoint,
     Tag,
+    UserAchievement,
+    UserDLC,
     UserGame,
     UserProfile,
+    WishlistItem,
+    WorkshopItem,
     create_database,
     friends_association,
     get_db,
     get_db_transaction,
     get_or_create,
+    lookup_app_name,
 )
+from shared.steam_store import search_store
+from shared.steamid import parse_steam_identifier
 
 # Set up logging
 logging.basicConfig(level=logging.INFO, format="%(asctime)s - %(levelname)s - %(message)s")
 logger = logging.getLogger(__name__)
 
+DAY = 24 * 60 * 60
+
+# Default lifetime of cached responses, by cache namespace (appdetails follows --cache-days)
+CACHE_TTLS = {"schema": 30 * DAY, "global_achievements": DAY, "deck_compat": 7 * DAY, "steamspy": 7 * DAY}
+
+# Cached responses are kept this long past their TTL so ETag/Last-Modified validators can revalidate them
+STALE_GRACE = 30 * DAY
+
+# Saved games between sync checkpoint writes
+CHECKPOINT_INTERVAL = 25
+
+# What a full sync does with stored games that GetOwnedGames no longer returns
+REMOVED_GAME_POLICIES = ("flag", "archive", "delete")
+
+# Query parameters that must never end up in cache keys
+SECRET_PARAMS = {"key", "access_token"}
+
+# Options that can carry credentials (proxy passwords, webhook tokens), so they're never stored
+# with a checkpoint; a resumed sync falls back to STEAM_PROXY and WISHLIST_SALE_WEBHOOK
+SECRET_OPTIONS = {"--proxy", "--sale-webhook"}
+
+# resolved_category values from the Steam Deck compatibility report
+DECK_COMPAT_CATEGORIES = {0: "unknown", 1: "unsupported", 2: "playable", 3: "verified"}
+
+
+def env_number(name: str, default: int | float, convert: type = int) -> int | float:
+    """Numeric environment variable, or default when unset; exits with an error naming the variable when it isn't a number"""
+    value = os.getenv(name)
+    if not value:
+        return default
+    try:
+        return convert(value)
+    except ValueError:
+        logger.error(f"Invalid {name} '{value}': expected {'a whole number' if convert is int else 'a number'}")
+        sys.exit(1)
+
+
+def checkpoint_options(argv: list[str]) -> list[str]:
+    """Command-line arguments to store with a checkpoint, without --resume or SECRET_OPTIONS and their values"""
+    options = []
+    args = iter(argv)
+    for arg in args:
+        if arg == "--resume" or arg.split("=", 1)[0] in SECRET_OPTIONS:
+            if arg in SECRET_OPTIONS:
+                next(args, None)
+            continue
+        options.append(arg)
+    return options
+
+
+def parse_cache_ttls(spec: str) -> dict[str, int]:
+    """Parse "namespace=hours,..." overrides (e.g. "steamspy=24,schema=720") into seconds"""
+    ttls = {}
+    for item in filter(None, (part.strip() for part in spec.split(","))):
+        namespace, _, hours = item.partition("=")
+        namespace = namespace.strip()
+        if namespace != "appdetails" and namespace not in CACHE_TTLS:
+            raise ValueError(f"Unknown cache namespace '{namespace}' (valid: appdetails, {', '.join(CACHE_TTLS)})")
+        ttls[namespace] = int(float(hours) * 60 * 60)
+    return ttls
+
+
+def iter_json_array_items(chunks: Iterator[str], key: str, prefix: dict | None = None) -> Iterator[dict]:
+    """Yield the objects of the first JSON array named `key` as they arrive, without parsing the whole document
+
+    Scalar fields appearing before the array (e.g. game_count) are collected into
+    `prefix`, when given.
+    """
+    decoder = json.JSONDecoder()
+    marker = f'"{key}"'
+    buffer = ""
+    started = False
+
+    for chunk in chunks:
+        buffer += chunk
+        if not started:
+            start = buffer.find(marker)
+            bracket = buffer.find("[", start + len(marker)) if start != -1 else -1
+            if bracket == -1:
+                continue
+            if prefix is not None:
+                prefix.update({name: int(value) for name, value in re.findall(r'"(\w+)"\s*:\s*(\d+)', buffer[:start])})
+            buffer = buffer[bracket + 1 :]
+            started = True
+
+        while True:
+            buffer = buffer.lstrip().lstrip(",").lstrip()
+            if buffer.startswith("]"):
+                return
+            try:
+                item, end = decoder.raw_decode(buffer)
+            except json.JSONDecodeError:
+                break  # Incomplete object - wait for more data
+            yield item
+            buffer = buffer[end:]
+
+
+# user_games columns compared by a dry run, keyed by the GetOwnedGames field they come from
+OWNED_GAME_FIELDS = {"playtime_forever": "playtime_forever", "playtime_2weeks": "playtime_2weeks", "rtime_last_played": "last_played", "playtime_windows_forever": "playtime_windows_forever", "playtime_mac_forever": "playtime_mac_forever", "playtime_linux_forever": "playtime_linux_forever", "playtime_deck_forever": "playtime_deck_forever"}
+
+
+def owned_game_playtime(game: dict) -> dict:
+    """Extract the per-user playtime fields from a GetOwnedGames entry"""
+    return {"playtime_forever": game.get("playtime_forever", 0), "playtime_2weeks": game.get("playtime_2weeks", 0), "rtime_last_played": game.get("rtime_last_played"), "playtime_windows_forever": game.get("playtime_windows_forever"), "playtime_mac_forever": game.get("playtime_mac_forever"), "playtime_linux_forever": game.get("playtime_linux_forever"), "playtime_deck_forever": game.get("playtime_deck_forever")}
+
+
+def requirements_text(requirements_html: str) -> str:
+    """Plain text of the store's HTML system requirements, one line per item"""
+    text = re.sub(r"<br\s*/?>|</li>|</p>", "\n", requirements_html or "", flags=re.IGNORECASE)
+    text = html.unescape(re.sub(r"<[^>]+>", "", text))
+    return "\n".join(line.strip() for line in text.splitlines() if line.strip())
+
 
 class SteamLibraryFetcher:
-    def __init__(self, api_key: str):
+    def __init__(self, api_key: str, rate_limit_delay: float = 1.0, cache: ResponseCache | None = None):
         self.api_key = api_key
-        self.session = requests.Session()
+        self.rate_limit_delay = rate_limit_delay  # Seconds between API calls
+        # Slows down on 429/5xx responses and pauses all requests after repeated failures
+        self.cancel_event = threading.Event()
+        self.throttle = AdaptiveThrottle(base_delay=self.rate_limit_delay, cancel_event=self.cancel_event)
+        # Retries 429/503 with jittered exponential backoff, honoring Retry-After
+        self.session = RetryingSession(self.throttle)
         # Add headers for API requests
         self.session.headers.update(
             {
@@ -66,25 +216,225 @@ class SteamLibraryFetcher:
                 "Accept-Language": "en-US,en;q=0.9",
             }
         )
-        self.rate_limit_delay = 1.0  # Seconds between API calls
-        self.last_api_call = 0
+        self.session.hooks["response"].append(self._record_response)
+        self.metrics = ApiMetrics()
         # Cache control attributes
         self.cache_days = 7  # Default to 7 days
         self.force_refresh = False
         self.skip_games = False
+        # One of SYNC_SCOPES; narrower scopes make frequent syncs cheap
+        self.scope = "full"
         self.fetch_friends = False
+        # Store only friends' owned app IDs (friends_games), without --friends' full library sync
+        self.fetch_friend_games = False
+        # Store the wishlist and alert when an item's discount reaches sale_threshold percent
+        self.fetch_wishlist = False
+        self.sale_threshold = 50
+        self.refresh_app_catalog = False
+        self.fetch_news = False
+        self.fetch_workshop = False
+        self.fetch_dlc = False
+        self.max_dlc_per_game = 25
+        self.max_review_texts = 0  # Individual reviews to store per game (0 disables)
+        self.fetch_steamspy = False
+        # Store API response cache; swap for DatabaseCache to survive restarts
+        self.cache = cache if cache is not None else MemoryCache()
+        # Coalesces identical in-flight requests when the fetcher is shared between threads
+        self.inflight = SingleFlight()
+        # Per-namespace TTL overrides in seconds (see parse_cache_ttls)
+        self.cache_ttls: dict[str, int] = {}
+        # Store region settings (overridden per library by user_profile.store_country/store_language)
+        self.country_code = "us"
+        self.language = "english"
+        # Steam web access token, required by the Family Groups service for shared library detection
+        self.access_token = None
+        # Pull each played game's unlocked achievements after the library sync (one request per game)
+        self.fetch_achievements = False
+        # Threads fetching game details concurrently during a library sync
+        self.workers = 1
+        # Games written per database transaction during a library sync
+        self.batch_size = 25
+        # Pause between games and cap on freshly fetched games per sync (per-library overrides, see apply_library_throttling)
+        self.game_delay = 0.0
+        self.max_enrichment: int | None = None
+        self._enrichment_lock = threading.Lock()
+        self._enriched = 0
+        # End-of-sync retries of games that failed, waiting retry_backoff seconds and doubling before each round
+        self.retry_attempts = 2
+        self.retry_backoff = 30.0
+        # Games the previous sync couldn't fetch, refreshed this time even if their cached data looks fresh
+        self._retry_next: set[int] = set()
+        # Progress events for subscribers (see fetcher.events)
+        self.events = SyncEvents()
+        # Continue an interrupted sync from its checkpoint instead of starting over
+        self.resume = False
+        # One of REMOVED_GAME_POLICIES
+        self.removed_game_policy = "flag"
+        # Command-line arguments stored with the checkpoint so a resumed sync uses the same options
+        self.sync_options: list[str] = []
+        # Library size reported by the most recent iter_owned_games call
+        self.owned_game_count = 0
 
     def _rate_limit(self):
         """Implement rate limiting to avoid hitting API limits"""
-        current_time = time.time()
-        time_since_last_call = current_time - self.last_api_call
-        if time_since_last_call < self.rate_limit_delay:
-            time.sleep(self.rate_limit_delay - time_since_last_call)
-        self.last_api_call = time.time()
+        self.throttle.wait()
+
+    def _cache_ttl(self, namespace: str) -> int:
+        """Seconds a cached response in the given namespace stays valid"""
+        if namespace in self.cache_ttls:
+            return self.cache_ttls[namespace]
+        if namespace == "appdetails":
+            return int(self.cache_days * DAY)
+        return CACHE_TTLS.get(namespace, DAY)
+
+    def _get_json(self, url: str, params: dict, namespace: str | None = None):
+        """GET a JSON endpoint through the rate limiter
+
+        When a cache namespace is given, the parsed body is cached (as JSON, so any
+        response shape works) for that namespace's TTL. Once the TTL passes, the stored
+        ETag/Last-Modified validators are sent and a 304 reuses the cached body.
+        Identical concurrent cached requests share a single HTTP call. Returns None
+        for other non-200 responses; network and decoding errors propagate to the caller.
+        """
+        if not namespace:
+            return self._fetch_json(url, params)
+
+        key_params = "&".join(f"{k}={v}" for k, v in sorted(params.items()) if k not in SECRET_PARAMS)
+        cache_key = f"{namespace}:{url}?{key_params}"
+        return self.inflight.do(cache_key, lambda: self._fetch_json(url, params, namespace, cache_key))
+
+    def _fetch_json(self, url: str, params: dict, namespace: str | None = None, cache_key: str | None = None):
+        """Cache lookup, conditional request, and cache update behind _get_json"""
+        entry = None
+        headers = {}
+        if cache_key:
+            entry = self.cache.get(cache_key)
+            # Entries written before validators were stored are raw bodies; refetch those
+            if not isinstance(entry, dict) or "fresh_until" not in entry:
+                entry = None
+            fresh = entry is not None and not self.force_refresh and entry["fresh_until"] > time.time()
+            self.metrics.observe_cache(url, fresh)
+            if fresh:
+                return entry["data"]
+            if entry:
+                if entry.get("etag"):
+                    headers["If-None-Match"] = entry["etag"]
+                if entry.get("last_modified"):
+                    headers["If-Modified-Since"] = entry["last_modified"]
+
+        self._rate_limit()
+        response = self.session.get(url, params=params, headers=headers, timeout=30)
+
+        if response.status_code == 304 and entry:
+            logger.debug(f"{url} not modified, reusing cached response")
+            data = entry["data"]
+        elif response.status_code == 200:
+            data = response.json()
+            entry = {"data": data, "etag": response.headers.get("ETag"), "last_modified": response.headers.get("Last-Modified")}
+        else:
+            logger.debug(f"{url} returned {response.status_code}")
+            return None
+
+        if cache_key:
+            ttl = self._cache_ttl(namespace)
+            entry["fresh_until"] = time.time() + ttl
+            self.cache.set(cache_key, entry, ttl + STALE_GRACE)
+        return data
+
+    def configure_transport(self, proxy: str | None = None, ca_bundle: str | None = None, client_cert: str | None = None, verify_tls: bool = True):
+        """Route Steam traffic through a proxy and/or customize TLS verification
+
+        proxy accepts http://, https://, socks5:// and socks5h:// URLs (SOCKS needs PySocks,
+        installed via requests[socks]). Custom transports can be mounted directly with
+        self.session.mount(prefix, adapter).
+        """
+        if proxy:
+            self.session.proxies.update({"http": proxy, "https": proxy})
+            logger.info(f"Routing Steam API traffic through proxy {proxy.split('@')[-1]}")
+        if ca_bundle:
+            self.session.verify = ca_bundle
+        elif not verify_tls:
+            logger.warning("TLS certificate verification is disabled for Steam API requests")
+            self.session.verify = False
+        if client_cert:
+            self.session.cert = client_cert
+
+    def enable_vcr(self, mode: str, fixture_dir: str):
+        """Record responses to, or replay them from, JSON fixtures in fixture_dir"""
+        install_vcr(self.session, mode, fixture_dir)
+        if mode == "replay":
+            # Nothing to be polite to when serving fixtures
+            self.throttle.base_delay = self.throttle.delay = 0
+
+    def cancel(self):
+        """Stop the sync immediately: abort requests in flight and any pending rate-limit waits"""
+        self.cancel_event.set()
+        aborted = self.session.abort_requests()
+        if aborted:
+            logger.debug(f"Aborted {aborted} in-flight Steam API connections")
+
+    def pause(self):
+        """Hold every worker before its next API call until resume(); cancel() still works while paused
+
+        Only sets a flag, so it is safe to call from a signal handler.
+        """
+        self.throttle.paused = True
+
+    def resume(self):
+        """Let a paused sync continue"""
+        self.throttle.paused = False
+
+    @property
+    def paused(self) -> bool:
+        return self.throttle.paused
+
+    def _record_response(self, response: requests.Response, *args, **kwargs):
+        """Session response hook feeding status codes to the adaptive throttle and usage metrics"""
+        self.metrics.observe_request(response.request.url, response.status_code, response.elapsed.total_seconds())
+        self.throttle.record(response.status_code, parse_retry_after(response.headers.get("Retry-After")))
+
+    def apply_library_region(self, steam_id: str):
+        """Use the library's store region/language overrides, if configured"""
+        with get_db() as session:
+            user = session.query(UserProfile).filter_by(steam_id=steam_id).first()
+            if user and user.store_country:
+                self.country_code = user.store_country.lower()
+            if user and user.store_language:
+                self.language = user.store_language
+        logger.info(f"Using store region '{self.country_code}' and language '{self.language}'")
+
+    def apply_library_throttling(self, steam_id: str):
+        """Use the library's sync pacing overrides, if configured
+
+        In multi-user setups a huge library can be slowed down or capped so it
+        doesn't use up the API budget other libraries' syncs depend on.
+        """
+        with get_db() as session:
+            user = session.query(UserProfile).filter_by(steam_id=steam_id).first()
+            if not user:
+                return
+            # Replay mode runs unthrottled
+            if user.sync_rate_limit and self.throttle.base_delay > 0:
+                self.throttle.base_delay = self.throttle.delay = 1 / user.sync_rate_limit
+                logger.info(f"Limiting this library's sync to {user.sync_rate_limit:g} requests per second")
+            if user.sync_game_delay:
+                self.game_delay = user.sync_game_delay
+                logger.info(f"Pausing {self.game_delay:g}s between games")
+            if user.sync_max_enrichment is not None:
+                self.max_enrichment = user.sync_max_enrichment
+                logger.info(f"Fetching store details for at most {self.max_enrichment} games this sync")
+
+    def _take_enrichment_slot(self) -> bool:
+        """Count a game against max_enrichment, returning False once the cap is reached"""
+        with self._enrichment_lock:
+            if self.max_enrichment is not None and self._enriched >= self.max_enrichment:
+                return False
+            self._enriched += 1
+            return True
 
     def _is_game_cached(self, app_id: int) -> bool:
         """Check if game data is recent enough to skip fetching"""
-        if self.force_refresh:
+        if self.force_refresh or app_id in self._retry_next:
             return False
 
         with get_db() as session:
@@ -100,6 +450,46 @@ class SteamLibraryFetcher:
 
             return False
 
+    def iter_owned_games(self, steam_id: str) -> Iterator[dict]:
+        """Yield the user's owned games one at a time while the response streams in
+
+        Large libraries (10k+ games) are never held in memory as a whole. The library
+        size reported by Steam is available in self.owned_game_count once the first
+        game has been yielded.
+        """
+        logger.info("Fetching owned games (streaming)...")
+        self.owned_game_count = 0
+
+        url = "http://api.steampowered.com/IPlayerService/GetOwnedGames/v0001/"
+        params = {"key": self.api_key, "steamid": steam_id, "include_appinfo": True, "include_played_free_games": True, "format": "json"}
+
+        try:
+            self._rate_limit()
+            with self.session.get(url, params=params, timeout=30, stream=True) as response:
+                if response.status_code != 200:
+                    logger.error(f"Steam API returned {response.status_code}")
+                    return
+
+                response.encoding = "utf-8"
+                prefix = {}
+                for game in iter_json_array_items(response.iter_content(chunk_size=65536, decode_unicode=True), "games", prefix):
+                    self.owned_game_count = prefix.get("game_count", 0)
+                    yield game
+
+        except requests.RequestException as e:
+            # A cancelled sync aborts the stream; don't let it look like the end of the library
+            if self.cancel_event.is_set():
+                raise FetchCancelled() from None
+            logger.error(f"Error fetching owned games: {e}")
+
+    def for_each_owned_game(self, steam_id: str, fn: Callable[[dict], None]) -> int:
+        """Call fn for every owned game as it streams in, returning how many games were seen"""
+        count = 0
+        for game in self.iter_owned_games(steam_id):
+            fn(game)
+            count += 1
+        return count
+
     def get_owned_games(self, steam_id: str) -> list[dict]:
         """Get list of games owned by the user using direct Steam Web API"""
         logger.info("Fetching owned games...")
@@ -135,25 +525,237 @@ class SteamLibraryFetcher:
             logger.error(f"Error fetching owned games: {e}")
             return []
 
-    def get_app_details(self, appid: int) -> dict | None:
+    def get_owned_game(self, steam_id: str, appid: int) -> dict | None:
+        """Get the owned-games entry (name and playtime) for a single app, or None if the user doesn't own it"""
+        url = "http://api.steampowered.com/IPlayerService/GetOwnedGames/v0001/"
+        params = {"key": self.api_key, "steamid": steam_id, "include_appinfo": True, "include_played_free_games": True, "appids_filter[0]": appid, "format": "json"}
+
+        try:
+            self._rate_limit()
+            response = self.session.get(url, params=params, timeout=30)
+            if response.status_code == 200:
+                games = response.json().get("response", {}).get("games", [])
+                return next((game for game in games if game.get("appid") == appid), None)
+            logger.error(f"Steam API returned {response.status_code}")
+
+        except Exception as e:
+            logger.error(f"Error fetching owned game {appid}: {e}")
+
+        return None
+
+    def get_app_list(self, if_modified_since: int = 0, max_results: int = 50000) -> list[dict]:
+        """Get the Steam app catalog (appid, name, last_modified) from Steam API
+
+        Pages through IStoreService/GetAppList. Pass if_modified_since to fetch only
+        apps changed after that timestamp instead of the full (very large) list.
+        """
+        logger.info(f"Fetching Steam app list (modified since {if_modified_since})...")
+
+        url = "https://api.steampowered.com/IStoreService/GetAppList/v1/"
+        apps = []
+        last_appid = 0
+
+        try:
+            while True:
+                self._rate_limit()
+                params = {"key": self.api_key, "if_modified_since": if_modified_since, "last_appid": last_appid, "max_results": max_results, "include_games": True, "include_dlc": True, "format": "json"}
+
+                response = self.session.get(url, params=params, timeout=60)
+
+                if response.status_code != 200:
+                    logger.error(f"Steam API returned {response.status_code} for app list")
+                    break
+
+                data = response.json().get("response", {})
+                apps.extend(data.get("apps", []))
+
+                if not data.get("have_more_results"):
+                    break
+                last_appid = data.get("last_appid", 0)
+
+        except Exception as e:
+            logger.error(f"Error fetching app list: {e}")
+
+        logger.info(f"Fetched {len(apps)} apps from Steam app list")
+        return apps
+
+    def update_app_catalog(self):
+        """Incrementally refresh the local appid -> name index"""
+        with get_db() as session:
+            last_modified = session.query(func.max(SteamApp.last_modified)).scalar() or 0
+
+        apps = self.get_app_list(if_modified_since=last_modified)
+
+        with get_db_transaction() as session:
+            for app in apps:
+                app_id = app.get("appid")
+                if not app_id or not app.get("name"):
+                    continue
+                entry = session.get(SteamApp, app_id)
+                if entry:
+                    entry.name = app["name"]
+                    entry.last_modified = app.get("last_modified", 0)
+                else:
+                    session.add(SteamApp(app_id=app_id, name=app["name"], last_modified=app.get("last_modified", 0)))
+
+        logger.info(f"App catalog updated with {len(apps)} changed apps")
+
+    def get_app_details(self, appid: int, cc: str | None = None, language: str | None = None) -> dict | None:
         """Get detailed information about a specific app/game from Store API"""
-        self._rate_limit()
+        url = "https://store.steampowered.com/api/appdetails"
+        params = {"appids": appid, "cc": cc or self.country_code, "l": language or self.language}
+
+        try:
+            data = self._get_json(url, params, namespace="appdetails")
+            if data and str(appid) in data and data[str(appid)].get("success"):
+                return data[str(appid)]["data"]
 
+        except Exception as e:
+            logger.debug(f"Error fetching app details for {appid}: {e}")
+
+        return None
+
+    def get_schema_for_game(self, appid: int) -> list[dict] | None:
+        """Get achievement definitions (display names, descriptions, icons) for an app from Steam API"""
+        try:
+            url = "http://api.steampowered.com/ISteamUserStats/GetSchemaForGame/v2/"
+            params = {"key": self.api_key, "appid": appid, "l": "english", "format": "json"}
+
+            data = self._get_json(url, params, namespace="schema")
+            if data is not None:
+                stats = data.get("game", {}).get("availableGameStats") or {}
+                return stats.get("achievements", [])
+
+        except Exception as e:
+            logger.debug(f"Error fetching achievement schema for {appid}: {e}")
+
+        return None
+
+    def get_global_achievement_percentages(self, appid: int) -> dict[str, float]:
+        """Get the percentage of all players who unlocked each achievement, keyed by API name"""
+        try:
+            url = "http://api.steampowered.com/ISteamUserStats/GetGlobalAchievementPercentagesForApp/v0002/"
+            params = {"gameid": appid, "format": "json"}
+
+            data = self._get_json(url, params, namespace="global_achievements")
+            if data is not None:
+                achievements = data.get("achievementpercentages", {}).get("achievements", [])
+                # Steam returns percent as a string for some apps
+                return {a["name"]: float(a.get("percent", 0)) for a in achievements if a.get("name")}
+
+        except Exception as e:
+            logger.debug(f"Error fetching global achievement percentages for {appid}: {e}")
+
+        return {}
+
+    def get_app_prices(self, app_ids: list[int], cc: str | None = None, batch_size: int = 100) -> dict[int, dict]:
+        """Get current price overviews for many apps using batched Store API requests
+
+        The appdetails endpoint accepts comma-separated appids when filtered to
+        price_overview, so one request covers a whole batch. Free or unavailable
+        apps are omitted from the result.
+        """
+        prices = {}
         url = "https://store.steampowered.com/api/appdetails"
-        params = {"appids": appid, "cc": "us", "l": "english"}
 
+        for i in range(0, len(app_ids), batch_size):
+            batch = app_ids[i : i + batch_size]
+            self._rate_limit()
+
+            try:
+                params = {"appids": ",".join(str(a) for a in batch), "cc": cc or self.country_code, "filters": "price_overview"}
+                response = self.session.get(url, params=params, timeout=30)
+
+                if response.status_code != 200:
+                    logger.debug(f"Store API returned {response.status_code} for price batch")
+                    continue
+
+                for app_id, entry in response.json().items():
+                    # Free games come back with an empty list instead of a dict
+                    data = entry.get("data") if entry.get("success") else None
+                    if isinstance(data, dict) and data.get("price_overview"):
+                        prices[int(app_id)] = data["price_overview"]
+
+            except Exception as e:
+                logger.debug(f"Error fetching price batch: {e}")
+
+        return prices
+
+    def update_game_prices(self, app_ids: list[int]):
+        """Refresh stored prices for the given games in batches, recording price changes in price_history"""
+        logger.info(f"Refreshing prices for {len(app_ids)} games...")
+        prices = self.get_app_prices(app_ids)
+        now = int(datetime.now().timestamp())
+
+        with get_db_transaction() as session:
+            for game in session.query(Game).filter(Game.app_id.in_(app_ids)).all():
+                price = prices.get(game.app_id)
+                if price:
+                    game.price_initial = price.get("initial")
+                    game.price_final = price.get("final")
+                    game.discount_percent = price.get("discount_percent", 0)
+                    game.currency = price.get("currency", "")
+                    self.record_price_history(session, game, now)
+                game.price_last_updated = now
+
+        logger.info(f"Updated prices for {len(prices)} games")
+
+    def record_price_history(self, session, game: Game, timestamp: int):
+        """Add a price_history row when the game's price differs from the last one recorded"""
+        last = session.query(PriceHistory).filter_by(app_id=game.app_id, currency=game.currency).order_by(PriceHistory.recorded_at.desc()).first()
+        if last and (last.price_initial, last.price_final, last.discount_percent) == (game.price_initial, game.price_final, game.discount_percent):
+            return
+        session.add(PriceHistory(app_id=game.app_id, currency=game.currency, recorded_at=timestamp, price_initial=game.price_initial, price_final=game.price_final, discount_percent=game.discount_percent))
+
+    def get_dlc_details(self, dlc_app_ids: list[int]) -> list[dict]:
+        """Get store details for a list of DLC app IDs"""
+        dlc_list = []
+        for dlc_app_id in dlc_app_ids:
+            details = self.get_app_details(dlc_app_id)
+            if not details:
+                continue
+            price = details.get("price_overview") or {}
+            release_date = details.get("release_date") or {}
+            dlc_list.append({"app_id": dlc_app_id, "name": details.get("name", ""), "short_description": details.get("short_description", ""), "header_image": details.get("header_image", ""), "release_date": release_date.get("date", ""), "is_free": details.get("is_free", False), "price_final": price.get("final"), "currency": price.get("currency", "")})
+        return dlc_list
+
+    def search_store(self, query: str, filters: dict | None = None) -> list[dict]:
+        """Search the Steam store for games, including ones not in the library"""
+        self._rate_limit()
+        return search_store(query, filters, cc=self.country_code, language=self.language, session=self.session)
+
+    def get_steamspy_details(self, appid: int) -> dict | None:
+        """Get community tags, owner estimates, and average playtime for an app from SteamSpy"""
         try:
-            response = self.session.get(url, params=params, timeout=30)
+            url = "https://steamspy.com/api.php"
+            params = {"request": "appdetails", "appid": appid}
 
-            if response.status_code == 200:
-                data = response.json()
-                if str(appid) in data and data[str(appid)].get("success"):
-                    return data[str(appid)]["data"]
-            else:
-                logger.debug(f"Store API returned {response.status_code} for appid {appid}")
+            data = self._get_json(url, params, namespace="steamspy")
+            # SteamSpy answers unknown apps with an empty record rather than an error
+            if data and data.get("name"):
+                tags = data.get("tags") or {}
+                # Tags come back as {name: votes}, or an empty list when there are none
+                tag_names = sorted(tags, key=tags.get, reverse=True) if isinstance(tags, dict) else []
+                return {"tags": tag_names, "owners": data.get("owners", ""), "average_forever": data.get("average_forever", 0), "median_forever": data.get("median_forever", 0)}
 
         except Exception as e:
-            logger.debug(f"Error fetching app details for {appid}: {e}")
+            logger.debug(f"Error fetching SteamSpy data for {appid}: {e}")
+
+        return None
+
+    def get_deck_compatibility(self, appid: int) -> str | None:
+        """Get the Steam Deck compatibility rating (verified, playable, unsupported, unknown) for an app"""
+        try:
+            url = "https://store.steampowered.com/saleaction/ajaxgetdeckappcompatibilityreport"
+            params = {"nAppID": appid, "l": self.language}
+
+            data = self._get_json(url, params, namespace="deck_compat")
+            if data and data.get("success") == 1:
+                category = data.get("results", {}).get("resolved_category", 0)
+                return DECK_COMPAT_CATEGORIES.get(category, "unknown")
+
+        except Exception as e:
+            logger.debug(f"Error fetching Deck compatibility for {appid}: {e}")
 
         return None
 
@@ -178,8 +780,6 @@ class SteamLibraryFetcher:
 
     def _extract_tags_from_html(self, html_content: str) -> list[str]:
         """Extract tags from Steam store page HTML"""
-        import re
-
         tags = []
 
         # Pattern to match the tag links within the popular_tags section
@@ -195,8 +795,12 @@ class SteamLibraryFetcher:
 
         return tags
 
-    def get_app_reviews(self, appid: int) -> dict | None:
-        """Get review summary for an app"""
+    def get_app_reviews(self, appid: int, language: str = "all") -> dict | None:
+        """Get review summary for an app
+
+        language restricts the counts to reviews written in one Steam language; the
+        default "all" summarizes every review.
+        """
         self._rate_limit()
 
         # Try using the Steam API first
@@ -204,7 +808,7 @@ class SteamLibraryFetcher:
             # Note: python-steam-api doesn't have a direct method for reviews
             # So we'll make a direct API call
             url = f"https://store.steampowered.com/appreviews/{appid}"
-            params = {"json": "1", "language": "all", "purchase_type": "all", "num_per_page": "0"}
+            params = {"json": "1", "language": language, "purchase_type": "all", "num_per_page": "0"}
 
             response = self.session.get(url, params=params, timeout=30)
 
@@ -220,6 +824,115 @@ class SteamLibraryFetcher:
 
         return None
 
+    def get_app_review_texts(self, appid: int, max_reviews: int = 20, review_filter: str = "all", language: str | None = None) -> list[dict]:
+        """Get individual reviews for an app, following the review cursor across pages
+
+        review_filter is "all" (sorted by helpfulness), "recent", or "updated".
+        Reviews are limited to the library's store language unless one is given.
+        """
+        reviews = []
+        cursor = "*"
+        seen_cursors = set()
+
+        try:
+            url = f"https://store.steampowered.com/appreviews/{appid}"
+            while len(reviews) < max_reviews and cursor not in seen_cursors:
+                seen_cursors.add(cursor)
+                self._rate_limit()
+
+                params = {"json": "1", "filter": review_filter, "language": language or self.language, "purchase_type": "all", "num_per_page": str(min(100, max_reviews - len(reviews))), "cursor": cursor}
+                response = self.session.get(url, params=params, timeout=30)
+
+                if response.status_code != 200:
+                    logger.debug(f"Review API returned {response.status_code} for appid {appid}")
+                    break
+
+                data = response.json()
+                page = data.get("reviews", [])
+                if not page:
+                    break
+
+                reviews.extend(page)
+                cursor = data.get("cursor") or cursor
+
+        except Exception as e:
+            logger.debug(f"Error fetching review texts for {appid}: {e}")
+
+        return reviews[:max_reviews]
+
+    def get_news_for_app(self, appid: int, count: int = 5, maxlength: int = 1000) -> list[dict]:
+        """Get recent news items (patch notes, announcements) for an app from Steam API"""
+        self._rate_limit()
+
+        try:
+            url = "http://api.steampowered.com/ISteamNews/GetNewsForApp/v0002/"
+            params = {"appid": appid, "count": count, "maxlength": maxlength, "format": "json"}
+
+            response = self.session.get(url, params=params, timeout=30)
+
+            if response.status_code == 200:
+                data = response.json()
+                if "appnews" in data:
+                    return data["appnews"].get("newsitems", [])
+            else:
+                logger.debug(f"News API returned {response.status_code} for appid {appid}")
+
+        except Exception as e:
+            logger.debug(f"Error fetching news for {appid}: {e}")
+
+        return []
+
+    def save_game_news(self, appid: int, news_items: list[dict]):
+        """Save news items for a game, skipping items already stored"""
+        with get_db_transaction() as session:
+            for item in news_items:
+                gid = str(item.get("gid", ""))
+                if not gid or session.get(GameNews, gid):
+                    continue
+                session.add(GameNews(gid=gid, app_id=appid, title=item.get("title", ""), url=item.get("url", ""), author=item.get("author", ""), contents=item.get("contents", ""), feed_label=item.get("feedlabel", ""), date=item.get("date", 0)))
+
+    def get_workshop_items(self, appid: int, count: int = 10) -> list[dict]:
+        """Get the most popular Steam Workshop items for an app from Steam API"""
+        self._rate_limit()
+
+        try:
+            url = "https://api.steampowered.com/IPublishedFileService/QueryFiles/v1/"
+            # query_type 3 = ranked by trend, days=30 keeps the ranking current
+            params = {"key": self.api_key, "appid": appid, "query_type": 3, "days": 30, "numperpage": count, "return_short_description": True, "format": "json"}
+
+            response = self.session.get(url, params=params, timeout=30)
+
+            if response.status_code == 200:
+                data = response.json()
+                return data.get("response", {}).get("publishedfiledetails", [])
+            else:
+                logger.debug(f"Workshop API returned {response.status_code} for appid {appid}")
+
+        except Exception as e:
+            logger.debug(f"Error fetching workshop items for {appid}: {e}")
+
+        return []
+
+    def save_workshop_items(self, appid: int, items: list[dict]):
+        """Replace the stored popular workshop items for a game"""
+        with get_db_transaction() as session:
+            session.query(WorkshopItem).filter_by(app_id=appid).delete()
+            for item in items:
+                if not item.get("publishedfileid"):
+                    continue
+                session.add(WorkshopItem(published_file_id=str(item["publishedfileid"]), app_id=appid, title=item.get("title", ""), short_description=item.get("short_description", ""), preview_url=item.get("preview_url", ""), subscriptions=item.get("subscriptions", 0), favorited=item.get("favorited", 0), views=item.get("views", 0), time_updated=item.get("time_updated", 0)))
+
+    def process_workshop_data(self, steam_id: str):
+        """Fetch popular workshop items for the user's games that support the Steam Workshop"""
+        with get_db() as session:
+            workshop_app_ids = [app_id for (app_id,) in session.query(Game.app_id).join(UserGame).filter(UserGame.steam_id == steam_id).join(Game.categories).filter(Category.category_name == "Steam Workshop").all()]
+
+        logger.info(f"Fetching workshop items for {len(workshop_app_ids)} games with Steam Workshop support...")
+        for app_id in workshop_app_ids:
+            items = self.get_workshop_items(app_id)
+            if items:
+                self.save_workshop_items(app_id, items)
+
     def get_player_summaries(self, steam_ids: str) -> list[dict]:
         """Get player profile information from Steam API (supports single ID or comma-separated list)"""
         logger.info(f"Fetching player profile(s) for Steam ID(s): {steam_ids}")
@@ -246,6 +959,98 @@ class SteamLibraryFetcher:
             logger.error(f"Error fetching player summaries: {e}")
             return []
 
+    def get_player_summaries_batch(self, steam_ids: list[str], batch_size: int = 100) -> list[dict]:
+        """Get player profiles for any number of Steam IDs, up to 100 per request (the API maximum)"""
+        profiles = []
+        for i in range(0, len(steam_ids), batch_size):
+            self._rate_limit()
+            profiles.extend(self.get_player_summaries(",".join(steam_ids[i : i + batch_size])))
+        return profiles
+
+    def refresh_user_profiles(self):
+        """Refresh persona names, avatars, and visibility for every stored library in batched requests"""
+        with get_db() as session:
+            steam_ids = [row.steam_id for row in session.query(UserProfile.steam_id).all()]
+        if not steam_ids:
+            return
+
+        logger.info(f"Refreshing {len(steam_ids)} stored profiles...")
+        for profile in self.get_player_summaries_batch(steam_ids):
+            self.save_user_profile(profile, profile.get("steamid"), include_badges=False)
+
+    def get_player_bans(self, steam_ids: str) -> list[dict]:
+        """Get VAC, game, community, and trade ban status from Steam API (supports comma-separated list)"""
+        try:
+            url = "http://api.steampowered.com/ISteamUser/GetPlayerBans/v1/"
+            params = {"key": self.api_key, "steamids": steam_ids, "format": "json"}
+
+            response = self.session.get(url, params=params, timeout=30)
+
+            if response.status_code == 200:
+                data = response.json()
+                if "players" in data:
+                    return data["players"]
+                else:
+                    logger.error("Invalid response structure for player bans")
+                    return []
+            else:
+                logger.error(f"Steam API returned {response.status_code} for player bans")
+                return []
+
+        except Exception as e:
+            logger.error(f"Error fetching player bans: {e}")
+            return []
+
+    def update_player_bans(self, steam_ids: list[str]):
+        """Store ban status on existing user profiles"""
+        bans = self.get_player_bans(",".join(steam_ids))
+        if not bans:
+            return
+
+        with get_db_transaction() as session:
+            for ban in bans:
+                user = session.query(UserProfile).filter_by(steam_id=ban.get("SteamId")).first()
+                if not user:
+                    continue
+                user.vac_banned = ban.get("VACBanned", False)
+                user.number_of_vac_bans = ban.get("NumberOfVACBans", 0)
+                user.number_of_game_bans = ban.get("NumberOfGameBans", 0)
+                user.days_since_last_ban = ban.get("DaysSinceLastBan", 0)
+                user.community_banned = ban.get("CommunityBanned", False)
+                user.economy_ban = ban.get("EconomyBan", "none")
+
+    def resolve_vanity_url(self, vanity: str) -> str | None:
+        """Resolve a custom profile URL name to a 64-bit Steam ID using Steam API"""
+        logger.info(f"Resolving vanity URL: {vanity}")
+
+        try:
+            url = "http://api.steampowered.com/ISteamUser/ResolveVanityURL/v0001/"
+            params = {"key": self.api_key, "vanityurl": vanity, "format": "json"}
+
+            response = self.session.get(url, params=params, timeout=30)
+
+            if response.status_code == 200:
+                data = response.json().get("response", {})
+                if data.get("success") == 1:
+                    return data.get("steamid")
+                else:
+                    logger.error(f"Could not resolve vanity URL '{vanity}': {data.get('message', 'no match')}")
+                    return None
+            else:
+                logger.error(f"Steam API returned {response.status_code} for vanity URL")
+                return None
+
+        except Exception as e:
+            logger.error(f"Error resolving vanity URL: {e}")
+            return None
+
+    def resolve_steam_id(self, identifier: str) -> str | None:
+        """Accept a SteamID64/SteamID3/SteamID2, a profile URL, or a vanity name and return the 64-bit Steam ID"""
+        kind, value = parse_steam_identifier(identifier)
+        if kind == "steamid64":
+            return value
+        return self.resolve_vanity_url(value)
+
     def get_player_badges(self, steam_id: str) -> dict | None:
         """Get player badges and XP information from Steam API"""
         logger.info(f"Fetching player badges/XP for Steam ID: {steam_id}")
@@ -271,6 +1076,136 @@ class SteamLibraryFetcher:
             logger.error(f"Error fetching player badges: {e}")
             return None
 
+    def get_user_stats_for_game(self, steam_id: str, appid: int) -> dict | None:
+        """Get a user's stats and achievement flags for a game from Steam API
+
+        Returns a dict with 'stats' and 'achievements' lists (name/value pairs),
+        or None when the game has no stats or the profile is private.
+        """
+        self._rate_limit()
+
+        try:
+            url = "http://api.steampowered.com/ISteamUserStats/GetUserStatsForGame/v0002/"
+            params = {"key": self.api_key, "steamid": steam_id, "appid": appid, "format": "json"}
+
+            response = self.session.get(url, params=params, timeout=30)
+
+            if response.status_code == 200:
+                data = response.json()
+                if "playerstats" in data:
+                    playerstats = data["playerstats"]
+                    return {"appid": appid, "game_name": playerstats.get("gameName", ""), "stats": playerstats.get("stats", []), "achievements": playerstats.get("achievements", [])}
+                else:
+                    logger.debug(f"No player stats in response for appid {appid}")
+                    return None
+            else:
+                # 400/403 are returned for games without stats or private profiles
+                logger.debug(f"User stats API returned {response.status_code} for appid {appid}")
+                return None
+
+        except Exception as e:
+            logger.debug(f"Error fetching user stats for {appid}: {e}")
+            return None
+
+    def get_player_achievements(self, steam_id: str, appid: int) -> list[dict] | None:
+        """Get a user's achievement progress for a game, with unlock times
+
+        Returns a list of {'apiname', 'achieved', 'unlocktime'} dicts, or None when the
+        game has no achievements or the profile's game details are private.
+        """
+        self._rate_limit()
+
+        try:
+            url = "http://api.steampowered.com/ISteamUserStats/GetPlayerAchievements/v0001/"
+            params = {"key": self.api_key, "steamid": steam_id, "appid": appid, "format": "json"}
+
+            response = self.session.get(url, params=params, timeout=30)
+
+            if response.status_code == 200:
+                playerstats = response.json().get("playerstats", {})
+                if playerstats.get("success"):
+                    return playerstats.get("achievements", [])
+                logger.debug(f"No achievements for appid {appid}: {playerstats.get('error', 'unknown error')}")
+            else:
+                # 400/403 are returned for games without stats or private profiles
+                logger.debug(f"Player achievements API returned {response.status_code} for appid {appid}")
+
+        except Exception as e:
+            logger.debug(f"Error fetching player achievements for {appid}: {e}")
+
+        return None
+
+    def achievements_enabled(self, steam_id: str) -> bool:
+        """Whether to run the achievement sync for a library (user_profile.sync_achievements overrides --achievements)"""
+        with get_db() as session:
+            user = session.get(UserProfile, steam_id)
+            if user and user.sync_achievements is not None:
+                return user.sync_achievements
+        return self.fetch_achievements
+
+    def sync_user_achievements(self, steam_id: str):
+        """Store unlocked achievements and completion percentage for each owned game with achievements
+
+        Only games played since their last achievement sync are requested, so repeat
+        syncs cost one request per recently played game rather than per owned game.
+        """
+        with get_db() as session:
+            rows = session.query(UserGame.app_id, UserGame.playtime_forever, UserGame.last_played, UserGame.achievements_updated, func.count(Achievement.api_name)).join(Achievement, Achievement.app_id == UserGame.app_id).filter(UserGame.steam_id == steam_id).group_by(UserGame.app_id).all()
+
+        now = int(datetime.now().timestamp())
+        pending = []
+        with get_db_transaction() as session:
+            for app_id, playtime, last_played, updated, total in rows:
+                if not playtime:
+                    # Never played, so nothing can be unlocked; no request needed
+                    session.query(UserGame).filter_by(steam_id=steam_id, app_id=app_id).update({"achievements_unlocked": 0, "achievements_total": total, "completion_percent": 0.0})
+                elif self.force_refresh or not updated or (last_played or now) > updated:
+                    pending.append((app_id, total))
+
+        logger.info(f"Syncing achievements for {len(pending)} games ({len(rows) - len(pending)} unchanged or unplayed)...")
+        synced = 0
+        for index, (app_id, total) in enumerate(pending, 1):
+            achievements = self.get_player_achievements(steam_id, app_id)
+            if achievements is None:
+                continue
+
+            unlocked = [a for a in achievements if a.get("achieved")]
+            total = max(total, len(achievements))
+            with get_db_transaction() as session:
+                session.query(UserAchievement).filter_by(steam_id=steam_id, app_id=app_id).delete()
+                for ach in achievements:
+                    session.add(UserAchievement(steam_id=steam_id, app_id=app_id, api_name=ach.get("apiname"), achieved=bool(ach.get("achieved")), unlock_time=ach.get("unlocktime") or None))
+                session.query(UserGame).filter_by(steam_id=steam_id, app_id=app_id).update({"achievements_unlocked": len(unlocked), "achievements_total": total, "completion_percent": round(len(unlocked) / total * 100, 1) if total else 0.0, "achievements_updated": now})
+            synced += 1
+
+            if index % 25 == 0:
+                logger.info(f"Achievement progress: {index}/{len(pending)} games")
+
+        logger.info(f"Synced achievements for {synced} games")
+
+    def get_steam_level(self, steam_id: str) -> int | None:
+        """Get player's Steam level directly from Steam API"""
+        try:
+            url = "http://api.steampowered.com/IPlayerService/GetSteamLevel/v1/"
+            params = {"key": self.api_key, "steamid": steam_id, "format": "json"}
+
+            response = self.session.get(url, params=params, timeout=30)
+
+            if response.status_code == 200:
+                data = response.json()
+                if "response" in data and "player_level" in data["response"]:
+                    return data["response"]["player_level"]
+                else:
+                    logger.debug("No player level in response (profile may be private)")
+                    return None
+            else:
+                logger.error(f"Steam API returned {response.status_code} for Steam level")
+                return None
+
+        except Exception as e:
+            logger.error(f"Error fetching Steam level: {e}")
+            return None
+
     def calculate_steam_level(self, xp: int) -> int:
         """Calculate Steam level from XP using Steam's formula"""
         # Steam's level calculation formula
@@ -302,6 +1237,208 @@ class SteamLibraryFetcher:
 
         return level
 
+    def get_wishlist(self, steam_id: str, page_size: int = 100) -> list[dict]:
+        """Get user's wishlist with priority and current discount data from Steam API
+
+        Pages through IWishlistService/GetWishlistSortedFiltered, which returns store
+        pricing alongside each wishlist entry so no per-game store lookup is needed.
+        """
+        logger.info(f"Fetching wishlist for Steam ID: {steam_id}")
+
+        url = "https://api.steampowered.com/IWishlistService/GetWishlistSortedFiltered/v1/"
+        wishlist = []
+        start_index = 0
+
+        try:
+            while True:
+                self._rate_limit()
+                input_json = {"steamid": steam_id, "context": {"language": self.language, "country_code": self.country_code.upper()}, "data_request": {"include_basic_info": True}, "start_index": start_index, "page_size": page_size}
+                params = {"key": self.api_key, "input_json": json.dumps(input_json)}
+
+                response = self.session.get(url, params=params, timeout=30)
+
+                if response.status_code != 200:
+                    logger.error(f"Steam API returned {response.status_code} for wishlist")
+                    break
+
+                items = response.json().get("response", {}).get("items", [])
+                for item in items:
+                    store_item = item.get("store_item") or {}
+                    purchase = store_item.get("best_purchase_option") or {}
+                    wishlist.append({"appid": item.get("appid"), "name": store_item.get("name", ""), "priority": item.get("priority", 0), "date_added": item.get("date_added", 0), "discount_percent": purchase.get("discount_pct", 0), "final_price": purchase.get("final_price_in_cents"), "original_price": purchase.get("original_price_in_cents", purchase.get("final_price_in_cents")), "formatted_final_price": purchase.get("formatted_final_price", "")})
+
+                if len(items) < page_size:
+                    break
+                start_index += page_size
+
+        except Exception as e:
+            logger.error(f"Error fetching wishlist: {e}")
+
+        logger.info(f"Found {len(wishlist)} wishlist items")
+        return wishlist
+
+    def get_shared_library_apps(self, steam_id: str) -> list[dict]:
+        """Get apps available to the user through Steam Family Sharing
+
+        The Family Groups service only accepts a user access token (not the Web API key),
+        so this returns an empty list unless an access token is configured.
+        """
+        if not self.access_token:
+            return []
+
+        try:
+            url = "https://api.steampowered.com/IFamilyGroupsService/GetFamilyGroupForUser/v1/"
+            params = {"access_token": self.access_token, "steamid": steam_id}
+            response = self.session.get(url, params=params, timeout=30)
+            if response.status_code != 200:
+                logger.error(f"Steam API returned {response.status_code} for family group")
+                return []
+
+            family_groupid = response.json().get("response", {}).get("family_groupid")
+            if not family_groupid:
+                logger.info("User is not in a Steam family group")
+                return []
+
+            url = "https://api.steampowered.com/IFamilyGroupsService/GetSharedLibraryApps/v1/"
+            params = {"access_token": self.access_token, "family_groupid": family_groupid, "steamid": steam_id, "include_own": False, "include_excluded": False}
+            response = self.session.get(url, params=params, timeout=30)
+            if response.status_code != 200:
+                logger.error(f"Steam API returned {response.status_code} for shared library apps")
+                return []
+
+            apps = response.json().get("response", {}).get("apps", [])
+            shared_apps = [app for app in apps if steam_id not in app.get("owner_steamids", [])]
+            logger.info(f"Found {len(shared_apps)} games shared through Family Sharing")
+            return shared_apps
+
+        except Exception as e:
+            logger.error(f"Error fetching shared library apps: {e}")
+            return []
+
+    def process_shared_library(self, steam_id: str, owned_app_ids: set[int]) -> set[int]:
+        """Save Family Sharing games that the user does not own themselves, returning their app IDs"""
+        shared_apps = [app for app in self.get_shared_library_apps(steam_id) if app.get("appid") not in owned_app_ids]
+
+        total = len(shared_apps)
+        for index, app in enumerate(shared_apps, 1):
+            try:
+                game = {"appid": app.get("appid"), "name": app.get("name"), "playtime_forever": app.get("rt_playtime", 0) // 60, "playtime_2weeks": 0}
+                game_data = self.process_game(game, index, total)
+                game_data["shared"] = True
+                game_data["shared_owner_steam_id"] = (app.get("owner_steamids") or [None])[0]
+                self.save_to_database(game_data, steam_id)
+            except Exception as e:
+                logger.error(f"Error processing shared game {app.get('name', 'Unknown')}: {e}")
+
+        return {app.get("appid") for app in shared_apps}
+
+    def get_owned_store_apps(self, steam_id: str) -> set[int] | None:
+        """Get every app the user owns on the store, including DLC, or None if unavailable
+
+        GetOwnedGames never lists DLC, so this reads the store's per-user data, which
+        needs the access token as a login cookie. It is never cached.
+        """
+        if not self.access_token:
+            return None
+
+        try:
+            response = self.session.get("https://store.steampowered.com/dynamicstore/userdata/", cookies={"steamLoginSecure": f"{steam_id}||{self.access_token}"}, timeout=30)
+            if response.status_code != 200:
+                logger.error(f"Steam store returned {response.status_code} for owned apps")
+                return None
+            owned = response.json().get("rgOwnedApps")
+            # An expired or mismatched token gets an empty response rather than an error
+            if not owned:
+                logger.warning("Steam store returned no owned apps; check STEAM_ACCESS_TOKEN")
+                return None
+            return set(owned)
+
+        except Exception as e:
+            logger.error(f"Error fetching owned store apps: {e}")
+            return None
+
+    def sync_owned_dlc(self, steam_id: str) -> int:
+        """Record which DLC of the user's games they own, returning the number owned
+
+        DLC is matched through each game's stored dlc_app_ids, so games whose store
+        details were fetched before DLC lists were kept are covered once refreshed.
+        """
+        owned = self.get_owned_store_apps(steam_id)
+        if owned is None:
+            return 0
+
+        try:
+            with get_db_transaction() as session:
+                rows = session.query(Game.app_id, Game.dlc_app_ids).join(UserGame, UserGame.app_id == Game.app_id).filter(UserGame.steam_id == steam_id, UserGame.removed_at.is_(None), Game.dlc_app_ids.isnot(None)).all()
+                owned_dlc = {dlc_app_id: parent_app_id for parent_app_id, dlc_app_ids in rows for dlc_app_id in json.loads(dlc_app_ids) if dlc_app_id in owned}
+
+                stored = {row.app_id: row for row in session.query(UserDLC).filter_by(steam_id=steam_id)}
+                for app_id, row in stored.items():
+                    if app_id not in owned_dlc:
+                        session.delete(row)
+                for app_id, parent_app_id in owned_dlc.items():
+                    if app_id in stored:
+                        stored[app_id].parent_app_id = parent_app_id
+                    else:
+                        session.add(UserDLC(steam_id=steam_id, app_id=app_id, parent_app_id=parent_app_id))
+
+            logger.info(f"User owns {len(owned_dlc)} DLC for games in their library")
+            return len(owned_dlc)
+        except Exception as e:
+            logger.error(f"Failed to save owned DLC: {e}")
+            return 0
+
+    def reconcile_removed_games(self, steam_id: str, owned_app_ids: set[int], shared_app_ids: set[int] | None = None) -> int:
+        """Apply the removed-games policy to stored games missing from the latest library listing
+
+        Games that left the library (refunds, removals, revoked Family Sharing) are
+        flagged with removed_at, archived to archived_user_games, or deleted, depending
+        on removed_game_policy. Shared games are only considered when shared_app_ids
+        comes from a successful Family Sharing listing. Games that reappear are
+        restored. Returns the number of games removed.
+        """
+        now = int(datetime.now().timestamp())
+        removed = 0
+
+        with get_db_transaction() as session:
+            for user_game in session.query(UserGame).filter_by(steam_id=steam_id).all():
+                if user_game.shared:
+                    # Without a Family Sharing listing there's no way to tell, so leave shared games alone
+                    if shared_app_ids is None:
+                        continue
+                    present = user_game.app_id in shared_app_ids
+                else:
+                    present = user_game.app_id in owned_app_ids
+                if present:
+                    # Back in the library (e.g. a Family Sharing group was rejoined)
+                    if user_game.removed_at is not None:
+                        logger.info(f"Game {user_game.app_id} is back in the library")
+                        user_game.removed_at = None
+                    continue
+                if self.removed_game_policy == "flag" and user_game.removed_at is not None:
+                    continue
+
+                name = user_game.game.name if user_game.game else lookup_app_name(session, user_game.app_id)
+                logger.info(f"Game {name or user_game.app_id} is no longer in the library ({self.removed_game_policy})")
+                removed += 1
+                if self.removed_game_policy == "flag":
+                    user_game.removed_at = now
+                    continue
+
+                if self.removed_game_policy == "archive":
+                    session.merge(ArchivedUserGame(steam_id=steam_id, app_id=user_game.app_id, name=name, playtime_forever=user_game.playtime_forever, last_played=user_game.last_played, shared=bool(user_game.shared), achievements_unlocked=user_game.achievements_unlocked, removed_at=now))
+                session.query(UserAchievement).filter_by(steam_id=steam_id, app_id=user_game.app_id).delete()
+                session.delete(user_game)
+
+            # Archived games that came back were re-created by this sync
+            present_app_ids = owned_app_ids | (shared_app_ids or set())
+            if present_app_ids:
+                session.query(ArchivedUserGame).filter(ArchivedUserGame.steam_id == steam_id, ArchivedUserGame.app_id.in_(present_app_ids)).delete(synchronize_session=False)
+
+        if removed:
+            logger.info(f"{removed} games are no longer in the library")
+        return removed
+
     def get_friend_list(self, steam_id: str) -> list[dict]:
         """Get friend list from Steam API"""
         logger.info(f"Fetching friend list for Steam ID: {steam_id}")
@@ -333,6 +1470,26 @@ class SteamLibraryFetcher:
             logger.error(f"Error fetching friend list: {e}")
             return []
 
+    def get_friends_with_profiles(self, steam_id: str, batch_size: int = 100) -> list[dict]:
+        """Get friend list enriched with persona names and avatars
+
+        Player summaries are requested in batches (Steam accepts up to 100 IDs per call).
+        Friends whose summary could not be fetched are still returned with empty profile fields.
+        """
+        friends = self.get_friend_list(steam_id)
+        if not friends:
+            return []
+
+        friend_ids = [f.get("steamid") for f in friends]
+        profiles = {profile.get("steamid"): profile for profile in self.get_player_summaries_batch(friend_ids, batch_size)}
+
+        enriched = []
+        for friend in friends:
+            profile = profiles.get(friend.get("steamid"), {})
+            enriched.append({"steamid": friend.get("steamid"), "relationship": friend.get("relationship", "friend"), "friend_since": friend.get("friend_since", 0), "persona_name": profile.get("personaname", ""), "profile_url": profile.get("profileurl", ""), "avatar": profile.get("avatar", ""), "avatarmedium": profile.get("avatarmedium", ""), "avatarfull": profile.get("avatarfull", ""), "visibility": profile.get("communityvisibilitystate", 1)})
+
+        return enriched
+
     def save_user_profile(self, player_data: dict | None, steam_id: str, include_badges: bool = False):
         """Save or update a user profile (reusable for main user and friends)
 
@@ -358,15 +1515,20 @@ class SteamLibraryFetcher:
                 # Get XP and level data only for main user
                 xp = 0
                 steam_level = 0
+                badge_count = 0
                 if include_badges:
                     badges_data = self.get_player_badges(steam_id)
                     if badges_data:
                         xp = badges_data.get("player_xp", 0)
                         steam_level = badges_data.get("player_level", 0)
-                        logger.info(f"Player XP: {xp}, Level: {steam_level}")
+                        badge_count = len(badges_data.get("badges", []))
+                    if not steam_level:
+                        # GetBadges omits the level for some privacy settings, GetSteamLevel does not
+                        steam_level = self.get_steam_level(steam_id) or 0
+                    logger.info(f"Player XP: {xp}, Level: {steam_level}, Badges: {badge_count}")
 
                 if not user:
-                    user = UserProfile(steam_id=steam_id, persona_name=persona_name, profile_url=profile_url, avatar_url=avatar, avatarmedium=avatarmedium, avatarfull=avatarfull, time_created=time_created, loccountrycode=loccountrycode, locstatecode=locstatecode, xp=xp, steam_level=steam_level, last_updated=int(datetime.now().timestamp()))
+                    user = UserProfile(steam_id=steam_id, persona_name=persona_name, profile_url=profile_url, avatar_url=avatar, avatarmedium=avatarmedium, avatarfull=avatarfull, time_created=time_created, loccountrycode=loccountrycode, locstatecode=locstatecode, xp=xp, steam_level=steam_level, badge_count=badge_count, last_updated=int(datetime.now().timestamp()))
                     session.add(user)
                     logger.info(f"Created user profile for: {persona_name} (Steam ID: {steam_id})")
                 else:
@@ -382,6 +1544,7 @@ class SteamLibraryFetcher:
                     if include_badges:
                         user.xp = xp
                         user.steam_level = steam_level
+                        user.badge_count = badge_count
                     user.last_updated = int(datetime.now().timestamp())
                     logger.info(f"Updated user profile for: {persona_name} (Steam ID: {steam_id})")
 
@@ -395,23 +1558,37 @@ class SteamLibraryFetcher:
     def process_game(self, game: dict, index: int, total: int) -> dict:
         """Process a single game and gather all required information"""
         appid = game.get("appid")
-        name = game.get("name", "Unknown")
+        name = game.get("name")
+        if not name:
+            with get_db() as session:
+                name = lookup_app_name(session, appid) or "Unknown"
+        playtime = owned_game_playtime(game)
 
         # Check if we should skip games entirely
-        if self.skip_games:
-            logger.debug(f"Skipping game details for {name} (--skip-games flag)")
-            return {"appid": appid, "name": name, "playtime_forever": game.get("playtime_forever", 0), "playtime_2weeks": game.get("playtime_2weeks", 0), "skip_details": True}
+        if self.skip_games or self.scope == "playtime":
+            logger.debug(f"Skipping game details for {name} ({'--skip-games flag' if self.skip_games else 'playtime scope'})")
+            return {"appid": appid, "name": name, **playtime, "skip_details": True}
+
+        # Reviews change independently of store data, so this scope ignores the details cache
+        if self.scope == "reviews":
+            logger.info(f"Processing [{index}/{total}]: {name} (AppID: {appid}) - Fetching reviews")
+            return {"appid": appid, "name": name, **playtime, "skip_details": True, **self.fetch_review_data(appid)}
 
         # Check if data is fresh enough to skip API calls
         if self._is_game_cached(appid):
             logger.info(f"Processing [{index}/{total}]: {name} (AppID: {appid}) - Using cached data")
             # Return minimal data - the save_to_database will only update playtime
-            return {"appid": appid, "name": name, "playtime_forever": game.get("playtime_forever", 0), "playtime_2weeks": game.get("playtime_2weeks", 0), "skip_details": True}
+            return {"appid": appid, "name": name, **playtime, "skip_details": True}
+
+        # Past the library's enrichment cap, stale games keep their data until a later sync
+        if not self._take_enrichment_slot():
+            logger.debug(f"Processing [{index}/{total}]: {name} (AppID: {appid}) - Enrichment cap reached, updating playtime only")
+            return {"appid": appid, "name": name, **playtime, "skip_details": True}
 
         # Progress indicator
         logger.info(f"Processing [{index}/{total}]: {name} (AppID: {appid}) - Fetching fresh data")
 
-        game_info = {"appid": appid, "name": name, "playtime_forever": game.get("playtime_forever", 0), "playtime_2weeks": game.get("playtime_2weeks", 0), "required_age": 0, "short_description": "", "detailed_description": "", "about_the_game": "", "recommendations_total": 0, "metacritic_score": 0, "metacritic_url": "", "header_image": "", "platforms_windows": False, "platforms_mac": False, "platforms_linux": False, "controller_support": "", "vr_support": False, "esrb_rating": "", "esrb_descriptors": "", "pegi_rating": "", "pegi_descriptors": "", "genres": "", "categories": "", "developers": "", "publishers": "", "release_date": "", "tags": "", "review_summary": "Unknown", "review_score": 0, "total_reviews": 0, "positive_reviews": 0, "negative_reviews": 0}
+        game_info = {"appid": appid, "name": name, **playtime, "required_age": 0, "short_description": "", "detailed_description": "", "about_the_game": "", "recommendations_total": 0, "metacritic_score": 0, "metacritic_url": "", "header_image": "", "platforms_windows": False, "platforms_mac": False, "platforms_linux": False, "controller_support": "", "vr_support": False, "esrb_rating": "", "esrb_descriptors": "", "pegi_rating": "", "pegi_descriptors": "", "genres": "", "categories": "", "developers": "", "publishers": "", "release_date": "", "tags": "", "review_summary": "Unknown", "review_score": 0, "total_reviews": 0, "positive_reviews": 0, "negative_reviews": 0}
 
         # Get detailed app information
         app_details = self.get_app_details(appid)
@@ -488,182 +1665,701 @@ class SteamLibraryFetcher:
             release_date = app_details.get("release_date") or {}
             game_info["release_date"] = release_date.get("date", "")
 
-        # Get review information
-        reviews = self.get_app_reviews(appid)
-        if reviews:
-            game_info["review_summary"] = reviews.get("review_score_desc", "Unknown")
-            game_info["review_score"] = reviews.get("review_score", 0)
-            game_info["total_reviews"] = reviews.get("total_reviews", 0)
-            game_info["positive_reviews"] = reviews.get("total_positive", 0)
-            game_info["negative_reviews"] = reviews.get("total_negative", 0)
+            # The store sends an empty list instead of an object when a game lists no requirements
+            pc_requirements = app_details.get("pc_requirements")
+            if isinstance(pc_requirements, dict):
+                game_info["pc_requirements"] = {"minimum": requirements_text(pc_requirements.get("minimum", "")), "recommended": requirements_text(pc_requirements.get("recommended", ""))}
+
+            # DLC app IDs are kept for ownership checks; details are only fetched when requested
+            game_info["dlc_app_ids"] = app_details.get("dlc") or []
+            if self.fetch_dlc and app_details.get("dlc"):
+                game_info["dlc"] = self.get_dlc_details(app_details["dlc"][: self.max_dlc_per_game])
+
+        # Get review information (the metadata scope leaves stored reviews untouched)
+        if self.scope != "metadata":
+            game_info.update(self.fetch_review_data(appid))
+
+        # Get Steam Deck compatibility rating
+        game_info["steam_deck_compat"] = self.get_deck_compatibility(appid)
 
         # Get user-generated tags
-        tags = self.get_app_tags(appid)
+        tags = self.get_app_tags(appid) or []
+
+        # SteamSpy fills in community tags the store page often lacks, plus ownership estimates
+        if self.fetch_steamspy:
+            steamspy = self.get_steamspy_details(appid)
+            if steamspy:
+                tags += [tag for tag in steamspy["tags"] if tag not in tags]
+                game_info["steamspy_owners"] = steamspy["owners"]
+                game_info["steamspy_average_playtime"] = steamspy["average_forever"]
+                game_info["steamspy_median_playtime"] = steamspy["median_forever"]
+
         if tags:
             game_info["tags"] = ", ".join(tags[:20])  # Limit to first 20 tags
             logger.debug(f"Found {len(tags)} tags for {name}: {', '.join(tags[:5])}...")
 
+        # Get achievement definitions for games that have Steam achievements
+        if "Steam Achievements" in game_info["categories"].split(", "):
+            achievements = self.get_schema_for_game(appid)
+            if achievements:
+                percentages = self.get_global_achievement_percentages(appid)
+                for ach in achievements:
+                    ach["global_percent"] = percentages.get(ach.get("name"))
+                game_info["achievements"] = achievements
+
         return game_info
 
+    def fetch_review_data(self, appid: int) -> dict:
+        """Fetch a game's review summary (and review texts if enabled) as game_data fields"""
+        review_data = {}
+        reviews = self.get_app_reviews(appid)
+        if reviews:
+            review_data["review_summary"] = reviews.get("review_score_desc", "Unknown")
+            review_data["review_score"] = reviews.get("review_score", 0)
+            review_data["total_reviews"] = reviews.get("total_reviews", 0)
+            review_data["positive_reviews"] = reviews.get("total_positive", 0)
+            review_data["negative_reviews"] = reviews.get("total_negative", 0)
+
+        if self.max_review_texts > 0:
+            review_data["review_texts"] = self.get_app_review_texts(appid, self.max_review_texts)
+        return review_data
+
+    def save_batch(self, batch: list[dict], steam_id: str) -> list[tuple[dict, Exception]]:
+        """Save several games in one transaction, returning (game_data, error) for games that failed
+
+        If the transaction fails it is rolled back and the games are saved one at a
+        time, so a single bad game doesn't lose the rest of the batch.
+        """
+        if not batch:
+            return []
+        try:
+            with get_db_transaction() as session:
+                for game_data in batch:
+                    self.save_game(session, game_data, steam_id)
+                    # Later games in the batch need to see genres, tags, etc. created by earlier ones
+                    session.flush()
+            return []
+        except Exception as e:
+            if len(batch) == 1:
+                return [(batch[0], e)]
+            logger.warning(f"Saving a batch of {len(batch)} games failed ({e}); saving them one at a time")
+
+        failures = []
+        for game_data in batch:
+            try:
+                self.save_to_database(game_data, steam_id)
+            except Exception as e:
+                failures.append((game_data, e))
+        return failures
+
+    def retry_failed_games(self, steam_id: str, games: dict[int, tuple[dict, int]], total_games: int) -> set[int]:
+        """Re-fetch and save games that failed earlier in the sync, backing off exponentially between rounds
+
+        games maps app ID to (owned game, library index); recovered games are removed
+        from it and their app IDs returned. Games still failing after retry_attempts
+        rounds are left for the next sync.
+        """
+        recovered = set()
+        # Fallback data marks a game as freshly fetched, so retries must bypass the details cache
+        self._retry_next.update(games)
+        for attempt in range(1, self.retry_attempts + 1):
+            if not games:
+                break
+            delay = self.retry_backoff * 2 ** (attempt - 1)
+            logger.info(f"Retrying {len(games)} failed games in {delay:g}s (attempt {attempt}/{self.retry_attempts})")
+            self.throttle.sleep(delay)
+
+            for app_id, (game, index) in list(games.items()):
+                try:
+                    game_data = self.process_game(game, index, total_games)
+                except Exception as e:
+                    logger.warning(f"Retry {attempt} of {game.get('name', 'Unknown')} failed: {e}")
+                    continue
+                failures = self.save_batch([game_data], steam_id)
+                if failures:
+                    logger.warning(f"Retry {attempt} of {game.get('name', 'Unknown')} failed to save: {failures[0][1]}")
+                    continue
+                del games[app_id]
+                recovered.add(app_id)
+                self.events.publish("game_processed", steam_id=steam_id, app_id=app_id, name=game.get("name"), status="saved", index=index, total_games=total_games, retry_attempt=attempt)
+
+        if games:
+            logger.warning(f"{len(games)} games still failed after {self.retry_attempts} retries; the next sync will try them again")
+        return recovered
+
     def save_to_database(self, game_data: dict, steam_id: str):
         """Save game data to SQLite database using SQLAlchemy"""
         with get_db_transaction() as session:
-            app_id = game_data["appid"]
-            skip_details = game_data.get("skip_details", False)
-
-            # Create or update game
-            game = session.query(Game).filter_by(app_id=app_id).first()
-            if not game:
-                game = Game(app_id=app_id, name=game_data["name"], required_age=game_data.get("required_age", 0), short_description=game_data.get("short_description", ""), detailed_description=game_data.get("detailed_description", ""), about_the_game=game_data.get("about_the_game", ""), recommendations_total=game_data.get("recommendations_total", 0), metacritic_score=game_data.get("metacritic_score", 0), metacritic_url=game_data.get("metacritic_url", ""), header_image=game_data.get("header_image", ""), platforms_windows=game_data.get("platforms_windows", False), platforms_mac=game_data.get("platforms_mac", False), platforms_linux=game_data.get("platforms_linux", False), controller_support=game_data.get("controller_support", ""), vr_support=game_data.get("vr_support", False), esrb_rating=game_data.get("esrb_rating", ""), esrb_descriptors=game_data.get("esrb_descriptors", ""), pegi_rating=game_data.get("pegi_rating", ""), pegi_descriptors=game_data.get("pegi_descriptors", ""), release_date=game_data.get("release_date", ""), last_updated=int(datetime.now().timestamp()) if not skip_details else None)
-                session.add(game)
-                session.flush()
-            elif not skip_details:
-                # Update existing game data only if we have fresh details
-                game.name = game_data["name"]
-                game.required_age = game_data.get("required_age", 0)
-                game.short_description = game_data.get("short_description", "")
-                game.detailed_description = game_data.get("detailed_description", "")
-                game.about_the_game = game_data.get("about_the_game", "")
-                game.recommendations_total = game_data.get("recommendations_total", 0)
-                game.metacritic_score = game_data.get("metacritic_score", 0)
-                game.metacritic_url = game_data.get("metacritic_url", "")
-                game.header_image = game_data.get("header_image", "")
-                game.platforms_windows = game_data.get("platforms_windows", False)
-                game.platforms_mac = game_data.get("platforms_mac", False)
-                game.platforms_linux = game_data.get("platforms_linux", False)
-                game.controller_support = game_data.get("controller_support", "")
-                game.vr_support = game_data.get("vr_support", False)
-                game.esrb_rating = game_data.get("esrb_rating", "")
-                game.esrb_descriptors = game_data.get("esrb_descriptors", "")
-                game.pegi_rating = game_data.get("pegi_rating", "")
-                game.pegi_descriptors = game_data.get("pegi_descriptors", "")
-                game.release_date = game_data.get("release_date", "")
-                game.last_updated = int(datetime.now().timestamp())
-
-            # Skip detailed updates if we're using skip_details
-            if not skip_details:
-                # Handle genres
-                if game_data.get("genres"):
-                    # Clear existing genres for this game
-                    game.genres.clear()
-                    for genre_name in game_data["genres"].split(", "):
-                        if genre_name.strip():
-                            genre = get_or_create(session, Genre, genre_name=genre_name.strip())
-                            game.genres.append(genre)
-
-                # Handle developers
-                if game_data.get("developers"):
-                    game.developers.clear()
-                    for dev_name in game_data["developers"].split(", "):
-                        if dev_name.strip():
-                            developer = get_or_create(session, Developer, developer_name=dev_name.strip())
-                            game.developers.append(developer)
-
-                # Handle publishers
-                if game_data.get("publishers"):
-                    game.publishers.clear()
-                    for pub_name in game_data["publishers"].split(", "):
-                        if pub_name.strip():
-                            publisher = get_or_create(session, Publisher, publisher_name=pub_name.strip())
-                            game.publishers.append(publisher)
-
-                # Handle categories
-                if game_data.get("categories"):
-                    game.categories.clear()
-                    for cat_name in game_data["categories"].split(", "):
-                        if cat_name.strip():
-                            category = get_or_create(session, Category, category_name=cat_name.strip())
-                            game.categories.append(category)
-
-                # Handle tags
-                if game_data.get("tags"):
-                    game.tags.clear()
-                    for tag_name in game_data["tags"].split(", "):
-                        if tag_name.strip():
-                            tag = get_or_create(session, Tag, tag_name=tag_name.strip())
-                            game.tags.append(tag)
-
-                # Handle reviews
-                if game_data.get("review_summary", "Unknown") != "Unknown" or game_data.get("total_reviews", 0) > 0:
-                    review = session.query(GameReview).filter_by(app_id=app_id).first()
-                    if not review:
-                        review = GameReview(app_id=app_id, review_summary=game_data.get("review_summary", "Unknown"), review_score=game_data.get("review_score", 0), total_reviews=game_data.get("total_reviews", 0), positive_reviews=game_data.get("positive_reviews", 0), negative_reviews=game_data.get("negative_reviews", 0), last_updated=int(datetime.now().timestamp()))
-                        session.add(review)
-                    else:
-                        # Update existing review
-                        review.review_summary = game_data.get("review_summary", "Unknown")
-                        review.review_score = game_data.get("review_score", 0)
-                        review.total_reviews = game_data.get("total_reviews", 0)
-                        review.positive_reviews = game_data.get("positive_reviews", 0)
-                        review.negative_reviews = game_data.get("negative_reviews", 0)
-                        review.last_updated = int(datetime.now().timestamp())
-
-            # Handle user game data (always update this regardless of skip_details)
-            user_game = session.query(UserGame).filter_by(steam_id=steam_id, app_id=app_id).first()
-
-            if not user_game:
-                user_game = UserGame(steam_id=steam_id, app_id=app_id, playtime_forever=game_data["playtime_forever"], playtime_2weeks=game_data["playtime_2weeks"])
-                session.add(user_game)
+            self.save_game(session, game_data, steam_id)
+
+    def save_game(self, session, game_data: dict, steam_id: str):
+        """Write one game and the user's ownership of it in an open session"""
+        app_id = game_data["appid"]
+        skip_details = game_data.get("skip_details", False)
+
+        # Create or update game
+        game = session.query(Game).filter_by(app_id=app_id).first()
+        if not game:
+            game = Game(app_id=app_id, name=game_data["name"], required_age=game_data.get("required_age", 0), short_description=game_data.get("short_description", ""), detailed_description=game_data.get("detailed_description", ""), about_the_game=game_data.get("about_the_game", ""), recommendations_total=game_data.get("recommendations_total", 0), metacritic_score=game_data.get("metacritic_score", 0), metacritic_url=game_data.get("metacritic_url", ""), header_image=game_data.get("header_image", ""), platforms_windows=game_data.get("platforms_windows", False), platforms_mac=game_data.get("platforms_mac", False), platforms_linux=game_data.get("platforms_linux", False), controller_support=game_data.get("controller_support", ""), vr_support=game_data.get("vr_support", False), esrb_rating=game_data.get("esrb_rating", ""), esrb_descriptors=game_data.get("esrb_descriptors", ""), pegi_rating=game_data.get("pegi_rating", ""), pegi_descriptors=game_data.get("pegi_descriptors", ""), release_date=game_data.get("release_date", ""), last_updated=int(datetime.now().timestamp()) if not skip_details else None)
+            session.add(game)
+            session.flush()
+        elif not skip_details:
+            # Update existing game data only if we have fresh details
+            game.name = game_data["name"]
+            game.required_age = game_data.get("required_age", 0)
+            game.short_description = game_data.get("short_description", "")
+            game.detailed_description = game_data.get("detailed_description", "")
+            game.about_the_game = game_data.get("about_the_game", "")
+            game.recommendations_total = game_data.get("recommendations_total", 0)
+            game.metacritic_score = game_data.get("metacritic_score", 0)
+            game.metacritic_url = game_data.get("metacritic_url", "")
+            game.header_image = game_data.get("header_image", "")
+            game.platforms_windows = game_data.get("platforms_windows", False)
+            game.platforms_mac = game_data.get("platforms_mac", False)
+            game.platforms_linux = game_data.get("platforms_linux", False)
+            game.controller_support = game_data.get("controller_support", "")
+            game.vr_support = game_data.get("vr_support", False)
+            game.esrb_rating = game_data.get("esrb_rating", "")
+            game.esrb_descriptors = game_data.get("esrb_descriptors", "")
+            game.pegi_rating = game_data.get("pegi_rating", "")
+            game.pegi_descriptors = game_data.get("pegi_descriptors", "")
+            game.release_date = game_data.get("release_date", "")
+            game.last_updated = int(datetime.now().timestamp())
+
+        if "dlc_app_ids" in game_data:
+            game.dlc_app_ids = json.dumps(game_data["dlc_app_ids"])
+
+        if "pc_requirements" in game_data:
+            game.pc_requirements_minimum = game_data["pc_requirements"]["minimum"] or None
+            game.pc_requirements_recommended = game_data["pc_requirements"]["recommended"] or None
+
+        if game_data.get("steamspy_owners"):
+            game.steamspy_owners = game_data["steamspy_owners"]
+            game.steamspy_average_playtime = game_data.get("steamspy_average_playtime", 0)
+            game.steamspy_median_playtime = game_data.get("steamspy_median_playtime", 0)
+
+        # Only overwrite the Deck rating when the lookup succeeded
+        if game_data.get("steam_deck_compat"):
+            game.steam_deck_compat = game_data["steam_deck_compat"]
+
+        # Skip detailed updates if we're using skip_details
+        if not skip_details:
+            # Handle genres
+            if game_data.get("genres"):
+                # Clear existing genres for this game
+                game.genres.clear()
+                for genre_name in game_data["genres"].split(", "):
+                    if genre_name.strip():
+                        genre = get_or_create(session, Genre, genre_name=genre_name.strip())
+                        game.genres.append(genre)
+
+            # Handle developers
+            if game_data.get("developers"):
+                game.developers.clear()
+                for dev_name in game_data["developers"].split(", "):
+                    if dev_name.strip():
+                        developer = get_or_create(session, Developer, developer_name=dev_name.strip())
+                        game.developers.append(developer)
+
+            # Handle publishers
+            if game_data.get("publishers"):
+                game.publishers.clear()
+                for pub_name in game_data["publishers"].split(", "):
+                    if pub_name.strip():
+                        publisher = get_or_create(session, Publisher, publisher_name=pub_name.strip())
+                        game.publishers.append(publisher)
+
+            # Handle categories
+            if game_data.get("categories"):
+                game.categories.clear()
+                for cat_name in game_data["categories"].split(", "):
+                    if cat_name.strip():
+                        category = get_or_create(session, Category, category_name=cat_name.strip())
+                        game.categories.append(category)
+
+            # Handle tags
+            if game_data.get("tags"):
+                game.tags.clear()
+                for tag_name in game_data["tags"].split(", "):
+                    if tag_name.strip():
+                        tag = get_or_create(session, Tag, tag_name=tag_name.strip())
+                        game.tags.append(tag)
+
+            # Handle DLC
+            if game_data.get("dlc"):
+                for dlc_data in game_data["dlc"]:
+                    dlc = session.get(DLC, dlc_data["app_id"])
+                    if not dlc:
+                        dlc = DLC(app_id=dlc_data["app_id"], parent_app_id=app_id)
+                        session.add(dlc)
+                    dlc.parent_app_id = app_id
+                    dlc.name = dlc_data["name"]
+                    dlc.short_description = dlc_data["short_description"]
+                    dlc.header_image = dlc_data["header_image"]
+                    dlc.release_date = dlc_data["release_date"]
+                    dlc.is_free = dlc_data["is_free"]
+                    dlc.price_final = dlc_data["price_final"]
+                    dlc.currency = dlc_data["currency"]
+                    dlc.last_updated = int(datetime.now().timestamp())
+
+            # Handle achievement definitions
+            if game_data.get("achievements"):
+                session.query(Achievement).filter_by(app_id=app_id).delete()
+                for ach in game_data["achievements"]:
+                    if ach.get("name"):
+                        session.add(Achievement(app_id=app_id, api_name=ach["name"], display_name=ach.get("displayName", ach["name"]), description=ach.get("description", ""), icon=ach.get("icon", ""), icon_gray=ach.get("icongray", ""), hidden=bool(ach.get("hidden", 0)), global_percent=ach.get("global_percent")))
+
+            # Keep the store text in the language it was fetched in
+            if game_data.get("short_description") or game_data.get("about_the_game"):
+                localization = session.get(GameLocalization, (app_id, self.language))
+                if not localization:
+                    localization = GameLocalization(app_id=app_id, language=self.language)
+                    session.add(localization)
+                localization.name = game_data["name"]
+                localization.short_description = game_data.get("short_description", "")
+                localization.detailed_description = game_data.get("detailed_description", "")
+                localization.about_the_game = game_data.get("about_the_game", "")
+                localization.last_updated = int(datetime.now().timestamp())
+
+        # Handle reviews (also refreshed on their own by --scope reviews)
+        if game_data.get("review_summary", "Unknown") != "Unknown" or game_data.get("total_reviews", 0) > 0:
+            review = session.query(GameReview).filter_by(app_id=app_id).first()
+            if not review:
+                review = GameReview(app_id=app_id, review_summary=game_data.get("review_summary", "Unknown"), review_score=game_data.get("review_score", 0), total_reviews=game_data.get("total_reviews", 0), positive_reviews=game_data.get("positive_reviews", 0), negative_reviews=game_data.get("negative_reviews", 0), last_updated=int(datetime.now().timestamp()))
+                session.add(review)
             else:
-                # Update playtime data
-                user_game.playtime_forever = max(user_game.playtime_forever, game_data["playtime_forever"])
-                user_game.playtime_2weeks = game_data["playtime_2weeks"]
+                # Update existing review
+                review.review_summary = game_data.get("review_summary", "Unknown")
+                review.review_score = game_data.get("review_score", 0)
+                review.total_reviews = game_data.get("total_reviews", 0)
+                review.positive_reviews = game_data.get("positive_reviews", 0)
+                review.negative_reviews = game_data.get("negative_reviews", 0)
+                review.last_updated = int(datetime.now().timestamp())
+
+        # Handle individual review texts, replacing any previously stored set
+        if game_data.get("review_texts"):
+            session.query(ReviewText).filter_by(app_id=app_id).delete()
+            for review in game_data["review_texts"]:
+                author = review.get("author", {})
+                session.add(ReviewText(recommendation_id=str(review.get("recommendationid")), app_id=app_id, author_steam_id=author.get("steamid"), language=review.get("language", ""), review=review.get("review", ""), voted_up=review.get("voted_up", False), votes_up=review.get("votes_up", 0), votes_funny=review.get("votes_funny", 0), weighted_vote_score=float(review.get("weighted_vote_score") or 0), playtime_at_review=author.get("playtime_at_review"), timestamp_created=review.get("timestamp_created")))
+
+        # Handle user game data (always update this regardless of skip_details)
+        user_game = session.query(UserGame).filter_by(steam_id=steam_id, app_id=app_id).first()
+
+        if not user_game:
+            user_game = UserGame(steam_id=steam_id, app_id=app_id, playtime_forever=game_data["playtime_forever"], playtime_2weeks=game_data["playtime_2weeks"])
+            session.add(user_game)
+        else:
+            # Update playtime data
+            user_game.playtime_forever = max(user_game.playtime_forever, game_data["playtime_forever"])
+            user_game.playtime_2weeks = game_data["playtime_2weeks"]
+
+        # Extended GetOwnedGames fields (absent for friends' limited-visibility libraries)
+        if game_data.get("rtime_last_played"):
+            user_game.last_played = game_data["rtime_last_played"]
+        for field in ("playtime_windows_forever", "playtime_mac_forever", "playtime_linux_forever", "playtime_deck_forever"):
+            if game_data.get(field) is not None:
+                setattr(user_game, field, game_data[field])
+
+        # Family Sharing - owned games always clear the flag
+        user_game.shared = game_data.get("shared", False)
+        user_game.shared_owner_steam_id = game_data.get("shared_owner_steam_id")
+
+    def sync_game(self, steam_id: str, appid: int) -> bool:
+        """Refresh store details, reviews, price, and playtime for one owned game, ignoring cache age
+
+        A narrower scope limits the refresh the same way it does for a library sync.
+        """
+        create_database()
+        self.apply_library_region(steam_id)
+
+        game = self.get_owned_game(steam_id, appid)
+        if not game:
+            logger.error(f"App {appid} is not in the library of {steam_id}")
+            return False
+
+        force_refresh = self.force_refresh
+        self.force_refresh = True
+        try:
+            self.save_to_database(self.process_game(game, 1, 1), steam_id)
+        finally:
+            self.force_refresh = force_refresh
+        if self.scope in ("full", "metadata"):
+            self.update_game_prices([appid])
+
+        logger.info(f"Synced {game.get('name', appid)} (AppID: {appid})")
+        return True
+
+    def preview_library_sync(self, steam_id: str) -> dict:
+        """Describe what a library sync would change without writing anything
+
+        Compares GetOwnedGames with the stored user_games rows and reports games that
+        would be created, updated (with field-level old/new values), or removed, plus
+        how many games' store details are old enough to be re-fetched.
+        """
+        owned = {game.get("appid"): game for game in self.iter_owned_games(steam_id)}
+        stale_before = int(datetime.now().timestamp()) - int(self.cache_days * DAY)
+
+        with get_db() as session:
+            stored = {ug.app_id: ug for ug in session.query(UserGame).filter_by(steam_id=steam_id)}
+            game_updated = dict(session.query(Game.app_id, Game.last_updated).filter(Game.app_id.in_(owned.keys())).all()) if owned else {}
+
+            created, updated, removed = [], [], []
+            for app_id, game in owned.items():
+                user_game = stored.get(app_id)
+                if not user_game:
+                    created.append({"app_id": app_id, "name": game.get("name"), "playtime_forever": game.get("playtime_forever", 0)})
+                    continue
+                changes = {column: {"old": getattr(user_game, column), "new": game.get(field)} for field, column in OWNED_GAME_FIELDS.items() if game.get(field) is not None and game.get(field) != getattr(user_game, column)}
+                if changes:
+                    updated.append({"app_id": app_id, "name": game.get("name"), "changes": changes})
+
+            # Shared games aren't in GetOwnedGames, so they're never reported as removed; neither are games already flagged
+            for app_id, user_game in stored.items():
+                if app_id not in owned and not user_game.shared and user_game.removed_at is None:
+                    removed.append({"app_id": app_id, "name": user_game.game.name if user_game.game else lookup_app_name(session, app_id)})
+
+        to_refresh = len(owned) if self.force_refresh else sum(1 for app_id in owned if (game_updated.get(app_id) or 0) < stale_before)
+        return {"steam_id": steam_id, "dry_run": True, "summary": {"owned": len(owned), "created": len(created), "updated": len(updated), "removed": len(removed), "unchanged": len(owned) - len(created) - len(updated), "details_to_refresh": to_refresh if self.scope in ("full", "metadata") and not self.skip_games else 0}, "created": created, "updated": updated, "removed": removed}
+
+    def start_checkpoint(self, steam_id: str) -> set[int]:
+        """Begin tracking sync progress, returning app IDs to skip when resuming an interrupted sync"""
+        now = int(datetime.now().timestamp())
+        with get_db_transaction() as session:
+            checkpoint = session.get(SyncCheckpoint, steam_id)
+            self._retry_next = set(json.loads(checkpoint.retry_app_ids or "[]")) if checkpoint else set()
+            if self._retry_next:
+                logger.info(f"Retrying {len(self._retry_next)} games the previous sync couldn't fetch")
+            if self.resume and checkpoint and checkpoint.resumable:
+                processed = set(json.loads(checkpoint.processed_app_ids or "[]"))
+                logger.info(f"Resuming sync from checkpoint: {len(processed)} games already processed")
+                # Requests made before the interruption count towards this sync's total
+                self.metrics.sync_requests += checkpoint.api_requests or 0
+                checkpoint.status = "running"
+                checkpoint.updated_at = now
+                return processed
+
+            if self.resume:
+                logger.info("No interrupted sync to resume, starting a full sync")
+            if not checkpoint:
+                checkpoint = SyncCheckpoint(steam_id=steam_id)
+                session.add(checkpoint)
+            checkpoint.status = "running"
+            checkpoint.options = json.dumps(self.sync_options)
+            checkpoint.processed_app_ids = "[]"
+            checkpoint.failed_app_ids = "[]"
+            checkpoint.retry_app_ids = json.dumps(sorted(self._retry_next))
+            checkpoint.cursor = 0
+            checkpoint.total_games = 0
+            checkpoint.api_requests = 0
+            checkpoint.started_at = now
+            checkpoint.updated_at = now
+        return set()
+
+    def save_checkpoint(self, steam_id: str, status: str, processed: set[int], cursor: int, total_games: int, failed: set[int] | None = None, retry: set[int] | None = None):
+        """Persist sync progress, including games that could not be saved and games to retry next sync"""
+        try:
+            with get_db_transaction() as session:
+                checkpoint = session.get(SyncCheckpoint, steam_id)
+                if not checkpoint:
+                    return
+                # Progress saved while paused (games fetched before the pause) keeps the paused status
+                checkpoint.status = "paused" if status == "running" and self.paused else status
+                checkpoint.processed_app_ids = json.dumps(sorted(processed))
+                checkpoint.failed_app_ids = json.dumps(sorted(failed or ()))
+                if retry is not None:
+                    checkpoint.retry_app_ids = json.dumps(sorted(retry))
+                checkpoint.cursor = cursor
+                checkpoint.total_games = total_games
+                checkpoint.api_requests = self.metrics.sync_requests
+                checkpoint.updated_at = int(datetime.now().timestamp())
+        except Exception as e:
+            logger.error(f"Failed to save sync checkpoint: {e}")
 
     def fetch_library_data(self, steam_id: str):
         """Main method to fetch all library data and save to database"""
+        started = time.monotonic()
+        self.metrics.observe_sync_started(self.scope)
+        outcome = "failed"
+        try:
+            self._fetch_library_data(steam_id)
+            outcome = "completed"
+        finally:
+            if outcome != "completed" and self.cancel_event.is_set():
+                outcome = "cancelled"
+            self.metrics.observe_sync_finished(self.scope, outcome, time.monotonic() - started)
+
+    def _fetch_library_data(self, steam_id: str):
         # Create database tables if they don't exist
         create_database()
 
+        # Drop expired responses so the persistent cache doesn't grow without bound
+        if isinstance(self.cache, DatabaseCache):
+            removed = self.cache.prune()
+            if removed:
+                logger.info(f"Pruned {removed} expired cache entries")
+
+        # Refresh the appid -> name index before processing games
+        if self.refresh_app_catalog:
+            self.update_app_catalog()
+
         # Create or update user profile (with XP/badges data for main user)
         player_profiles = self.get_player_summaries(steam_id)
         player_data = player_profiles[0] if player_profiles else None
         self.save_user_profile(player_data, steam_id, include_badges=True)
-
-        # Get owned games
-        owned_games = self.get_owned_games(steam_id)
-        if not owned_games:
-            logger.error("No games found in library")
-            return
-
-        total_games = len(owned_games)
+        self.update_player_bans([steam_id])
+        self.apply_library_region(steam_id)
+        self.apply_library_throttling(steam_id)
+        self._enriched = 0
+
+        # Stream owned games so huge libraries are processed without holding the whole response;
+        # only the app IDs needed by the later passes are kept
+        owned_app_ids = []
+        recent_app_ids = []
         failed_count = 0
         processed_count = 0
+        processed = self.start_checkpoint(steam_id)
+        # Games that couldn't be saved even with fallback data; --resume retries them
+        failed: set[int] = set()
+        # Games that failed or got fallback data this sync, retried once the library is done: app ID -> (game, index)
+        retry_queue: dict[int, tuple[dict, int]] = {}
+        # Games still needing a retry after this sync, starting with those left by the previous one
+        retry = set(self._retry_next)
+        cursor = 0
+        total_games = 0
+        last_checkpoint = len(processed)
+        # Results waiting to be written in one transaction: (game_data, game, index, total_games, processing error)
+        batch: list[tuple[dict, dict, int, int, Exception | None]] = []
 
-        logger.info(f"Starting to process {total_games} games...")
         logger.info("This may take a while due to rate limiting...")
         logger.info("Note: Some games may not have store data available (403 errors are normal)")
+        self.events.publish("sync_started", steam_id=steam_id, resumed_games=len(processed))
 
-        for index, game in enumerate(owned_games, 1):
+        def save_result(future: Future, game: dict, index: int, total_games: int):
+            nonlocal failed_count
             try:
-                game_data = self.process_game(game, index, total_games)
-                # Save to database immediately
-                self.save_to_database(game_data, steam_id)
-                processed_count += 1
+                batch.append((future.result(), game, index, total_games, None))
+            except Exception as e:
+                failed_count += 1
+                logger.error(f"Error processing game {game.get('name', 'Unknown')}: {e}")
+                # Still save basic info even if detailed processing fails
+                batch.append((fallback_data(game), game, index, total_games, e))
+            if len(batch) >= self.batch_size:
+                flush_batch()
+
+        def fallback_data(game: dict) -> dict:
+            return {"appid": game.get("appid"), "name": game.get("name", "Unknown"), **owned_game_playtime(game), "required_age": 0, "short_description": "", "detailed_description": "", "about_the_game": "", "recommendations_total": 0, "metacritic_score": 0, "metacritic_url": "", "header_image": "", "platforms_windows": False, "platforms_mac": False, "platforms_linux": False, "controller_support": "", "vr_support": False, "esrb_rating": "", "esrb_descriptors": "", "pegi_rating": "", "pegi_descriptors": "", "genres": "", "categories": "", "developers": "", "publishers": "", "release_date": "", "review_summary": "Unknown", "review_score": 0, "total_reviews": 0, "positive_reviews": 0, "negative_reviews": 0}
+
+        def flush_batch():
+            nonlocal failed_count, processed_count, cursor, last_checkpoint
+            if not batch:
+                return
+            # Saves stay on this thread so database writes are never concurrent
+            failures = {id(game_data): error for game_data, error in self.save_batch([item[0] for item in batch], steam_id)}
+            for game_data, game, index, total_games, error in batch:
+                status = "fallback" if error else "saved"
+                if id(game_data) in failures and not error:
+                    failed_count += 1
+                    error = failures[id(game_data)]
+                    logger.error(f"Error saving game {game.get('name', 'Unknown')}: {error}")
+                    try:
+                        self.save_to_database(fallback_data(game), steam_id)
+                        status = "fallback"
+                    except Exception as db_error:
+                        error, status = db_error, "failed"
+                elif id(game_data) in failures:
+                    error, status = failures[id(game_data)], "failed"
+
+                if status == "failed":
+                    logger.error(f"Failed to save fallback data for {game.get('name', 'Unknown')}: {error}")
+                    failed.add(game.get("appid"))
+                else:
+                    processed_count += 1
+                    processed.add(game.get("appid"))
+                    failed.discard(game.get("appid"))
+                if status == "saved":
+                    retry.discard(game.get("appid"))
+                else:
+                    retry_queue[game.get("appid")] = (game, index)
+                    retry.add(game.get("appid"))
+                cursor = index
+                self.metrics.observe_game(status)
+                self.events.publish("game_processed", steam_id=steam_id, app_id=game.get("appid"), name=game.get("name"), status=status, index=index, total_games=total_games, **({"error": str(error)} if error else {}))
 
                 # Show progress every 10 games
                 if index % 10 == 0:
-                    estimated_time = (total_games - index) * self.rate_limit_delay
+                    estimated_time = (total_games - index) * self.throttle.delay
                     logger.info(f"Progress: {index}/{total_games} games processed. Estimated time remaining: {estimated_time:.0f} seconds")
+            batch.clear()
 
-            except Exception as e:
-                failed_count += 1
-                logger.error(f"Error processing game {game.get('name', 'Unknown')}: {e}")
-                # Still save basic info even if detailed processing fails
-                fallback_data = {"appid": game.get("appid"), "name": game.get("name", "Unknown"), "playtime_forever": game.get("playtime_forever", 0), "playtime_2weeks": game.get("playtime_2weeks", 0), "required_age": 0, "short_description": "", "detailed_description": "", "about_the_game": "", "recommendations_total": 0, "metacritic_score": 0, "metacritic_url": "", "header_image": "", "platforms_windows": False, "platforms_mac": False, "platforms_linux": False, "controller_support": "", "vr_support": False, "esrb_rating": "", "esrb_descriptors": "", "pegi_rating": "", "pegi_descriptors": "", "genres": "", "categories": "", "developers": "", "publishers": "", "release_date": "", "review_summary": "Unknown", "review_score": 0, "total_reviews": 0, "positive_reviews": 0, "negative_reviews": 0}
+            if len(processed) - last_checkpoint >= CHECKPOINT_INTERVAL:
+                last_checkpoint = len(processed)
+                self.save_checkpoint(steam_id, "running", processed, cursor, total_games, failed, retry)
+
+        # Workers fetch game details in parallel; every request still passes through the shared throttle,
+        # so extra workers overlap request latency rather than exceeding the rate limit
+        try:
+            pool = ThreadPoolExecutor(max_workers=self.workers, thread_name_prefix="enrich")
+            try:
+                pending = deque()
+                for index, game in enumerate(self.iter_owned_games(steam_id), 1):
+                    owned_app_ids.append(game.get("appid"))
+                    if game.get("playtime_2weeks", 0) > 0:
+                        recent_app_ids.append(game.get("appid"))
+                    total_games = max(self.owned_game_count, index)
+                    # Already saved before the interrupted sync stopped
+                    if game.get("appid") in processed:
+                        continue
+                    if self.game_delay and index > 1:
+                        self.throttle.sleep(self.game_delay)
+                    pending.append((pool.submit(self.process_game, game, index, total_games), game, index, total_games))
+
+                    # Save in library order, and keep only a few games queued so streaming stays memory-bounded
+                    while pending and (len(pending) > self.workers * 2 or pending[0][0].done()):
+                        save_result(*pending.popleft())
+
+                while pending:
+                    save_result(*pending.popleft())
+                flush_batch()
+
+                if retry_queue and self.retry_attempts:
+                    self.events.publish("phase_started", steam_id=steam_id, phase="retries")
+                    recovered = self.retry_failed_games(steam_id, retry_queue, total_games)
+                    processed_count += len(recovered & failed)
+                    failed_count -= len(recovered)
+                    processed |= recovered
+                    failed -= recovered
+                    retry -= recovered
+            finally:
+                # A cancelled sync doesn't wait for workers; their aborted requests end them shortly
+                pool.shutdown(wait=not self.cancel_event.is_set(), cancel_futures=True)
+        except BaseException:
+            # Crashes keep their progress for --resume, including games fetched but not yet written;
+            # a cancellation stops writing at once and leaves those games for the resumed sync
+            if not self.cancel_event.is_set():
                 try:
-                    self.save_to_database(fallback_data, steam_id)
-                    processed_count += 1
-                except Exception as db_error:
-                    logger.error(f"Failed to save fallback data for {game.get('name', 'Unknown')}: {db_error}")
+                    flush_batch()
+                except Exception as e:
+                    logger.error(f"Failed to save the last batch of games: {e}")
+            self.save_checkpoint(steam_id, "interrupted", processed, cursor, total_games, failed, retry)
+            self.events.publish("sync_interrupted", steam_id=steam_id, processed_games=len(processed), total_games=total_games)
+            raise
+        # Games that left the library don't need retrying
+        retry &= set(owned_app_ids)
+        self.save_checkpoint(steam_id, "running", processed, cursor, total_games, failed, retry)
+
+        if not owned_app_ids:
+            logger.error("No games found in library")
+            self.save_checkpoint(steam_id, "completed", processed, cursor, total_games, failed, retry)
+            self.events.publish("sync_completed", steam_id=steam_id, processed_games=0, failed_games=0, total_games=0)
+            return
 
         if failed_count > 0:
             logger.warning(f"Note: {failed_count} games had limited data due to API restrictions")
 
+        # Games borrowed through Family Sharing are stored with the shared flag set
+        shared_app_ids = None
+        if self.access_token:
+            self.events.publish("phase_started", steam_id=steam_id, phase="shared_library")
+            # An empty listing can't be told apart from a failed request, so it never revokes shared games
+            shared_app_ids = self.process_shared_library(steam_id, set(owned_app_ids)) or None
+
+        # A listing cut short (e.g. a dropped connection) would make most of the library look removed
+        if len(owned_app_ids) >= self.owned_game_count:
+            self.events.publish("phase_started", steam_id=steam_id, phase="removed_games")
+            self.reconcile_removed_games(steam_id, set(owned_app_ids), shared_app_ids)
+        else:
+            logger.warning(f"Only {len(owned_app_ids)} of {self.owned_game_count} owned games were listed; skipping removed game detection")
+
+        # DLC ownership needs the store login cookie, and the DLC lists come with store metadata
+        if self.access_token and self.scope in ("full", "metadata"):
+            self.events.publish("phase_started", steam_id=steam_id, phase="dlc")
+            self.sync_owned_dlc(steam_id)
+
+        # Prices change independently of cached metadata, so refresh them for every game
+        if not self.skip_games and self.scope in ("full", "metadata"):
+            self.events.publish("phase_started", steam_id=steam_id, phase="prices")
+            self.update_game_prices(owned_app_ids)
+
+        # Achievement progress costs a request per played game, so it's opt-in per library
+        if self.scope == "full" and self.achievements_enabled(steam_id):
+            self.events.publish("phase_started", steam_id=steam_id, phase="achievements")
+            self.sync_user_achievements(steam_id)
+
         logger.info(f"Completed! Processed {processed_count} games successfully. Data saved to database")
 
+        # Fetch news for recently played games if requested
+        if self.fetch_news:
+            logger.info(f"Fetching news for {len(recent_app_ids)} recently played games...")
+            self.events.publish("phase_started", steam_id=steam_id, phase="news")
+            for app_id in recent_app_ids:
+                news_items = self.get_news_for_app(app_id)
+                if news_items:
+                    self.save_game_news(app_id, news_items)
+
+        # Fetch popular workshop items if requested
+        if self.fetch_workshop:
+            self.events.publish("phase_started", steam_id=steam_id, phase="workshop")
+            self.process_workshop_data(steam_id)
+
         # Process friends if requested
         if self.fetch_friends:
+            self.events.publish("phase_started", steam_id=steam_id, phase="friends")
             self.process_friends_data(steam_id)
+        elif self.fetch_friend_games:
+            self.events.publish("phase_started", steam_id=steam_id, phase="friends_games")
+            self.process_friend_games(steam_id)
+
+        # The wishlist listing carries current prices, so every scope keeps them fresh
+        if self.fetch_wishlist:
+            self.events.publish("phase_started", steam_id=steam_id, phase="wishlist")
+            self.sync_wishlist(steam_id)
+
+        self.save_checkpoint(steam_id, "completed", processed, cursor, total_games, failed, retry)
+        self.events.publish("sync_completed", steam_id=steam_id, processed_games=processed_count, failed_games=failed_count, total_games=total_games)
+
+    def sync_wishlist(self, steam_id: str):
+        """Replace the stored wishlist and alert on items whose discount reached sale_threshold
+
+        An item alerts once per sale: sale_alerted_at stays set until its discount drops
+        below the threshold again. Alerts are logged and published as wishlist_sale events.
+        """
+        items = self.get_wishlist(steam_id)
+        if not items:
+            # A private wishlist looks the same as an empty one, so keep what's stored
+            logger.info("Wishlist is empty or private; keeping stored wishlist")
+            return
+
+        now = int(datetime.now().timestamp())
+        alerts = []
+        with get_db_transaction() as session:
+            stored = {item.app_id: item for item in session.query(WishlistItem).filter_by(steam_id=steam_id)}
+            for data in items:
+                app_id = data.get("appid")
+                item = stored.pop(app_id, None)
+                if item is None:
+                    item = WishlistItem(steam_id=steam_id, app_id=app_id)
+                    session.add(item)
+                item.name = data.get("name") or item.name
+                item.priority = data.get("priority", 0)
+                item.date_added = data.get("date_added")
+                item.price_initial = data.get("original_price")
+                item.price_final = data.get("final_price")
+                item.discount_percent = data.get("discount_percent") or 0
+                item.formatted_price = data.get("formatted_final_price", "")
+                item.price_updated_at = now
+                if item.discount_percent < self.sale_threshold:
+                    item.sale_alerted_at = None
+                elif not item.sale_alerted_at:
+                    item.sale_alerted_at = now
+                    alerts.append({"app_id": app_id, "name": item.name, "discount_percent": item.discount_percent, "price_final": item.price_final, "price_initial": item.price_initial, "formatted_price": item.formatted_price})
+            for removed in stored.values():
+                session.delete(removed)
+
+        for alert in alerts:
+            logger.info(f"Wishlist sale: {alert['name']} ({alert['app_id']}) is {alert['discount_percent']}% off at {alert['formatted_price']}")
+            self.events.publish("wishlist_sale", steam_id=steam_id, threshold=self.sale_threshold, **alert)
+        logger.info(f"Saved {len(items)} wishlist items, {len(alerts)} newly on sale")
 
     def process_friends_data(self, user_steam_id: str, batch_size: int = 100):
         """Fetch and process friends list and their games"""
@@ -688,6 +2384,41 @@ class SteamLibraryFetcher:
 
         logger.info("\nFriends data processing completed!")
 
+    def process_friend_games(self, user_steam_id: str, batch_size: int = 100):
+        """Store which games each public friend owns, one GetOwnedGames request per friend
+
+        A lighter alternative to process_friends_data: friends' games get no store
+        lookups or user_games rows, only friends_games entries.
+        """
+        friends = self.get_friend_list(user_steam_id)
+        if not friends:
+            logger.warning("No friends found or profile is private")
+            return
+
+        self._save_friend_relationships(user_steam_id, friends)
+        profiles = self.get_player_summaries_batch([f.get("steamid") for f in friends], batch_size)
+        public = [profile for profile in profiles if profile.get("communityvisibilitystate", 1) == 3]
+        logger.info(f"Fetching owned games for {len(public)} of {len(friends)} friends with public profiles")
+
+        for profile in public:
+            friend_steam_id = profile.get("steamid")
+            self.save_user_profile(profile, friend_steam_id, include_badges=False)
+            friend_games = self.get_owned_games(friend_steam_id)
+            # Game details can be private even when the profile is public
+            if friend_games:
+                self.save_friend_games(friend_steam_id, friend_games)
+
+    def save_friend_games(self, friend_steam_id: str, games: list[dict]):
+        """Replace a friend's stored owned games"""
+        now = int(datetime.now().timestamp())
+        try:
+            with get_db_transaction() as session:
+                session.query(FriendGame).filter_by(steam_id=friend_steam_id).delete()
+                for game in games:
+                    session.add(FriendGame(steam_id=friend_steam_id, app_id=game.get("appid"), name=game.get("name"), playtime_forever=game.get("playtime_forever", 0), updated_at=now))
+        except Exception as e:
+            logger.error(f"Failed to save games for friend {friend_steam_id}: {e}")
+
     def _save_friend_relationships(self, user_steam_id: str, friends: list[dict]):
         """Save friend relationships to database using association table"""
         with get_db_transaction() as session:
@@ -729,6 +2460,7 @@ class SteamLibraryFetcher:
                     friend_games = self.get_owned_games(friend_steam_id)
                     if friend_games:
                         logger.info(f"  Found {len(friend_games)} games for {profile.get('personaname')}")
+                        self.save_friend_games(friend_steam_id, friend_games)
 
                         # Process games using existing logic with caching
                         total_games = len(friend_games)
@@ -743,8 +2475,42 @@ class SteamLibraryFetcher:
                 else:
                     logger.info(f"Skipping friend with private profile: Steam ID {friend_steam_id}")
 
+            # Record account standing for the friends saved in this batch
+            self.update_player_bans(batch)
+
+
+class PauseSignals:
+    """SIGUSR1 pauses the sync before its next API call and SIGUSR2 resumes it (used by the MCP server)
+
+    The server may pause a sync as soon as the process starts, so the handlers go in
+    before anything else; until the fetcher exists they only remember the request.
+    """
+
+    def __init__(self):
+        self.fetcher: SteamLibraryFetcher | None = None
+        self.paused = False
+
+    def install(self):
+        if hasattr(signal, "SIGUSR1"):
+            signal.signal(signal.SIGUSR1, lambda signum, frame: self.set_paused(True))
+            signal.signal(signal.SIGUSR2, lambda signum, frame: self.set_paused(False))
+
+    def set_paused(self, paused: bool):
+        self.paused = paused
+        if self.fetcher:
+            self.fetcher.pause() if paused else self.fetcher.resume()
+
+    def attach(self, fetcher: SteamLibraryFetcher):
+        """Apply pause requests to fetcher, including one that arrived before it was created"""
+        self.fetcher = fetcher
+        if self.paused:
+            fetcher.pause()
+
 
 def main():
+    pause_signals = PauseSignals()
+    pause_signals.install()
+
     # Load environment variables from .env file
     load_dotenv()
 
@@ -754,7 +2520,39 @@ def main():
     parser.add_argument("--cache-days", type=int, default=7, help="Maximum age in days before re-fetching game data (default: 7)")
     parser.add_argument("--force-refresh", action="store_true", help="Force refresh all game data, ignoring cache")
     parser.add_argument("--skip-games", action="store_true", help="Skip fetching game details entirely")
+    parser.add_argument("--scope", choices=SYNC_SCOPES, default="full", help="What to fetch per game: everything (full), only playtime, store metadata without reviews, or only reviews (default: full)")
     parser.add_argument("--friends", action="store_true", help="Also fetch friends list and their game libraries")
+    parser.add_argument("--friends-games", action="store_true", help="Also fetch friends list and which games public friends own, without store details (much cheaper than --friends)")
+    parser.add_argument("--wishlist", action="store_true", help="Store the wishlist with current prices and alert on sales (default: FETCH_WISHLIST env)")
+    parser.add_argument("--sale-threshold", type=int, default=None, metavar="PERCENT", help="Discount that triggers a wishlist sale alert (default: WISHLIST_SALE_THRESHOLD env or 50)")
+    parser.add_argument("--sale-webhook", default=None, metavar="URL", help="POST wishlist sale alerts as JSON to URL (default: WISHLIST_SALE_WEBHOOK env)")
+    parser.add_argument("--news", action="store_true", help="Fetch recent news for games played in the last two weeks")
+    parser.add_argument("--workshop", action="store_true", help="Fetch popular Steam Workshop items for games with workshop support")
+    parser.add_argument("--dlc", action="store_true", help="Fetch details for each game's DLC")
+    vcr = parser.add_mutually_exclusive_group()
+    vcr.add_argument("--record", metavar="DIR", help="Save every Steam API response as a JSON fixture in DIR")
+    vcr.add_argument("--replay", metavar="DIR", help="Serve Steam API responses from fixtures in DIR instead of the network")
+    parser.add_argument("--proxy", default=None, help="HTTP(S) or SOCKS proxy URL for Steam API requests (default: STEAM_PROXY env)")
+    parser.add_argument("--rate-limit", type=float, default=None, metavar="RPS", help="Maximum Steam API requests per second (default: STEAM_RATE_LIMIT env or 1)")
+    parser.add_argument("--workers", type=int, default=None, help="Games to fetch details for concurrently, sharing the rate limit (default: FETCH_WORKERS env or 1)")
+    parser.add_argument("--batch-size", type=int, default=None, metavar="N", help="Games to save per database transaction (default: DB_BATCH_SIZE env or 25)")
+    parser.add_argument("--cache-backend", choices=["memory", "database", "none"], help="Where to cache Store API responses, or 'none' to disable caching (default: CACHE_BACKEND env or memory)")
+    parser.add_argument("--cache-ttl", default=None, metavar="NS=HOURS,...", help="Per-endpoint cache lifetimes, e.g. 'steamspy=24,schema=720' (default: CACHE_TTLS env)")
+    parser.add_argument("--achievements", action="store_true", help="Sync unlocked achievements and completion percentage for played games (per-library override: user_profile.sync_achievements)")
+    parser.add_argument("--steamspy", action="store_true", help="Merge SteamSpy community tags and store owner/playtime estimates")
+    parser.add_argument("--review-texts", type=int, default=0, metavar="N", help="Store up to N of the most helpful individual reviews per game")
+    parser.add_argument("--country", default=None, help="Store country code for prices, e.g. 'gb' or 'de' (default: us)")
+    parser.add_argument("--language", default=None, help="Store language for game metadata, e.g. 'german' (default: english)")
+    parser.add_argument("--game", type=int, default=None, metavar="APPID", help="Only refresh one owned game (details, reviews, price, and playtime)")
+    parser.add_argument("--dry-run", action="store_true", help="Report the changes a library sync would make as JSON, without writing to the database")
+    parser.add_argument("--dry-run-output", default=None, metavar="PATH", help="Write the --dry-run report to PATH, or '-' for stdout (default: log it)")
+    parser.add_argument("--retry-attempts", type=int, default=None, metavar="N", help="Rounds of retries at the end of a sync for games that failed, 0 to disable (default: RETRY_ATTEMPTS env or 2)")
+    parser.add_argument("--retry-backoff", type=float, default=None, metavar="SECONDS", help="Wait before the first retry round, doubled for each later round (default: RETRY_BACKOFF env or 30)")
+    parser.add_argument("--removed-games", choices=REMOVED_GAME_POLICIES, default=None, help="What to do with stored games no longer in the library: flag them with removed_at, archive them to archived_user_games, or delete them (default: REMOVED_GAMES env or flag)")
+    parser.add_argument("--progress-events", default=None, metavar="PATH", help="Append sync progress events as JSON lines to PATH, or '-' for stdout (default: PROGRESS_EVENTS env)")
+    parser.add_argument("--resume", action="store_true", help="Continue an interrupted sync from its checkpoint, skipping games already saved")
+    parser.add_argument("--refresh-profiles", action="store_true", help="Refresh every stored user profile in batched requests")
+    parser.add_argument("--refresh-app-catalog", action="store_true", help="Incrementally refresh the local Steam app name index")
 
     args = parser.parse_args()
 
@@ -767,10 +2565,11 @@ def main():
 
     # Get environment variables (support both .env and env vars)
     steam_id = os.getenv("STEAM_ID")
-    api_key = os.getenv("STEAM_API_KEY")
+    # Replayed fixtures never contain the API key, so any placeholder works
+    api_key = os.getenv("STEAM_API_KEY") or ("replay" if args.replay else None)
 
     # Also check for CACHE_DAYS env var
-    cache_days = int(os.getenv("CACHE_DAYS", args.cache_days))
+    cache_days = env_number("CACHE_DAYS", args.cache_days)
 
     if not steam_id or not api_key:
         logger.error("Missing environment variables!")
@@ -783,14 +2582,111 @@ def main():
     else:
         logger.info(f"Using cache threshold of {cache_days} days")
 
+    rate_limit = args.rate_limit if args.rate_limit is not None else env_number("STEAM_RATE_LIMIT", 1.0, float)
+    if rate_limit <= 0:
+        logger.error(f"Invalid rate limit {rate_limit}: must be greater than 0 requests per second")
+        sys.exit(1)
+    logger.info(f"Limiting Steam API requests to {rate_limit:g} per second")
+
     # Create fetcher and run
-    fetcher = SteamLibraryFetcher(api_key)
+    fetcher = SteamLibraryFetcher(api_key, rate_limit_delay=1 / rate_limit, cache=create_cache(args.cache_backend or os.getenv("CACHE_BACKEND", "memory")))
+    pause_signals.attach(fetcher)
+    if args.record or args.replay:
+        fetcher.enable_vcr("record" if args.record else "replay", args.record or args.replay)
+
     fetcher.cache_days = cache_days
+    fetcher.resume = args.resume
+    fetcher.sync_options = checkpoint_options(sys.argv[1:])
+    fetcher.batch_size = max(1, args.batch_size if args.batch_size is not None else env_number("DB_BATCH_SIZE", 25))
+    fetcher.retry_attempts = max(0, args.retry_attempts if args.retry_attempts is not None else env_number("RETRY_ATTEMPTS", 2))
+    fetcher.retry_backoff = max(0.0, args.retry_backoff if args.retry_backoff is not None else env_number("RETRY_BACKOFF", 30.0, float))
+    fetcher.removed_game_policy = args.removed_games or os.getenv("REMOVED_GAMES", "flag")
+    if fetcher.removed_game_policy not in REMOVED_GAME_POLICIES:
+        logger.error(f"Invalid REMOVED_GAMES policy '{fetcher.removed_game_policy}': expected one of {', '.join(REMOVED_GAME_POLICIES)}")
+        sys.exit(1)
+    fetcher.workers = max(1, args.workers if args.workers is not None else env_number("FETCH_WORKERS", 1))
     fetcher.force_refresh = args.force_refresh
     fetcher.skip_games = args.skip_games
+    fetcher.scope = args.scope
     fetcher.fetch_friends = args.friends
-
-    fetcher.fetch_library_data(steam_id)
+    fetcher.fetch_friend_games = args.friends_games
+    fetcher.fetch_wishlist = args.wishlist or os.getenv("FETCH_WISHLIST", "false").lower() == "true"
+    fetcher.sale_threshold = args.sale_threshold if args.sale_threshold is not None else env_number("WISHLIST_SALE_THRESHOLD", 50)
+    fetcher.refresh_app_catalog = args.refresh_app_catalog
+    fetcher.fetch_news = args.news
+    fetcher.fetch_workshop = args.workshop
+    fetcher.fetch_dlc = args.dlc
+    fetcher.max_review_texts = args.review_texts
+    fetcher.fetch_steamspy = args.steamspy
+    fetcher.fetch_achievements = args.achievements or os.getenv("FETCH_ACHIEVEMENTS", "false").lower() == "true"
+    try:
+        fetcher.cache_ttls = parse_cache_ttls(args.cache_ttl or os.getenv("CACHE_TTLS", ""))
+    except ValueError as e:
+        logger.error(f"Invalid cache TTL configuration: {e}")
+        sys.exit(1)
+    fetcher.country_code = (args.country or os.getenv("STEAM_COUNTRY", "us")).lower()
+    fetcher.language = args.language or os.getenv("STEAM_LANGUAGE", "english")
+    fetcher.access_token = os.getenv("STEAM_ACCESS_TOKEN")
+    fetcher.configure_transport(proxy=args.proxy or os.getenv("STEAM_PROXY"), ca_bundle=os.getenv("STEAM_CA_BUNDLE"), client_cert=os.getenv("STEAM_CLIENT_CERT"), verify_tls=os.getenv("STEAM_TLS_VERIFY", "true").lower() != "false")
+
+    # STEAM_ID may be a 64-bit ID, a profile URL, or a custom URL name
+    resolved_steam_id = fetcher.resolve_steam_id(steam_id)
+    if not resolved_steam_id:
+        logger.error(f"Could not resolve STEAM_ID '{steam_id}' to a 64-bit Steam ID")
+        sys.exit(1)
+    steam_id = resolved_steam_id
+
+    # Kubernetes and docker send SIGTERM on shutdown; abort the in-flight request instead of
+    # waiting for it (and the rest of the sync) to finish
+    def handle_shutdown(signum, frame):
+        logger.warning(f"Received {signal.Signals(signum).name}, cancelling sync...")
+        fetcher.cancel()
+        raise FetchCancelled()
+
+    signal.signal(signal.SIGTERM, handle_shutdown)
+    signal.signal(signal.SIGINT, handle_shutdown)
+
+    progress_events = args.progress_events or os.getenv("PROGRESS_EVENTS")
+    progress_thread = follow(fetcher.events.subscribe(), json_lines_writer(progress_events)) if progress_events else None
+    sale_webhook = args.sale_webhook or os.getenv("WISHLIST_SALE_WEBHOOK")
+    webhook_thread = follow(fetcher.events.subscribe(), notification_handler([WebhookNotifier(sale_webhook)], {"wishlist_sale"})) if sale_webhook else None
+    notifiers = notifiers_from_env()
+    notify_thread = follow(fetcher.events.subscribe(), notification_handler(notifiers)) if notifiers else None
+
+    try:
+        if args.dry_run:
+            preview = json.dumps(fetcher.preview_library_sync(steam_id), indent=2)
+            if args.dry_run_output == "-":
+                sys.stdout.write(preview + "\n")
+            elif args.dry_run_output:
+                with open(args.dry_run_output, "w", encoding="utf-8") as output:
+                    output.write(preview + "\n")
+            else:
+                logger.info(f"Dry run report:\n{preview}")
+        elif args.game:
+            if not fetcher.sync_game(steam_id, args.game):
+                fetcher.events.publish("sync_failed", steam_id=steam_id, app_id=args.game, error=f"Could not refresh game {args.game}")
+                sys.exit(1)
+        else:
+            fetcher.fetch_library_data(steam_id)
+            if args.refresh_profiles:
+                fetcher.refresh_user_profiles()
+    except FetchCancelled:
+        logger.warning("Sync cancelled; data saved so far has been kept")
+        fetcher.events.publish("sync_cancelled", steam_id=steam_id)
+        sys.exit(130)
+    except Exception as e:
+        # Whatever stopped the sync, subscribers (notifications in particular) hear about it
+        fetcher.events.publish("sync_failed", steam_id=steam_id, error=str(e) or type(e).__name__)
+        raise
+    finally:
+        if not args.dry_run:
+            fetcher.metrics.flush()
+        # Let the subscriber drain before the process exits
+        fetcher.events.close()
+        for thread in (progress_thread, webhook_thread, notify_thread):
+            if thread:
+                thread.join(timeout=5)
 
 
 if __name__ == "__main__":
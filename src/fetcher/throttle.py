@@ -0,0 +1,239 @@
+"""Adaptive request pacing with a circuit breaker for Steam API calls
+
+Steam rate-limits the store endpoints aggressively during large syncs. Instead of
+failing game after game, the throttle backs off when it sees 429/5xx responses and,
+after repeated failures, pauses all requests for a cooldown period.
+"""
+
+import logging
+import random
+import socket
+import threading
+import time
+import weakref
+from datetime import datetime
+from email.utils import parsedate_to_datetime
+
+import requests
+from requests.adapters import HTTPAdapter
+from urllib3.connection import HTTPConnection, HTTPSConnection
+from urllib3.connectionpool import HTTPConnectionPool, HTTPSConnectionPool
+
+logger = logging.getLogger(__name__)
+
+# Responses worth retrying after a delay
+RETRY_STATUSES = {429, 503}
+
+
+def parse_retry_after(value: str | None) -> float | None:
+    """Parse a Retry-After header given in seconds or as an HTTP date"""
+    if not value:
+        return None
+    if value.strip().isdigit():
+        return float(value)
+    try:
+        return max(0.0, (parsedate_to_datetime(value) - datetime.now().astimezone()).total_seconds())
+    except (TypeError, ValueError):
+        return None
+
+
+class FetchCancelled(BaseException):
+    """Raised when a sync is cancelled
+
+    Derives from BaseException (like KeyboardInterrupt) so the fetcher's broad
+    `except Exception` handlers don't swallow it and carry on with the next game.
+    """
+
+
+class AdaptiveThrottle:
+    """Spaces out requests, slowing down on 429/5xx and pausing after repeated failures"""
+
+    def __init__(self, base_delay: float = 1.0, max_delay: float = 30.0, failure_threshold: int = 5, cooldown: float = 60.0, cancel_event: threading.Event | None = None):
+        self.base_delay = base_delay
+        self.max_delay = max_delay
+        self.failure_threshold = failure_threshold
+        self.cooldown = cooldown
+        self.delay = base_delay
+        self.consecutive_failures = 0
+        self.open_until = 0.0
+        self.last_request = 0.0
+        self.cancel_event = cancel_event or threading.Event()
+        # Set from signal handlers, so it's a plain flag that wait() polls rather than an Event
+        self.paused = False
+        # Worker threads share one throttle, so slot reservation and pacing updates are locked
+        self._lock = threading.Lock()
+
+    @property
+    def is_open(self) -> bool:
+        """True while the circuit breaker is pausing requests"""
+        return time.time() < self.open_until
+
+    def wait(self):
+        """Block until the next request is allowed, raising FetchCancelled if the sync is cancelled"""
+        while self.paused:
+            self.sleep(0.5)
+
+        if self.is_open:
+            pause = self.open_until - time.time()
+            logger.warning(f"Circuit breaker open after repeated rate limiting, pausing {pause:.0f}s")
+            self.sleep(pause)
+
+        # Reserve the next free slot, then sleep until it arrives
+        with self._lock:
+            slot = max(time.time(), self.last_request + self.delay)
+            self.last_request = slot
+
+        pause = slot - time.time()
+        if pause > 0:
+            self.sleep(pause)
+        elif self.cancel_event.is_set():
+            raise FetchCancelled()
+
+    def sleep(self, seconds: float):
+        """Sleep that wakes up immediately on cancellation"""
+        if self.cancel_event.wait(seconds):
+            raise FetchCancelled()
+
+    def record(self, status_code: int, retry_after: float | None = None):
+        """Adjust pacing based on a response status"""
+        with self._lock:
+            self._record(status_code, retry_after)
+
+    def _record(self, status_code: int, retry_after: float | None):
+        if status_code == 429 or status_code >= 500:
+            self.consecutive_failures += 1
+            self.delay = min(self.delay * 2, self.max_delay)
+            logger.debug(f"Got {status_code}, slowing to {self.delay:.1f}s between requests")
+
+            if self.consecutive_failures >= self.failure_threshold:
+                self.open_until = time.time() + max(self.cooldown, retry_after or 0)
+                self.consecutive_failures = 0
+                logger.warning(f"Tripped circuit breaker after {self.failure_threshold} consecutive {status_code} responses")
+        else:
+            self.consecutive_failures = 0
+            # Recover gradually so we don't immediately hit the limit again
+            self.delay = max(self.base_delay, self.delay * 0.9)
+
+
+class ConnectionRegistry:
+    """Open HTTP connections that a cancellation should cut off mid-request"""
+
+    def __init__(self):
+        self._connections = weakref.WeakSet()
+        # Reentrant because abort() runs from signal handlers on threads that may be registering
+        self._lock = threading.RLock()
+
+    def add(self, connection):
+        with self._lock:
+            self._connections.add(connection)
+
+    def abort(self) -> int:
+        """Shut down every open socket so blocked reads and writes fail immediately"""
+        with self._lock:
+            connections = list(self._connections)
+        aborted = 0
+        for connection in connections:
+            sock = getattr(connection, "sock", None)
+            if sock is None:
+                continue
+            try:
+                sock.shutdown(socket.SHUT_RDWR)
+                aborted += 1
+            except OSError:
+                pass
+        return aborted
+
+
+def _tracked_pool_classes(registry: ConnectionRegistry) -> dict:
+    """urllib3 pool classes whose connections register themselves once connected"""
+
+    def tracked(connection_cls):
+        class TrackedConnection(connection_cls):
+            def connect(self):
+                super().connect()
+                registry.add(self)
+
+        return TrackedConnection
+
+    class TrackedHTTPPool(HTTPConnectionPool):
+        ConnectionCls = tracked(HTTPConnection)
+
+    class TrackedHTTPSPool(HTTPSConnectionPool):
+        ConnectionCls = tracked(HTTPSConnection)
+
+    return {"http": TrackedHTTPPool, "https": TrackedHTTPSPool}
+
+
+class CancellableAdapter(HTTPAdapter):
+    """HTTP adapter whose in-flight requests can be aborted from another thread
+
+    SOCKS proxies use their own connection classes and are not tracked; their
+    requests still stop at the next rate-limit wait.
+    """
+
+    def __init__(self, registry: ConnectionRegistry, *args, **kwargs):
+        self.registry = registry
+        super().__init__(*args, **kwargs)
+
+    def init_poolmanager(self, *args, **kwargs):
+        super().init_poolmanager(*args, **kwargs)
+        self.poolmanager.pool_classes_by_scheme = _tracked_pool_classes(self.registry)
+
+    def proxy_manager_for(self, proxy, **proxy_kwargs):
+        manager = super().proxy_manager_for(proxy, **proxy_kwargs)
+        if not proxy.lower().startswith("socks"):
+            manager.pool_classes_by_scheme = _tracked_pool_classes(self.registry)
+        return manager
+
+
+class RetryingSession(requests.Session):
+    """Session that retries 429/503 responses with jittered exponential backoff
+
+    A Retry-After header, when present, takes precedence over the computed delay.
+    Waits go through the throttle so cancellation interrupts them, and every
+    attempt passes through the response hooks so the throttle sees each failure.
+    Cancellation also aborts requests in flight (see abort_requests) and discards
+    any response that arrives afterwards by raising FetchCancelled.
+    """
+
+    def __init__(self, throttle: AdaptiveThrottle, max_retries: int = 4, backoff_base: float = 2.0, max_backoff: float = 120.0):
+        super().__init__()
+        self.throttle = throttle
+        self.max_retries = max_retries
+        self.backoff_base = backoff_base
+        self.max_backoff = max_backoff
+        self.connections = ConnectionRegistry()
+        self.mount("http://", CancellableAdapter(self.connections))
+        self.mount("https://", CancellableAdapter(self.connections))
+
+    def abort_requests(self) -> int:
+        """Cut off every request in flight, returning how many connections were closed"""
+        return self.connections.abort()
+
+    def request(self, method, url, *args, **kwargs):
+        for attempt in range(self.max_retries + 1):
+            if self.throttle.cancel_event.is_set():
+                raise FetchCancelled()
+            try:
+                response = super().request(method, url, *args, **kwargs)
+            except requests.RequestException:
+                # An aborted connection surfaces as a connection error
+                if self.throttle.cancel_event.is_set():
+                    raise FetchCancelled() from None
+                raise
+            if self.throttle.cancel_event.is_set():
+                response.close()
+                raise FetchCancelled()
+            if response.status_code not in RETRY_STATUSES or attempt == self.max_retries:
+                return response
+
+            delay = parse_retry_after(response.headers.get("Retry-After"))
+            if delay is None:
+                # Full jitter keeps parallel clients from retrying in lockstep
+                delay = random.uniform(0, self.backoff_base * 2**attempt)
+            # Never retry while the circuit breaker is pausing requests
+            delay = max(min(delay, self.max_backoff), self.throttle.open_until - time.time())
+            logger.info(f"Got {response.status_code} from {response.url.split('?')[0]}, retrying in {delay:.1f}s (attempt {attempt + 1}/{self.max_retries})")
+            self.throttle.sleep(delay)
+
+        return response
@@ -1,6 +1,6 @@
 """Simplified Steam Librarian MCP Server"""
 
 # Import all modules to register MCP decorators
-from . import completions, prompts, resources, tools
+from . import api, completions, mcp_logging, prompts, resources, subscriptions, tools
 
 __version__ = "1.6.2"
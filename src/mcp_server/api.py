@@ -0,0 +1,661 @@
+"""Plain HTTP JSON API routes served alongside the MCP endpoint for dashboards and scripts"""
+
+import asyncio
+import json
+import logging
+from datetime import datetime
+
+from sqlalchemy.orm import joinedload
+from starlette.requests import Request
+from starlette.responses import JSONResponse, StreamingResponse
+
+from shared.database import DLC, Game, GameNews, PriceHistory, ReviewText, UserDLC, UserGame, WorkshopItem, get_db, get_price_summary, resolve_user_identifier
+from shared.steam_store import StoreUnavailable, search_store
+
+from .events import events, format_sse, matches
+from .game_queries import GameQuery, query_games, sort_by_release_date
+from .game_search import search_games
+from .pagination import encode_cursor, page_limit, paginate
+from .scheduler import scheduler
+from .server import mcp
+from .sync import FINISHED_JOB_HISTORY, QuotaExceeded, SyncAlreadyRunning, cancel_sync, checkpoint_to_dict, find_checkpoint, pause_sync, preview_sync, quota_status, resume_sync, sync_all_libraries, sync_batch_status, sync_game, sync_queue
+
+logger = logging.getLogger(__name__)
+
+# Seconds between comments that keep idle /api/events streams open through proxies
+EVENT_KEEPALIVE_SECONDS = 15
+
+
+def news_item_to_dict(item: GameNews) -> dict:
+    """Serialize a stored news item"""
+    return {"gid": item.gid, "title": item.title, "url": item.url, "author": item.author, "feed_label": item.feed_label, "date": item.date, "contents": item.contents}
+
+
+def review_text_to_dict(item: ReviewText) -> dict:
+    """Serialize a stored player review"""
+    return {"recommendation_id": item.recommendation_id, "voted_up": item.voted_up, "review": item.review, "language": item.language, "votes_up": item.votes_up, "votes_funny": item.votes_funny, "weighted_vote_score": item.weighted_vote_score, "playtime_at_review": item.playtime_at_review, "timestamp_created": item.timestamp_created}
+
+
+def price_history_to_dict(entry: PriceHistory) -> dict:
+    """Serialize a recorded price change"""
+    return {"recorded_at": entry.recorded_at, "currency": entry.currency, "price_initial": entry.price_initial, "price_final": entry.price_final, "discount_percent": entry.discount_percent}
+
+
+def workshop_item_to_dict(item: WorkshopItem) -> dict:
+    """Serialize a stored workshop item"""
+    return {"published_file_id": item.published_file_id, "title": item.title, "short_description": item.short_description, "preview_url": item.preview_url, "url": f"https://steamcommunity.com/sharedfiles/filedetails/?id={item.published_file_id}", "subscriptions": item.subscriptions, "favorited": item.favorited, "views": item.views, "time_updated": item.time_updated}
+
+
+def game_to_dict(game: Game, user_game: UserGame | None) -> dict:
+    """Serialize a game in a list, with the library's playtime when listing one"""
+    item = {"app_id": game.app_id, "name": game.name, "release_date": game.release_date, "genres": [g.genre_name for g in game.genres], "positive_percentage": round(game.reviews.positive_percentage, 1) if game.reviews and game.reviews.total_reviews else None, "price_final": game.price_final, "discount_percent": game.discount_percent or 0}
+    if user_game is not None:
+        item.update({"playtime_hours": round((user_game.playtime_forever or 0) / 60, 1), "last_played": user_game.last_played_iso})
+    return item
+
+
+def parse_bool(value: str) -> bool:
+    """A true/false query parameter"""
+    if value.lower() in ("true", "1", "yes"):
+        return True
+    if value.lower() in ("false", "0", "no"):
+        return False
+    raise ValueError(f"Expected true or false, got '{value}'")
+
+
+@mcp.custom_route("/api/games", methods=["GET"])
+async def list_games(request: Request) -> JSONResponse:
+    """Games in the catalogue, or in ?library=, filtered, sorted, and a page at a time"""
+    params = request.query_params
+    try:
+        options = GameQuery(genre=params.get("genre") or None, tag=params.get("tag") or None, min_playtime=float(params["min_playtime"]) if params.get("min_playtime") else None, on_sale=parse_bool(params["on_sale"]) if params.get("on_sale") else None, sort_by=params.get("sort_by", "name"), order=params.get("order", "asc"))
+        limit = page_limit(params.get("limit"), default=50, maximum=200)
+        cursor = params.get("cursor")
+        # offset is the plain alternative to cursor for scripts paging by number
+        if params.get("offset") and not cursor:
+            if int(params["offset"]) < 0:
+                raise ValueError("offset must be 0 or more")
+            cursor = encode_cursor(int(params["offset"]))
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+
+    def load():
+        with get_db() as session:
+            library = params.get("library")
+            if library:
+                options.steam_id = resolve_user_identifier(library, session)
+                if not options.steam_id:
+                    return None, JSONResponse({"error": f"Library '{library}' not found"}, status_code=404)
+            games = query_games(session, options)
+            total = games.count()
+            games = games.options(joinedload(Game.genres), joinedload(Game.reviews))
+            if options.sort_by == "release_date":
+                rows, next_cursor = paginate(sort_by_release_date(games.all(), options.order), cursor, limit)
+            else:
+                rows, next_cursor = paginate(games, cursor, limit)
+            result = {"total": total, "games": [game_to_dict(game, user_game) for game, user_game in rows]}
+            if next_cursor:
+                result["nextCursor"] = next_cursor
+            return result, None
+
+    try:
+        result, error = await asyncio.to_thread(load)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+    except Exception as e:
+        logger.error(f"Failed to list games: {e}")
+        return JSONResponse({"error": f"Failed to list games: {str(e)}"}, status_code=500)
+    return error or JSONResponse(result)
+
+
+@mcp.custom_route("/api/games/{app_id:int}/news", methods=["GET"])
+async def game_news(request: Request) -> JSONResponse:
+    """Recent news and patch notes stored for a game"""
+    app_id = request.path_params["app_id"]
+    try:
+        limit = min(max(int(request.query_params.get("limit", "10")), 1), 50)
+    except ValueError:
+        return JSONResponse({"error": "limit must be an integer"}, status_code=400)
+
+    try:
+        with get_db() as session:
+            game = session.query(Game).filter_by(app_id=app_id).first()
+            if not game:
+                return JSONResponse({"error": f"Game {app_id} not found"}, status_code=404)
+
+            news = session.query(GameNews).filter_by(app_id=app_id).order_by(GameNews.date.desc()).limit(limit).all()
+            return JSONResponse({"app_id": app_id, "name": game.name, "news": [news_item_to_dict(item) for item in news]})
+    except Exception as e:
+        logger.error(f"Failed to load news for {app_id}: {e}")
+        return JSONResponse({"error": f"Failed to load news: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/games/{app_id:int}/reviews", methods=["GET"])
+async def game_reviews(request: Request) -> JSONResponse:
+    """Individual player reviews stored for a game, most helpful first"""
+    app_id = request.path_params["app_id"]
+    try:
+        limit = min(max(int(request.query_params.get("limit", "20")), 1), 100)
+    except ValueError:
+        return JSONResponse({"error": "limit must be an integer"}, status_code=400)
+
+    sentiment = request.query_params.get("sentiment", "all")
+    if sentiment not in ("all", "positive", "negative"):
+        return JSONResponse({"error": "sentiment must be one of: all, positive, negative"}, status_code=400)
+
+    try:
+        with get_db() as session:
+            game = session.query(Game).filter_by(app_id=app_id).first()
+            if not game:
+                return JSONResponse({"error": f"Game {app_id} not found"}, status_code=404)
+
+            query = session.query(ReviewText).filter_by(app_id=app_id)
+            if sentiment != "all":
+                query = query.filter(ReviewText.voted_up.is_(sentiment == "positive"))
+            reviews = query.order_by(ReviewText.votes_up.desc()).limit(limit).all()
+            return JSONResponse({"app_id": app_id, "name": game.name, "reviews": [review_text_to_dict(item) for item in reviews]})
+    except Exception as e:
+        logger.error(f"Failed to load reviews for {app_id}: {e}")
+        return JSONResponse({"error": f"Failed to load reviews: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/games/{app_id:int}/prices", methods=["GET"])
+async def game_prices(request: Request) -> JSONResponse:
+    """Recorded price changes for a game with its lowest-ever price"""
+    app_id = request.path_params["app_id"]
+    try:
+        with get_db() as session:
+            game = session.query(Game).filter_by(app_id=app_id).first()
+            if not game:
+                return JSONResponse({"error": f"Game {app_id} not found"}, status_code=404)
+
+            history = session.query(PriceHistory).filter_by(app_id=app_id).order_by(PriceHistory.recorded_at.desc()).all()
+            return JSONResponse({"app_id": app_id, "name": game.name, "summary": get_price_summary(session, game), "history": [price_history_to_dict(entry) for entry in history]})
+    except Exception as e:
+        logger.error(f"Failed to load price history for {app_id}: {e}")
+        return JSONResponse({"error": f"Failed to load price history: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/games/{app_id:int}/workshop", methods=["GET"])
+async def game_workshop(request: Request) -> JSONResponse:
+    """Popular Steam Workshop items stored for a game"""
+    app_id = request.path_params["app_id"]
+
+    try:
+        with get_db() as session:
+            game = session.query(Game).filter_by(app_id=app_id).first()
+            if not game:
+                return JSONResponse({"error": f"Game {app_id} not found"}, status_code=404)
+
+            items = session.query(WorkshopItem).filter_by(app_id=app_id).order_by(WorkshopItem.subscriptions.desc()).all()
+            return JSONResponse({"app_id": app_id, "name": game.name, "workshop_items": [workshop_item_to_dict(item) for item in items]})
+    except Exception as e:
+        logger.error(f"Failed to load workshop items for {app_id}: {e}")
+        return JSONResponse({"error": f"Failed to load workshop items: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/games/{app_id:int}/dlc", methods=["GET"])
+async def game_dlc(request: Request) -> JSONResponse:
+    """A game's DLC with who owns each one, or whether ?user= owns it"""
+    app_id = request.path_params["app_id"]
+    try:
+        with get_db() as session:
+            steam_id, error = resolve_sync_user(request, session)
+            if error:
+                return error
+            game = session.query(Game).filter_by(app_id=app_id).first()
+            if not game:
+                return JSONResponse({"error": f"Game {app_id} not found"}, status_code=404)
+
+            details = {dlc.app_id: dlc for dlc in session.query(DLC).filter_by(parent_app_id=app_id)}
+            owners: dict[int, list[str]] = {}
+            for row in session.query(UserDLC).filter_by(parent_app_id=app_id):
+                owners.setdefault(row.app_id, []).append(row.steam_id)
+
+            dlc = []
+            for dlc_app_id in dict.fromkeys([*json.loads(game.dlc_app_ids or "[]"), *details, *owners]):
+                item = {"app_id": dlc_app_id, "name": details[dlc_app_id].name if dlc_app_id in details else None, "price_final": details[dlc_app_id].price_final if dlc_app_id in details else None}
+                if steam_id:
+                    item["owned"] = steam_id in owners.get(dlc_app_id, [])
+                else:
+                    item["owners"] = owners.get(dlc_app_id, [])
+                dlc.append(item)
+            return JSONResponse({"app_id": app_id, "name": game.name, "dlc": dlc})
+    except Exception as e:
+        logger.error(f"Failed to load DLC for {app_id}: {e}")
+        return JSONResponse({"error": f"Failed to load DLC: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/store/search", methods=["GET"])
+async def store_search(request: Request) -> JSONResponse:
+    """Search the Steam store, including games not in any library"""
+    query = request.query_params.get("q", "").strip()
+    if not query:
+        return JSONResponse({"error": "q parameter is required"}, status_code=400)
+
+    filters = {}
+    try:
+        if "max_price" in request.query_params:
+            filters["max_price"] = int(request.query_params["max_price"])
+        if "min_metascore" in request.query_params:
+            filters["min_metascore"] = int(request.query_params["min_metascore"])
+    except ValueError:
+        return JSONResponse({"error": "max_price and min_metascore must be integers"}, status_code=400)
+    if request.query_params.get("platforms"):
+        filters["platforms"] = [p.strip().lower() for p in request.query_params["platforms"].split(",") if p.strip()]
+
+    try:
+        results = await asyncio.to_thread(search_store, query, filters, raise_errors=True)
+    except StoreUnavailable as e:
+        return JSONResponse({"error": f"Steam store search is unavailable: {e}"}, status_code=502)
+    return JSONResponse({"query": query, "filters": filters, "results": results})
+
+
+@mcp.custom_route("/api/search/games", methods=["GET"])
+async def game_search(request: Request) -> JSONResponse:
+    """Full-text search of stored games, or of ?library=, best match first"""
+    query = request.query_params.get("q", "").strip()
+    if not query:
+        return JSONResponse({"error": "q parameter is required"}, status_code=400)
+    try:
+        limit = page_limit(request.query_params.get("limit"), default=20, maximum=100)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+
+    def load():
+        with get_db() as session:
+            matches = search_games(session, query)
+            library = request.query_params.get("library")
+            if library:
+                steam_id = resolve_user_identifier(library, session)
+                if not steam_id:
+                    return None, JSONResponse({"error": f"Library '{library}' not found"}, status_code=404)
+                owned = {app_id for (app_id,) in session.query(UserGame.app_id).filter(UserGame.steam_id == steam_id, UserGame.removed_at.is_(None))}
+                matches = [(app_id, score) for app_id, score in matches if app_id in owned]
+
+            page, next_cursor = paginate(matches, request.query_params.get("cursor"), limit)
+            games = {game.app_id: game for game in session.query(Game).options(joinedload(Game.genres), joinedload(Game.developers)).filter(Game.app_id.in_([app_id for app_id, _ in page]))}
+            results = [{"app_id": app_id, "name": games[app_id].name, "score": score, "short_description": games[app_id].short_description, "developers": [d.developer_name for d in games[app_id].developers], "genres": [g.genre_name for g in games[app_id].genres]} for app_id, score in page if app_id in games]
+            result = {"query": query, "total": len(matches), "results": results}
+            if next_cursor:
+                result["nextCursor"] = next_cursor
+            return result, None
+
+    try:
+        result, error = await asyncio.to_thread(load)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+    except Exception as e:
+        logger.error(f"Failed to search games for '{query}': {e}")
+        return JSONResponse({"error": f"Failed to search games: {str(e)}"}, status_code=500)
+    return error or JSONResponse(result)
+
+
+def resolve_sync_user(request: Request, session) -> tuple[str | None, JSONResponse | None]:
+    """Resolve the optional ?user= parameter of the sync and DLC endpoints"""
+    user = request.query_params.get("user")
+    if not user:
+        return None, None
+    steam_id = resolve_user_identifier(user, session)
+    if not steam_id:
+        return None, JSONResponse({"error": f"User '{user}' not found"}, status_code=404)
+    return steam_id, None
+
+
+@mcp.custom_route("/api/sync/status", methods=["GET"])
+async def sync_status(request: Request) -> JSONResponse:
+    """Progress of the latest library sync and of any sync started by this server"""
+    try:
+        with get_db() as session:
+            steam_id, error = resolve_sync_user(request, session)
+            if error:
+                return error
+            checkpoint = find_checkpoint(session, steam_id)
+            return JSONResponse({"checkpoint": checkpoint_to_dict(checkpoint) if checkpoint else None, "queue": sync_queue.status(), "budget": quota_status(session)})
+    except Exception as e:
+        logger.error(f"Failed to load sync status: {e}")
+        return JSONResponse({"error": f"Failed to load sync status: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/sync/preview", methods=["GET"])
+async def sync_preview(request: Request) -> JSONResponse:
+    """Games a library sync would create, update, or remove, from a fetcher dry run"""
+
+    def preview():
+        with get_db() as session:
+            steam_id, error = resolve_sync_user(request, session)
+            return error or JSONResponse(preview_sync(session, steam_id, request.query_params.get("scope", "full")))
+
+    try:
+        return await asyncio.to_thread(preview)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+    except LookupError as e:
+        return JSONResponse({"error": str(e)}, status_code=404)
+    except Exception as e:
+        logger.error(f"Failed to preview sync: {e}")
+        return JSONResponse({"error": f"Failed to preview sync: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/sync/queue", methods=["GET"])
+async def sync_queue_state(request: Request) -> JSONResponse:
+    """Running, queued (with positions), and recently finished syncs started by this server"""
+    return JSONResponse(sync_queue.status())
+
+
+@mcp.custom_route("/api/sync/history", methods=["GET"])
+async def sync_history(request: Request) -> JSONResponse:
+    """Finished syncs started by this server, most recent first, a page at a time"""
+    try:
+        limit = page_limit(request.query_params.get("limit"), default=FINISHED_JOB_HISTORY, maximum=FINISHED_JOB_HISTORY)
+        jobs, next_cursor = paginate(sync_queue.history(), request.query_params.get("cursor"), limit)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+    result = {"jobs": jobs}
+    if next_cursor:
+        result["nextCursor"] = next_cursor
+    return JSONResponse(result)
+
+
+@mcp.custom_route("/api/sync/budget", methods=["GET"])
+async def sync_budget(request: Request) -> JSONResponse:
+    """Steam requests made today against STEAM_API_DAILY_BUDGET, and how many are left"""
+    try:
+        with get_db() as session:
+            return JSONResponse(quota_status(session))
+    except Exception as e:
+        logger.error(f"Failed to load request budget: {e}")
+        return JSONResponse({"error": f"Failed to load request budget: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler", methods=["GET"])
+async def scheduler_status(request: Request) -> JSONResponse:
+    """Default cron schedule, scheduler counters, and each library's last and next scheduled sync"""
+    try:
+        return JSONResponse(await asyncio.to_thread(scheduler.status))
+    except Exception as e:
+        logger.error(f"Failed to load scheduler status: {e}")
+        return JSONResponse({"error": f"Failed to load scheduler status: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler/upcoming", methods=["GET"])
+async def scheduler_upcoming(request: Request) -> JSONResponse:
+    """Each library's next full and incremental scheduled sync, soonest first"""
+    try:
+        return JSONResponse(await asyncio.to_thread(scheduler.upcoming))
+    except Exception as e:
+        logger.error(f"Failed to load upcoming syncs: {e}")
+        return JSONResponse({"error": f"Failed to load upcoming syncs: {str(e)}"}, status_code=500)
+
+
+def parse_time(value: str) -> int:
+    """Unix time from a Unix timestamp or an ISO 8601 date/time (server local time unless it has an offset)"""
+    if value.isdigit():
+        return int(value)
+    return int(datetime.fromisoformat(value).timestamp())
+
+
+@mcp.custom_route("/api/scheduler/blackouts", methods=["GET"])
+async def scheduler_blackouts(request: Request) -> JSONResponse:
+    """Current and upcoming blackout windows, or all of them with ?all=true"""
+    try:
+        return JSONResponse({"blackouts": await asyncio.to_thread(scheduler.list_blackouts, request.query_params.get("all", "false").lower() == "true")})
+    except Exception as e:
+        logger.error(f"Failed to load blackouts: {e}")
+        return JSONResponse({"error": f"Failed to load blackouts: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler/blackouts", methods=["POST"])
+async def scheduler_add_blackout(request: Request) -> JSONResponse:
+    """Suppress scheduled syncs from start to end (?start=...&end=...&reason=...)"""
+    params = request.query_params
+    if not params.get("start") or not params.get("end"):
+        return JSONResponse({"error": "Both start and end are required (Unix time or ISO 8601)"}, status_code=400)
+    try:
+        blackout = await asyncio.to_thread(scheduler.add_blackout, parse_time(params["start"]), parse_time(params["end"]), params.get("reason"))
+        return JSONResponse(blackout, status_code=201)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+    except Exception as e:
+        logger.error(f"Failed to add blackout: {e}")
+        return JSONResponse({"error": f"Failed to add blackout: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler/blackouts/{blackout_id:int}", methods=["DELETE"])
+async def scheduler_remove_blackout(request: Request) -> JSONResponse:
+    """Delete a blackout window"""
+    try:
+        return JSONResponse(await asyncio.to_thread(scheduler.remove_blackout, request.path_params["blackout_id"]))
+    except LookupError as e:
+        return JSONResponse({"error": str(e)}, status_code=404)
+    except Exception as e:
+        logger.error(f"Failed to remove blackout: {e}")
+        return JSONResponse({"error": f"Failed to remove blackout: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler/one-shots", methods=["GET"])
+async def scheduler_one_shots(request: Request) -> JSONResponse:
+    """Pending one-shot syncs, or all of them with ?all=true"""
+    try:
+        return JSONResponse({"one_shots": await asyncio.to_thread(scheduler.list_one_shots, request.query_params.get("all", "false").lower() == "true")})
+    except Exception as e:
+        logger.error(f"Failed to load one-shot syncs: {e}")
+        return JSONResponse({"error": f"Failed to load one-shot syncs: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler/one-shots", methods=["POST"])
+async def scheduler_add_one_shot(request: Request) -> JSONResponse:
+    """Run a single sync at a set time (?at=...&scope=full&user=...)"""
+    if not request.query_params.get("at"):
+        return JSONResponse({"error": "at is required (Unix time or ISO 8601)"}, status_code=400)
+    try:
+        with get_db() as session:
+            steam_id, error = resolve_sync_user(request, session)
+            if error:
+                return error
+            # Like the other sync endpoints, default to the most recently synced library
+            if not steam_id:
+                checkpoint = find_checkpoint(session)
+                if not checkpoint:
+                    return JSONResponse({"error": "No library has been synced yet; pass ?user="}, status_code=404)
+                steam_id = checkpoint.steam_id
+        one_shot = await asyncio.to_thread(scheduler.schedule_one_shot, steam_id, parse_time(request.query_params["at"]), request.query_params.get("scope", "full"))
+        return JSONResponse(one_shot, status_code=201)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+    except Exception as e:
+        logger.error(f"Failed to schedule one-shot sync: {e}")
+        return JSONResponse({"error": f"Failed to schedule one-shot sync: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler/one-shots/{one_shot_id:int}", methods=["DELETE"])
+async def scheduler_cancel_one_shot(request: Request) -> JSONResponse:
+    """Cancel a pending one-shot sync"""
+    try:
+        return JSONResponse(await asyncio.to_thread(scheduler.cancel_one_shot, request.path_params["one_shot_id"]))
+    except LookupError as e:
+        return JSONResponse({"error": str(e)}, status_code=404)
+    except Exception as e:
+        logger.error(f"Failed to cancel one-shot sync: {e}")
+        return JSONResponse({"error": f"Failed to cancel one-shot sync: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler/pause", methods=["POST"])
+async def scheduler_pause(request: Request) -> JSONResponse:
+    """Stop queueing scheduled syncs, e.g. during maintenance"""
+    try:
+        return JSONResponse(await asyncio.to_thread(scheduler.pause))
+    except Exception as e:
+        logger.error(f"Failed to pause scheduler: {e}")
+        return JSONResponse({"error": f"Failed to pause scheduler: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler/resume", methods=["POST"])
+async def scheduler_resume(request: Request) -> JSONResponse:
+    """Queue scheduled syncs again"""
+    try:
+        return JSONResponse(await asyncio.to_thread(scheduler.resume))
+    except Exception as e:
+        logger.error(f"Failed to resume scheduler: {e}")
+        return JSONResponse({"error": f"Failed to resume scheduler: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/scheduler/run-now", methods=["POST"])
+async def scheduler_run_now(request: Request) -> JSONResponse:
+    """Queue every library's next scheduled sync without waiting for it to come due"""
+    try:
+        return JSONResponse({"results": await asyncio.to_thread(scheduler.run_now)}, status_code=202)
+    except Exception as e:
+        logger.error(f"Failed to run scheduler: {e}")
+        return JSONResponse({"error": f"Failed to run scheduler: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/sync/all", methods=["POST"])
+async def sync_all(request: Request) -> JSONResponse:
+    """Queue a sync of every library, returning a batch to poll for aggregate progress"""
+
+    # Queueing every library estimates each one's requests; keep other requests moving meanwhile
+    def start():
+        with get_db() as session:
+            return sync_all_libraries(session, request.query_params.get("priority", "manual"), request.query_params.get("scope", "full"))
+
+    try:
+        return JSONResponse(await asyncio.to_thread(start), status_code=202)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+    except LookupError as e:
+        return JSONResponse({"error": str(e)}, status_code=404)
+    except Exception as e:
+        logger.error(f"Failed to queue syncs for all libraries: {e}")
+        return JSONResponse({"error": f"Failed to queue syncs: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/sync/all/{batch_id:int}", methods=["GET"])
+async def sync_all_status(request: Request) -> JSONResponse:
+    """Aggregate progress of a sync-all batch"""
+    batch = sync_batch_status(request.path_params["batch_id"])
+    if not batch:
+        return JSONResponse({"error": f"Sync batch {request.path_params['batch_id']} not found"}, status_code=404)
+    return JSONResponse(batch)
+
+
+@mcp.custom_route("/api/sync/cancel", methods=["POST"])
+async def sync_cancel(request: Request) -> JSONResponse:
+    """Stop a running sync immediately, or drop a queued one"""
+
+    def cancel():
+        with get_db() as session:
+            steam_id, error = resolve_sync_user(request, session)
+        return error or JSONResponse({"job": cancel_sync(steam_id)})
+
+    try:
+        return await asyncio.to_thread(cancel)
+    except LookupError as e:
+        return JSONResponse({"error": str(e)}, status_code=404)
+    except Exception as e:
+        logger.error(f"Failed to cancel sync: {e}")
+        return JSONResponse({"error": f"Failed to cancel sync: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/sync/pause", methods=["POST"])
+async def sync_pause(request: Request) -> JSONResponse:
+    """Pause a running sync without losing its progress"""
+
+    def pause():
+        with get_db() as session:
+            steam_id, error = resolve_sync_user(request, session)
+        return error or JSONResponse({"job": pause_sync(steam_id)})
+
+    try:
+        return await asyncio.to_thread(pause)
+    except LookupError as e:
+        return JSONResponse({"error": str(e)}, status_code=404)
+    except Exception as e:
+        logger.error(f"Failed to pause sync: {e}")
+        return JSONResponse({"error": f"Failed to pause sync: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/sync/resume", methods=["POST"])
+async def sync_resume(request: Request) -> JSONResponse:
+    """Continue a paused sync, or resume an interrupted library sync from its checkpoint"""
+
+    def start():
+        with get_db() as session:
+            steam_id, error = resolve_sync_user(request, session)
+            return error or JSONResponse(resume_sync(session, steam_id, request.query_params.get("priority", "manual")), status_code=202)
+
+    try:
+        return await asyncio.to_thread(start)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+    except LookupError as e:
+        return JSONResponse({"error": str(e)}, status_code=404)
+    except SyncAlreadyRunning as e:
+        return JSONResponse({"error": str(e)}, status_code=409)
+    except QuotaExceeded as e:
+        return JSONResponse({"error": str(e)}, status_code=429)
+    except Exception as e:
+        logger.error(f"Failed to resume sync: {e}")
+        return JSONResponse({"error": f"Failed to resume sync: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/games/{app_id:int}/sync", methods=["POST"])
+async def game_sync(request: Request) -> JSONResponse:
+    """Refresh store details, reviews, price, and playtime for one game in the background"""
+    app_id = request.path_params["app_id"]
+
+    def start():
+        with get_db() as session:
+            steam_id, error = resolve_sync_user(request, session)
+            return error or JSONResponse({"app_id": app_id, "job": sync_game(session, app_id, steam_id, request.query_params.get("priority", "manual"), request.query_params.get("scope", "full"))}, status_code=202)
+
+    try:
+        return await asyncio.to_thread(start)
+    except ValueError as e:
+        return JSONResponse({"error": str(e)}, status_code=400)
+    except LookupError as e:
+        return JSONResponse({"error": str(e)}, status_code=404)
+    except SyncAlreadyRunning as e:
+        return JSONResponse({"error": str(e)}, status_code=409)
+    except QuotaExceeded as e:
+        return JSONResponse({"error": str(e)}, status_code=429)
+    except Exception as e:
+        logger.error(f"Failed to sync game {app_id}: {e}")
+        return JSONResponse({"error": f"Failed to sync game: {str(e)}"}, status_code=500)
+
+
+@mcp.custom_route("/api/events", methods=["GET"])
+async def event_stream(request: Request):
+    """Server-Sent Events stream of sync, conflict, and scheduler events, optionally only ?types="""
+    types = [wanted.strip() for wanted in request.query_params.get("types", "").split(",") if wanted.strip()]
+    last_event_id = request.headers.get("last-event-id") or request.query_params.get("last_event_id")
+    try:
+        last_event_id = int(last_event_id) if last_event_id else None
+    except ValueError:
+        return JSONResponse({"error": "Last-Event-ID must be an integer"}, status_code=400)
+
+    async def stream():
+        # Subscribed before replaying, so nothing published in between is missed
+        queue = events.subscribe()
+        try:
+            sent = 0
+            if last_event_id is not None:
+                for event in events.since(last_event_id):
+                    if matches(event, types):
+                        yield format_sse(event)
+                    sent = event["id"]
+            else:
+                # Lets clients see the stream is open before the first event
+                yield ": connected\n\n"
+            while True:
+                try:
+                    event = await asyncio.wait_for(queue.get(), timeout=EVENT_KEEPALIVE_SECONDS)
+                except asyncio.TimeoutError:
+                    yield ": keepalive\n\n"
+                    continue
+                if event["id"] > sent and matches(event, types):
+                    yield format_sse(event)
+        finally:
+            events.unsubscribe(queue)
+
+    return StreamingResponse(stream(), media_type="text/event-stream", headers={"Cache-Control": "no-cache", "X-Accel-Buffering": "no"})
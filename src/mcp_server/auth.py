@@ -0,0 +1,159 @@
+"""Token authentication for the HTTP transport
+
+With MCP_AUTH_TOKENS set, every HTTP request except the /health liveness probe
+needs a token, sent as "Authorization: Bearer TOKEN" or "X-API-Key: TOKEN". Each
+token has a scope:
+
+- read: MCP resources, prompts, completions, and read-only tools, and GET /api routes
+- sync: everything, including tools that aren't annotated readOnlyHint (syncs, the
+  scheduler) and /api routes that change state (POST, DELETE)
+
+Without MCP_AUTH_TOKENS the server is open, which is only safe on localhost.
+"""
+
+import hmac
+import json
+import logging
+
+from starlette.responses import JSONResponse
+
+from .config import config
+from .server import mcp
+
+logger = logging.getLogger(__name__)
+
+SCOPES = ("read", "sync")
+
+# Paths served without a token, for container liveness probes
+OPEN_PATHS = {"/health"}
+
+
+def parse_tokens(spec: str) -> dict[str, str]:
+    """Scope of each token in "TOKEN=SCOPE; TOKEN=SCOPE" (a token without =SCOPE is read-only)"""
+    tokens = {}
+    for entry in spec.replace(",", ";").split(";"):
+        token, _, scope = entry.strip().partition("=")
+        if not token:
+            continue
+        scope = scope.strip().lower() or "read"
+        if scope not in SCOPES:
+            raise ValueError(f"Unknown scope '{scope}' in MCP_AUTH_TOKENS: expected one of {', '.join(SCOPES)}")
+        tokens[token.strip()] = scope
+    return tokens
+
+
+def request_token(headers: dict[str, str]) -> str | None:
+    """Token from the Authorization (Bearer) or X-API-Key header"""
+    scheme, _, credentials = headers.get("authorization", "").partition(" ")
+    if scheme.lower() == "bearer" and credentials.strip():
+        return credentials.strip()
+    return headers.get("x-api-key") or None
+
+
+def token_scope(tokens: dict[str, str], token: str | None) -> str | None:
+    """Scope of a presented token, comparing in constant time, or None if it isn't known"""
+    if not token:
+        return None
+    scope = None
+    for known, known_scope in tokens.items():
+        if hmac.compare_digest(known.encode(), token.encode()):
+            scope = known_scope
+    return scope
+
+
+async def sync_tools() -> set[str]:
+    """Tools that need the sync scope: every tool not annotated read-only"""
+    return {tool.name for tool in await mcp.list_tools() if not (tool.annotations and tool.annotations.readOnlyHint)}
+
+
+def calls_sync_tool(body: bytes, tools: set[str]) -> str | None:
+    """Name of a sync-scope tool a JSON-RPC request body calls, if any"""
+    try:
+        messages = json.loads(body)
+    except ValueError:
+        # Not our concern; the transport rejects it
+        return None
+    for message in messages if isinstance(messages, list) else [messages]:
+        if isinstance(message, dict) and message.get("method") == "tools/call":
+            name = (message.get("params") or {}).get("name")
+            if name in tools:
+                return name
+    return None
+
+
+class TokenAuthMiddleware:
+    """ASGI middleware checking tokens and scopes in front of the MCP app and its custom routes"""
+
+    def __init__(self, app, tokens: dict[str, str]):
+        self.app = app
+        self.tokens = tokens
+
+    async def __call__(self, scope, receive, send):
+        if scope["type"] != "http" or scope["path"] in OPEN_PATHS:
+            await self.app(scope, receive, send)
+            return
+
+        headers = {name.decode("latin-1").lower(): value.decode("latin-1") for name, value in scope["headers"]}
+        granted = token_scope(self.tokens, request_token(headers))
+        if granted is None:
+            response = JSONResponse({"error": "Authentication required: send Authorization: Bearer <token> or X-API-Key"}, status_code=401, headers={"WWW-Authenticate": "Bearer"})
+            await response(scope, receive, send)
+            return
+
+        if granted != "sync":
+            method, path = scope["method"], scope["path"]
+            if path.startswith("/api/") and method not in ("GET", "HEAD", "OPTIONS"):
+                await self.forbidden(scope, receive, send, f"{method} {path} changes server state")
+                return
+            if path.startswith("/mcp") and method == "POST":
+                # Tool calls are only visible in the body, which is read here and replayed to the app
+                body = await read_body(receive)
+                if tool := calls_sync_tool(body, await sync_tools()):
+                    await self.forbidden(scope, receive, send, f"Tool '{tool}' changes server state")
+                    return
+                receive = replay(body, receive)
+
+        await self.app(scope, receive, send)
+
+    @staticmethod
+    async def forbidden(scope, receive, send, reason: str):
+        logger.warning(f"Refused read-only token: {reason}")
+        await JSONResponse({"error": f"{reason} and needs a token with the sync scope", "required_scope": "sync"}, status_code=403)(scope, receive, send)
+
+
+async def read_body(receive) -> bytes:
+    """The whole request body from an ASGI receive channel"""
+    body = b""
+    while True:
+        message = await receive()
+        if message["type"] != "http.request":
+            return body
+        body += message.get("body", b"")
+        if not message.get("more_body"):
+            return body
+
+
+def replay(body: bytes, receive):
+    """An ASGI receive channel that yields body once, then passes on receive's messages (such as the client disconnecting)"""
+    sent = False
+
+    async def replayed():
+        nonlocal sent
+        if not sent:
+            sent = True
+            return {"type": "http.request", "body": body, "more_body": False}
+        return await receive()
+
+    return replayed
+
+
+def http_app():
+    """The streamable HTTP app, behind token authentication when MCP_AUTH_TOKENS is set"""
+    app = mcp.streamable_http_app()
+    tokens = parse_tokens(config.auth_tokens)
+    if not tokens:
+        if config.host not in ("127.0.0.1", "localhost", "::1"):
+            logger.warning(f"Serving on {config.host} without authentication; set MCP_AUTH_TOKENS to require tokens")
+        return app
+    logger.info(f"Token authentication enabled ({sum(scope == 'sync' for scope in tokens.values())} sync, {sum(scope == 'read' for scope in tokens.values())} read-only tokens)")
+    return TokenAuthMiddleware(app, tokens)
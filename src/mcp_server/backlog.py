@@ -0,0 +1,62 @@
+"""Backlog queries: what to play next from games already owned
+
+Never-played games have no playtime at all. The backlog is broader: short games
+the user hasn't finished, played or not. A game counts as finished once its
+playtime reaches its estimated length or every achievement is unlocked.
+
+Estimated length is SteamSpy's median playtime across all owners, so it's only
+known for games the fetcher enriched with SteamSpy data. Both lists put the best
+reviewed games first and, among equally reviewed games, the shortest.
+"""
+
+from sqlalchemy import and_, case, or_
+
+from shared.database import Game, GameReview, UserGame
+
+# Games need this many Steam reviews before their score ranks them
+MIN_REVIEWS = 10
+
+# Percentage of positive reviews, or NULL for games with too few reviews
+REVIEW_PERCENT = case((GameReview.total_reviews >= MIN_REVIEWS, GameReview.positive_reviews * 100.0 / GameReview.total_reviews), else_=None)
+
+# Median minutes owners play the game, or NULL when unknown
+ESTIMATED_MINUTES = case((Game.steamspy_median_playtime > 0, Game.steamspy_median_playtime), else_=None)
+
+
+def library_games(session, steam_id: str, min_review: int | None = None):
+    """Query of (Game, UserGame) for games the user owns, with their review scores joined"""
+    games = session.query(Game, UserGame).join(UserGame, (Game.app_id == UserGame.app_id) & (UserGame.steam_id == steam_id)).outerjoin(GameReview, GameReview.app_id == Game.app_id).filter(UserGame.removed_at.is_(None))
+    if min_review is not None:
+        games = games.filter(REVIEW_PERCENT >= min_review)
+    return games.order_by(REVIEW_PERCENT.desc().nulls_last(), ESTIMATED_MINUTES.asc().nulls_last(), Game.name)
+
+
+def never_played_games(session, steam_id: str, min_review: int | None = None, max_hours: float | None = None):
+    """Games the user has never launched; max_hours leaves out games estimated to take longer"""
+    games = library_games(session, steam_id, min_review).filter(or_(UserGame.playtime_forever.is_(None), UserGame.playtime_forever == 0))
+    if max_hours is not None:
+        games = games.filter(ESTIMATED_MINUTES <= max_hours * 60)
+    return games
+
+
+def backlog_games(session, steam_id: str, max_hours: float, min_review: int | None = None):
+    """Unfinished games estimated to take at most max_hours"""
+    unfinished = and_(or_(UserGame.playtime_forever.is_(None), UserGame.playtime_forever < ESTIMATED_MINUTES), or_(UserGame.achievements_total.is_(None), UserGame.achievements_total == 0, UserGame.achievements_unlocked < UserGame.achievements_total))
+    return library_games(session, steam_id, min_review).filter(ESTIMATED_MINUTES <= max_hours * 60, unfinished)
+
+
+def backlog_entry(game: Game, user_game: UserGame) -> dict:
+    """What a backlog list reports for each game"""
+    played = user_game.playtime_forever or 0
+    estimated = game.steamspy_median_playtime if game.steamspy_median_playtime else None
+    review = game.reviews if game.reviews and (game.reviews.total_reviews or 0) >= MIN_REVIEWS else None
+    return {
+        "app_id": game.app_id,
+        "name": game.name,
+        "positive_percentage": review.positive_percentage if review else None,
+        "review_summary": review.review_summary if review else None,
+        "estimated_hours": round(estimated / 60, 1) if estimated else None,
+        "playtime_hours": round(played / 60, 1),
+        "remaining_hours": round(max(estimated - played, 0) / 60, 1) if estimated else None,
+        "genres": [genre.genre_name for genre in game.genres],
+    }
@@ -1,4 +1,13 @@
 # src/mcp_server/completions.py
+"""Argument completions (completion/complete)
+
+MCP clients ask for completions of prompt arguments and resource template
+parameters; tools have no completion in the protocol. Game names, users, genres
+and tags come from the library database, and arguments with a fixed set of
+values complete from that set. Matching is a case-insensitive prefix match, and
+at most MAX_COMPLETIONS values are returned, with hasMore set when there are more.
+"""
+
 from mcp.types import (
     Completion,
     CompletionArgument,
@@ -6,123 +15,104 @@ from mcp.types import (
     PromptReference,
     ResourceTemplateReference,
 )
+from sqlalchemy import String, cast, func, or_
+
+from shared.database import Game, Genre, Tag, UserGame, UserProfile, get_db
 
-from shared.database import Game, UserGame, get_db
+from .recommendations import MOODS
 
 # Import the server instance from server.py
 from .server import mcp
 
+# The protocol caps a completion at 100 values
+MAX_COMPLETIONS = 100
+
+
+def complete_from(values: list[str], prefix: str) -> Completion:
+    """Values starting with prefix, in the order given"""
+    matching = [value for value in values if value.lower().startswith(prefix.lower())]
+    return Completion(values=matching[:MAX_COMPLETIONS], total=len(matching), hasMore=len(matching) > MAX_COMPLETIONS)
+
+
+def from_query(query) -> Completion:
+    """Completion from a query of single values, fetching one extra row to tell whether there are more"""
+    values = [str(value) for (value,) in query.limit(MAX_COMPLETIONS + 1)]
+    return Completion(values=values[:MAX_COMPLETIONS], hasMore=len(values) > MAX_COMPLETIONS)
+
+
+def game_names(prefix: str) -> Completion:
+    """Names of games in any library, most played first"""
+    with get_db() as session:
+        return from_query(session.query(Game.name).join(UserGame, UserGame.app_id == Game.app_id).filter(Game.name.ilike(f"{prefix}%")).group_by(Game.app_id, Game.name).order_by(func.max(UserGame.playtime_forever).desc(), Game.name))
+
+
+def game_ids(prefix: str) -> Completion:
+    """App ids of games in any library, most played first"""
+    with get_db() as session:
+        return from_query(session.query(Game.app_id).join(UserGame, UserGame.app_id == Game.app_id).filter(cast(Game.app_id, String).like(f"{prefix}%")).group_by(Game.app_id).order_by(func.max(UserGame.playtime_forever).desc(), Game.app_id))
+
+
+def user_names(prefix: str, default_alias: bool = False) -> Completion:
+    """Steam ids and persona names of library users; resource URIs also accept 'default'"""
+    with get_db() as session:
+        users = session.query(UserProfile.steam_id, UserProfile.persona_name).filter(or_(UserProfile.steam_id.like(f"{prefix}%"), UserProfile.persona_name.ilike(f"{prefix}%"))).order_by(UserProfile.persona_name).all()
+    values = ["default"] if default_alias else []
+    for steam_id, persona_name in users:
+        values.append(steam_id)
+        if persona_name:
+            values.append(persona_name)
+    return complete_from(values, prefix)
+
+
+def genre_names(prefix: str) -> Completion:
+    """Genres, most common first"""
+    with get_db() as session:
+        return from_query(session.query(Genre.genre_name).outerjoin(Genre.games).filter(Genre.genre_name.ilike(f"{prefix}%")).group_by(Genre.genre_id, Genre.genre_name).order_by(func.count(Game.app_id).desc(), Genre.genre_name))
+
+
+def tag_names(prefix: str) -> Completion:
+    """Community tags, most common first"""
+    with get_db() as session:
+        return from_query(session.query(Tag.tag_name).outerjoin(Tag.games).filter(Tag.tag_name.ilike(f"{prefix}%")).group_by(Tag.tag_id, Tag.tag_name).order_by(func.count(Game.app_id).desc(), Tag.tag_name))
+
+
+def choices(*values: str):
+    """Completer for an argument with a fixed set of values"""
+    return lambda prefix: complete_from(list(values), prefix)
+
+
+# Completers for each prompt's arguments
+PROMPT_ARGUMENTS = {
+    "family_games": {"child_age": choices("3", "5", "7", "8", "10", "12", "13", "16", "18")},
+    "quick_session": {"minutes_available": choices("15", "30", "45", "60", "90", "120")},
+    "similar_games": {"game_name": game_names},
+    "natural_search": {"query": choices("relaxing puzzle games", "unplayed gems", "family games", "co-op games", "quick session", "story rich adventures")},
+    "gaming_insights": {"analysis_type": choices("patterns", "gaps", "value", "social", "achievements", "trends")},
+    "mood_games": {"mood": choices(*MOODS)},
+    "explore_genre": {"genre_name": genre_names},
+    "elicitation_guide": {"tool_name": choices("find_games_with_preferences", "recommend_games")},
+    "tool_usage_patterns": {"scenario": choices("discovery", "family", "quick_play")},
+}
+
+# Completers for resource template parameters, whichever template they appear in
+TEMPLATE_PARAMETERS = {
+    "user_id": lambda prefix: user_names(prefix, default_alias=True),
+    "game_id": game_ids,
+    "genre_name": genre_names,
+    "tag_name": tag_names,
+    "platform": choices("windows", "mac", "linux", "vr"),
+    "type": choices("coop", "pvp", "local", "online"),
+}
+
 
 @mcp.completion()
-async def tool_argument_completions(
-    ref: PromptReference | ResourceTemplateReference,
-    argument: CompletionArgument,
-    context: CompletionContext | None,
-) -> Completion | None:
-    """Provide intelligent completions for tool arguments."""
-
-    # Handle tool-specific completions
-    if hasattr(ref, "toolName"):
-        tool_name = ref.toolName
-
-        # Smart search completions
-        if tool_name == "smart_search":
-            if argument.name == "query":
-                # Provide popular searches and game names from database
-                try:
-                    with get_db() as session:
-                        # Get top 5 games by playtime
-                        top_games = session.query(Game.name).join(UserGame).order_by(UserGame.playtime_forever.desc()).limit(5).all()
-
-                        suggestions = [g[0] for g in top_games]
-                        suggestions.extend(["unplayed gems", "family games", "quick session", "relaxing", "action", "indie"])
-
-                        matching = [s for s in suggestions if s.lower().startswith(argument.value.lower())]
-                        return Completion(values=matching[:10], hasMore=len(matching) > 10)
-                except Exception:
-                    pass  # Fallback to basic suggestions
-
-                return Completion(values=["unplayed gems", "family games", "multiplayer", "coop", "puzzle", "action"], hasMore=False)
-
-            elif argument.name == "filters":
-                # Provide filter templates
-                templates = ['{"genres": ["Action"], "categories": ["Single-player"]}', '{"tags": ["Roguelike"], "playtime": "played"}', '{"categories": ["Co-op", "Multi-player"]}', '{"playtime": "unplayed", "tags": ["Indie"]}', '{"genres": ["Puzzle", "Casual"], "playtime": "any"}']
-                return Completion(values=templates, hasMore=False)
-
-            elif argument.name == "sort_by":
-                options = ["relevance", "playtime", "metacritic", "recent", "random"]
-                matching = [o for o in options if o.startswith(argument.value.lower())]
-                return Completion(values=matching, hasMore=False)
-
-        # Recommendation engine completions
-        elif tool_name == "recommend_games":
-            if argument.name == "context":
-                contexts = ["family", "quick_session", "similar_to", "mood_based", "unplayed_gems", "abandoned"]
-                matching = [c for c in contexts if c.startswith(argument.value.lower())]
-                return Completion(values=matching, hasMore=False)
-
-            elif argument.name == "parameters":
-                # Context-specific parameter templates
-                if "family" in argument.value.lower():
-                    return Completion(values=['{"age": 8, "players": 1}', '{"age": 12, "players": 2, "content_concerns": ["violence"]}', '{"age": 5, "content_concerns": ["scary", "complex"]}'], hasMore=False)
-                elif "quick_session" in argument.value.lower():
-                    return Completion(values=['{"minutes": 15}', '{"minutes": 30}', '{"minutes": 60}'], hasMore=False)
-                elif "similar_to" in argument.value.lower():
-                    try:
-                        with get_db() as session:
-                            # Get popular game names for similar_to
-                            popular_games = session.query(Game.name).join(UserGame).filter(UserGame.playtime_forever > 300).order_by(UserGame.playtime_forever.desc()).limit(8).all()  # 5+ hours
-
-                            game_templates = [f'{{"game": "{g[0]}"}}' for g in popular_games]
-                            return Completion(values=game_templates, hasMore=False)
-                    except Exception:
-                        pass
-                    return Completion(values=['{"game": "Portal"}', '{"game": "Stardew Valley"}', '{"game": "Terraria"}'], hasMore=False)
-                elif "mood_based" in argument.value.lower():
-                    return Completion(values=['{"mood": "relaxing"}', '{"mood": "energetic"}', '{"mood": "competitive"}', '{"mood": "social"}', '{"mood": "creative"}', '{"mood": "story"}'], hasMore=False)
-
-        # Library insights completions
-        elif tool_name == "get_library_insights":
-            if argument.name == "analysis_type":
-                types = ["patterns", "gaps", "value", "social", "achievements", "trends"]
-                matching = [t for t in types if t.startswith(argument.value.lower())]
-                return Completion(values=matching, hasMore=False)
-
-            elif argument.name == "compare_to":
-                options = ["friends", "global", "genre_average"]
-                matching = [o for o in options if o.startswith(argument.value.lower())]
-                return Completion(values=matching, hasMore=False)
-
-            elif argument.name == "time_range":
-                ranges = ["all", "recent", "last_month"]
-                matching = [r for r in ranges if r.startswith(argument.value.lower())]
-                return Completion(values=matching, hasMore=False)
-
-        # Legacy tool completions (kept for backwards compatibility)
-        elif argument.name == "child_age" and tool_name == "find_family_games":
-            return Completion(values=["3", "5", "7", "10", "13", "16"], hasMore=False)
-
-        elif argument.name == "session_length" and tool_name == "find_quick_session_games":
-            return Completion(values=["short", "medium", "long"], hasMore=False)
-
-        # Generic user completions for all tools
-        elif argument.name == "user":
-            try:
-                with get_db() as session:
-                    from shared.database import UserProfile
-
-                    users = session.query(UserProfile).all()
-
-                    user_suggestions = []
-                    for user in users:
-                        # Add both steam_id and persona_name as options
-                        user_suggestions.append(user.steam_id)
-                        if user.persona_name:
-                            user_suggestions.append(user.persona_name)
-
-                    matching = [u for u in user_suggestions if u.lower().startswith(argument.value.lower())]
-                    return Completion(values=matching[:10], hasMore=len(matching) > 10)
-            except Exception:
-                pass  # Fall back to no suggestions
-
-    return None
+async def argument_completions(ref: PromptReference | ResourceTemplateReference, argument: CompletionArgument, context: CompletionContext | None) -> Completion | None:
+    """Complete a prompt argument or resource template parameter"""
+    if isinstance(ref, PromptReference):
+        completer = PROMPT_ARGUMENTS.get(ref.name, {}).get(argument.name)
+    else:
+        completer = TEMPLATE_PARAMETERS.get(argument.name) if f"{{{argument.name}}}" in ref.uri else None
+
+    if completer is None:
+        return None
+    return completer(argument.value)
@@ -12,12 +12,39 @@ class Config:
     host: str = os.getenv("MCP_HOST", "127.0.0.1")
     port: int = int(os.getenv("MCP_PORT", "8000"))
 
+    # "http" (streamable HTTP) or "stdio" for hosts that start the server as a subprocess
+    transport: str = os.getenv("MCP_TRANSPORT", "http").lower()
+
+    # HTTP access tokens, "TOKEN=SCOPE; ..." with scope read or sync (see auth.py); empty leaves the server open
+    auth_tokens: str = os.getenv("MCP_AUTH_TOKENS", "")
+
     # Default user for single-user mode
     default_user: str = os.getenv("DEFAULT_USER", "default")
 
     # Database
     database_url: str = os.getenv("DATABASE_URL", "sqlite:///steam_library.db")
 
+    # Fetcher runs started by the server that may execute at once (see sync.py)
+    sync_workers: int = max(1, int(os.getenv("SYNC_WORKERS", "1")))
+
+    # Steam requests syncs may make per UTC day; 0 disables the budget
+    steam_api_daily_budget: int = max(0, int(os.getenv("STEAM_API_DAILY_BUDGET", "0")))
+
+    # Cron schedules for syncs started by the server, "CRON=SCOPE; ..." (see scheduler.py)
+    sync_schedule: str = os.getenv("SYNC_SCHEDULE", "")
+
+    # After a restart, scheduled runs missed within this many seconds still run
+    scheduler_catch_up: int = max(0, int(os.getenv("SCHEDULER_CATCH_UP", "3600")))
+
+    # Scheduled syncs queued or running at once; 0 leaves it to SYNC_WORKERS
+    max_concurrent_auto_syncs: int = max(0, int(os.getenv("MAX_CONCURRENT_AUTO_SYNCS", "0")))
+
+    # Percent of the daily request budget scheduled syncs leave for manual ones
+    scheduler_quota_reserve: int = min(100, max(0, int(os.getenv("SCHEDULER_QUOTA_RESERVE", "20"))))
+
+    # Items per page of paginated list resources (library://users, library://users/{user_id}/games)
+    resource_page_size: int = max(1, int(os.getenv("RESOURCE_PAGE_SIZE", "100")))
+
     # Debug mode
     debug: bool = os.getenv("DEBUG", "false").lower() == "true"
 
@@ -0,0 +1,96 @@
+"""Application events for the /api/events Server-Sent Events feed
+
+The sync queue and the scheduler publish what they do here, and each open
+/api/events stream gets a copy, so dashboards and scripts can follow syncs
+without polling. Event types are dotted, with the part before the dot naming
+where they come from:
+
+- sync.queued, sync.started, sync.paused, sync.resumed, sync.cancelled,
+  sync.finished, sync.failed: a sync's lifecycle, with the job as data
+- sync.conflict: a sync was refused because the same one is already queued or running
+- scheduler.queued, scheduler.skipped: a scheduled or one-shot sync was queued or skipped
+- scheduler.paused, scheduler.resumed, scheduler.blackout_added,
+  scheduler.blackout_removed, scheduler.one_shot_scheduled,
+  scheduler.one_shot_cancelled: changes to the scheduler
+
+Each event has an increasing id, its type, a timestamp, and its data. Events are
+published from worker threads and handed to each stream's event loop, and a
+stream that falls too far behind loses events rather than holding up syncs. The
+last EVENT_HISTORY events are kept so a client that reconnects with Last-Event-ID
+gets what it missed.
+"""
+
+import asyncio
+import itertools
+import json
+import logging
+import threading
+import time
+from collections import deque
+
+logger = logging.getLogger(__name__)
+
+# Events kept for clients that reconnect with Last-Event-ID
+EVENT_HISTORY = 200
+
+
+def matches(event: dict, types: list[str]) -> bool:
+    """Whether an event is one of types, each an exact type ("sync.failed") or a source ("sync")"""
+    return not types or any(event["type"] == wanted or event["type"].startswith(f"{wanted}.") for wanted in types)
+
+
+def format_sse(event: dict) -> str:
+    """An event as a Server-Sent Events message"""
+    return f"id: {event['id']}\nevent: {event['type']}\ndata: {json.dumps(event)}\n\n"
+
+
+class EventBus:
+    """Fans published events out to the open streams"""
+
+    def __init__(self, history: int = EVENT_HISTORY):
+        self._ids = itertools.count(1)
+        self._history: deque[dict] = deque(maxlen=history)
+        self._streams: dict[asyncio.Queue, asyncio.AbstractEventLoop] = {}
+        self._lock = threading.Lock()
+
+    def publish(self, event_type: str, data: dict) -> dict:
+        """Record an event and pass it to every open stream; safe to call from any thread"""
+        with self._lock:
+            event = {"id": next(self._ids), "type": event_type, "timestamp": time.time(), "data": data}
+            self._history.append(event)
+            streams = list(self._streams.items())
+        for queue, loop in streams:
+            try:
+                loop.call_soon_threadsafe(self._deliver, queue, event)
+            except RuntimeError:
+                # The stream's event loop has shut down
+                self.unsubscribe(queue)
+        return event
+
+    @staticmethod
+    def _deliver(queue: asyncio.Queue, event: dict):
+        try:
+            queue.put_nowait(event)
+        except asyncio.QueueFull:
+            logger.debug(f"Dropping {event['type']} event for a slow stream")
+
+    def subscribe(self, maxsize: int = 1000) -> asyncio.Queue:
+        """A queue receiving every event published from now on; call from the stream's event loop"""
+        queue: asyncio.Queue = asyncio.Queue(maxsize=maxsize)
+        with self._lock:
+            self._streams[queue] = asyncio.get_running_loop()
+        return queue
+
+    def unsubscribe(self, queue: asyncio.Queue):
+        """Stop delivering events to a stream's queue"""
+        with self._lock:
+            self._streams.pop(queue, None)
+
+    def since(self, event_id: int) -> list[dict]:
+        """Kept events published after event_id, oldest first"""
+        with self._lock:
+            return [event for event in self._history if event["id"] > event_id]
+
+
+# Published to by the sync queue and scheduler, read by /api/events
+events = EventBus()
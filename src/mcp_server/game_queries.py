@@ -0,0 +1,95 @@
+"""Query options for listing games
+
+GameQuery collects the filters and sort order a game list was asked for, and
+query_games turns them into one database query, so the /api/games route (and
+anything else listing games) doesn't build its own joins. Without a library the
+whole catalogue is listed; with one, only games that library owns, along with
+its playtime and last-played time.
+
+Release dates are stored as Steam shows them ("21 Oct, 2008", "Oct 21, 2008",
+"Coming soon"), which don't sort as text, so that sort happens after loading.
+"""
+
+from dataclasses import dataclass
+from datetime import datetime
+
+from sqlalchemy import null, or_
+
+from shared.database import Game, GameReview, Genre, Tag, UserGame
+
+from .backlog import REVIEW_PERCENT
+
+SORTS = ("name", "playtime", "review_score", "release_date", "last_played")
+ORDERS = ("asc", "desc")
+
+# Sorts that need a library's playtime
+LIBRARY_SORTS = ("playtime", "last_played")
+
+RELEASE_DATE_FORMATS = ("%d %b, %Y", "%b %d, %Y", "%d %B, %Y", "%B %d, %Y", "%b %Y", "%B %Y", "%Y")
+
+
+@dataclass
+class GameQuery:
+    """Filters and sort order for a game list"""
+
+    steam_id: str | None = None
+    genre: str | None = None
+    tag: str | None = None
+    min_playtime: float | None = None  # Hours
+    on_sale: bool | None = None
+    sort_by: str = "name"
+    order: str = "asc"
+
+    def validate(self):
+        """Raise ValueError for options that can't be combined into a query"""
+        if self.sort_by not in SORTS:
+            raise ValueError(f"sort_by must be one of: {', '.join(SORTS)}")
+        if self.order not in ORDERS:
+            raise ValueError(f"order must be one of: {', '.join(ORDERS)}")
+        if not self.steam_id and self.sort_by in LIBRARY_SORTS:
+            raise ValueError(f"sort_by={self.sort_by} needs a library")
+        if not self.steam_id and self.min_playtime is not None:
+            raise ValueError("min_playtime needs a library")
+
+
+def query_games(session, options: GameQuery):
+    """Query of (Game, UserGame) rows matching options, sorted; UserGame is None without a library"""
+    options.validate()
+    if options.steam_id:
+        games = session.query(Game, UserGame).join(UserGame, (Game.app_id == UserGame.app_id) & (UserGame.steam_id == options.steam_id)).filter(UserGame.removed_at.is_(None))
+    else:
+        games = session.query(Game, null().label("user_game"))
+    games = games.outerjoin(GameReview, GameReview.app_id == Game.app_id)
+
+    if options.genre:
+        games = games.filter(Game.genres.any(Genre.genre_name.ilike(options.genre)))
+    if options.tag:
+        games = games.filter(Game.tags.any(Tag.tag_name.ilike(options.tag)))
+    if options.min_playtime is not None:
+        games = games.filter(UserGame.playtime_forever >= options.min_playtime * 60)
+    if options.on_sale is not None:
+        games = games.filter(Game.discount_percent > 0 if options.on_sale else or_(Game.discount_percent.is_(None), Game.discount_percent == 0))
+
+    column = {"name": Game.name, "playtime": UserGame.playtime_forever, "review_score": REVIEW_PERCENT, "last_played": UserGame.last_played}.get(options.sort_by)
+    if column is None:
+        # release_date: see sort_by_release_date
+        return games.order_by(Game.app_id)
+    ordered = column.desc() if options.order == "desc" else column.asc()
+    return games.order_by(ordered.nulls_last(), Game.app_id)
+
+
+def release_date_key(release_date: str | None) -> datetime | None:
+    """Date a Steam release date string stands for, or None for unknown dates such as Coming soon"""
+    for date_format in RELEASE_DATE_FORMATS:
+        try:
+            return datetime.strptime((release_date or "").strip(), date_format)
+        except ValueError:
+            continue
+    return None
+
+
+def sort_by_release_date(rows: list, order: str = "asc") -> list:
+    """(Game, UserGame) rows by release date, with unknown dates last in either order"""
+    dated = [row for row in rows if release_date_key(row[0].release_date)]
+    undated = [row for row in rows if not release_date_key(row[0].release_date)]
+    return sorted(dated, key=lambda row: release_date_key(row[0].release_date), reverse=order == "desc") + undated
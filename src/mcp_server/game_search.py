@@ -0,0 +1,143 @@
+"""Full-text game search
+
+Searches game names, short descriptions, developers, publishers, genres, and
+community tags, best matches first. A match in the name counts most, then
+developers, genres, and tags, then publishers, then the description.
+
+The search runs on whatever the database offers:
+
+- SQLite: an FTS5 table, games_fts, ranked with bm25. The fetcher writes games
+  from its own process, so the index is rebuilt from the games tables whenever
+  they've changed since the last search (a rebuild takes well under a second
+  for a few thousand games). Every word must match, and words match as
+  prefixes, so "port" finds Portal.
+- PostgreSQL: weighted tsvectors ranked with ts_rank, queried with
+  websearch_to_tsquery, so quoted phrases and -exclusions work.
+- Anything else, or SQLite built without FTS5: case-insensitive substring
+  matching, scored by which fields match each word.
+"""
+
+import logging
+import re
+import threading
+
+from sqlalchemy import or_, text
+from sqlalchemy.exc import OperationalError
+
+from shared.database import Developer, Game, Genre, Publisher, Tag
+
+logger = logging.getLogger(__name__)
+
+# How much a match in each field counts toward a game's score
+WEIGHTS = {"name": 10.0, "description": 1.0, "developers": 4.0, "publishers": 2.0, "genres": 3.0, "tags": 3.0}
+
+SQLITE_CREATE = "CREATE VIRTUAL TABLE IF NOT EXISTS games_fts USING fts5(app_id UNINDEXED, name, description, developers, publishers, genres, tags, tokenize='unicode61 remove_diacritics 2')"
+
+SQLITE_FILL = """
+INSERT INTO games_fts (app_id, name, description, developers, publishers, genres, tags)
+SELECT g.app_id, g.name, coalesce(g.short_description, ''),
+    coalesce((SELECT group_concat(d.developer_name, ' ') FROM game_developers gd JOIN developers d ON d.developer_id = gd.developer_id WHERE gd.app_id = g.app_id), ''),
+    coalesce((SELECT group_concat(p.publisher_name, ' ') FROM game_publishers gp JOIN publishers p ON p.publisher_id = gp.publisher_id WHERE gp.app_id = g.app_id), ''),
+    coalesce((SELECT group_concat(ge.genre_name, ' ') FROM game_genres gg JOIN genres ge ON ge.genre_id = gg.genre_id WHERE gg.app_id = g.app_id), ''),
+    coalesce((SELECT group_concat(t.tag_name, ' ') FROM game_tags gt JOIN tags t ON t.tag_id = gt.tag_id WHERE gt.app_id = g.app_id), '')
+FROM games g
+"""
+
+# Anything about the games tables that changes when a sync adds or updates games
+SQLITE_FINGERPRINT = "SELECT (SELECT count(*) FROM games), (SELECT max(last_updated) FROM games), (SELECT count(*) FROM game_developers), (SELECT count(*) FROM game_publishers), (SELECT count(*) FROM game_genres), (SELECT count(*) FROM game_tags)"
+
+SQLITE_SEARCH = f"SELECT app_id, -bm25(games_fts, 0.0, {WEIGHTS['name']}, {WEIGHTS['description']}, {WEIGHTS['developers']}, {WEIGHTS['publishers']}, {WEIGHTS['genres']}, {WEIGHTS['tags']}) AS score FROM games_fts WHERE games_fts MATCH :query ORDER BY score DESC, app_id"
+
+POSTGRES_SEARCH = """
+SELECT app_id, ts_rank(document, query) AS score
+FROM (
+    SELECT g.app_id,
+        setweight(to_tsvector('english', coalesce(g.name, '')), 'A')
+        || setweight(to_tsvector('english', coalesce((SELECT string_agg(d.developer_name, ' ') FROM game_developers gd JOIN developers d ON d.developer_id = gd.developer_id WHERE gd.app_id = g.app_id), '')), 'B')
+        || setweight(to_tsvector('english', coalesce((SELECT string_agg(ge.genre_name, ' ') FROM game_genres gg JOIN genres ge ON ge.genre_id = gg.genre_id WHERE gg.app_id = g.app_id), '')), 'B')
+        || setweight(to_tsvector('english', coalesce((SELECT string_agg(t.tag_name, ' ') FROM game_tags gt JOIN tags t ON t.tag_id = gt.tag_id WHERE gt.app_id = g.app_id), '')), 'B')
+        || setweight(to_tsvector('english', coalesce((SELECT string_agg(p.publisher_name, ' ') FROM game_publishers gp JOIN publishers p ON p.publisher_id = gp.publisher_id WHERE gp.app_id = g.app_id), '')), 'C')
+        || setweight(to_tsvector('english', coalesce(g.short_description, '')), 'D') AS document
+    FROM games g
+) documents, websearch_to_tsquery('english', :query) query
+WHERE document @@ query
+ORDER BY score DESC, app_id
+"""
+
+_index_lock = threading.Lock()
+_index_fingerprint = None
+_fts5_available = True
+
+
+def search_terms(query: str) -> list[str]:
+    """Words in a search query"""
+    return re.findall(r"\w+", query.lower())
+
+
+def fts5_query(terms: list[str]) -> str:
+    """FTS5 MATCH expression requiring every term, each as a prefix"""
+    return " ".join(f'"{term}"*' for term in terms)
+
+
+def refresh_sqlite_index(session):
+    """Create games_fts, and rebuild it if the games tables changed since the last rebuild"""
+    global _index_fingerprint
+    with _index_lock:
+        # Keyed on the database too, so switching databases (as the tests do) rebuilds the index
+        fingerprint = (str(session.get_bind().url), *session.execute(text(SQLITE_FINGERPRINT)).one())
+        if fingerprint == _index_fingerprint:
+            return
+        session.execute(text(SQLITE_CREATE))
+        session.execute(text("DELETE FROM games_fts"))
+        session.execute(text(SQLITE_FILL))
+        session.commit()
+        _index_fingerprint = fingerprint
+        logger.info(f"Rebuilt the game search index ({fingerprint[1]} games)")
+
+
+def search_sqlite(session, terms: list[str]) -> list[tuple[int, float]]:
+    refresh_sqlite_index(session)
+    return [(app_id, score) for app_id, score in session.execute(text(SQLITE_SEARCH), {"query": fts5_query(terms)})]
+
+
+def search_postgres(session, query: str) -> list[tuple[int, float]]:
+    return [(app_id, score) for app_id, score in session.execute(text(POSTGRES_SEARCH), {"query": query})]
+
+
+def search_substrings(session, terms: list[str]) -> list[tuple[int, float]]:
+    """Fallback search: every term must appear somewhere, scored by the fields it appears in"""
+    fields = {"name": lambda pattern: Game.name.ilike(pattern), "description": lambda pattern: Game.short_description.ilike(pattern), "developers": lambda pattern: Game.developers.any(Developer.developer_name.ilike(pattern)), "publishers": lambda pattern: Game.publishers.any(Publisher.publisher_name.ilike(pattern)), "genres": lambda pattern: Game.genres.any(Genre.genre_name.ilike(pattern)), "tags": lambda pattern: Game.tags.any(Tag.tag_name.ilike(pattern))}
+    games = session.query(Game.app_id)
+    for term in terms:
+        games = games.filter(or_(*(match(f"%{term}%") for match in fields.values())))
+    candidates = [app_id for (app_id,) in games]
+
+    scores = dict.fromkeys(candidates, 0.0)
+    for term in terms:
+        for field, match in fields.items():
+            for (app_id,) in session.query(Game.app_id).filter(Game.app_id.in_(candidates), match(f"%{term}%")):
+                scores[app_id] += WEIGHTS[field]
+    return sorted(scores.items(), key=lambda item: (-item[1], item[0]))
+
+
+def search_games(session, query: str) -> list[tuple[int, float]]:
+    """(app_id, score) of every game matching query, best match first; higher scores are better"""
+    global _fts5_available
+    terms = search_terms(query)
+    if not terms:
+        raise ValueError("Search query has no words to search for")
+
+    dialect = session.get_bind().dialect.name
+    if dialect == "postgresql":
+        return search_postgres(session, query)
+    if dialect == "sqlite" and _fts5_available:
+        try:
+            return search_sqlite(session, terms)
+        except OperationalError as e:
+            if "fts5" not in str(e):
+                raise
+            # SQLite built without FTS5
+            _fts5_available = False
+            session.rollback()
+            logger.warning(f"Full-text search unavailable, falling back to substring search: {e}")
+    return search_substrings(session, terms)
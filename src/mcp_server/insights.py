@@ -0,0 +1,79 @@
+"""Library-wide statistics computed in the database
+
+Every figure here comes from an aggregate query, so summarizing a library of
+thousands of games never loads its rows into memory. Spend is estimated from each
+game's current list price (price_initial), since purchase prices aren't known;
+games borrowed through Family Sharing and games that left the library don't count.
+"""
+
+from sqlalchemy import case, func
+
+from shared.database import Game, Genre, UserGame
+
+# Playtime percentiles reported for played games
+PERCENTILES = (25, 50, 75, 90)
+
+# Games need this much playtime (minutes) before their cost per hour means anything
+MIN_VALUE_PLAYTIME = 60
+
+
+def owned_games(session, steam_id: str):
+    """Query of the games the user currently owns"""
+    return session.query(UserGame).filter(UserGame.steam_id == steam_id, UserGame.removed_at.is_(None))
+
+
+def playtime_percentiles(session, steam_id: str, played: int) -> dict[str, float]:
+    """Hours of playtime at each percentile of played games, one indexed lookup per percentile"""
+    if not played:
+        return {}
+    ordered = owned_games(session, steam_id).filter(UserGame.playtime_forever > 0).order_by(UserGame.playtime_forever).with_entities(UserGame.playtime_forever)
+    # Nearest-rank percentiles: the smallest value with at least p% of games at or below it
+    return {f"p{p}": round(ordered.offset(max(-(-p * played // 100) - 1, 0)).limit(1).scalar() / 60, 1) for p in PERCENTILES}
+
+
+def genre_distribution(session, steam_id: str, total_minutes: int) -> list[dict]:
+    """Games and playtime per genre, most played first"""
+    rows = session.query(Genre.genre_name, func.count(UserGame.app_id), func.sum(UserGame.playtime_forever), func.sum(case((UserGame.playtime_forever == 0, 1), else_=0))).select_from(UserGame).join(Game, Game.app_id == UserGame.app_id).join(Game.genres).filter(UserGame.steam_id == steam_id, UserGame.removed_at.is_(None)).group_by(Genre.genre_name).order_by(func.sum(UserGame.playtime_forever).desc()).all()
+    return [{"genre": name, "games": games, "playtime_hours": round((minutes or 0) / 60, 1), "playtime_share": round((minutes or 0) / total_minutes * 100, 1) if total_minutes else 0.0, "never_played": unplayed} for name, games, minutes, unplayed in rows]
+
+
+def completion_rates(session, steam_id: str) -> dict:
+    """Achievement completion across games that have achievements"""
+    with_achievements = owned_games(session, steam_id).filter(UserGame.achievements_total > 0)
+    games, average, perfect, started = with_achievements.with_entities(func.count(), func.avg(UserGame.completion_percent), func.sum(case((UserGame.achievements_unlocked >= UserGame.achievements_total, 1), else_=0)), func.sum(case((UserGame.achievements_unlocked > 0, 1), else_=0))).one()
+    return {"games_with_achievements": games, "average_completion_percent": round(average, 1) if average is not None else None, "perfect_games": perfect or 0, "games_with_unlocks": started or 0}
+
+
+def spend_value(session, steam_id: str) -> dict:
+    """Estimated spend against playtime, and the best and worst value games"""
+    purchased = owned_games(session, steam_id).join(Game, Game.app_id == UserGame.app_id).filter(UserGame.shared.isnot(True), Game.price_initial > 0)
+    priced, spend, spend_minutes, unplayed_spend = purchased.with_entities(func.count(), func.sum(Game.price_initial), func.sum(UserGame.playtime_forever), func.sum(case((UserGame.playtime_forever == 0, Game.price_initial), else_=0))).one()
+    currency = purchased.with_entities(Game.currency).group_by(Game.currency).order_by(func.count().desc()).limit(1).scalar()
+
+    cost_per_hour = Game.price_initial * 60.0 / UserGame.playtime_forever
+    valued = purchased.filter(UserGame.playtime_forever >= MIN_VALUE_PLAYTIME).with_entities(Game.app_id, Game.name, Game.price_initial, UserGame.playtime_forever)
+
+    def ranked(order) -> list[dict]:
+        return [{"app_id": app_id, "name": name, "price": round(price / 100, 2), "playtime_hours": round(minutes / 60, 1), "cost_per_hour": round(price / 100 / (minutes / 60), 2)} for app_id, name, price, minutes in valued.order_by(order).limit(5)]
+
+    spend_hours = (spend_minutes or 0) / 60
+    return {"currency": currency, "priced_games": priced, "estimated_spend": round((spend or 0) / 100, 2), "unplayed_spend": round((unplayed_spend or 0) / 100, 2), "cost_per_hour": round((spend or 0) / 100 / spend_hours, 2) if spend_hours else None, "best_value": ranked(cost_per_hour.asc()), "worst_value": ranked(cost_per_hour.desc())}
+
+
+def library_insights(session, steam_id: str) -> dict:
+    """Genre distribution, playtime percentiles, completion rates, spend vs. playtime, and never-played counts"""
+    total, played, total_minutes, recent_minutes = owned_games(session, steam_id).with_entities(func.count(), func.sum(case((UserGame.playtime_forever > 0, 1), else_=0)), func.sum(UserGame.playtime_forever), func.sum(UserGame.playtime_2weeks)).one()
+    played, total_minutes = played or 0, total_minutes or 0
+
+    return {
+        "total_games": total,
+        "games_played": played,
+        "never_played": total - played,
+        "never_played_percent": round((total - played) / total * 100, 1) if total else 0.0,
+        "total_playtime_hours": round(total_minutes / 60, 1),
+        "recent_playtime_hours": round((recent_minutes or 0) / 60, 1),
+        "playtime_percentiles_hours": playtime_percentiles(session, steam_id, played),
+        "genres": genre_distribution(session, steam_id, total_minutes),
+        "completion": completion_rates(session, steam_id),
+        "value": spend_value(session, steam_id),
+    }
@@ -0,0 +1,65 @@
+"""MCP logging capability
+
+A client picks a minimum level with logging/setLevel and from then on receives
+the server's log records at or above it as notifications/message, so it can see
+sync failures and scheduler errors as they happen. Records come from the
+mcp_server loggers only; sessions that never set a level get no notifications.
+
+Sync workers and the scheduler log from their own threads, so records are handed
+to the event loop of the session they're sent to.
+"""
+
+import logging
+
+from mcp.types import LoggingLevel
+
+from .server import mcp
+from .sessions import LiveSessions
+
+# MCP log levels (RFC 5424 severities) mapped onto Python logging levels
+MCP_LEVELS = {"debug": logging.DEBUG, "info": logging.INFO, "notice": logging.INFO + 5, "warning": logging.WARNING, "error": logging.ERROR, "critical": logging.CRITICAL, "alert": logging.CRITICAL + 5, "emergency": logging.CRITICAL + 10}
+
+
+def mcp_level(levelno: int) -> LoggingLevel:
+    """The most severe MCP level a Python log level reaches"""
+    return max((level for level, number in MCP_LEVELS.items() if number <= levelno), key=MCP_LEVELS.get, default="debug")
+
+
+class SessionLogHandler(logging.Handler):
+    """Forwards log records to the MCP sessions that asked for them"""
+
+    def __init__(self):
+        super().__init__()
+        # Minimum level of each session
+        self.sessions = LiveSessions()
+
+    def subscribe(self, session, level: LoggingLevel):
+        self.sessions.set(session, MCP_LEVELS[level])
+
+    def unsubscribe(self, session):
+        self.sessions.discard(session)
+
+    def emit(self, record: logging.LogRecord):
+        targets = [session for session, levelno in self.sessions.items() if record.levelno >= levelno]
+        if not targets:
+            return
+
+        try:
+            message = self.format(record)
+        except Exception:
+            self.handleError(record)
+            return
+
+        for session in targets:
+            self.sessions.send(session, session.send_log_message(level=mcp_level(record.levelno), data=message, logger=record.name))
+
+
+session_log_handler = SessionLogHandler()
+session_log_handler.setFormatter(logging.Formatter("%(message)s"))
+logging.getLogger("mcp_server").addHandler(session_log_handler)
+
+
+@mcp._mcp_server.set_logging_level()
+async def set_logging_level(level: LoggingLevel) -> None:
+    """Send this session the server's log records at or above level"""
+    session_log_handler.subscribe(mcp._mcp_server.request_context.session, level)
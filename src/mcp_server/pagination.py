@@ -0,0 +1,62 @@
+"""Cursor pagination for list responses
+
+Large libraries don't fit in one response, so list resources and API routes return
+a page at a time with a nextCursor for the following page. Cursors are opaque to
+clients: they're the offset of the next item, base64-encoded so nobody is tempted
+to build one by hand. A page that reaches the end has no nextCursor.
+"""
+
+import base64
+import binascii
+import json
+
+from mcp.types import Annotations, CallToolResult, TextContent
+
+
+def encode_cursor(offset: int) -> str:
+    """Cursor pointing at the item at offset"""
+    return base64.urlsafe_b64encode(json.dumps({"offset": offset}).encode()).decode().rstrip("=")
+
+
+def decode_cursor(cursor: str | None) -> int:
+    """Offset a cursor points at; a missing cursor means the first page"""
+    if not cursor:
+        return 0
+    try:
+        offset = json.loads(base64.urlsafe_b64decode(cursor + "=" * (-len(cursor) % 4)))["offset"]
+    except (binascii.Error, ValueError, KeyError, TypeError):
+        raise ValueError(f"Invalid cursor: {cursor}") from None
+    if not isinstance(offset, int) or offset < 0:
+        raise ValueError(f"Invalid cursor: {cursor}")
+    return offset
+
+
+def page_limit(limit: int | str | None, default: int, maximum: int) -> int:
+    """Page size from a client-supplied limit, clamped to 1..maximum"""
+    if limit in (None, ""):
+        return default
+    try:
+        return min(max(int(limit), 1), maximum)
+    except ValueError:
+        raise ValueError(f"Invalid limit: {limit}") from None
+
+
+def paginate(items, cursor: str | None, limit: int) -> tuple[list, str | None]:
+    """One page of a list or SQLAlchemy query, and the cursor of the next page (None on the last)"""
+    offset = decode_cursor(cursor)
+    if isinstance(items, list):
+        page = items[offset : offset + limit + 1]
+    else:
+        page = items.offset(offset).limit(limit + 1).all()
+    # Fetching one extra item tells whether there's another page without counting
+    if len(page) > limit:
+        return page[:limit], encode_cursor(offset + limit)
+    return page, None
+
+
+def page_result(lines: list[str], data: dict, next_cursor: str | None) -> CallToolResult:
+    """Tool result for one page, telling the client how to get the next one when there is one"""
+    if next_cursor:
+        lines = [*lines, f"\nMore results: call again with cursor=\"{next_cursor}\""]
+        data = {**data, "nextCursor": next_cursor}
+    return CallToolResult(content=[TextContent(type="text", text="\n".join(lines), annotations=Annotations(audience=["user"], priority=0.8))], structuredContent=data, isError=False)
@@ -0,0 +1,324 @@
+"""Ranked game suggestions for a play session
+
+A session context describes what the player is after right now: a mood, how long
+they have, how many friends are playing along, and whether it has to be family
+friendly. score_game() rates one owned game against it and gives a reason for every
+point it awards, so recommendations can explain themselves. rank_library() scores a
+whole library and returns the best matches.
+"""
+
+import re
+from dataclasses import dataclass
+
+from sqlalchemy import func, or_, select
+from sqlalchemy.orm import joinedload
+
+from shared.database import Category, FriendGame, Game, UserGame, friends_association, get_db
+
+# Tags, genres, and categories that suit each mood
+MOODS = {"relaxing": {"tags": ["Casual", "Puzzle", "Atmospheric", "Zen"], "genres": ["Casual", "Indie"]}, "energetic": {"tags": ["Fast-Paced", "Action", "Arcade", "Bullet Hell"], "genres": ["Action"]}, "competitive": {"tags": ["PvP", "Competitive", "Esports"], "categories": ["Multi-player", "PvP"]}, "social": {"tags": ["Co-op", "Party Game"], "categories": ["Multi-player", "Co-op"]}, "creative": {"tags": ["Building", "Sandbox", "Creative"], "genres": ["Simulation"]}, "story": {"tags": ["Story Rich", "Narrative"], "genres": ["Adventure", "RPG"]}}
+
+# Tags for sessions of two hours or more, where a long campaign is fine
+LONG_SESSION_TAGS = ["Story Rich", "Open World", "Exploration", "RPG", "Strategy", "Simulation"]
+
+# Tags that don't fit a short session
+LONG_HAUL_TAGS = ["Open World", "Story Rich", "Grand Strategy", "MMORPG"]
+
+# Categories for playing with others, on one screen or online
+LOCAL_MULTIPLAYER_CATEGORIES = ["Shared/Split Screen", "Shared/Split Screen Co-op", "Shared/Split Screen PvP", "Local Co-op", "Local Multi-Player"]
+MULTIPLAYER_CATEGORIES = ["Multi-player", "Co-op", "Online Co-op", "Online PvP", "PvP", *LOCAL_MULTIPLAYER_CATEGORIES]
+
+# Age assumed for family-friendly sessions when none is given
+DEFAULT_FAMILY_AGE = 8
+
+
+# Amounts in a free-text duration, e.g. "45 minutes", "1.5h", "an hour"
+# An amount and its unit; h and m only count after a digit or number word, so "am" isn't a minute
+DURATION = re.compile(r"\b(?:(\d+(?:\.\d+)?)\s*(hours?|hrs?|h|minutes?|mins?|m)|(one|two|three|half)\s+(hours?|hrs?|h|minutes?|mins?|m)|(an?)\s+(hours?|hrs?|minutes?|mins?))\b")
+DURATION_WORDS = {"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "half": 0.5}
+
+
+def parse_duration(text: str) -> int:
+    """Minutes in a free-text duration such as "I have 45 minutes", "1.5h", "1h30", or "an hour and a half"; a bare number is minutes"""
+    text = text.lower().strip().replace("half an hour", "30 minutes")
+    if re.fullmatch(r"\d+", text):
+        minutes = float(text)
+    elif hours_and_minutes := re.search(r"\b(\d+)\s*h\s*(\d{1,2})\b", text):
+        minutes = int(hours_and_minutes[1]) * 60 + int(hours_and_minutes[2])
+    else:
+        amounts = [tuple(group for group in match if group) for match in DURATION.findall(text)]
+        minutes = sum((DURATION_WORDS.get(amount) or float(amount)) * (60 if unit.startswith("h") else 1) for amount, unit in amounts)
+        if amounts and amounts[-1][1].startswith("h") and "and a half" in text:
+            minutes += 30
+    if not minutes or minutes <= 0:
+        raise ValueError(f"Couldn't read a length of time from '{text}'; try something like '45 minutes' or '2 hours'")
+    return round(minutes)
+
+
+def remaining_minutes(game, user_game) -> int | None:
+    """Minutes left to finish by SteamSpy's median playtime, or None if unknown or already past it"""
+    if not game.steamspy_median_playtime:
+        return None
+    remaining = game.steamspy_median_playtime - (user_game.playtime_forever or 0)
+    return remaining if remaining > 0 else None
+
+
+def quick_session_tags(minutes: int) -> list[str]:
+    """Tags of games that fit a session of the given length"""
+    if minutes <= 15:
+        return ["Arcade", "Casual", "Puzzle", "Score Attack"]
+    if minutes <= 30:
+        return ["Arcade", "Casual", "Puzzle", "Fast-Paced", "Card Game", "Runner"]
+    if minutes < 120:
+        return ["Casual", "Puzzle", "Card Game", "Beat 'em up", "Party Game", "Addictive"]
+    return LONG_SESSION_TAGS
+
+
+def get_max_esrb_for_age(age: int) -> str:
+    if age < 6:
+        return "EC"
+    elif age < 10:
+        return "E"
+    elif age < 13:
+        return "E10+"
+    elif age < 17:
+        return "T"
+    else:
+        return "M"
+
+
+def get_esrb_ratings_up_to(max_rating: str) -> list[str]:
+    ratings = ["EC", "E", "E10+", "T", "M"]
+    return ratings[: ratings.index(max_rating) + 1]
+
+
+def get_max_pegi_for_age(age: int) -> str:
+    if age < 7:
+        return "3"
+    elif age < 12:
+        return "7"
+    elif age < 16:
+        return "12"
+    elif age < 18:
+        return "16"
+    else:
+        return "18"
+
+
+def get_pegi_ratings_up_to(max_rating: str) -> list[str]:
+    ratings = ["3", "7", "12", "16", "18"]
+    return ratings[: ratings.index(max_rating) + 1]
+
+
+def esrb_descriptor_list(descriptors: str | None) -> list[str]:
+    """ESRB content descriptors as stored from the store, one per line or comma-separated"""
+    return [d.strip() for d in (descriptors or "").replace("\r", "\n").replace(",", "\n").split("\n") if d.strip()]
+
+
+def family_friendly_games(session, steam_id: str, max_rating: str, exclude_descriptors: list[str] = (), include_unrated: bool = False):
+    """Query of (Game, UserGame) for owned games rated at most max_rating by the ESRB
+
+    A game is left out when any of its content descriptors contains one of
+    exclude_descriptors, so "Violence" also excludes "Fantasy Violence". Games
+    without an ESRB rating have no descriptors to check and are only included
+    when include_unrated is set.
+    """
+    unrated = or_(Game.esrb_rating.is_(None), Game.esrb_rating == "")
+    rated_ok = Game.esrb_rating.in_(get_esrb_ratings_up_to(max_rating))
+    games = session.query(Game, UserGame).join(UserGame, (Game.app_id == UserGame.app_id) & (UserGame.steam_id == steam_id)).filter(UserGame.removed_at.is_(None), or_(rated_ok, unrated) if include_unrated else rated_ok)
+    for descriptor in exclude_descriptors:
+        games = games.filter(or_(Game.esrb_descriptors.is_(None), ~Game.esrb_descriptors.ilike(f"%{descriptor}%")))
+    return games.order_by(UserGame.playtime_forever.desc(), Game.name)
+
+
+def parse_flag(value) -> bool:
+    """A true/false parameter given as a boolean, 0/1, or text such as "false" (which bool() would count as true)"""
+    if isinstance(value, bool):
+        return value
+    if str(value).lower() in ("true", "1", "yes"):
+        return True
+    if str(value).lower() in ("false", "0", "no", "none", ""):
+        return False
+    raise ValueError(f"Expected true or false, got '{value}'")
+
+
+@dataclass
+class SessionContext:
+    """What the player is looking for right now; unset fields don't affect the ranking"""
+
+    mood: str | None = None
+    minutes: int | None = None
+    friends_present: int = 0  # Other people playing along
+    family_friendly: bool = False
+    age: int | None = None  # Youngest player, for family-friendly sessions
+
+    @classmethod
+    def from_params(cls, params: dict) -> "SessionContext":
+        """Build a context from recommend_games parameters, rejecting values it can't use"""
+        mood = params.get("mood")
+        if mood is not None and mood.lower() not in MOODS:
+            raise ValueError(f"Unknown mood '{mood}'. Choose from: {', '.join(MOODS)}")
+
+        friends = params.get("friends_present", params.get("friends", 0))
+        # Accept "friends_present": true for "someone is playing along"
+        friends = 1 if friends is True else int(friends or 0)
+
+        age = params.get("age")
+        family = parse_flag(params.get("family_friendly", False)) or age is not None
+        minutes = params.get("minutes")
+        if minutes is not None and int(minutes) <= 0:
+            raise ValueError("minutes must be positive")
+
+        return cls(mood=mood.lower() if mood else None, minutes=int(minutes) if minutes is not None else None, friends_present=max(friends, 0), family_friendly=family, age=int(age) if age is not None else None)
+
+    def describe(self) -> str:
+        parts = []
+        if self.mood:
+            parts.append(f"{self.mood} mood")
+        if self.minutes:
+            parts.append(f"{self.minutes} minutes")
+        if self.friends_present:
+            parts.append(f"{self.friends_present} friend{'s' if self.friends_present != 1 else ''} playing")
+        if self.family_friendly:
+            parts.append(f"family friendly (age {self.age or DEFAULT_FAMILY_AGE}+)")
+        return ", ".join(parts) or "no preferences"
+
+
+@dataclass
+class Recommendation:
+    app_id: int
+    name: str
+    score: int
+    reasons: list[str]
+    playtime_hours: float
+
+    def to_dict(self) -> dict:
+        return {"app_id": self.app_id, "name": self.name, "score": self.score, "reasons": self.reasons, "playtime_hours": self.playtime_hours}
+
+
+def suits_age(game, age: int) -> bool:
+    """Whether a game's ESRB or PEGI rating allows the age; unrated games are allowed, like the family context"""
+    if not game.esrb_rating and not game.pegi_rating:
+        return True
+    return game.esrb_rating in get_esrb_ratings_up_to(get_max_esrb_for_age(age)) or game.pegi_rating in get_pegi_ratings_up_to(get_max_pegi_for_age(age))
+
+
+def score_game(game, user_game, context: SessionContext, friends_owning: int = 0) -> Recommendation | None:
+    """Score an owned game for the session, or None if it doesn't fit at all"""
+    tags = {t.tag_name for t in game.tags}
+    genres = {g.genre_name for g in game.genres}
+    categories = {c.category_name for c in game.categories}
+    score = 0
+    reasons = []
+
+    if context.family_friendly:
+        age = context.age or DEFAULT_FAMILY_AGE
+        if not suits_age(game, age):
+            return None
+        rating = " / ".join(r for r in (game.esrb_rating and f"ESRB {game.esrb_rating}", game.pegi_rating and f"PEGI {game.pegi_rating}") if r)
+        reasons.append(f"Rated {rating}, fine for age {age}+" if rating else f"Unrated, check it's fine for age {age}+")
+
+    if context.friends_present:
+        multiplayer = sorted(categories & set(MULTIPLAYER_CATEGORIES))
+        if not multiplayer:
+            return None
+        local = sorted(categories & set(LOCAL_MULTIPLAYER_CATEGORIES))
+        if local:
+            score += 3
+            reasons.append(f"Plays on one screen ({', '.join(local[:2])})")
+        else:
+            score += 2
+            reasons.append(f"Multiplayer ({', '.join(multiplayer[:2])})")
+        if friends_owning:
+            score += 1
+            reasons.append(f"{friends_owning} of your friends own it")
+
+    if context.mood:
+        mapping = MOODS[context.mood]
+        matches = sorted((tags & set(mapping.get("tags", []))) | (genres & set(mapping.get("genres", []))) | (categories & set(mapping.get("categories", []))))
+        if matches:
+            score += 3
+            reasons.append(f"Fits a {context.mood} mood ({', '.join(matches[:3])})")
+
+    if context.minutes:
+        matches = sorted(tags & set(quick_session_tags(context.minutes)))
+        if matches:
+            score += 2
+            reasons.append(f"Suits a {context.minutes}-minute session ({', '.join(matches[:3])})")
+        elif context.minutes <= 30 and tags & set(LONG_HAUL_TAGS):
+            score -= 1
+        remaining = remaining_minutes(game, user_game)
+        if remaining is not None and remaining <= context.minutes:
+            score += 2
+            reasons.append(f"Players typically finish it in about {remaining} more minutes, so it fits this session")
+
+    if user_game.playtime_2weeks:
+        score += 1
+        reasons.append(f"You've played it {user_game.playtime_2weeks / 60:.1f}h in the last two weeks")
+    elif not user_game.playtime_forever:
+        score += 1
+        reasons.append("Unplayed, a chance to try something new")
+
+    if game.reviews and game.reviews.total_reviews and game.reviews.positive_percentage >= 85:
+        score += 1
+        reasons.append(f"{game.reviews.positive_percentage:.0f}% positive reviews")
+
+    if score <= 0:
+        return None
+    return Recommendation(app_id=game.app_id, name=game.name, score=score, reasons=reasons, playtime_hours=round((user_game.playtime_forever or 0) / 60, 1))
+
+
+def friends_owning(session, steam_id: str) -> dict[int, int]:
+    """Number of the user's friends owning each app, from synced friends' libraries"""
+    rows = session.query(FriendGame.app_id, func.count(FriendGame.steam_id)).join(friends_association, friends_association.c.friend_steam_id == FriendGame.steam_id).filter(friends_association.c.user_steam_id == steam_id).group_by(FriendGame.app_id).all()
+    return dict(rows)
+
+
+def rank_library(steam_id: str, context: SessionContext, limit: int = 10) -> list[Recommendation]:
+    """The library's best matches for the session, highest score first"""
+    with get_db() as session:
+        owned = session.query(Game, UserGame).join(UserGame, (Game.app_id == UserGame.app_id) & (UserGame.steam_id == steam_id)).filter(UserGame.removed_at.is_(None)).options(joinedload(Game.genres), joinedload(Game.tags), joinedload(Game.categories), joinedload(Game.reviews)).all()
+        owners = friends_owning(session, steam_id) if context.friends_present else {}
+
+        ranked = [recommendation for game, user_game in owned if (recommendation := score_game(game, user_game, context, owners.get(game.app_id, 0)))]
+
+    ranked.sort(key=lambda r: (-r.score, r.name.lower()))
+    return ranked[:limit]
+
+
+def common_games(session, steam_ids: list[str], multiplayer_only: bool = True) -> list[dict]:
+    """Games every one of steam_ids owns, best first
+
+    Ownership comes from synced libraries and from friends' public game lists, so
+    friends can be compared without syncing their libraries. Games are ranked by the
+    sum of their positive review share and their combined playtime relative to the
+    most played common game, both between 0 and 1. With multiplayer_only, only games
+    whose store details list a multiplayer category count, so games known only from
+    a friend's list, without store details, are left out.
+    """
+    library = session.query(UserGame.steam_id.label("steam_id"), UserGame.app_id.label("app_id"), UserGame.playtime_forever.label("minutes")).filter(UserGame.steam_id.in_(steam_ids), UserGame.removed_at.is_(None))
+    friends = session.query(FriendGame.steam_id, FriendGame.app_id, FriendGame.playtime_forever).filter(FriendGame.steam_id.in_(steam_ids))
+    ownership = library.union_all(friends).subquery()
+    # Someone who is both a synced user and a friend appears twice; their larger playtime counts
+    owners = session.query(ownership.c.steam_id, ownership.c.app_id, func.max(ownership.c.minutes)).group_by(ownership.c.steam_id, ownership.c.app_id).subquery()
+    shared = select(owners.c.app_id).group_by(owners.c.app_id).having(func.count() == len(set(steam_ids)))
+
+    games = session.query(Game).filter(Game.app_id.in_(shared)).options(joinedload(Game.categories), joinedload(Game.reviews))
+    if multiplayer_only:
+        games = games.filter(Game.categories.any(Category.category_name.in_(MULTIPLAYER_CATEGORIES)))
+    games = games.all()
+    if not games:
+        return []
+
+    playtime: dict[int, dict[str, int]] = {}
+    for steam_id, app_id, minutes in session.query(owners).filter(owners.c.app_id.in_([game.app_id for game in games])):
+        playtime.setdefault(app_id, {})[steam_id] = minutes or 0
+    most_played = max(sum(minutes.values()) for minutes in playtime.values()) or 1
+
+    results = []
+    for game in games:
+        minutes = playtime.get(game.app_id, {})
+        review = game.reviews.positive_percentage if game.reviews and game.reviews.total_reviews else None
+        results.append({"app_id": game.app_id, "name": game.name, "score": round((review or 0) / 100 + sum(minutes.values()) / most_played, 2), "combined_playtime_hours": round(sum(minutes.values()) / 60, 1), "playtime_hours": {steam_id: round(m / 60, 1) for steam_id, m in minutes.items()}, "positive_percentage": review, "multiplayer": sorted({c.category_name for c in game.categories} & set(MULTIPLAYER_CATEGORIES))})
+    results.sort(key=lambda game: (-game["score"], game["name"].lower()))
+    return results
@@ -9,16 +9,29 @@ from sqlalchemy.orm import joinedload
 
 from shared.database import (
     Category,
+    FriendGame,
     Game,
+    GameLocalization,
+    GameNews,
     Genre,
+    PriceHistory,
+    ReviewText,
     Tag,
+    UserDLC,
     UserGame,
     UserProfile,
+    WishlistItem,
+    WorkshopItem,
+    friends_association,
     get_db,
+    get_price_summary,
     resolve_user_for_tool,
+    resolve_user_identifier,
 )
 
 from .config import config
+from .insights import library_insights
+from .pagination import paginate
 from .server import mcp
 
 
@@ -87,12 +100,34 @@ def get_game_details(game_id: str) -> TextResourceContents:
                 # Platform Support
                 "platforms": {"windows": game.platforms_windows, "mac": game.platforms_mac, "linux": game.platforms_linux},
                 "vr_support": game.vr_support,
+                "steam_deck_compat": game.steam_deck_compat or "unknown",
                 "controller_support": game.controller_support,
                 # Scores & Reviews
                 "metacritic_score": game.metacritic_score,
                 "metacritic_url": game.metacritic_url,
+                # Community estimates (SteamSpy)
+                "community": {"owners": game.steamspy_owners, "average_playtime_hours": round(game.steamspy_average_playtime / 60, 1) if game.steamspy_average_playtime else None, "median_playtime_hours": round(game.steamspy_median_playtime / 60, 1) if game.steamspy_median_playtime else None},
+                # Pricing
+                "price": {"initial": round(game.price_initial / 100, 2) if game.price_initial is not None else None, "final": round(game.price_final / 100, 2) if game.price_final is not None else None, "discount_percent": game.discount_percent, "currency": game.currency},
             }
 
+            # Compare against recorded prices so deals stand out
+            price_summary = get_price_summary(session, game)
+            if price_summary:
+                game_data["price"].update({"lowest_ever": round(price_summary["lowest"] / 100, 2), "is_historical_low": price_summary["is_historical_low"], "above_lowest_percent": price_summary["above_lowest_percent"]})
+
+            # Prefer descriptions in the default user's store language when they were fetched
+            if user_steam_id:
+                user = session.query(UserProfile).filter_by(steam_id=user_steam_id).first()
+                if user and user.store_language:
+                    localization = session.get(GameLocalization, (game.app_id, user.store_language))
+                    if localization:
+                        game_data.update({"name": localization.name or game.name, "short_description": localization.short_description, "about_the_game": localization.about_the_game, "language": localization.language})
+
+            # Add DLC if any were fetched
+            if game.dlc:
+                game_data["dlc"] = [{"id": d.app_id, "name": d.name, "release_date": d.release_date, "is_free": d.is_free, "price": round(d.price_final / 100, 2) if d.price_final is not None else None, "currency": d.currency} for d in game.dlc]
+
             # Add detailed review data if available
             if game.reviews:
                 game_data["reviews"] = {"summary": game.reviews.review_summary, "score": game.reviews.review_score, "total_reviews": game.reviews.total_reviews, "positive_reviews": game.reviews.positive_reviews, "negative_reviews": game.reviews.negative_reviews, "positive_percentage": game.reviews.positive_percentage, "review_score_desc": game.reviews.review_score_desc}
@@ -102,9 +137,18 @@ def get_game_details(game_id: str) -> TextResourceContents:
                 user_game = session.query(UserGame).filter_by(steam_id=user_steam_id, app_id=game.app_id).first()
 
                 if user_game:
-                    game_data["user_stats"] = {"owned": True, "playtime_forever_minutes": user_game.playtime_forever, "playtime_forever_hours": round(user_game.playtime_forever / 60, 2), "playtime_2weeks_minutes": user_game.playtime_2weeks, "playtime_2weeks_hours": round(user_game.playtime_2weeks / 60, 2), "last_played": user_game.last_played, "achievements_total": user_game.achievements_total, "achievements_unlocked": user_game.achievements_unlocked, "achievement_percentage": round((user_game.achievements_unlocked / max(user_game.achievements_total, 1)) * 100, 1) if user_game.achievements_total else 0}
+                    owned_dlc = {app_id for (app_id,) in session.query(UserDLC.app_id).filter_by(steam_id=user_steam_id, parent_app_id=game.app_id)}
+                    for dlc in game_data.get("dlc", []):
+                        dlc["owned"] = dlc["id"] in owned_dlc
+                    game_data["user_stats"] = {"owned": True, "playtime_forever_minutes": user_game.playtime_forever, "playtime_forever_hours": round(user_game.playtime_forever / 60, 2), "playtime_2weeks_minutes": user_game.playtime_2weeks, "playtime_2weeks_hours": round(user_game.playtime_2weeks / 60, 2), "last_played": user_game.last_played_iso, "playtime_by_platform_minutes": {"windows": user_game.playtime_windows_forever, "mac": user_game.playtime_mac_forever, "linux": user_game.playtime_linux_forever, "deck": user_game.playtime_deck_forever}, "achievements_total": user_game.achievements_total, "achievements_unlocked": user_game.achievements_unlocked, "achievement_percentage": round((user_game.achievements_unlocked / max(user_game.achievements_total, 1)) * 100, 1) if user_game.achievements_total else 0}
+                    game_data["user_stats"]["dlc_owned"] = sorted(owned_dlc)
                 else:
                     game_data["user_stats"] = {"owned": False, "note": "Game not in user's library"}
+
+                # Friends whose stored libraries include the game (fetcher --friends-games or --friends)
+                friends = session.query(UserProfile.persona_name).join(FriendGame, FriendGame.steam_id == UserProfile.steam_id).join(friends_association, friends_association.c.friend_steam_id == FriendGame.steam_id).filter(friends_association.c.user_steam_id == user_steam_id, FriendGame.app_id == game.app_id).all()
+                if friends:
+                    game_data["friends_who_own"] = sorted(name for (name,) in friends if name)
             else:
                 game_data["user_stats"] = {"note": "No user context available for personalized stats"}
 
@@ -114,6 +158,91 @@ def get_game_details(game_id: str) -> TextResourceContents:
         return create_error_resource(uri, name, f"Failed to get game details: {str(e)}")
 
 
+@mcp.resource("library://games/{game_id}/news")
+def get_game_news(game_id: str) -> TextResourceContents:
+    """Get recent news and patch notes for a game."""
+    uri = f"library://games/{game_id}/news"
+    name = f"game_{game_id}_news"
+
+    try:
+        with get_db() as session:
+            game = session.query(Game).filter_by(app_id=int(game_id)).first()
+            if not game:
+                return create_error_resource(uri, name, f"Game with ID {game_id} not found")
+
+            news = session.query(GameNews).filter_by(app_id=game.app_id).order_by(GameNews.date.desc()).limit(10).all()
+            news_data = {"id": game.app_id, "name": game.name, "news": [{"title": item.title, "url": item.url, "author": item.author, "feed_label": item.feed_label, "date": datetime.fromtimestamp(item.date).isoformat() if item.date else None, "contents": item.contents} for item in news]}
+
+            return create_resource_content(uri=uri, name=name, title=f"News: {game.name}", description=f"Recent announcements and patch notes for {game.name}", data=news_data, priority=0.5, audience=["user", "assistant"])
+
+    except Exception as e:
+        return create_error_resource(uri, name, f"Failed to get game news: {str(e)}")
+
+
+@mcp.resource("library://games/{game_id}/reviews")
+def get_game_reviews(game_id: str) -> TextResourceContents:
+    """Get the most helpful individual player reviews for a game."""
+    uri = f"library://games/{game_id}/reviews"
+    name = f"game_{game_id}_reviews"
+
+    try:
+        with get_db() as session:
+            game = session.query(Game).options(joinedload(Game.reviews)).filter_by(app_id=int(game_id)).first()
+            if not game:
+                return create_error_resource(uri, name, f"Game with ID {game_id} not found")
+
+            reviews = session.query(ReviewText).filter_by(app_id=game.app_id).order_by(ReviewText.votes_up.desc()).limit(20).all()
+            reviews_data = {"id": game.app_id, "name": game.name, "summary": game.reviews.review_summary if game.reviews else None, "reviews": [{"recommended": item.voted_up, "review": item.review, "votes_up": item.votes_up, "votes_funny": item.votes_funny, "playtime_at_review_hours": round(item.playtime_at_review / 60, 1) if item.playtime_at_review else None, "date": datetime.fromtimestamp(item.timestamp_created).isoformat() if item.timestamp_created else None} for item in reviews]}
+
+            return create_resource_content(uri=uri, name=name, title=f"Reviews: {game.name}", description=f"Most helpful player reviews for {game.name}", data=reviews_data, priority=0.5, audience=["user", "assistant"])
+
+    except Exception as e:
+        return create_error_resource(uri, name, f"Failed to get game reviews: {str(e)}")
+
+
+@mcp.resource("library://games/{game_id}/price-history")
+def get_game_price_history(game_id: str) -> TextResourceContents:
+    """Get recorded price changes for a game and how the current price compares."""
+    uri = f"library://games/{game_id}/price-history"
+    name = f"game_{game_id}_price_history"
+
+    try:
+        with get_db() as session:
+            game = session.query(Game).filter_by(app_id=int(game_id)).first()
+            if not game:
+                return create_error_resource(uri, name, f"Game with ID {game_id} not found")
+
+            history = session.query(PriceHistory).filter_by(app_id=game.app_id).order_by(PriceHistory.recorded_at).all()
+            summary = get_price_summary(session, game)
+            price_data = {"id": game.app_id, "name": game.name, "currency": game.currency, "current_price": round(game.price_final / 100, 2) if game.price_final is not None else None, "lowest_ever": round(summary["lowest"] / 100, 2) if summary else None, "lowest_ever_date": datetime.fromtimestamp(summary["lowest_recorded_at"]).isoformat() if summary else None, "is_historical_low": summary["is_historical_low"] if summary else None, "above_lowest_percent": summary["above_lowest_percent"] if summary else None, "history": [{"date": datetime.fromtimestamp(entry.recorded_at).isoformat(), "price": round(entry.price_final / 100, 2) if entry.price_final is not None else None, "discount_percent": entry.discount_percent, "currency": entry.currency} for entry in history]}
+
+            return create_resource_content(uri=uri, name=name, title=f"Price History: {game.name}", description=f"Recorded price changes for {game.name}", data=price_data, priority=0.4, audience=["user", "assistant"])
+
+    except Exception as e:
+        return create_error_resource(uri, name, f"Failed to get price history: {str(e)}")
+
+
+@mcp.resource("library://games/{game_id}/workshop")
+def get_game_workshop(game_id: str) -> TextResourceContents:
+    """Get popular Steam Workshop items for a game."""
+    uri = f"library://games/{game_id}/workshop"
+    name = f"game_{game_id}_workshop"
+
+    try:
+        with get_db() as session:
+            game = session.query(Game).filter_by(app_id=int(game_id)).first()
+            if not game:
+                return create_error_resource(uri, name, f"Game with ID {game_id} not found")
+
+            items = session.query(WorkshopItem).filter_by(app_id=game.app_id).order_by(WorkshopItem.subscriptions.desc()).all()
+            workshop_data = {"id": game.app_id, "name": game.name, "workshop_items": [{"title": item.title, "short_description": item.short_description, "url": f"https://steamcommunity.com/sharedfiles/filedetails/?id={item.published_file_id}", "subscriptions": item.subscriptions, "favorited": item.favorited} for item in items]}
+
+            return create_resource_content(uri=uri, name=name, title=f"Workshop: {game.name}", description=f"Popular Steam Workshop mods and content for {game.name}", data=workshop_data, priority=0.4, audience=["user", "assistant"])
+
+    except Exception as e:
+        return create_error_resource(uri, name, f"Failed to get workshop items: {str(e)}")
+
+
 @mcp.resource("library://overview")
 def library_overview() -> TextResourceContents:
     """Get library overview with navigation to user-specific resources."""
@@ -170,8 +299,9 @@ def get_user_profile(user_id: str) -> str:
             else:
                 resolved_user_id = user_id
 
-            # Try to resolve user_id as either steam_id or persona_name
-            user = session.query(UserProfile).filter((UserProfile.steam_id == resolved_user_id) | (UserProfile.persona_name.ilike(resolved_user_id))).first()
+            # Resolve user_id as any Steam ID format, profile URL, vanity name, or persona name
+            steam_id = resolve_user_identifier(resolved_user_id, session)
+            user = session.query(UserProfile).filter_by(steam_id=steam_id).first() if steam_id else None
 
             if not user:
                 return json.dumps({"error": f"User '{user_id}' not found"})
@@ -179,7 +309,7 @@ def get_user_profile(user_id: str) -> str:
             # Get game count
             game_count = session.query(UserGame).filter_by(steam_id=user.steam_id).count()
 
-            user_data = {"steam_id": user.steam_id, "persona_name": user.persona_name, "profile_url": user.profile_url, "avatar_url": user.avatar_url, "avatarmedium": user.avatarmedium, "avatarfull": user.avatarfull, "steam_level": user.steam_level, "xp": user.xp, "time_created": user.time_created, "location": {"country": user.loccountrycode, "state": user.locstatecode}, "game_count": game_count, "last_updated": user.last_updated, "is_default": user.steam_id == config.default_user or user.persona_name == config.default_user}
+            user_data = {"steam_id": user.steam_id, "persona_name": user.persona_name, "profile_url": user.profile_url, "avatar_url": user.avatar_url, "avatarmedium": user.avatarmedium, "avatarfull": user.avatarfull, "steam_level": user.steam_level, "xp": user.xp, "badge_count": user.badge_count, "account_standing": {"vac_banned": user.vac_banned, "vac_bans": user.number_of_vac_bans, "game_bans": user.number_of_game_bans, "days_since_last_ban": user.days_since_last_ban, "community_banned": user.community_banned, "economy_ban": user.economy_ban}, "time_created": user.time_created, "location": {"country": user.loccountrycode, "state": user.locstatecode}, "game_count": game_count, "last_updated": user.last_updated, "is_default": user.steam_id == config.default_user or user.persona_name == config.default_user}
 
             return json.dumps(user_data, indent=2)
 
@@ -189,7 +319,17 @@ def get_user_profile(user_id: str) -> str:
 
 @mcp.resource("library://users/{user_id}/games")
 def get_user_games(user_id: str) -> str:
-    """Get user's complete game library with playtime data."""
+    """Get the first page of a user's game library with playtime data, most played first."""
+    return user_games_page(user_id, None)
+
+
+@mcp.resource("library://users/{user_id}/games/page/{cursor}")
+def get_user_games_page(user_id: str, cursor: str) -> str:
+    """Get the page of a user's game library that a previous page's nextCursor points at."""
+    return user_games_page(user_id, cursor)
+
+
+def user_games_page(user_id: str, cursor: str | None) -> str:
     try:
         with get_db() as session:
             # Use default user if user_id is "default" or empty
@@ -202,25 +342,29 @@ def get_user_games(user_id: str) -> str:
                 resolved_user_id = user_id
 
             # Resolve user
-            user = session.query(UserProfile).filter((UserProfile.steam_id == resolved_user_id) | (UserProfile.persona_name.ilike(resolved_user_id))).first()
+            steam_id = resolve_user_identifier(resolved_user_id, session)
+            user = session.query(UserProfile).filter_by(steam_id=steam_id).first() if steam_id else None
 
             if not user:
                 return json.dumps({"error": f"User '{user_id}' not found"})
 
-            # Get user's games with details
-            user_games = session.query(UserGame).options(joinedload(UserGame.game).joinedload(Game.genres), joinedload(UserGame.game).joinedload(Game.developers)).filter(UserGame.steam_id == user.steam_id).all()
+            # Get a page of the user's games with details, most played first
+            games_query = session.query(UserGame).filter(UserGame.steam_id == user.steam_id)
+            total_games = games_query.count()
+            user_games, next_cursor = paginate(games_query.options(joinedload(UserGame.game).joinedload(Game.genres), joinedload(UserGame.game).joinedload(Game.developers)).order_by(UserGame.playtime_forever.desc(), UserGame.app_id), cursor, config.resource_page_size)
 
             games_data = []
             for ug in user_games:
-                games_data.append({"app_id": ug.game.app_id, "name": ug.game.name, "playtime_forever_minutes": ug.playtime_forever, "playtime_forever_hours": ug.playtime_hours, "playtime_2weeks_minutes": ug.playtime_2weeks, "playtime_2weeks_hours": ug.playtime_2weeks_hours, "genres": [g.genre_name for g in ug.game.genres], "developers": [d.developer_name for d in ug.game.developers], "release_date": ug.game.release_date})
-
-            # Sort by playtime descending
-            games_data.sort(key=lambda x: x["playtime_forever_minutes"], reverse=True)
+                games_data.append({"app_id": ug.game.app_id, "name": ug.game.name, "playtime_forever_minutes": ug.playtime_forever, "playtime_forever_hours": ug.playtime_hours, "playtime_2weeks_minutes": ug.playtime_2weeks, "playtime_2weeks_hours": ug.playtime_2weeks_hours, "last_played": ug.last_played_iso, "genres": [g.genre_name for g in ug.game.genres], "developers": [d.developer_name for d in ug.game.developers], "release_date": ug.game.release_date, "shared": bool(ug.shared), "removed_at": ug.removed_at})
 
-            library_data = {"user": user.persona_name, "steam_id": user.steam_id, "total_games": len(games_data), "games": games_data}
+            library_data = {"user": user.persona_name, "steam_id": user.steam_id, "total_games": total_games, "games": games_data}
+            if next_cursor:
+                library_data["nextCursor"] = next_cursor
 
             return json.dumps(library_data, indent=2)
 
+    except ValueError as e:
+        return json.dumps({"error": str(e)})
     except Exception as e:
         return json.dumps({"error": f"Failed to get user games: {str(e)}"})
 
@@ -240,38 +384,53 @@ def get_user_stats(user_id: str) -> str:
                 resolved_user_id = user_id
 
             # Resolve user
-            user = session.query(UserProfile).filter((UserProfile.steam_id == resolved_user_id) | (UserProfile.persona_name.ilike(resolved_user_id))).first()
+            steam_id = resolve_user_identifier(resolved_user_id, session)
+            user = session.query(UserProfile).filter_by(steam_id=steam_id).first() if steam_id else None
 
             if not user:
                 return json.dumps({"error": f"User '{user_id}' not found"})
 
-            # Get user's games with genres
-            user_games = session.query(UserGame).options(joinedload(UserGame.game).joinedload(Game.genres)).filter(UserGame.steam_id == user.steam_id).all()
+            insights = library_insights(session, user.steam_id)
+            played = insights["games_played"]
+            stats_data = {"user": user.persona_name, "steam_id": user.steam_id, "total_games": insights["total_games"], "games_played": played, "games_unplayed": insights["never_played"], "completion_rate": round(played / max(insights["total_games"], 1) * 100, 1), "total_playtime_hours": insights["total_playtime_hours"], "recent_playtime_hours": insights["recent_playtime_hours"], "average_playtime_hours": round(insights["total_playtime_hours"] / max(played, 1), 1), "top_genres": [{"genre": genre["genre"], "count": genre["games"], "playtime_hours": genre["playtime_hours"]} for genre in insights["genres"][:10]], "insights": insights}
 
-            # Calculate stats
-            total_games = len(user_games)
-            total_playtime = sum(ug.playtime_forever for ug in user_games)
-            played_games = sum(1 for ug in user_games if ug.playtime_forever > 0)
-            recent_playtime = sum(ug.playtime_2weeks for ug in user_games)
+            return json.dumps(stats_data, indent=2)
 
-            # Genre breakdown
-            genre_stats = {}
-            for ug in user_games:
-                for genre in ug.game.genres:
-                    if genre.genre_name not in genre_stats:
-                        genre_stats[genre.genre_name] = {"count": 0, "playtime": 0}
-                    genre_stats[genre.genre_name]["count"] += 1
-                    genre_stats[genre.genre_name]["playtime"] += ug.playtime_forever
+    except Exception as e:
+        return json.dumps({"error": f"Failed to get user stats: {str(e)}"})
 
-            # Sort genres by playtime
-            top_genres = sorted([{"genre": name, "count": data["count"], "playtime_hours": round(data["playtime"] / 60, 1)} for name, data in genre_stats.items()], key=lambda x: x["playtime_hours"], reverse=True)[:10]
 
-            stats_data = {"user": user.persona_name, "steam_id": user.steam_id, "total_games": total_games, "games_played": played_games, "games_unplayed": total_games - played_games, "completion_rate": round(played_games / max(total_games, 1) * 100, 1), "total_playtime_hours": round(total_playtime / 60, 1), "recent_playtime_hours": round(recent_playtime / 60, 1), "average_playtime_hours": round(total_playtime / 60 / max(played_games, 1), 1), "top_genres": top_genres}
+@mcp.resource("library://users/{user_id}/wishlist")
+def get_user_wishlist(user_id: str) -> str:
+    """Get user's wishlist with current prices and the items on sale past the alert threshold."""
+    try:
+        with get_db() as session:
+            # Use default user if user_id is "default" or empty
+            if user_id == "default" or not user_id:
+                user_result = resolve_user_for_tool(None, get_default_user_fallback)
+                if "error" in user_result:
+                    return json.dumps({"error": f"No default user configured: {user_result['message']}"})
+                resolved_user_id = user_result["steam_id"]
+            else:
+                resolved_user_id = user_id
 
-            return json.dumps(stats_data, indent=2)
+            steam_id = resolve_user_identifier(resolved_user_id, session)
+            user = session.query(UserProfile).filter_by(steam_id=steam_id).first() if steam_id else None
+
+            if not user:
+                return json.dumps({"error": f"User '{user_id}' not found"})
+
+            items = session.query(WishlistItem).filter_by(steam_id=user.steam_id).all()
+            # Unranked items (priority 0) go after the ranked ones
+            items.sort(key=lambda item: item.priority or float("inf"))
+
+            wishlist = [{"app_id": item.app_id, "name": item.name, "priority": item.priority, "date_added": item.date_added, "price_initial_cents": item.price_initial, "price_final_cents": item.price_final, "discount_percent": item.discount_percent, "formatted_price": item.formatted_price, "price_updated_at": item.price_updated_at, "sale_alerted_at": item.sale_alerted_at} for item in items]
+            on_sale = sorted((entry for entry in wishlist if entry["sale_alerted_at"]), key=lambda entry: entry["discount_percent"], reverse=True)
+
+            return json.dumps({"user": user.persona_name, "steam_id": user.steam_id, "total_items": len(wishlist), "on_sale": on_sale, "wishlist": wishlist}, indent=2)
 
     except Exception as e:
-        return json.dumps({"error": f"Failed to get user stats: {str(e)}"})
+        return json.dumps({"error": f"Failed to get user wishlist: {str(e)}"})
 
 
 @mcp.resource("library://genres")
@@ -334,10 +493,21 @@ def get_games_by_genre(genre_name: str) -> str:
 
 @mcp.resource("library://users")
 def available_users() -> str:
-    """Get list of all users in the database."""
+    """Get the first page of users in the database."""
+    return users_page(None)
+
+
+@mcp.resource("library://users/page/{cursor}")
+def available_users_page(cursor: str) -> str:
+    """Get the page of users that a previous page's nextCursor points at."""
+    return users_page(cursor)
+
+
+def users_page(cursor: str | None) -> str:
     try:
         with get_db() as session:
-            users = session.query(UserProfile).all()
+            total_users = session.query(UserProfile).count()
+            users, next_cursor = paginate(session.query(UserProfile).order_by(UserProfile.steam_id), cursor, config.resource_page_size)
 
             user_list = []
             for user in users:
@@ -352,9 +522,13 @@ def available_users() -> str:
 
                 user_list.append(user_data)
 
-            users_data = {"total_users": len(user_list), "default_user": config.default_user, "users": user_list}
+            users_data = {"total_users": total_users, "default_user": config.default_user, "users": user_list}
+            if next_cursor:
+                users_data["nextCursor"] = next_cursor
             return json.dumps(users_data, indent=2)
 
+    except ValueError as e:
+        return json.dumps({"error": str(e)})
     except Exception as e:
         return json.dumps({"error": f"Failed to get users: {str(e)}"})
 
@@ -6,14 +6,19 @@ import signal
 import sys
 from pathlib import Path
 
+import anyio
+import uvicorn
+
 # Add src to path for imports
 sys.path.insert(0, str(Path(__file__).parent.parent))
 
 
 # Import all modules to register decorators
 from mcp_server import __version__
+from mcp_server.auth import http_app
 from mcp_server.config import config
-from mcp_server.server import mcp
+from mcp_server.scheduler import scheduler
+from mcp_server.stdio import run_stdio
 
 
 def setup_signal_handlers():
@@ -43,14 +48,25 @@ def main():
         logger.info(f"Default User: {config.default_user}")
         logger.info(f"Database: {config.database_url}")
 
+        # Queue syncs as SYNC_SCHEDULE and per-library schedules come due
+        scheduler.start()
+
+        if config.transport == "stdio":
+            # Content-Length framed or newline-delimited JSON over stdin/stdout; logs stay on stderr
+            logger.info("Serving MCP over stdio...")
+            anyio.run(run_stdio)
+            return
+        if config.transport != "http":
+            raise ValueError(f"Unknown MCP_TRANSPORT '{config.transport}': expected http or stdio")
+
         # Start the server
         logger.info("Starting FastMCP HTTP server...")
         logger.info(f"Health check: http://{config.host}:{config.port}/health")
         logger.info(f"Detailed health: http://{config.host}:{config.port}/health/detailed")
         logger.info(f"MCP endpoint: http://{config.host}:{config.port}/mcp")
 
-        # Run the FastMCP server synchronously
-        mcp.run(transport="streamable-http")
+        # Run the streamable HTTP app synchronously, behind token auth if configured
+        uvicorn.run(http_app(), host=config.host, port=config.port, log_level="debug" if config.debug else "info")
 
     except KeyboardInterrupt:
         logger.info("Received keyboard interrupt, shutting down...")
@@ -0,0 +1,478 @@
+"""Cron schedules for server-started syncs
+
+SYNC_SCHEDULE lists cron expressions with the sync scope each one runs, separated
+by semicolons:
+
+    SYNC_SCHEDULE="0 3 * * *=full; */30 * * * *=playtime"
+
+A library's user_profile.sync_schedule column replaces the global schedule for that
+library (an empty string turns scheduled syncs off for it). Expressions are read in
+the server's local time zone. A full sync covers the narrower scopes, so when one
+fires in the same minute as them only the full sync runs; playtime, metadata, and
+reviews syncs are independent of each other and all run when due together (one
+after another, as the sync queue never runs two jobs for a library at once).
+Scheduled syncs go through the sync queue at "scheduled"
+priority, so manual syncs still go first and the daily request budget defers them.
+
+MAX_CONCURRENT_AUTO_SYNCS caps how many scheduled syncs are queued or running at
+once. Due libraries past the cap wait as pending and are queued as slots free up,
+the least recently synced first.
+
+With STEAM_API_DAILY_BUDGET set, scheduled syncs leave SCHEDULER_QUOTA_RESERVE
+percent of the budget for manual syncs. A sync that wouldn't fit is downgraded to
+a playtime sync, or skipped when even that doesn't fit; each decision is listed in
+the scheduler status. One-shot syncs are fitted to the budget the same way.
+
+The time of the last check, the counters, and the last sync queued per library are
+kept in the database. After a restart the scheduler catches up on runs it missed
+while it was down, but only those within SCHEDULER_CATCH_UP seconds, so a long
+outage doesn't queue every library at once.
+
+Pausing the scheduler (e.g. for maintenance) skips every run until it is resumed,
+and blackout windows (sync_blackouts) do the same between fixed times, e.g. during
+a Steam sale when the store API is flaky. Skipped runs are not caught up
+afterwards. run_now() queues each library's next scheduled sync straight away,
+paused or not.
+
+One-shot syncs (one_shot_syncs) run a single sync at a set time, e.g. a full sync
+on Saturday at 4am. They wait out pauses and blackouts instead of being skipped.
+"""
+
+import logging
+import threading
+import time
+from collections import deque
+from datetime import datetime
+
+from croniter import croniter
+
+from shared.database import SYNC_SCOPES, OneShotSync, ScheduledSync, SchedulerState, SyncBlackout, SyncCheckpoint, UserProfile, create_database, get_db, get_db_transaction
+
+from .config import config
+from .events import events
+from .sync import QuotaExceeded, SyncAlreadyRunning, estimate_requests, quota_status, submit_within_budget, sync_queue
+
+logger = logging.getLogger(__name__)
+
+# How often the scheduler checks for due syncs; cron has minute resolution
+TICK_SECONDS = 60
+
+STAT_NAMES = ("ticks", "queued", "skipped", "errors")
+
+# Quota decisions kept for the scheduler status
+QUOTA_DECISION_HISTORY = 50
+
+# Fire times checked per scope when looking for its next run that a full sync doesn't override
+UPCOMING_SEARCH_LIMIT = 1000
+
+
+def parse_schedule(spec: str) -> list[tuple[str, str]]:
+    """Parse 'CRON=SCOPE; CRON=SCOPE' into (cron, scope) pairs, raising ValueError for bad entries"""
+    schedules = []
+    for entry in filter(None, (part.strip() for part in spec.split(";"))):
+        expression, _, scope = entry.rpartition("=")
+        expression, scope = expression.strip(), scope.strip()
+        if not expression:
+            # A bare expression runs a full sync
+            expression, scope = scope, "full"
+        if scope not in SYNC_SCOPES:
+            raise ValueError(f"Unknown scope '{scope}' in schedule '{entry}': expected one of {', '.join(SYNC_SCOPES)}")
+        if not croniter.is_valid(expression):
+            raise ValueError(f"Invalid cron expression '{expression}'")
+        schedules.append((expression, scope))
+    return schedules
+
+
+def merge_scopes(scopes) -> list[str]:
+    """Scopes to run for a library: just full when it's among them, as it covers the rest, otherwise each one once"""
+    return ["full"] if "full" in scopes else [scope for scope in SYNC_SCOPES if scope in scopes]
+
+
+def next_run(expression: str, after: float) -> float:
+    """Unix time the cron expression next fires after the given time"""
+    return croniter(expression, datetime.fromtimestamp(after).astimezone()).get_next(float)
+
+
+def upcoming_runs(schedules: list[tuple[str, str]], after: float, blackouts: list[tuple[int, int]] = ()) -> dict[str, int]:
+    """Unix time each scheduled scope next actually runs after the given time
+
+    A fire time that coincides with a full sync's belongs to the full sync, so e.g.
+    a half-hourly playtime sync's next run skips the minute of a full sync. Narrower
+    scopes don't override each other, so two of them can share a time.
+    Fire times inside a (start, end) blackout are skipped too. Scopes without a run
+    in the next UPCOMING_SEARCH_LIMIT fire times are left out.
+    """
+    runs = {}
+    for scope in {scope for _, scope in schedules}:
+        own = [expression for expression, other in schedules if other == scope]
+        broader = [expression for expression, other in schedules if other == "full" and scope != "full"]
+        at = after
+        for _ in range(UPCOMING_SEARCH_LIMIT):
+            at = min(next_run(expression, at) for expression in own)
+            # Jump past a blackout instead of stepping through every fire time inside it
+            blackout_end = next((end for start, end in blackouts if start <= at < end), None)
+            if blackout_end:
+                at = blackout_end - 1
+                continue
+            # Cron fires on minute boundaries, so a broader expression fires at the same time exactly
+            if not any(next_run(expression, at - 1) == at for expression in broader):
+                runs[scope] = int(at)
+                break
+    return runs
+
+
+def blackout_to_dict(blackout: SyncBlackout) -> dict:
+    """Serialize a blackout window, with local ISO times for readability"""
+    return {"id": blackout.id, "starts_at": blackout.starts_at, "ends_at": blackout.ends_at, "starts_at_iso": datetime.fromtimestamp(blackout.starts_at).astimezone().isoformat(), "ends_at_iso": datetime.fromtimestamp(blackout.ends_at).astimezone().isoformat(), "reason": blackout.reason, "created_at": blackout.created_at}
+
+
+def one_shot_to_dict(one_shot: OneShotSync) -> dict:
+    """Serialize a one-shot sync"""
+    return {"id": one_shot.id, "steam_id": one_shot.steam_id, "scope": one_shot.scope, "run_at": one_shot.run_at, "run_at_iso": datetime.fromtimestamp(one_shot.run_at).astimezone().isoformat(), "status": one_shot.status, "job_id": one_shot.job_id, "detail": one_shot.detail, "created_at": one_shot.created_at, "queued_at": one_shot.queued_at}
+
+
+def publish_results(results: list[dict], trigger: str):
+    """Publish each sync a scheduling pass queued or skipped, with what triggered the pass (schedule or run_now)"""
+    for result in results:
+        events.publish("scheduler.queued" if "job_id" in result else "scheduler.skipped", {**result, "trigger": trigger})
+
+
+class Scheduler:
+    """Background thread that queues syncs as their cron schedules come due"""
+
+    def __init__(self, schedule: str = "", catch_up: int = 3600, max_concurrent: int = 0, quota_reserve: int = 20):
+        self.schedule = schedule  # Parsed into default_schedule by start()
+        self.default_schedule: list[tuple[str, str]] = []
+        self.catch_up = catch_up
+        self.max_concurrent = max_concurrent  # 0 leaves the limit to SYNC_WORKERS
+        self.quota_reserve = quota_reserve  # Percent of the daily budget kept for manual syncs
+        self.quota_decisions: deque[dict] = deque(maxlen=QUOTA_DECISION_HISTORY)
+        self.pending: dict[str, list[str]] = {}  # steam_id -> scopes of due syncs waiting for a free slot
+        self.last_tick: float | None = None
+        self.last_runs: dict[str, dict] = {}  # steam_id -> the last sync this scheduler queued
+        self.stats = dict.fromkeys(STAT_NAMES, 0)
+        self.paused = False
+        self._lock = threading.Lock()
+        self._thread: threading.Thread | None = None
+
+    def start(self):
+        """Load the saved state and start checking schedules in the background (once)
+
+        An invalid default schedule is logged and ignored, so per-library schedules and
+        one-shots still run.
+        """
+        if self._thread is None:
+            try:
+                self.default_schedule = parse_schedule(self.schedule)
+            except ValueError as e:
+                logger.error(f"Ignoring SYNC_SCHEDULE: {e}")
+            create_database()
+            self.load()
+            self._thread = threading.Thread(target=self._run, name="sync-scheduler", daemon=True)
+            self._thread.start()
+            logger.info(f"Sync scheduler started with {len(self.default_schedule)} default schedule(s)")
+
+    def load(self, now: float | None = None):
+        """Restore counters and last runs, resuming from the last check within the catch-up window"""
+        now = time.time() if now is None else now
+        with self._lock, get_db() as session:
+            state = dict(session.query(SchedulerState.name, SchedulerState.value))
+            self.stats = {name: state.get(name, 0) for name in STAT_NAMES}
+            self.last_runs = {run.steam_id: {"scope": run.scope, "queued_at": run.queued_at, "job_id": run.job_id} for run in session.query(ScheduledSync)}
+            self.paused = bool(state.get("paused"))
+        last_tick = state.get("last_tick")
+        self.last_tick = max(last_tick, now - self.catch_up) if last_tick else now
+        if last_tick and last_tick < self.last_tick:
+            logger.warning(f"Scheduler was stopped for {int(now - last_tick)}s; only catching up on runs from the last {self.catch_up}s")
+
+    def save(self):
+        """Write the last check time, counters, and last runs to the database"""
+        with get_db_transaction() as session:
+            for name, value in [("last_tick", int(self.last_tick or 0)), ("paused", int(self.paused)), *self.stats.items()]:
+                session.merge(SchedulerState(name=name, value=value))
+            for steam_id, run in self.last_runs.items():
+                session.merge(ScheduledSync(steam_id=steam_id, scope=run["scope"], queued_at=run["queued_at"], job_id=run["job_id"]))
+
+    def library_schedules(self, session) -> dict[str, list[tuple[str, str]]]:
+        """Schedules per library, for every library that has been synced before"""
+        overrides = dict(session.query(UserProfile.steam_id, UserProfile.sync_schedule).filter(UserProfile.sync_schedule.isnot(None)))
+        schedules = {}
+        for (steam_id,) in session.query(SyncCheckpoint.steam_id).order_by(SyncCheckpoint.steam_id):
+            if steam_id not in overrides:
+                schedules[steam_id] = self.default_schedule
+                continue
+            try:
+                schedules[steam_id] = parse_schedule(overrides[steam_id])
+            except ValueError as e:
+                logger.error(f"Ignoring sync_schedule for {steam_id}: {e}")
+                schedules[steam_id] = []
+        return schedules
+
+    def last_synced(self, session) -> dict[str, int]:
+        """When each library's data was last fresh: its last completed sync, or the start of an unfinished one"""
+        return {checkpoint.steam_id: (checkpoint.updated_at if checkpoint.status == "completed" else checkpoint.started_at) or 0 for checkpoint in session.query(SyncCheckpoint)}
+
+    def by_staleness(self, session, steam_ids) -> list[str]:
+        """steam_ids ordered from least to most recently synced"""
+        synced = self.last_synced(session)
+        return sorted(steam_ids, key=lambda steam_id: (synced.get(steam_id, 0), steam_id))
+
+    def due(self, schedules: list[tuple[str, str]], since: float, now: float) -> list[str]:
+        """Scopes to run because their cron expressions fired in (since, now]; see merge_scopes"""
+        return merge_scopes({scope for expression, scope in schedules if next_run(expression, since) <= now})
+
+    def next_runs(self, session, now: float) -> dict[str, dict | None]:
+        """Each library's next scheduled sync (scope and Unix time), None when it has no schedule"""
+        blackouts = self.blackout_windows(session, now)
+        return {steam_id: min(({"scope": scope, "at": at} for scope, at in upcoming_runs(schedules, now, blackouts).items()), key=lambda run: run["at"], default=None) for steam_id, schedules in self.library_schedules(session).items()}
+
+    def blackout_windows(self, session, now: float) -> list[tuple[int, int]]:
+        """(start, end) of every blackout that hasn't ended yet"""
+        return [(blackout.starts_at, blackout.ends_at) for blackout in session.query(SyncBlackout).filter(SyncBlackout.ends_at > now).order_by(SyncBlackout.starts_at)]
+
+    def list_blackouts(self, include_past: bool = False) -> list[dict]:
+        """Blackout windows ordered by start, only current and future ones unless include_past"""
+        with get_db() as session:
+            query = session.query(SyncBlackout)
+            if not include_past:
+                query = query.filter(SyncBlackout.ends_at > time.time())
+            return [blackout_to_dict(blackout) for blackout in query.order_by(SyncBlackout.starts_at)]
+
+    def add_blackout(self, starts_at: int, ends_at: int, reason: str | None = None) -> dict:
+        """Suppress scheduled syncs between two Unix times, raising ValueError for an empty or past window"""
+        if ends_at <= starts_at:
+            raise ValueError("A blackout must end after it starts")
+        if ends_at <= time.time():
+            raise ValueError("A blackout must end in the future")
+        with get_db_transaction() as session:
+            blackout = SyncBlackout(starts_at=starts_at, ends_at=ends_at, reason=reason)
+            session.add(blackout)
+            session.flush()
+            result = blackout_to_dict(blackout)
+        logger.info(f"Added sync blackout {result['id']} from {result['starts_at_iso']} to {result['ends_at_iso']}{f': {reason}' if reason else ''}")
+        events.publish("scheduler.blackout_added", result)
+        return result
+
+    def list_one_shots(self, include_done: bool = False) -> list[dict]:
+        """One-shot syncs ordered by run time, only pending ones unless include_done"""
+        with get_db() as session:
+            query = session.query(OneShotSync)
+            if not include_done:
+                query = query.filter(OneShotSync.status == "pending")
+            return [one_shot_to_dict(one_shot) for one_shot in query.order_by(OneShotSync.run_at)]
+
+    def schedule_one_shot(self, steam_id: str, run_at: int, scope: str = "full") -> dict:
+        """Run one sync of steam_id at run_at, raising ValueError for an unknown scope or a past time"""
+        if scope not in SYNC_SCOPES:
+            raise ValueError(f"Unknown scope '{scope}': expected one of {', '.join(SYNC_SCOPES)}")
+        if run_at <= time.time():
+            raise ValueError("A one-shot sync must be scheduled in the future")
+        with get_db_transaction() as session:
+            one_shot = OneShotSync(steam_id=steam_id, scope=scope, run_at=run_at)
+            session.add(one_shot)
+            session.flush()
+            result = one_shot_to_dict(one_shot)
+        logger.info(f"Scheduled one-shot {scope} sync {result['id']} for {steam_id} at {result['run_at_iso']}")
+        events.publish("scheduler.one_shot_scheduled", result)
+        return result
+
+    def cancel_one_shot(self, one_shot_id: int) -> dict:
+        """Cancel a pending one-shot sync, raising LookupError if there is no such pending sync"""
+        with get_db_transaction() as session:
+            one_shot = session.get(OneShotSync, one_shot_id)
+            if not one_shot or one_shot.status != "pending":
+                raise LookupError(f"No pending one-shot sync {one_shot_id}")
+            one_shot.status = "cancelled"
+            result = one_shot_to_dict(one_shot)
+        logger.info(f"Cancelled one-shot sync {one_shot_id}")
+        events.publish("scheduler.one_shot_cancelled", result)
+        return result
+
+    def remove_blackout(self, blackout_id: int) -> dict:
+        """Delete a blackout window, raising LookupError if it doesn't exist"""
+        with get_db_transaction() as session:
+            blackout = session.get(SyncBlackout, blackout_id)
+            if not blackout:
+                raise LookupError(f"Blackout {blackout_id} not found")
+            result = blackout_to_dict(blackout)
+            session.delete(blackout)
+        logger.info(f"Removed sync blackout {blackout_id}")
+        events.publish("scheduler.blackout_removed", result)
+        return result
+
+    def upcoming(self, now: float | None = None) -> dict:
+        """Each library's schedule with its next full and next incremental (narrower scope) sync"""
+        now = time.time() if now is None else now
+        with self._lock, get_db() as session:
+            names = dict(session.query(UserProfile.steam_id, UserProfile.persona_name))
+            libraries = []
+            blackouts = self.blackout_windows(session, now)
+            for steam_id, schedules in self.library_schedules(session).items():
+                runs = upcoming_runs(schedules, now, blackouts)
+                incremental = min(({"scope": scope, "at": at} for scope, at in runs.items() if scope != "full"), key=lambda run: run["at"], default=None)
+                libraries.append({"steam_id": steam_id, "persona_name": names.get(steam_id), "schedule": [f"{expression}={scope}" for expression, scope in schedules], "next_full": runs.get("full"), "next_incremental": incremental, "last_run": self.last_runs.get(steam_id)})
+        # Libraries due soonest first; unscheduled ones last
+        libraries.sort(key=lambda library: min(filter(None, [library["next_full"], (library["next_incremental"] or {}).get("at")]), default=float("inf")))
+        return {"paused": self.paused, "blackouts": [{"starts_at": start, "ends_at": end} for start, end in blackouts], "generated_at": int(now), "libraries": libraries, "one_shots": self.list_one_shots()}
+
+    def tick(self, now: float | None = None) -> list[dict]:
+        """Queue every sync that came due since the previous tick, unless paused or in a blackout"""
+        now = time.time() if now is None else now
+        since = self.last_tick if self.last_tick is not None else now
+        with self._lock, get_db() as session:
+            self.stats["ticks"] += 1
+            results = []
+            blackout = any(start <= now < end for start, end in self.blackout_windows(session, now))
+            if blackout and self.pending:
+                logger.info(f"Skipping {len(self.pending)} pending scheduled syncs during a blackout")
+                publish_results([{"steam_id": steam_id, "scope": scope, "skipped": "blackout"} for steam_id, scopes in self.pending.items() for scope in scopes], "schedule")
+                self.pending.clear()
+            if not self.paused and not blackout:
+                results.extend(self._run_one_shots(now))
+                for steam_id, schedules in self.library_schedules(session).items():
+                    scopes = self.due(schedules, since, now)
+                    # A full sync that comes due replaces pending narrower ones, which otherwise add up
+                    if scopes:
+                        self.pending[steam_id] = merge_scopes([*self.pending.get(steam_id, []), *scopes])
+                results.extend(self._queue_pending(session, now))
+            self.last_tick = now
+            self.save()
+            publish_results(results, "schedule")
+            return results
+
+    def run_now(self) -> list[dict]:
+        """Queue each library's next scheduled sync immediately (every scope sharing that time), even while paused"""
+        now = time.time()
+        with self._lock, get_db() as session:
+            blackouts = self.blackout_windows(session, now)
+            due = {}
+            for steam_id, schedules in self.library_schedules(session).items():
+                runs = upcoming_runs(schedules, now, blackouts)
+                if runs:
+                    due[steam_id] = [scope for scope in SYNC_SCOPES if runs.get(scope) == min(runs.values())]
+            results = [self._queue(session, steam_id, scope, now) for steam_id in self.by_staleness(session, due) for scope in due[steam_id]]
+            self.save()
+        publish_results(results, "run_now")
+        logger.info(f"Manual scheduling pass queued {sum(1 for result in results if 'job_id' in result)} of {len(results)} scheduled syncs")
+        return results
+
+    def pause(self) -> dict:
+        """Stop queueing scheduled syncs until resume(); runs due meanwhile are skipped"""
+        return self._set_paused(True)
+
+    def resume(self) -> dict:
+        """Queue scheduled syncs again from the next due run"""
+        return self._set_paused(False)
+
+    def _set_paused(self, paused: bool) -> dict:
+        with self._lock:
+            self.paused = paused
+            # Due syncs waiting for a slot are skipped like the rest
+            if paused:
+                self.pending.clear()
+            self.save()
+        logger.info(f"Sync scheduler {'paused' if paused else 'resumed'}")
+        events.publish("scheduler.paused" if paused else "scheduler.resumed", {"paused": paused})
+        return self.status()
+
+    def _run_one_shots(self, now: float) -> list[dict]:
+        """Queue one-shot syncs whose time has come, at their requested scope unless the budget calls for a narrower one"""
+        results = []
+        with get_db_transaction() as session:
+            for one_shot in session.query(OneShotSync).filter(OneShotSync.status == "pending", OneShotSync.run_at <= now).order_by(OneShotSync.run_at):
+                scope, reason = self._fit_to_budget(session, one_shot.steam_id, one_shot.scope, now)
+                if not scope:
+                    one_shot.status, one_shot.detail = "skipped", reason
+                    self.stats["skipped"] += 1
+                    results.append({"steam_id": one_shot.steam_id, "scope": one_shot.scope, "one_shot_id": one_shot.id, "skipped": reason})
+                    continue
+                args = ["--scope", scope] if scope != "full" else []
+                try:
+                    job = submit_within_budget(session, one_shot.steam_id, args, priority="scheduled")
+                except (SyncAlreadyRunning, QuotaExceeded) as e:
+                    logger.info(f"Skipping one-shot sync {one_shot.id} for {one_shot.steam_id}: {e}")
+                    one_shot.status, one_shot.detail = "skipped", str(e)
+                    self.stats["skipped"] += 1
+                else:
+                    one_shot.status, one_shot.job_id, one_shot.queued_at = "queued", job.id, int(now)
+                    # A downgraded one-shot says why it ran at a narrower scope
+                    one_shot.detail = f"Ran as a {scope} sync: {reason}" if reason else None
+                    self.stats["queued"] += 1
+                results.append({"steam_id": one_shot.steam_id, "scope": scope, "one_shot_id": one_shot.id, **({"job_id": one_shot.job_id} if one_shot.status == "queued" else {"skipped": one_shot.detail})})
+        return results
+
+    def _queue_pending(self, session, now: float) -> list[dict]:
+        """Queue pending syncs, least recently synced first, up to the free concurrency slots"""
+        slots = max(0, self.max_concurrent - sync_queue.active_count("scheduled")) if self.max_concurrent else sum(map(len, self.pending.values()))
+        results = []
+        for steam_id in self.by_staleness(session, list(self.pending)):
+            scopes = self.pending[steam_id]
+            while scopes and slots > 0:
+                result = self._queue(session, steam_id, scopes.pop(0), now)
+                if "job_id" in result:
+                    slots -= 1
+                results.append(result)
+            if not scopes:
+                del self.pending[steam_id]
+        if self.pending:
+            logger.info(f"{sum(map(len, self.pending.values()))} scheduled syncs wait for one of {self.max_concurrent} concurrent slots")
+        return results
+
+    def fit_to_budget(self, session, steam_id: str, scope: str) -> tuple[str | None, str | None]:
+        """The scope to run within the budget left for scheduled syncs (None to skip), and why it changed"""
+        budget = quota_status(session)
+        if not budget["budget"]:
+            return scope, None
+        available = max(0, budget["remaining"] - budget["budget"] * self.quota_reserve // 100)
+        estimates = {candidate: estimate_requests(session, steam_id, ["--scope", candidate] if candidate != "full" else []) for candidate in dict.fromkeys([scope, "playtime"])}
+        if estimates[scope] <= available:
+            return scope, None
+        reason = f"a {scope} sync needs about {estimates[scope]} requests but only {available} are left for scheduled syncs"
+        return ("playtime" if estimates["playtime"] <= available else None), reason
+
+    def _fit_to_budget(self, session, steam_id: str, requested: str, now: float) -> tuple[str | None, str | None]:
+        """fit_to_budget, recording and logging a downgrade or skip"""
+        scope, reason = self.fit_to_budget(session, steam_id, requested)
+        if scope != requested:
+            self.quota_decisions.append({"at": int(now), "steam_id": steam_id, "requested": requested, "scope": scope, "reason": reason})
+            logger.info(f"{'Downgrading' if scope else 'Skipping'} scheduled {requested} sync for {steam_id}{f' to {scope}' if scope else ''}: {reason}")
+        return scope, reason
+
+    def _queue(self, session, steam_id: str, scope: str, now: float) -> dict:
+        """Submit one scheduled sync, recording it as the library's last run"""
+        requested = scope
+        scope, reason = self._fit_to_budget(session, steam_id, scope, now)
+        if not scope:
+            self.stats["skipped"] += 1
+            return {"steam_id": steam_id, "scope": requested, "skipped": reason}
+        args = ["--scope", scope] if scope != "full" else []
+        try:
+            job = submit_within_budget(session, steam_id, args, priority="scheduled")
+        except (SyncAlreadyRunning, QuotaExceeded) as e:
+            logger.info(f"Skipping scheduled {scope} sync for {steam_id}: {e}")
+            self.stats["skipped"] += 1
+            return {"steam_id": steam_id, "scope": scope, "skipped": str(e)}
+        self.last_runs[steam_id] = {"scope": scope, "queued_at": int(now), "job_id": job.id}
+        self.stats["queued"] += 1
+        return {"steam_id": steam_id, "scope": scope, "job_id": job.id}
+
+    def status(self) -> dict:
+        """Default schedule, whether paused, counters, the last sync queued per library, and each library's next run"""
+        now = time.time()
+        with self._lock, get_db() as session:
+            blackouts = self.blackout_windows(session, now)
+            return {"running": self._thread is not None, "paused": self.paused, "in_blackout": any(start <= now < end for start, end in blackouts), "max_concurrent": self.max_concurrent or None, "pending": {steam_id: list(scopes) for steam_id, scopes in self.pending.items()}, "quota_decisions": list(reversed(self.quota_decisions)), "default_schedule": [f"{expression}={scope}" for expression, scope in self.default_schedule], "last_tick": int(self.last_tick) if self.last_tick else None, "stats": dict(self.stats), "last_runs": dict(self.last_runs), "next_runs": self.next_runs(session, now)}
+
+    def _run(self):
+        while True:
+            # Wake just after each minute boundary, when cron expressions fire
+            time.sleep(TICK_SECONDS - time.time() % TICK_SECONDS + 1)
+            try:
+                self.tick()
+            except Exception as e:
+                self.stats["errors"] += 1
+                logger.error(f"Scheduled sync check failed: {e}")
+
+
+# Started by run_server; the web API reads its status
+scheduler = Scheduler(config.sync_schedule, config.scheduler_catch_up, config.max_concurrent_auto_syncs, config.scheduler_quota_reserve)
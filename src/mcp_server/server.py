@@ -1,20 +1,24 @@
 """Steam Librarian MCP Server - Simplified HTTP Streaming Implementation"""
 
+import json
 import logging
 
 from mcp.server.fastmcp import FastMCP
+from mcp.shared.version import SUPPORTED_PROTOCOL_VERSIONS
 from mcp.types import (
+    LATEST_PROTOCOL_VERSION,
     Completion,
     CompletionArgument,
     CompletionContext,
     PromptReference,
     ResourceTemplateReference,
 )
-from sqlalchemy import create_engine, text
+from sqlalchemy import create_engine, inspect, text
 from starlette.requests import Request
 from starlette.responses import JSONResponse, PlainTextResponse
 
 from .config import config
+from .sync import sync_queue
 
 # Configure logging
 logging.basicConfig(level=logging.DEBUG if config.debug else logging.INFO, format="%(asctime)s - %(name)s - %(levelname)s - %(message)s")
@@ -50,7 +54,7 @@ async def health_detailed(request: Request) -> JSONResponse:
         with engine.connect() as conn:
             conn.execute(text("SELECT 1")).fetchone()
 
-        health_info = {"status": "healthy", "server": "steam-librarian-simplified", "default_user": config.default_user, "debug": config.debug, "version": "1.1.2", "database": "connected"}
+        health_info = {"status": "healthy", "server": "steam-librarian-simplified", "default_user": config.default_user, "debug": config.debug, "version": "1.1.2", "database": "connected", "protocol_versions": SUPPORTED_PROTOCOL_VERSIONS, "latest_protocol_version": LATEST_PROTOCOL_VERSION}
 
         return JSONResponse(health_info)
     except Exception as e:
@@ -59,6 +63,66 @@ async def health_detailed(request: Request) -> JSONResponse:
         return JSONResponse(error_info, status_code=503)
 
 
+# Metric families written by the fetcher, with their Prometheus types and help text
+METRIC_FAMILIES = {
+    "steam_api_requests_total": ("counter", "Steam API requests by endpoint and HTTP status"),
+    "steam_api_errors_total": ("counter", "Steam API responses with HTTP status 400 or above"),
+    "steam_api_cache_lookups_total": ("counter", "Response cache lookups by endpoint and result (hit/miss)"),
+    "steam_api_request_duration_seconds": ("histogram", "Steam API request latency"),
+    "steam_library_syncs_started_total": ("counter", "Library syncs started, by scope"),
+    "steam_library_syncs_completed_total": ("counter", "Library syncs that finished, by scope"),
+    "steam_library_syncs_failed_total": ("counter", "Library syncs that stopped early, by scope and reason (error/cancelled)"),
+    "steam_library_games_processed_total": ("counter", "Games written by library syncs, by status (saved/fallback/failed)"),
+    "steam_library_sync_duration_seconds": ("histogram", "Library sync duration, by outcome"),
+    "steam_library_last_sync_games_per_second": ("gauge", "Games written per second by the most recent library sync"),
+    "steam_library_last_sync_timestamp_seconds": ("gauge", "Unix time the most recent library sync ended, by outcome"),
+}
+
+
+def format_metric_labels(labels_json: str) -> str:
+    """Render stored JSON labels as a Prometheus label set"""
+    labels = json.loads(labels_json)
+    if not labels:
+        return ""
+    return "{" + ",".join(f'{key}="{value}"' for key, value in labels.items()) + "}"
+
+
+@mcp.custom_route("/metrics", methods=["GET"])
+async def metrics(request: Request) -> PlainTextResponse:
+    """Steam API usage and library sync metrics, in Prometheus text format"""
+    try:
+        tables = inspect(engine)
+        rows, active = [], []
+        with engine.connect() as conn:
+            if tables.has_table("api_metrics"):
+                rows = conn.execute(text("SELECT name, labels, value FROM api_metrics ORDER BY name, labels")).fetchall()
+            # Checkpoints cover syncs started anywhere (cronjob, CLI, or this server)
+            if tables.has_table("sync_checkpoints"):
+                active = conn.execute(text("SELECT status, COUNT(*) FROM sync_checkpoints WHERE status IN ('running', 'paused') GROUP BY status")).fetchall()
+
+        lines = []
+        for family, (metric_type, help_text) in METRIC_FAMILIES.items():
+            samples = [row for row in rows if row.name == family or row.name.startswith(f"{family}_")]
+            if not samples:
+                continue
+            lines.append(f"# HELP {family} {help_text}")
+            lines.append(f"# TYPE {family} {metric_type}")
+            lines.extend(f"{row.name}{format_metric_labels(row.labels)} {row.value:.17g}" for row in samples)
+
+        counts = dict(active)
+        lines.append("# HELP steam_library_syncs_active Library syncs in progress, by state (running/paused)")
+        lines.append("# TYPE steam_library_syncs_active gauge")
+        lines.extend(f'steam_library_syncs_active{{state="{state}"}} {counts.get(state, 0)}' for state in ("running", "paused"))
+        lines.append("# HELP steam_library_syncs_queued Syncs waiting for a worker in this server's queue")
+        lines.append("# TYPE steam_library_syncs_queued gauge")
+        lines.append(f"steam_library_syncs_queued {len(sync_queue.status()['queued'])}")
+
+        return PlainTextResponse("\n".join(lines) + "\n", media_type="text/plain; version=0.0.4")
+    except Exception as e:
+        logger.error(f"Metrics export failed: {e}")
+        return PlainTextResponse(f"# metrics unavailable: {str(e)}\n", status_code=500)
+
+
 # Basic completion handler
 @mcp.completion()
 async def handle_completion(
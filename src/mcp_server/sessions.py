@@ -0,0 +1,54 @@
+"""Per-session state for notifications sent from other threads
+
+Log records and sync results arrive on worker threads, but each MCP session
+belongs to the event loop that serves it. LiveSessions keeps a piece of state
+for each session (a log level, a set of subscribed URIs) along with that loop,
+and hands notifications to the loop to send.
+"""
+
+import asyncio
+import threading
+import weakref
+
+
+class LiveSessions:
+    """State for each connected session, and a way to send to it from any thread"""
+
+    def __init__(self):
+        # Sessions drop out on their own once the client disconnects
+        self._sessions: weakref.WeakKeyDictionary = weakref.WeakKeyDictionary()
+        self._lock = threading.Lock()
+
+    def set(self, session, state):
+        """Keep state for a session; call from the session's event loop"""
+        with self._lock:
+            self._sessions[session] = (state, asyncio.get_running_loop())
+
+    def get(self, session, default=None):
+        with self._lock:
+            return self._sessions.get(session, (default, None))[0]
+
+    def discard(self, session):
+        with self._lock:
+            self._sessions.pop(session, None)
+
+    def items(self) -> list[tuple]:
+        """(session, state) for every session, as they are now"""
+        with self._lock:
+            return [(session, state) for session, (state, _) in self._sessions.items()]
+
+    def send(self, session, notification):
+        """Run a notification coroutine on the session's event loop, dropping the session if sending fails"""
+        with self._lock:
+            _, loop = self._sessions.get(session, (None, None))
+        if loop is None:
+            notification.close()
+            return
+        try:
+            future = asyncio.run_coroutine_threadsafe(notification, loop)
+        except RuntimeError:
+            # The session's event loop has shut down
+            notification.close()
+            self.discard(session)
+            return
+        future.add_done_callback(lambda done: self.discard(session) if not done.cancelled() and done.exception() else None)
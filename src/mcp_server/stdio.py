@@ -0,0 +1,129 @@
+"""stdio transport with Content-Length framing detection
+
+The MCP SDK's stdio transport reads newline-delimited JSON, but some hosts frame
+messages LSP-style, with a Content-Length header and a blank line before each
+body. Each incoming message is read in whichever framing it arrives in, and
+responses use the framing of the last message received, so either kind of host
+works without configuration. Messages aren't limited in size in either framing.
+
+Logs go to stderr, so stdout carries nothing but protocol messages.
+"""
+
+import logging
+import re
+import sys
+from contextlib import asynccontextmanager
+
+import anyio
+import mcp.types as types
+from mcp.shared.message import SessionMessage
+
+from .server import mcp
+from .subscriptions import initialization_options
+
+logger = logging.getLogger(__name__)
+
+# The start of a header line, such as "Content-Length: 52"
+HEADER = re.compile(rb"^[A-Za-z-]+:\s*")
+
+
+class Framing:
+    """How the host frames its messages, updated as each one arrives"""
+
+    def __init__(self):
+        self.content_length = False
+
+    def frame(self, body: bytes) -> bytes:
+        """A message body framed the way the host sends its own"""
+        if self.content_length:
+            return b"Content-Length: %d\r\n\r\n" % len(body) + body
+        return body + b"\n"
+
+    async def read(self, stream) -> bytes | None:
+        """The next message body from stream, or None at end of input
+
+        stream needs async readline() and read(n), like an anyio-wrapped binary file.
+        """
+        while True:
+            line = await stream.readline()
+            if not line:
+                return None
+            if line.strip():
+                break
+
+        # Anything that isn't a header is a newline-delimited message; a stray line that
+        # isn't JSON fails to parse and the session reports it
+        if not HEADER.match(line):
+            self.content_length = False
+            return line.strip()
+
+        # Headers run to a blank line; only Content-Length matters (Content-Type is always JSON)
+        self.content_length = True
+        length = None
+        while line.strip():
+            name, _, value = line.partition(b":")
+            if name.strip().lower() == b"content-length":
+                length = value.strip()
+            line = await stream.readline()
+            if not line:
+                return None
+        if length is None:
+            raise ValueError("Content-Length header without a length")
+        if not length.isdigit():
+            raise ValueError(f"Content-Length header isn't a number: {length.decode(errors='replace')}")
+        length = int(length)
+
+        body = b""
+        while len(body) < length:
+            chunk = await stream.read(length - len(body))
+            if not chunk:
+                return None
+            body += chunk
+        return body
+
+
+@asynccontextmanager
+async def stdio_server():
+    """Read and write streams for the low-level server over this process's stdin and stdout"""
+    stdin = anyio.wrap_file(sys.stdin.buffer)
+    stdout = anyio.wrap_file(sys.stdout.buffer)
+    framing = Framing()
+
+    read_stream_writer, read_stream = anyio.create_memory_object_stream(0)
+    write_stream, write_stream_reader = anyio.create_memory_object_stream(0)
+
+    async def stdin_reader():
+        async with read_stream_writer:
+            while True:
+                try:
+                    body = await framing.read(stdin)
+                except ValueError as e:
+                    logger.warning(f"Skipping malformed stdio message: {e}")
+                    continue
+                if body is None:
+                    return
+                try:
+                    message = types.JSONRPCMessage.model_validate_json(body)
+                except Exception as e:
+                    # Passed on for the session to handle, as the SDK's own stdio transport does
+                    await read_stream_writer.send(e)
+                    continue
+                await read_stream_writer.send(SessionMessage(message))
+
+    async def stdout_writer():
+        async with write_stream_reader:
+            async for session_message in write_stream_reader:
+                body = session_message.message.model_dump_json(by_alias=True, exclude_none=True).encode()
+                await stdout.write(framing.frame(body))
+                await stdout.flush()
+
+    async with anyio.create_task_group() as tg:
+        tg.start_soon(stdin_reader)
+        tg.start_soon(stdout_writer)
+        yield read_stream, write_stream
+
+
+async def run_stdio():
+    """Serve MCP over stdin and stdout until the host closes stdin"""
+    async with stdio_server() as (read_stream, write_stream):
+        await mcp._mcp_server.run(read_stream, write_stream, initialization_options())
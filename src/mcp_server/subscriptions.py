@@ -0,0 +1,84 @@
+"""MCP resource subscriptions
+
+Clients subscribe to resource URIs with resources/subscribe. When a sync run by
+this server finishes, every subscribed URI that describes the synced library gets
+a notifications/resources/updated, so the client knows to read it again instead
+of re-reading everything after each sync.
+"""
+
+from mcp.server.lowlevel import Server
+from mcp.server.models import InitializationOptions
+from pydantic import AnyUrl
+
+from shared.database import resolve_user_for_tool
+
+from .resources import get_default_user_fallback
+from .server import mcp
+from .sessions import LiveSessions
+from .sync import sync_queue
+
+# Resources covering every library, which a sync of any library can change
+ALL_LIBRARIES_URIS = {"library://users", "library://overview"}
+
+
+def changed_by_sync(uri: str, steam_id: str, default_steam_id: str | None = None) -> bool:
+    """Whether a sync of steam_id can change the resource at uri"""
+    if uri in ALL_LIBRARIES_URIS or uri.startswith("library://users/page/"):
+        return True
+    # library://users/default/... resources follow the default user's library
+    owners = [steam_id, "default"] if steam_id == default_steam_id else [steam_id]
+    return any(uri == f"library://users/{owner}" or uri.startswith(f"library://users/{owner}/") for owner in owners)
+
+
+class Subscriptions:
+    """Resource URIs each session is subscribed to"""
+
+    def __init__(self):
+        # Each session's set of URIs, replaced rather than changed so notify_sync can read it from another thread
+        self.sessions = LiveSessions()
+
+    def subscribe(self, session, uri: str):
+        self.sessions.set(session, self.sessions.get(session, frozenset()) | {uri})
+
+    def unsubscribe(self, session, uri: str):
+        self.sessions.set(session, self.sessions.get(session, frozenset()) - {uri})
+
+    def notify_sync(self, job: dict):
+        """Tell subscribers which of their resources a finished sync may have changed"""
+        targets = [(session, uris) for session, uris in self.sessions.items() if uris]
+        if not targets:
+            return
+
+        default_steam_id = resolve_user_for_tool(None, get_default_user_fallback).get("steam_id")
+        for session, uris in targets:
+            for uri in uris:
+                if changed_by_sync(uri, job["steam_id"], default_steam_id):
+                    self.sessions.send(session, session.send_resource_updated(AnyUrl(uri)))
+
+
+subscriptions = Subscriptions()
+sync_queue.add_listener(subscriptions.notify_sync)
+
+
+def initialization_options(notification_options=None, experimental_capabilities=None) -> InitializationOptions:
+    """The server's own initialization options, advertising resources.subscribe since this module handles it"""
+    options = Server.create_initialization_options(mcp._mcp_server, notification_options, experimental_capabilities)
+    if options.capabilities.resources:
+        options.capabilities.resources.subscribe = True
+    return options
+
+
+# The streamable HTTP session manager starts each session with the server's create_initialization_options()
+mcp._mcp_server.create_initialization_options = initialization_options
+
+
+@mcp._mcp_server.subscribe_resource()
+async def subscribe_resource(uri: AnyUrl) -> None:
+    """Notify this session when the resource changes"""
+    subscriptions.subscribe(mcp._mcp_server.request_context.session, str(uri))
+
+
+@mcp._mcp_server.unsubscribe_resource()
+async def unsubscribe_resource(uri: AnyUrl) -> None:
+    """Stop notifying this session about the resource"""
+    subscriptions.unsubscribe(mcp._mcp_server.request_context.session, str(uri))
@@ -0,0 +1,574 @@
+"""Run the Steam library fetcher from the MCP server
+
+Syncs normally run on the fetcher's schedule. The web API and MCP tools can also
+request one; requests go into a priority queue (manual before scheduled) and are
+run as child processes by a fixed number of workers (SYNC_WORKERS), so a long sync
+never blocks the server. Two jobs for the same library never run at once. The
+child inherits the server's environment, so STEAM_API_KEY must be set for the
+server as well.
+
+A running job can be paused and resumed: the fetcher holds before its next API
+call on SIGUSR1 and carries on after SIGUSR2, keeping all progress. A paused job
+still occupies its worker. Cancelling sends SIGTERM, which makes the fetcher abort
+its in-flight requests at once and save an "interrupted" checkpoint.
+
+preview_sync runs the fetcher's --dry-run directly, outside the queue, since it
+writes nothing and makes a single Steam request.
+"""
+
+import itertools
+import json
+import logging
+import os
+import signal
+import subprocess
+import sys
+import tempfile
+import threading
+import time
+from collections import deque
+from collections.abc import Callable, Iterable
+from dataclasses import dataclass, field
+from pathlib import Path
+
+from shared.database import SYNC_SCOPES, ApiUsage, SyncCheckpoint, UserGame, api_usage_day, get_db_transaction
+
+from .config import config
+from .events import events
+
+logger = logging.getLogger(__name__)
+
+FETCHER_MODULE = "fetcher.steam_library_fetcher"
+SRC_DIR = Path(__file__).parent.parent
+
+# How long a --dry-run preview may take before it's given up on
+PREVIEW_TIMEOUT_SECONDS = 120
+
+# A "running" checkpoint updated more recently than this likely belongs to a live sync (e.g. the cron job)
+ACTIVE_CHECKPOINT_SECONDS = 15 * 60
+
+# Lower values run first
+PRIORITIES = {"manual": 0, "scheduled": 1}
+
+# Finished jobs kept for status reporting
+FINISHED_JOB_HISTORY = 20
+
+# Estimated Steam requests for a library that hasn't completed a sync with the same options:
+# a fixed cost (profile, owned games, badges, ...) plus a cost per game for each scope
+SYNC_BASE_REQUESTS = 10
+GAME_REQUESTS = {"full": 3, "playtime": 0, "metadata": 2, "reviews": 1}
+
+
+def fetcher_env(steam_id: str) -> dict[str, str]:
+    """Environment for a fetcher child process syncing steam_id"""
+    return {**os.environ, "STEAM_ID": steam_id, "PYTHONPATH": os.pathsep.join(filter(None, [str(SRC_DIR), os.environ.get("PYTHONPATH")]))}
+
+
+def checkpoint_to_dict(checkpoint: SyncCheckpoint) -> dict:
+    """Serialize a sync checkpoint"""
+    return {"steam_id": checkpoint.steam_id, "status": checkpoint.status, "resumable": checkpoint.resumable, "processed_games": checkpoint.processed_count, "failed_app_ids": json.loads(checkpoint.failed_app_ids or "[]"), "retry_app_ids": json.loads(checkpoint.retry_app_ids or "[]"), "cursor": checkpoint.cursor, "total_games": checkpoint.total_games, "api_requests": checkpoint.api_requests, "options": json.loads(checkpoint.options or "[]"), "started_at": checkpoint.started_at, "updated_at": checkpoint.updated_at}
+
+
+class SyncAlreadyRunning(RuntimeError):
+    """Raised when a sync is requested while the same sync is still queued or running"""
+
+
+class QuotaExceeded(RuntimeError):
+    """Raised when a manual sync would take more Steam requests than today's budget has left"""
+
+
+class InvalidSyncOption(ValueError):
+    """Raised for a sync option (scope or priority) outside its allowed values"""
+
+    def __init__(self, name: str, value: str, allowed: Iterable[str]):
+        self.field = name
+        self.allowed = list(allowed)
+        super().__init__(f"Unknown {name} '{value}': expected one of {', '.join(self.allowed)}")
+
+
+@dataclass(eq=False)
+class SyncJob:
+    """One fetcher run requested through the server"""
+
+    id: int
+    steam_id: str
+    args: list[str]
+    priority: str
+    state: str = "queued"  # queued, running, paused, or finished
+    submitted_at: int = field(default_factory=lambda: int(time.time()))
+    started_at: int | None = None
+    finished_at: int | None = None
+    exit_code: int | None = None
+    pid: int | None = None
+    cancelled: bool = False
+    estimated_requests: int = 0
+    not_before: int | None = None  # Deferred until this Unix time (the next budget reset)
+    done: threading.Event = field(default_factory=threading.Event, repr=False)
+
+    @property
+    def sort_key(self) -> tuple[int, int]:
+        return PRIORITIES[self.priority], self.id
+
+    def to_dict(self, position: int | None = None) -> dict:
+        data = {"id": self.id, "steam_id": self.steam_id, "args": self.args, "priority": self.priority, "state": self.state, "submitted_at": self.submitted_at, "started_at": self.started_at, "finished_at": self.finished_at, "exit_code": self.exit_code, "pid": self.pid, "cancelled": self.cancelled, "estimated_requests": self.estimated_requests, "deferred_until": self.not_before}
+        if position is not None:
+            data["position"] = position
+        return data
+
+
+class SyncQueue:
+    """Priority queue of fetcher runs executed as child processes by a pool of workers"""
+
+    def __init__(self, workers: int = 1):
+        self.workers = workers
+        self._condition = threading.Condition()
+        self._ids = itertools.count(1)
+        self._queued: list[SyncJob] = []
+        self._running: dict[int, SyncJob] = {}
+        self._finished: deque[SyncJob] = deque(maxlen=FINISHED_JOB_HISTORY)
+        self._jobs: dict[int, SyncJob] = {}
+        self._threads: list[threading.Thread] = []
+        self._listeners: list[Callable[[dict], None]] = []
+
+    def add_listener(self, listener: Callable[[dict], None]):
+        """Call listener with each job that finishes after running, from the worker thread that ran it"""
+        self._listeners.append(listener)
+
+    def submit(self, steam_id: str, args: list[str], priority: str = "manual") -> dict:
+        """Queue a fetcher run for steam_id, returning the job with its queue position"""
+        job = self.submit_job(steam_id, args, priority)
+        return self.job(job.id) or job.to_dict()
+
+    def submit_job(self, steam_id: str, args: list[str], priority: str = "manual", estimated_requests: int = 0, not_before: int | None = None) -> SyncJob:
+        """Queue a fetcher run for steam_id and return the job itself, held back until not_before if given"""
+        if priority not in PRIORITIES:
+            raise InvalidSyncOption("priority", priority, PRIORITIES)
+
+        with self._condition:
+            for job in [*self._running.values(), *self._queued]:
+                if job.steam_id == steam_id and job.args == args:
+                    events.publish("sync.conflict", {"steam_id": steam_id, "args": args, "priority": priority, "existing": job.to_dict(self._position(job))})
+                    raise SyncAlreadyRunning(f"The same sync for {steam_id} is already {job.state}")
+
+            job = SyncJob(id=next(self._ids), steam_id=steam_id, args=args, priority=priority, estimated_requests=estimated_requests, not_before=not_before)
+            self._queued.append(job)
+            self._queued.sort(key=lambda queued: queued.sort_key)
+            self._jobs[job.id] = job
+            self._start_workers()
+            self._condition.notify_all()
+            logger.info(f"Queued {priority} sync {job.id} for {steam_id}: {' '.join(args) or '(default options)'}")
+            events.publish("sync.queued", job.to_dict(self._position(job)))
+            return job
+
+    def wait(self, job_id: int, timeout: float) -> int | None:
+        """Wait up to timeout seconds for a job, returning its exit code or None if it hasn't finished"""
+        job = self._jobs.get(job_id)
+        if job is None or not job.done.wait(timeout):
+            return None
+        return job.exit_code
+
+    def job(self, job_id: int) -> dict | None:
+        """Describe one job, including its queue position while it's waiting"""
+        with self._condition:
+            job = self._jobs.get(job_id)
+            return job.to_dict(self._position(job)) if job else None
+
+    def active_job(self, steam_id: str | None = None, state: str | None = None) -> SyncJob | None:
+        """Return a running or paused job, for steam_id if given, optionally only in one state"""
+        with self._condition:
+            return next((job for job in self._running.values() if (not steam_id or job.steam_id == steam_id) and (not state or job.state == state)), None)
+
+    def reserved_requests(self) -> int:
+        """Estimated Steam requests of running jobs and of queued jobs that aren't deferred"""
+        now = time.time()
+        with self._condition:
+            return sum(job.estimated_requests for job in [*self._running.values(), *self._queued] if not job.not_before or job.not_before <= now)
+
+    def active_count(self, priority: str | None = None) -> int:
+        """Queued, running, and paused jobs, only of one priority if given"""
+        with self._condition:
+            return sum(1 for job in [*self._running.values(), *self._queued] if not priority or job.priority == priority)
+
+    def queued_job(self, steam_id: str | None = None) -> SyncJob | None:
+        """Return the next queued job, for steam_id if given"""
+        with self._condition:
+            return next((job for job in self._queued if not steam_id or job.steam_id == steam_id), None)
+
+    def cancel(self, job_id: int) -> dict:
+        """Drop a queued job or stop a running (or paused) one, raising LookupError if it has finished"""
+        with self._condition:
+            job = self._jobs.get(job_id)
+            if not job or job.state == "finished":
+                raise LookupError(f"Sync {job_id} is not queued or running")
+            job.cancelled = True
+            if job.state == "queued":
+                self._queued.remove(job)
+                job.state = "finished"
+                job.finished_at = int(time.time())
+                self._finished.append(job)
+                job.done.set()
+            elif job.pid:
+                try:
+                    os.kill(job.pid, signal.SIGTERM)
+                except ProcessLookupError:
+                    # The fetcher exited on its own; its worker thread records how it ended
+                    pass
+            logger.info(f"Cancelled sync {job.id} for {job.steam_id}")
+            events.publish("sync.cancelled", job.to_dict())
+            return job.to_dict()
+
+    def pause(self, job_id: int) -> dict:
+        """Pause a running job before its next API call, raising LookupError if it isn't running"""
+        return self._signal(job_id, "running", "paused", "SIGUSR1")
+
+    def unpause(self, job_id: int) -> dict:
+        """Let a paused job continue, raising LookupError if it isn't paused"""
+        return self._signal(job_id, "paused", "running", "SIGUSR2")
+
+    def _signal(self, job_id: int, expected: str, new_state: str, signal_name: str) -> dict:
+        if not hasattr(signal, signal_name):
+            raise LookupError(f"Pausing syncs isn't supported on this platform (no {signal_name})")
+        with self._condition:
+            job = self._jobs.get(job_id)
+            if not job or job.state != expected or not job.pid:
+                raise LookupError(f"Sync {job_id} is not {expected}")
+            try:
+                os.kill(job.pid, getattr(signal, signal_name))
+            except ProcessLookupError:
+                raise LookupError(f"Sync {job_id} has already exited") from None
+            job.state = new_state
+            logger.info(f"Sync {job.id} for {job.steam_id} is now {new_state}")
+            events.publish("sync.paused" if new_state == "paused" else "sync.resumed", job.to_dict())
+            return job.to_dict()
+
+    def status(self) -> dict:
+        """Running (or paused), queued, and recently finished jobs"""
+        with self._condition:
+            return {"workers": self.workers, "running": [job.to_dict() for job in self._running.values()], "queued": [job.to_dict(position) for position, job in enumerate(self._queued, 1)], "finished": [job.to_dict() for job in reversed(self._finished)]}
+
+    def history(self) -> list[dict]:
+        """Finished jobs still in the history, most recent first"""
+        with self._condition:
+            return [job.to_dict() for job in reversed(self._finished)]
+
+    def _position(self, job: SyncJob) -> int | None:
+        return self._queued.index(job) + 1 if job in self._queued else None
+
+    def _start_workers(self):
+        # Workers start with the first job so importing the server never spawns threads
+        while len(self._threads) < self.workers:
+            thread = threading.Thread(target=self._work, name=f"sync-worker-{len(self._threads) + 1}", daemon=True)
+            self._threads.append(thread)
+            thread.start()
+
+    def _next_job(self) -> SyncJob:
+        """Block until a job can run; jobs for a library that's already syncing wait their turn"""
+        with self._condition:
+            while True:
+                busy = {job.steam_id for job in self._running.values()}
+                now = time.time()
+                job = next((job for job in self._queued if job.steam_id not in busy and (not job.not_before or job.not_before <= now)), None)
+                if job:
+                    self._queued.remove(job)
+                    job.state = "running"
+                    job.started_at = int(time.time())
+                    self._running[job.id] = job
+                    events.publish("sync.started", job.to_dict())
+                    return job
+                # Wake up for the earliest deferred job as well as for new or finished ones
+                deferred = [job.not_before for job in self._queued if job.not_before and job.not_before > now]
+                self._condition.wait(timeout=min(deferred) - now if deferred else None)
+
+    def _work(self):
+        while True:
+            job = self._next_job()
+            try:
+                logger.info(f"Starting sync {job.id} for {job.steam_id}")
+                process = subprocess.Popen([sys.executable, "-m", FETCHER_MODULE, *job.args], env=fetcher_env(job.steam_id))
+                job.pid = process.pid
+                # Cancelled between leaving the queue and starting
+                if job.cancelled:
+                    process.terminate()
+                job.exit_code = process.wait()
+            except Exception as e:
+                logger.error(f"Sync {job.id} for {job.steam_id} failed to run: {e}")
+                job.exit_code = -1
+
+            with self._condition:
+                del self._running[job.id]
+                job.state = "finished"
+                job.finished_at = int(time.time())
+                self._finished.append(job)
+                # Only finished jobs still in the history can be looked up
+                self._jobs = {job_id: known for job_id, known in self._jobs.items() if known.state != "finished" or known in self._finished}
+                self._condition.notify_all()
+            job.done.set()
+            if job.exit_code and not job.cancelled:
+                logger.warning(f"Sync {job.id} for {job.steam_id} failed with exit code {job.exit_code}")
+            else:
+                logger.info(f"Sync {job.id} for {job.steam_id} finished with exit code {job.exit_code}")
+            events.publish("sync.failed" if job.exit_code and not job.cancelled else "sync.finished", job.to_dict())
+            for listener in self._listeners:
+                try:
+                    listener(job.to_dict())
+                except Exception as e:
+                    logger.error(f"Sync listener failed for sync {job.id}: {e}")
+
+
+# Shared by the web API and MCP tools
+sync_queue = SyncQueue(workers=config.sync_workers)
+
+
+def find_checkpoint(session, steam_id: str | None = None) -> SyncCheckpoint | None:
+    """Return the checkpoint for steam_id, or the most recently updated one"""
+    if steam_id:
+        return session.get(SyncCheckpoint, steam_id)
+    return session.query(SyncCheckpoint).order_by(SyncCheckpoint.updated_at.desc()).first()
+
+
+def next_budget_reset(now: float | None = None) -> int:
+    """Unix time of the next UTC midnight, when the daily request budget starts over"""
+    now = time.time() if now is None else now
+    return (int(now) // 86400 + 1) * 86400
+
+
+def quota_status(session) -> dict:
+    """Today's Steam request budget: requests made, reserved by queued and running syncs, and left
+
+    Usage is recorded by each fetcher when it exits, so a running sync is counted by
+    its estimate until then. budget and remaining are None when no budget is set.
+    """
+    budget = config.steam_api_daily_budget
+    usage = session.get(ApiUsage, api_usage_day())
+    used = usage.requests if usage else 0
+    reserved = sync_queue.reserved_requests()
+    return {"budget": budget or None, "used": used, "reserved": reserved, "remaining": max(0, budget - used - reserved) if budget else None, "resets_at": next_budget_reset()}
+
+
+def estimate_requests(session, steam_id: str, args: list[str]) -> int:
+    """Estimate how many Steam requests a fetcher run with args will make"""
+    scope = args[args.index("--scope") + 1] if "--scope" in args else "full"
+    if "--game" in args:
+        return SYNC_BASE_REQUESTS + GAME_REQUESTS[scope]
+
+    # A completed sync with the same options is the best guide, since it reflects the response cache
+    checkpoint = session.get(SyncCheckpoint, steam_id)
+    options = [arg for arg in args if arg != "--resume"]
+    if checkpoint and checkpoint.status == "completed" and checkpoint.api_requests and json.loads(checkpoint.options or "[]") == options:
+        return checkpoint.api_requests
+
+    games = session.query(UserGame).filter_by(steam_id=steam_id).count()
+    if "--resume" in args and checkpoint:
+        games = max(0, (checkpoint.total_games or games) - checkpoint.processed_count)
+    return SYNC_BASE_REQUESTS + games * GAME_REQUESTS[scope]
+
+
+_budget_lock = threading.Lock()
+
+
+def submit_within_budget(session, steam_id: str, args: list[str], priority: str = "manual") -> SyncJob:
+    """Queue a fetcher run unless it would overrun today's request budget
+
+    A manual sync that doesn't fit raises QuotaExceeded; a scheduled one is
+    deferred until the budget resets at UTC midnight.
+    """
+    estimate = estimate_requests(session, steam_id, args)
+    not_before = None
+    # Syncs started at the same time from different requests (or the scheduler) mustn't both spend the same remaining budget
+    with _budget_lock:
+        budget = quota_status(session)
+        if budget["budget"] and estimate > budget["remaining"]:
+            if priority == "manual":
+                raise QuotaExceeded(f"Syncing {steam_id} needs about {estimate} Steam requests but only {budget['remaining']} of today's budget of {budget['budget']} are left")
+            not_before = budget["resets_at"]
+            logger.info(f"Deferring sync for {steam_id} until the request budget resets ({estimate} requests estimated, {budget['remaining']} left)")
+        return sync_queue.submit_job(steam_id, args, priority, estimate, not_before)
+
+
+class SyncBatch:
+    """Jobs queued together by sync_all_libraries, tracked as one unit of progress"""
+
+    def __init__(self, batch_id: int, jobs: list[SyncJob], skipped: list[dict]):
+        self.id = batch_id
+        self.jobs = jobs
+        self.skipped = skipped
+        self.created_at = int(time.time())
+
+    def to_dict(self) -> dict:
+        states = [job.state for job in self.jobs]
+        finished = [job for job in self.jobs if job.state == "finished"]
+        failed = sum(1 for job in finished if job.exit_code != 0)
+        return {"id": self.id, "created_at": self.created_at, "total": len(self.jobs), "queued": states.count("queued"), "running": states.count("running") + states.count("paused"), "finished": len(finished), "failed": failed, "done": len(finished) == len(self.jobs), "jobs": [sync_queue.job(job.id) or job.to_dict() for job in self.jobs], "skipped": self.skipped}
+
+
+_batches: dict[int, SyncBatch] = {}
+_batch_ids = itertools.count(1)
+_batches_lock = threading.Lock()
+
+
+def with_scope(options: list[str], scope: str) -> list[str]:
+    """Fetcher options with any --scope replaced by scope"""
+    args = []
+    options = iter(options)
+    for option in options:
+        if option == "--scope":
+            next(options, None)
+        elif not option.startswith("--scope="):
+            args.append(option)
+    return [*args, "--scope", scope] if scope != "full" else args
+
+
+def sync_all_libraries(session, priority: str = "manual", scope: str = "full") -> dict:
+    """Queue a sync of every library that has been synced before, returning the batch's progress
+
+    Libraries are the users with a sync checkpoint; friends' profiles stored by
+    --friends aren't synced on their own. Libraries whose sync is already queued
+    or running are skipped, as are libraries that would overrun the request
+    budget when queued manually. SYNC_WORKERS limits how many run at once. Each
+    library runs with the options of its last sync (--wishlist, --friends, ...),
+    with scope in place of its --scope.
+    """
+    if scope not in SYNC_SCOPES:
+        raise InvalidSyncOption("scope", scope, SYNC_SCOPES)
+    if priority not in PRIORITIES:
+        raise InvalidSyncOption("priority", priority, PRIORITIES)
+
+    checkpoints = session.query(SyncCheckpoint).order_by(SyncCheckpoint.steam_id).all()
+    if not checkpoints:
+        raise LookupError("No library has been synced yet")
+
+    jobs, skipped = [], []
+    for checkpoint in checkpoints:
+        try:
+            jobs.append(submit_within_budget(session, checkpoint.steam_id, with_scope(json.loads(checkpoint.options or "[]"), scope), priority))
+        except (SyncAlreadyRunning, QuotaExceeded) as e:
+            skipped.append({"steam_id": checkpoint.steam_id, "reason": str(e)})
+
+    with _batches_lock:
+        batch = SyncBatch(next(_batch_ids), jobs, skipped)
+        _batches[batch.id] = batch
+        # Batches are kept as long as finished jobs are
+        for old_id in sorted(_batches)[:-FINISHED_JOB_HISTORY]:
+            del _batches[old_id]
+    logger.info(f"Queued sync batch {batch.id}: {len(jobs)} libraries, {len(skipped)} skipped")
+    return batch.to_dict()
+
+
+def sync_batch_status(batch_id: int) -> dict | None:
+    """Aggregate progress of a batch queued by sync_all_libraries"""
+    with _batches_lock:
+        batch = _batches.get(batch_id)
+    return batch.to_dict() if batch else None
+
+
+def set_checkpoint_status(steam_id: str, old: str, new: str):
+    """Update a library's checkpoint status if it is still old"""
+    with get_db_transaction() as session:
+        checkpoint = session.get(SyncCheckpoint, steam_id)
+        if checkpoint and checkpoint.status == old:
+            checkpoint.status = new
+            checkpoint.updated_at = int(time.time())
+
+
+def cancel_sync(steam_id: str | None = None) -> dict:
+    """Cancel the running (or paused) sync for steam_id, or its queued sync if none is running
+
+    Without a steam_id the running sync, then the next queued one, is cancelled.
+    Progress is kept in the checkpoint for resume_sync. Raises LookupError when
+    there is nothing to cancel.
+    """
+    job = sync_queue.active_job(steam_id) or sync_queue.queued_job(steam_id)
+    if not job:
+        raise LookupError(f"No queued or running sync to cancel{f' for {steam_id}' if steam_id else ''}")
+    return sync_queue.cancel(job.id)
+
+
+def pause_sync(steam_id: str | None = None) -> dict:
+    """Pause the running sync for steam_id (or the only running one), keeping its progress
+
+    Raises LookupError when no sync started by this server is running.
+    """
+    job = sync_queue.active_job(steam_id, state="running")
+    if not job:
+        raise LookupError(f"No running sync to pause{f' for {steam_id}' if steam_id else ''}")
+    paused = sync_queue.pause(job.id)
+    # Single-game refreshes don't use the library checkpoint
+    if "--game" not in job.args:
+        set_checkpoint_status(job.steam_id, "running", "paused")
+    return paused
+
+
+def resume_sync(session, steam_id: str | None = None, priority: str = "manual") -> dict:
+    """Continue a paused sync, or queue an interrupted one to resume with its original options
+
+    Without a steam_id a paused job is preferred, then the most recently updated
+    checkpoint. Raises LookupError when there is nothing to resume and
+    SyncAlreadyRunning when the sync is still in progress, and QuotaExceeded when
+    a manual resume would overrun the request budget.
+    """
+    job = sync_queue.active_job(steam_id, state="paused")
+    if job:
+        resumed = sync_queue.unpause(job.id)
+        if "--game" not in job.args:
+            set_checkpoint_status(job.steam_id, "paused", "running")
+        session.expire_all()
+        checkpoint = find_checkpoint(session, job.steam_id)
+        return {"checkpoint": checkpoint_to_dict(checkpoint) if checkpoint else None, "job": resumed, "unpaused": True}
+
+    checkpoint = find_checkpoint(session, steam_id)
+    if not checkpoint or not checkpoint.resumable:
+        raise LookupError(f"No interrupted sync to resume{f' for {steam_id}' if steam_id else ''}")
+    if checkpoint.status == "running" and time.time() - (checkpoint.updated_at or 0) < ACTIVE_CHECKPOINT_SECONDS:
+        events.publish("sync.conflict", {"steam_id": checkpoint.steam_id, "args": [*json.loads(checkpoint.options or "[]"), "--resume"], "priority": priority, "checkpoint": checkpoint_to_dict(checkpoint)})
+        raise SyncAlreadyRunning(f"The sync for {checkpoint.steam_id} is still making progress")
+
+    args = [*json.loads(checkpoint.options or "[]"), "--resume"]
+    job = submit_within_budget(session, checkpoint.steam_id, args, priority)
+    return {"checkpoint": checkpoint_to_dict(checkpoint), "job": sync_queue.job(job.id) or job.to_dict(), "unpaused": False}
+
+
+def preview_sync(session, steam_id: str | None = None, scope: str = "full") -> dict:
+    """What a library sync of steam_id (or the most recently synced library) would change, without writing anything
+
+    Raises LookupError when there's no library to preview and RuntimeError when the
+    fetcher fails.
+    """
+    if scope not in SYNC_SCOPES:
+        raise InvalidSyncOption("scope", scope, SYNC_SCOPES)
+    if not steam_id:
+        checkpoint = find_checkpoint(session)
+        if not checkpoint:
+            raise LookupError("No synced library to preview")
+        steam_id = checkpoint.steam_id
+
+    with tempfile.TemporaryDirectory() as directory:
+        output = Path(directory) / "preview.json"
+        args = ["--dry-run", "--dry-run-output", str(output), *(["--scope", scope] if scope != "full" else [])]
+        try:
+            result = subprocess.run([sys.executable, "-m", FETCHER_MODULE, *args], env=fetcher_env(steam_id), timeout=PREVIEW_TIMEOUT_SECONDS)
+        except subprocess.TimeoutExpired:
+            raise RuntimeError(f"The sync preview for {steam_id} took longer than {PREVIEW_TIMEOUT_SECONDS}s") from None
+        if result.returncode or not output.exists():
+            raise RuntimeError(f"The sync preview for {steam_id} failed with exit code {result.returncode}")
+        return json.loads(output.read_text())
+
+
+def sync_game(session, app_id: int, steam_id: str | None = None, priority: str = "manual", scope: str = "full") -> dict:
+    """Queue a refresh of a single game for steam_id, defaulting to the library synced most recently
+
+    scope limits what is re-fetched (see SYNC_SCOPES). Raises ValueError for an
+    unknown scope, LookupError when no library has been synced,
+    SyncAlreadyRunning when the same refresh is already queued, and QuotaExceeded
+    when a manual refresh would overrun the request budget.
+    """
+    if scope not in SYNC_SCOPES:
+        raise InvalidSyncOption("scope", scope, SYNC_SCOPES)
+    if not steam_id:
+        checkpoint = find_checkpoint(session)
+        if not checkpoint:
+            raise LookupError("No library has been synced yet")
+        steam_id = checkpoint.steam_id
+
+    args = ["--game", str(app_id)] + (["--scope", scope] if scope != "full" else [])
+    job = submit_within_budget(session, steam_id, args, priority)
+    return sync_queue.job(job.id) or job.to_dict()
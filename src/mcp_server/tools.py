@@ -1,9 +1,15 @@
 """Enhanced MCP tools with full specification compliance including input/output schemas and structured responses"""
 
+import asyncio
+import json
+
 from mcp.server.fastmcp import Context
+from mcp.shared.exceptions import McpError
 from mcp.types import (
     Annotations,
     CallToolResult,
+    ClientCapabilities,
+    SamplingCapability,
     SamplingMessage,
     TextContent,
     ToolAnnotations,
@@ -13,19 +19,35 @@ from sqlalchemy import and_, case, func, or_
 from sqlalchemy.orm import joinedload
 
 from shared.database import (
+    Achievement,
     Category,
+    FriendGame,
     Game,
+    GameReview,
     Genre,
+    SyncCheckpoint,
     Tag,
+    UserAchievement,
     UserGame,
     UserProfile,
+    create_error_response,
+    friends_association,
     get_db,
+    handle_game_not_found,
     handle_user_not_found,
     resolve_user_for_tool,
+    resolve_user_identifier,
 )
+from shared.steam_store import StoreUnavailable, search_store
 
+from .backlog import backlog_entry, backlog_games, never_played_games
 from .config import config
+from .insights import library_insights
+from .pagination import page_limit, page_result, paginate
+from .recommendations import MOODS, SessionContext, common_games, esrb_descriptor_list, family_friendly_games, get_esrb_ratings_up_to, get_max_esrb_for_age, get_max_pegi_for_age, get_pegi_ratings_up_to, parse_duration, quick_session_tags, rank_library
+from .scheduler import scheduler
 from .server import mcp
+from .sync import InvalidSyncOption, QuotaExceeded, SyncAlreadyRunning, cancel_sync, pause_sync, resume_sync, sync_all_libraries, sync_batch_status, sync_game, sync_queue
 
 
 class FamilyPreferences(BaseModel):
@@ -49,6 +71,103 @@ def get_default_user_fallback():
     return None
 
 
+def tool_error(error_type: str, message: str, details: dict | None = None) -> CallToolResult:
+    """Error result whose structured content carries an error_type clients can branch on"""
+    return CallToolResult(content=[TextContent(type="text", text=message, annotations=Annotations(audience=["user", "assistant"], priority=0.9))], structuredContent=create_error_response(error_type, message, details), isError=True)
+
+
+def user_error(user_result: dict) -> CallToolResult:
+    """Error result for a user that couldn't be resolved, with the resolution error as structured content"""
+    return CallToolResult(content=[TextContent(type="text", text=f"User error: {user_result['message']}", annotations=Annotations(audience=["assistant"], priority=0.9))], structuredContent=user_result, isError=True)
+
+
+def sync_error(e: Exception) -> CallToolResult:
+    """Error result for an exception raised while queueing or controlling a sync"""
+    if isinstance(e, SyncAlreadyRunning):
+        return tool_error("SYNC_ALREADY_RUNNING", str(e))
+    if isinstance(e, QuotaExceeded):
+        return tool_error("QUOTA_EXCEEDED", str(e))
+    if isinstance(e, LookupError):
+        return tool_error("NOT_FOUND", str(e))
+    if isinstance(e, InvalidSyncOption):
+        return tool_error("INVALID_PARAMS", str(e), {"field": e.field, "allowed": e.allowed})
+    return tool_error("INVALID_PARAMS", str(e))
+
+
+async def elicit_library(ctx: Context | None, choices: list[dict], message: str) -> dict | None:
+    """Ask the client to pick one of several libraries: {"steam_id": ...} when it does, an error response when the user declines or cancels, or None when the client can't be asked"""
+    if not (ctx and getattr(ctx, "session", None)):
+        return None
+    requested_schema = {"type": "object", "properties": {"steam_id": {"type": "string", "title": "Library", "description": "Steam library to use for this request", "enum": [choice["steam_id"] for choice in choices], "enumNames": [f"{choice['persona_name']} ({choice['steam_id']})" for choice in choices]}}, "required": ["steam_id"]}
+    try:
+        # Context.elicit only takes a pydantic model, which can't express the list of libraries as an enum
+        result = await ctx.session.elicit(message=message, requestedSchema=requested_schema, related_request_id=ctx.request_id)
+    except McpError:
+        # Clients without elicitation reject the request
+        return None
+
+    if result.action == "accept" and result.content and result.content.get("steam_id") in {choice["steam_id"] for choice in choices}:
+        return {"steam_id": result.content["steam_id"]}
+    if result.action == "decline":
+        return create_error_response("USER_SELECTION_DECLINED", "No library was chosen. Pass user='<Steam ID or name>' to pick one.", {"available_users": choices})
+    return create_error_response("USER_SELECTION_CANCELLED", "Request cancelled before a library was chosen.", {"available_users": choices})
+
+
+async def resolve_user_or_elicit(user: str | None, ctx: Context | None) -> dict:
+    """Resolve the tool's user like resolve_user_for_tool, but ask which library to use instead of failing when several match"""
+    user_result = resolve_user_for_tool(user, get_default_user_fallback)
+    if user_result.get("error_type") != "MULTIPLE_USERS_FOUND":
+        return user_result
+    # Clients that can't be asked get the usual list of libraries to choose from
+    return await elicit_library(ctx, user_result["details"]["available_users"], "There are several Steam libraries. Which one should I use?") or user_result
+
+
+def library_choices(steam_ids: list[str]) -> list[dict]:
+    """Libraries to offer in an elicitation, in the form handle_multiple_users lists them"""
+    with get_db() as session:
+        names = dict(session.query(UserProfile.steam_id, UserProfile.persona_name).filter(UserProfile.steam_id.in_(steam_ids)))
+    return [{"steam_id": steam_id, "persona_name": names.get(steam_id) or "Unknown"} for steam_id in steam_ids]
+
+
+async def resolve_sync_user(user: str | None, ctx: Context | None, candidates, message: str) -> tuple[str | None, CallToolResult | None]:
+    """Library a sync tool acts on, or an error result
+
+    A given user must resolve. Without one, candidates() lists the libraries the tool
+    could act on; when there are several the client is asked to pick, and when it
+    can't be asked (or there's only one) None leaves the choice to the sync helpers.
+    """
+    if user:
+        steam_id = await asyncio.to_thread(resolve_user_identifier, user)
+        if not steam_id:
+            return None, CallToolResult(content=[TextContent(type="text", text=f"User '{user}' not found")], structuredContent=handle_user_not_found(user), isError=True)
+        return steam_id, None
+
+    steam_ids = await asyncio.to_thread(candidates)
+    if len(steam_ids) < 2:
+        return None, None
+    result = await elicit_library(ctx, await asyncio.to_thread(library_choices, steam_ids), message)
+    if result is None:
+        return None, None
+    if "error" in result:
+        return None, tool_error(result["error_type"], result["message"], result["details"])
+    return result["steam_id"], None
+
+
+async def sample_text(ctx: Context | None, prompt: str, max_tokens: int = 300) -> str | None:
+    """Ask the client's model to write text, or None when the client can't sample or the request fails"""
+    if not (ctx and hasattr(ctx, "session") and ctx.session):
+        return None
+    try:
+        if not ctx.session.check_client_capability(ClientCapabilities(sampling=SamplingCapability())):
+            return None
+        result = await ctx.session.create_message(messages=[SamplingMessage(role="user", content=TextContent(type="text", text=prompt))], max_tokens=max_tokens)
+    except Exception:
+        return None
+    if result.content.type != "text" or not result.content.text.strip():
+        return None
+    return result.content.text.strip()
+
+
 def is_natural_language_query(query: str) -> bool:
     """Check if query is natural language vs simple keywords."""
     # Natural language indicators
@@ -95,6 +214,10 @@ def parse_natural_language_filters(text: str) -> dict:
     # Platform detection
     if "vr" in text.lower():
         filters["vr_support"] = True
+    if "deck verified" in text.lower():
+        filters["steam_deck"] = "verified"
+    elif "steam deck" in text.lower() or "deck" in text.lower().split():
+        filters["steam_deck"] = "playable"
 
     # Playtime detection
     if any(word in text.lower() for word in ["unplayed", "never played"]):
@@ -112,7 +235,7 @@ async def smart_search(query: str, filters: str = "", sort_by: str = "relevance"
 
     Args:
         query: Search query - can be game names, natural language descriptions, or specific requests
-        filters: JSON string with filter criteria: {"genres": [], "categories": [], "tags": [], "playtime": "any"}
+        filters: JSON string with filter criteria: {"genres": [], "categories": [], "tags": [], "playtime": "any", "steam_deck": "verified|playable", "ownership": "owned|shared|removed"}
         sort_by: Sort order - relevance|playtime|metacritic|recent|random
         limit: Maximum number of results to return (1-50)
         ctx: MCP context for AI sampling and elicitation
@@ -123,12 +246,10 @@ async def smart_search(query: str, filters: str = "", sort_by: str = "relevance"
         - query="relaxing puzzle games" - Natural language search with AI interpretation
         - query="unplayed gems", filters='{"playtime": "unplayed"}' - Unplayed with good scores
     """
-    import json
-
     # Resolve user
-    user_result = resolve_user_for_tool(user, get_default_user_fallback)
+    user_result = await resolve_user_or_elicit(user, ctx)
     if "error" in user_result:
-        return CallToolResult(content=[TextContent(type="text", text=f"User error: {user_result['message']}", annotations=Annotations(audience=["assistant"], priority=0.9))], isError=True)
+        return user_error(user_result)
 
     user_steam_id = user_result["steam_id"]
 
@@ -143,7 +264,7 @@ async def smart_search(query: str, filters: str = "", sort_by: str = "relevance"
             filter_dict = parse_natural_language_filters(filters)
 
             # Validate filter structure
-            valid_filters = ["genres", "categories", "tags", "min_rating", "max_rating", "playtime", "vr_support", "platform"]
+            valid_filters = ["genres", "categories", "tags", "min_rating", "max_rating", "playtime", "vr_support", "steam_deck", "platform", "ownership"]
 
             invalid_keys = [k for k in filter_dict.keys() if k not in valid_filters]
             if invalid_keys:
@@ -164,7 +285,7 @@ Natural language examples:
 - "unplayed indie games"
 - "vr games"'''
 
-                return CallToolResult(content=[TextContent(type="text", text=error_msg, annotations=Annotations(audience=["user"], priority=0.9))], isError=True)
+                return tool_error("INVALID_PARAMS", error_msg, {"field": "filters", "invalid_keys": invalid_keys, "allowed": valid_filters})
 
     # Use Sampling for natural language queries
     if ctx and hasattr(ctx, "session") and ctx.session and is_natural_language_query(query):
@@ -250,6 +371,21 @@ Natural language examples:
         if filter_dict.get("vr_support"):
             games_query = games_query.filter(Game.vr_support)
 
+        # Steam Deck filter: "verified" only, or "playable" which also includes verified games
+        if filter_dict.get("steam_deck") == "verified":
+            games_query = games_query.filter(Game.steam_deck_compat == "verified")
+        elif filter_dict.get("steam_deck") == "playable":
+            games_query = games_query.filter(Game.steam_deck_compat.in_(["verified", "playable"]))
+
+        # Family Sharing filter: "owned" excludes borrowed games, "shared" shows only borrowed ones,
+        # and "removed" shows games flagged as no longer in the library
+        if filter_dict.get("ownership") == "owned":
+            games_query = games_query.filter(or_(UserGame.shared.is_(None), UserGame.shared.is_(False)), UserGame.removed_at.is_(None))
+        elif filter_dict.get("ownership") == "shared":
+            games_query = games_query.filter(UserGame.shared.is_(True), UserGame.removed_at.is_(None))
+        elif filter_dict.get("ownership") == "removed":
+            games_query = games_query.filter(UserGame.removed_at.isnot(None))
+
         # Text search if no specific filters applied or for general queries
         if not any(filter_dict.get(k) for k in ["genres", "categories", "tags"]) or query.lower() not in ["unplayed gems", "family games", "multiplayer", "coop"]:
             # Add text search
@@ -271,7 +407,7 @@ Natural language examples:
         # Get results
         results = []
         for game, user_game in games_query.distinct().limit(limit):
-            results.append({"name": game.name, "metacritic": game.metacritic_score, "platforms": {"windows": game.platforms_windows, "mac": game.platforms_mac, "linux": game.platforms_linux, "vr": game.vr_support}, "playtime": user_game.playtime_forever / 60 if user_game.playtime_forever else 0, "recent_playtime": user_game.playtime_2weeks / 60 if user_game.playtime_2weeks else 0, "genres": [g.genre_name for g in game.genres[:3]], "tags": [t.tag_name for t in game.tags[:3]]})
+            results.append({"name": game.name, "metacritic": game.metacritic_score, "platforms": {"windows": game.platforms_windows, "mac": game.platforms_mac, "linux": game.platforms_linux, "vr": game.vr_support}, "steam_deck": game.steam_deck_compat or "unknown", "playtime": user_game.playtime_forever / 60 if user_game.playtime_forever else 0, "recent_playtime": user_game.playtime_2weeks / 60 if user_game.playtime_2weeks else 0, "genres": [g.genre_name for g in game.genres[:3]], "tags": [t.tag_name for t in game.tags[:3]]})
 
         if not results:
             no_results_msg = f"No games found matching '{query}'" + (f" with filters: {filter_dict}" if filter_dict else "")
@@ -395,6 +531,7 @@ async def recommend_games(context: str, parameters: str = "", use_play_history:
     - "weekend": Games perfect for weekend sessions (20-40 hour campaigns)
     - "family": Age-appropriate games (will ask for age)
     - "quick_session": Games for short sessions (will ask for time available)
+    - "session": Ranked picks for right now, with reasons, from any mix of mood, minutes, friends_present, and family_friendly (or age)
 
     Parameters can be:
     - JSON object: {"exclude_genres": ["Horror"], "min_rating": 80}
@@ -405,6 +542,7 @@ async def recommend_games(context: str, parameters: str = "", use_play_history:
     - context="abandoned", parameters="focus on shorter games"
     - context="mood:relaxing", parameters="no multiplayer"
     - context="similar_to:Hades", parameters='{"exclude_genres": ["Horror"]}'
+    - context="session", parameters='{"mood": "social", "minutes": 45, "friends_present": 2, "family_friendly": true}'
 
     Args:
         context: Recommendation context (see available contexts above)
@@ -416,12 +554,10 @@ async def recommend_games(context: str, parameters: str = "", use_play_history:
     Returns:
         CallToolResult with personalized recommendations, structured data, and potential resource links
     """
-    import json
-
     # Resolve user
-    user_result = resolve_user_for_tool(user, get_default_user_fallback)
+    user_result = await resolve_user_or_elicit(user, ctx)
     if "error" in user_result:
-        return CallToolResult(content=[TextContent(type="text", text=f"User error: {user_result['message']}", annotations=Annotations(audience=["assistant"], priority=0.9))], isError=True)
+        return user_error(user_result)
 
     user_steam_id = user_result["steam_id"]
 
@@ -446,7 +582,7 @@ async def recommend_games(context: str, parameters: str = "", use_play_history:
 
 Received type: {type(parameters).__name__}
 Received value: {parameters}"""
-            return CallToolResult(content=[TextContent(type="text", text=error_msg, annotations=Annotations(audience=["user"], priority=0.9))], isError=True)
+            return tool_error("INVALID_PARAMS", error_msg, {"field": "parameters", "received_type": type(parameters).__name__})
 
     # Enhanced elicitation with proper MCP JSON schemas
     if context == "family" and ctx and hasattr(ctx, "elicit"):
@@ -481,9 +617,11 @@ Received value: {parameters}"""
         return await recommend_unplayed_gems(user_steam_id)
     elif context == "abandoned":
         return await recommend_abandoned_games(user_steam_id)
+    elif context == "session":
+        return await recommend_for_session(params, user_steam_id)
     else:
         # Validate context format
-        valid_contexts = ["abandoned", "trending", "hidden_gems", "completionist", "weekend", "family", "quick_session"]
+        valid_contexts = ["abandoned", "trending", "hidden_gems", "completionist", "weekend", "family", "quick_session", "session"]
         valid_prefixes = ["similar_to:", "mood:", "genre:"]
 
         context_valid = context in valid_contexts or any(context.startswith(p) for p in valid_prefixes)
@@ -504,7 +642,7 @@ Examples:
 
 💡 Use 'get_tool_help("recommend_games")' for detailed examples."""
 
-            return CallToolResult(content=[TextContent(type="text", text=error_msg, annotations=Annotations(audience=["user"], priority=0.9))], isError=True)
+            return tool_error("INVALID_PARAMS", error_msg, {"field": "context", "allowed": valid_contexts + [f"{prefix}..." for prefix in valid_prefixes]})
 
         # Use elicitation to help user select appropriate context
         if ctx and hasattr(ctx, "elicit"):
@@ -551,7 +689,7 @@ Valid contexts: {', '.join(valid_contexts + ['similar_to:[game]', 'mood:[feeling
 
 💡 Use 'get_tool_help("recommend_games")' for detailed examples."""
 
-            return CallToolResult(content=[TextContent(type="text", text=error_msg, annotations=Annotations(audience=["user"], priority=0.9))], isError=True)
+            return tool_error("INVALID_PARAMS", error_msg, {"field": "context", "allowed": valid_contexts + [f"{prefix}..." for prefix in valid_prefixes]})
 
 
 # Helper functions for recommend_games
@@ -595,13 +733,7 @@ async def recommend_quick_session_games(params: dict, user_steam_id: str) -> str
     """Find games perfect for quick sessions."""
     minutes = params.get("minutes", 30)
 
-    # Map minutes to session tags
-    if minutes <= 15:
-        session_tags = ["Arcade", "Casual", "Puzzle", "Score Attack"]
-    elif minutes <= 30:
-        session_tags = ["Arcade", "Casual", "Puzzle", "Fast-Paced", "Card Game", "Runner"]
-    else:
-        session_tags = ["Casual", "Puzzle", "Card Game", "Beat 'em up", "Party Game", "Addictive"]
+    session_tags = quick_session_tags(minutes)
 
     with get_db() as session:
         games_query = session.query(Game, UserGame).join(UserGame, (Game.app_id == UserGame.app_id) & (UserGame.steam_id == user_steam_id)).join(Game.tags).filter(Tag.tag_name.in_(session_tags))
@@ -658,8 +790,6 @@ async def recommend_similar_games(params: dict, user_steam_id: str, ctx: Context
 
                 # Use AI analysis to find similar games
                 if analysis.content.type == "text":
-                    import json
-
                     try:
                         criteria = json.loads(analysis.content.text)
 
@@ -710,10 +840,7 @@ async def recommend_by_mood(params: dict, user_steam_id: str, ctx: Context | Non
     """Recommend games based on current mood."""
     mood = params.get("mood", "relaxed")
 
-    # Map moods to tags and genres
-    mood_mappings = {"relaxing": {"tags": ["Casual", "Puzzle", "Atmospheric", "Zen"], "genres": ["Casual", "Indie"]}, "energetic": {"tags": ["Fast-Paced", "Action", "Arcade", "Bullet Hell"], "genres": ["Action"]}, "competitive": {"tags": ["PvP", "Competitive", "Esports"], "categories": ["Multi-player", "PvP"]}, "social": {"tags": ["Co-op", "Party Game"], "categories": ["Multi-player", "Co-op"]}, "creative": {"tags": ["Building", "Sandbox", "Creative"], "genres": ["Simulation"]}, "story": {"tags": ["Story Rich", "Narrative"], "genres": ["Adventure", "RPG"]}}
-
-    mapping = mood_mappings.get(mood.lower(), mood_mappings["relaxing"])
+    mapping = MOODS.get(mood.lower(), MOODS["relaxing"])
 
     with get_db() as session:
         games_query = session.query(Game, UserGame).join(UserGame, (Game.app_id == UserGame.app_id) & (UserGame.steam_id == user_steam_id))
@@ -744,6 +871,29 @@ async def recommend_by_mood(params: dict, user_steam_id: str, ctx: Context | Non
         return f"**Games for {mood} mood:**\n\n" + "\n\n".join(results)
 
 
+async def recommend_for_session(params: dict, user_steam_id: str) -> CallToolResult:
+    """Rank the library for a session context, explaining each pick."""
+    try:
+        session_context = SessionContext.from_params(params)
+        limit = min(max(int(params.get("limit", 10)), 1), 50)
+    except (TypeError, ValueError) as e:
+        return tool_error("INVALID_PARAMS", f"Invalid session parameters: {e}", {"field": "parameters"})
+
+    recommendations = await asyncio.to_thread(rank_library, user_steam_id, session_context, limit)
+    description = session_context.describe()
+
+    if not recommendations:
+        return CallToolResult(content=[TextContent(type="text", text=f"No games in your library fit this session ({description})", annotations=Annotations(audience=["user"], priority=0.8))], structuredContent={"context": "session", "session": description, "recommendations": []}, isError=False)
+
+    results = []
+    for rank, recommendation in enumerate(recommendations, 1):
+        playtime_str = f"{recommendation.playtime_hours:.1f}h" if recommendation.playtime_hours > 0 else "Unplayed"
+        results.append(f"{rank}. **{recommendation.name}** (score {recommendation.score}, {playtime_str})\n" + "\n".join(f"   - {reason}" for reason in recommendation.reasons))
+
+    output = f"**Picks for this session ({description}):**\n\n" + "\n\n".join(results)
+    return CallToolResult(content=[TextContent(type="text", text=output, annotations=Annotations(audience=["user"], priority=0.9))], structuredContent={"context": "session", "session": description, "recommendations": [r.to_dict() for r in recommendations]}, isError=False)
+
+
 async def recommend_unplayed_gems(user_steam_id: str) -> str:
     """Find high-rated games you haven't played."""
     with get_db() as session:
@@ -821,11 +971,11 @@ class ContextSelection(BaseModel):
     details: str = Field(default="", description="Additional details (e.g., specific game name, age, mood, or session length)")
 
 
-@mcp.tool()
+@mcp.tool(annotations=ToolAnnotations(title="Find Games by Preference", readOnlyHint=True, idempotentHint=False))  # Asks the user for preferences
 async def find_games_with_preferences(initial_genre: str, ctx: Context, user: str | None = None) -> str:
     """Find games with user preferences via elicitation."""
     # Resolve user with default fallback
-    user_result = resolve_user_for_tool(user, get_default_user_fallback)
+    user_result = await resolve_user_or_elicit(user, ctx)
     if "error" in user_result:
         return f"User error: {user_result['message']}"
 
@@ -889,7 +1039,7 @@ async def find_games_with_preferences(initial_genre: str, ctx: Context, user: st
         return f"Failed to find games: {str(e)}"
 
 
-@mcp.tool()
+@mcp.tool(annotations=ToolAnnotations(title="Library Analytics", readOnlyHint=True, idempotentHint=False))  # AI insights are sampled
 async def get_library_insights(analysis_type: str, compare_to: str = "", time_range: str = "all", ctx: Context | None = None, user: str | None = None) -> str:  # patterns|gaps|value|social|achievements|trends  # friends|global|genre_average  # all|recent|last_month
     """
     Deep analytics and insights about gaming library and habits.
@@ -903,7 +1053,7 @@ async def get_library_insights(analysis_type: str, compare_to: str = "", time_ra
     - trends: How gaming habits changed over time
     """
     # Resolve user
-    user_result = resolve_user_for_tool(user, get_default_user_fallback)
+    user_result = await resolve_user_or_elicit(user, ctx)
     if "error" in user_result:
         return f"User error: {user_result['message']}"
 
@@ -962,7 +1112,7 @@ async def analyze_patterns(user_steam_id: str, display_name: str, ctx: Context |
         binges = []
         for ug in user_games:
             if ug.playtime_forever > 600 and ug.playtime_2weeks == 0:  # 10+ hours, not recent
-                binges.append({"game": ug.game.name, "hours": ug.playtime_forever / 60, "last_played": "Over 2 weeks ago"})
+                binges.append({"game": ug.game.name, "hours": ug.playtime_forever / 60, "last_played": ug.last_played_iso[:10] if ug.last_played else "Over 2 weeks ago"})
 
         # Sort and format results
         top_genres = sorted(genre_time.items(), key=lambda x: x[1]["hours"], reverse=True)[:5]
@@ -1126,15 +1276,83 @@ async def analyze_gaps(user_steam_id: str, ctx: Context | None) -> str:
 
 
 async def analyze_social(user_steam_id: str, compare_to: str, ctx: Context | None) -> str:
-    """Compare with friends' libraries (placeholder - would need friends data)."""
-    # This would require friends data to be available
-    return "**Social Analysis:** Social comparison features require friends data to be fetched. Use the Steam library fetcher with --friends flag to enable social analysis."
+    """Compare with friends' libraries using the games stored for them by the fetcher."""
+    with get_db() as session:
+        friend_ids = [row.friend_steam_id for row in session.execute(friends_association.select().where(friends_association.c.user_steam_id == user_steam_id))]
+        owners: dict[int, list[str]] = {}
+        app_names: dict[int, str] = {}
+        for row in session.query(FriendGame.steam_id, FriendGame.app_id, FriendGame.name).filter(FriendGame.steam_id.in_(friend_ids)):
+            owners.setdefault(row.app_id, []).append(row.steam_id)
+            app_names[row.app_id] = row.name
+        if not owners:
+            return "**Social Analysis:** No friends' games are stored yet. Run the Steam library fetcher with --friends-games (or --friends) to enable social analysis."
+
+        names = {profile.steam_id: profile.persona_name or profile.steam_id for profile in session.query(UserProfile).filter(UserProfile.steam_id.in_(friend_ids))}
+        owned = {ug.app_id: ug for ug in session.query(UserGame).options(joinedload(UserGame.game).joinedload(Game.categories)).filter(UserGame.steam_id == user_steam_id, UserGame.removed_at.is_(None))}
+
+        # Games in common with each friend
+        in_common: dict[str, int] = {}
+        for app_id, friends in owners.items():
+            if app_id in owned:
+                for friend in friends:
+                    in_common[friend] = in_common.get(friend, 0) + 1
+
+        # Multiplayer games you already own that friends own too
+        coop = [(owned[app_id].game.name, friends) for app_id, friends in owners.items() if app_id in owned and any("Co-op" in category.category_name or "Multi-player" in category.category_name for category in owned[app_id].game.categories)]
+        coop.sort(key=lambda item: len(item[1]), reverse=True)
+
+        # Popular among friends but missing from your library
+        missing = sorted((app_id for app_id in owners if app_id not in owned), key=lambda app_id: len(owners[app_id]), reverse=True)[:5]
+
+    analysis = f"**Social Analysis** ({len({friend for friends in owners.values() for friend in friends})} friends with stored libraries):\n\n"
+
+    analysis += "**Most Games in Common:**\n"
+    for friend, count in sorted(in_common.items(), key=lambda item: item[1], reverse=True)[:5]:
+        analysis += f"• **{names.get(friend, friend)}**: {count} games\n"
+
+    if coop:
+        analysis += "\n**Co-op & Multiplayer Games to Play Together:**\n"
+        for name, friends in coop[:10]:
+            analysis += f"• **{name}** - owned by {', '.join(names.get(friend, friend) for friend in friends[:5])}{f' and {len(friends) - 5} more' if len(friends) > 5 else ''}\n"
+
+    if missing:
+        analysis += "\n**Popular With Friends, Not in Your Library:**\n"
+        for app_id in missing:
+            analysis += f"• **{app_names.get(app_id) or f'App {app_id}'}** - {len(owners[app_id])} friends own it\n"
+
+    return analysis
 
 
 async def analyze_achievements(user_steam_id: str, ctx: Context | None) -> str:
-    """Achievement completion analysis (placeholder - would need achievement data)."""
-    # This would require achievement data to be available in the database
-    return "**Achievement Analysis:** Achievement tracking features are not yet implemented. This would show completion rates and achievement hunting opportunities."
+    """Achievement completion analysis from synced achievement progress."""
+    with get_db() as session:
+        tracked = session.query(UserGame).options(joinedload(UserGame.game)).filter(UserGame.steam_id == user_steam_id, UserGame.achievements_updated.isnot(None), UserGame.achievements_total > 0).all()
+        if not tracked:
+            return "**Achievement Analysis:** No achievement progress has been synced yet. Run the Steam library fetcher with --achievements to enable it."
+
+        unlocked = sum(ug.achievements_unlocked for ug in tracked)
+        total = sum(ug.achievements_total for ug in tracked)
+        perfect = [ug for ug in tracked if ug.achievements_unlocked >= ug.achievements_total]
+        close = sorted((ug for ug in tracked if 75 <= (ug.completion_percent or 0) < 100), key=lambda ug: ug.completion_percent, reverse=True)[:5]
+
+        # Rarest achievements the user has unlocked, by global unlock percentage
+        rarest = session.query(Achievement, Game.name).join(UserAchievement, (UserAchievement.app_id == Achievement.app_id) & (UserAchievement.api_name == Achievement.api_name)).join(Game, Game.app_id == Achievement.app_id).filter(UserAchievement.steam_id == user_steam_id, UserAchievement.achieved.is_(True), Achievement.global_percent.isnot(None)).order_by(Achievement.global_percent).limit(5).all()
+
+        analysis = "**Achievement Analysis:**\n\n"
+        analysis += f"• **Unlocked:** {unlocked} of {total} achievements ({unlocked / total * 100:.1f}%) across {len(tracked)} games\n"
+        analysis += f"• **Perfect games:** {len(perfect)}\n"
+
+        if close:
+            analysis += "\n**Closest to 100%:**\n"
+            for ug in close:
+                analysis += f"• **{ug.game.name}** - {ug.achievements_unlocked}/{ug.achievements_total} ({ug.completion_percent:.1f}%)\n"
+
+        if rarest:
+            analysis += "\n**Rarest unlocks:**\n"
+            for achievement, game_name in rarest:
+                analysis += f"• **{achievement.display_name}** ({game_name}) - {achievement.global_percent:.1f}% of players\n"
+
+        return analysis
 
 
 async def analyze_trends(user_steam_id: str, time_range: str, ctx: Context | None) -> str:
@@ -1200,7 +1418,7 @@ async def analyze_trends(user_steam_id: str, time_range: str, ctx: Context | Non
         return analysis
 
 
-@mcp.tool()
+@mcp.tool(annotations=ToolAnnotations(title="Family Game Finder", readOnlyHint=True, idempotentHint=False))  # Asks the user for content preferences
 async def find_family_games(child_age: int, ctx: Context | None = None, user: str | None = None) -> str:
     """Find age-appropriate games for family gaming.
 
@@ -1208,7 +1426,7 @@ async def find_family_games(child_age: int, ctx: Context | None = None, user: st
     For young children (under 10), may gather additional preferences.
     """
     # Resolve user
-    user_result = resolve_user_for_tool(user, get_default_user_fallback)
+    user_result = await resolve_user_or_elicit(user, ctx)
     if "error" in user_result:
         return f"User error: {user_result['message']}"
 
@@ -1249,7 +1467,7 @@ async def find_family_games(child_age: int, ctx: Context | None = None, user: st
         return f"Family-friendly games for age {child_age}:\n" + "\n".join(results)
 
 
-@mcp.tool()
+@mcp.tool(annotations=ToolAnnotations(title="Quick Session Finder", readOnlyHint=True, idempotentHint=True))
 async def find_quick_session_games(session_length: str = "short", user: str | None = None) -> str:
     """Find games perfect for quick gaming sessions (5-60 minutes).
 
@@ -1384,6 +1602,715 @@ async def find_quick_session_games(session_length: str = "short", user: str | No
         return "\n".join(results)
 
 
+def find_game(session, game: str, *options) -> Game | None:
+    """Game by app ID, or the shortest name containing the given text"""
+    game_query = session.query(Game).options(*options)
+    if game.strip().isdigit():
+        return game_query.filter(Game.app_id == int(game)).first()
+    return game_query.filter(Game.name.ilike(f"%{game.strip()}%")).order_by(func.length(Game.name)).first()
+
+
+@mcp.tool(name="describe_game", title="Describe a Game", description="Write a short prose description of a game from its stored details and your play history, using the connected model when the client supports sampling", annotations=ToolAnnotations(title="Describe a Game", readOnlyHint=True, idempotentHint=False))
+async def describe_game(game: str, ctx: Context | None = None, user: str | None = None) -> CallToolResult:
+    """Describe a game in a few sentences.
+
+    Args:
+        game: Steam app ID or (part of) the game's name
+        user: Steam user whose playtime to mention (optional, uses default if not provided)
+    """
+    with get_db() as session:
+        found = find_game(session, game, joinedload(Game.genres), joinedload(Game.tags), joinedload(Game.developers), joinedload(Game.reviews))
+        if not found:
+            return CallToolResult(content=[TextContent(type="text", text=f"Game '{game}' not found")], structuredContent=handle_game_not_found(game), isError=True)
+
+        data = {"app_id": found.app_id, "name": found.name, "release_date": found.release_date, "developers": [d.developer_name for d in found.developers], "genres": [g.genre_name for g in found.genres], "tags": [t.tag_name for t in found.tags[:10]], "short_description": found.short_description, "metacritic_score": found.metacritic_score, "review_summary": found.reviews.review_summary if found.reviews else None, "playtime_hours": None}
+
+        user_result = resolve_user_for_tool(user, get_default_user_fallback)
+        if "error" not in user_result:
+            user_game = session.query(UserGame).filter_by(steam_id=user_result["steam_id"], app_id=found.app_id).first()
+            if user_game:
+                data["playtime_hours"] = round(user_game.playtime_forever / 60, 1)
+
+    prompt = f"""Write a 2-3 sentence description of the game {data['name']} for someone deciding whether to play it tonight.
+Use only these facts, and mention how much they've played it if a playtime is given:
+{json.dumps(data, indent=2)}"""
+    description = await sample_text(ctx, prompt)
+    generated_by = "sampling"
+
+    if not description:
+        # Clients without sampling still get a readable description built from the same facts
+        generated_by = "template"
+        genres = "/".join(data["genres"][:2]) or "game"
+        by = f" by {', '.join(data['developers'][:2])}" if data["developers"] else ""
+        released = f", released {data['release_date']}" if data["release_date"] else ""
+        description = f"{data['name']} is a {genres}{by}{released}."
+        if data["short_description"]:
+            description += f" {data['short_description']}"
+        if data["review_summary"]:
+            description += f" Steam reviews are {data['review_summary']}."
+        if data["playtime_hours"] is not None:
+            description += f" You've played it for {data['playtime_hours']} hours." if data["playtime_hours"] else " You haven't played it yet."
+
+    return CallToolResult(content=[TextContent(type="text", text=description, annotations=Annotations(audience=["user"], priority=0.8))], structuredContent={**data, "description": description, "generated_by": generated_by}, isError=False)
+
+
+@mcp.tool(name="compare_games", title="Compare Games", description="Compare two or more games side by side: your playtime, review scores, genres, features, platforms, price, and system requirements", annotations=ToolAnnotations(title="Compare Games", readOnlyHint=True, idempotentHint=True))
+async def compare_games(games: list[str], ctx: Context | None = None, user: str | None = None) -> CallToolResult:
+    """Compare games side by side.
+
+    Args:
+        games: Two or more Steam app IDs or game names
+        ctx: MCP context for elicitation
+        user: Steam user whose playtime to compare (optional, uses default if not provided)
+    """
+    if len(games) < 2:
+        return tool_error("INVALID_PARAMS", "Give at least two games to compare", {"field": "games"})
+
+    user_result = await resolve_user_or_elicit(user, ctx)
+    if "error" in user_result:
+        return user_error(user_result)
+    steam_id = user_result["steam_id"]
+
+    compared = []
+    with get_db() as session:
+        for name in games:
+            game = find_game(session, name, joinedload(Game.genres), joinedload(Game.categories), joinedload(Game.reviews))
+            if not game:
+                return CallToolResult(content=[TextContent(type="text", text=f"Game '{name}' not found")], structuredContent=handle_game_not_found(name), isError=True)
+            user_game = session.query(UserGame).filter_by(steam_id=steam_id, app_id=game.app_id).first()
+            reviews = game.reviews
+            compared.append({"app_id": game.app_id, "name": game.name, "owned": user_game is not None, "playtime_hours": round(user_game.playtime_forever / 60, 1) if user_game else None, "review_summary": reviews.review_summary if reviews else None, "positive_percentage": round(reviews.positive_percentage, 1) if reviews and reviews.total_reviews else None, "total_reviews": reviews.total_reviews if reviews else 0, "metacritic_score": game.metacritic_score or None, "genres": [g.genre_name for g in game.genres], "features": [c.category_name for c in game.categories], "platforms": [platform for platform, supported in (("windows", game.platforms_windows), ("mac", game.platforms_mac), ("linux", game.platforms_linux)) if supported], "steam_deck": game.steam_deck_compat, "controller_support": game.controller_support or None, "price": {"final": game.price_final, "initial": game.price_initial, "discount_percent": game.discount_percent, "currency": game.currency} if game.price_final is not None else None, "requirements": {"minimum": game.pc_requirements_minimum, "recommended": game.pc_requirements_recommended}})
+
+    def best(key):
+        candidates = [game for game in compared if game[key]]
+        return max(candidates, key=lambda game: game[key])["name"] if candidates else None
+
+    shared_genres = sorted(set.intersection(*(set(game["genres"]) for game in compared)))
+    priced = [game for game in compared if game["price"]]
+    highlights = {"most_played": best("playtime_hours"), "best_reviewed": best("positive_percentage"), "highest_metacritic": best("metacritic_score"), "cheapest": min(priced, key=lambda game: game["price"]["final"])["name"] if priced else None, "shared_genres": shared_genres}
+
+    lines = [f"**Comparing {', '.join(game['name'] for game in compared)}:**"]
+    for game in compared:
+        price = "Unknown" if not game["price"] else "Free" if not game["price"]["final"] else f"{game['price']['final'] / 100:.2f} {game['price']['currency']}" + (f" (-{game['price']['discount_percent']}%)" if game["price"]["discount_percent"] else "")
+        playtime = "Not owned" if not game["owned"] else f"{game['playtime_hours']}h played" if game["playtime_hours"] else "Unplayed"
+        reviews = f"{game['review_summary']} ({game['positive_percentage']}% of {game['total_reviews']})" if game["positive_percentage"] is not None else game["review_summary"] or "No reviews"
+        requirements = (game["requirements"]["minimum"] or "Unknown").replace("\n", "; ")
+        lines.append(f"• **{game['name']}** (App ID {game['app_id']})\n  {playtime} | Reviews: {reviews} | Metacritic: {game['metacritic_score'] or 'N/A'}\n  Genres: {', '.join(game['genres']) or 'Unknown'}\n  Platforms: {', '.join(game['platforms']) or 'Unknown'} | Steam Deck: {game['steam_deck'] or 'unknown'} | Price: {price}\n  Minimum requirements: {requirements}")
+
+    summary = [f"{label}: {highlights[key]}" for key, label in (("most_played", "Most played"), ("best_reviewed", "Best reviewed"), ("highest_metacritic", "Highest Metacritic"), ("cheapest", "Cheapest")) if highlights[key]]
+    if shared_genres:
+        summary.append(f"Genres in common: {', '.join(shared_genres)}")
+    lines.append("\n".join(summary))
+
+    return CallToolResult(content=[TextContent(type="text", text="\n\n".join(lines), annotations=Annotations(audience=["user"], priority=0.8))], structuredContent={"games": compared, "highlights": highlights}, isError=False)
+
+
+@mcp.tool(name="list_games", title="List Library Games", description="List games in a library a page at a time, narrowed by genre, tag, playtime, review score, ESRB rating, sale status, and features; pass nextCursor back as cursor for the next page", annotations=ToolAnnotations(title="List Library Games", readOnlyHint=True, idempotentHint=True))
+async def list_games(genre: str | None = None, tag: str | None = None, min_playtime: float | None = None, max_playtime: float | None = None, min_review: int | None = None, esrb_rating: str | None = None, on_sale: bool | None = None, features: str = "", cursor: str | None = None, limit: int = 50, ctx: Context | None = None, user: str | None = None) -> CallToolResult:
+    """List library games matching every given filter, most played first.
+
+    Args:
+        genre: Only games in this genre
+        tag: Only games with this community tag
+        min_playtime: Minimum hours played
+        max_playtime: Maximum hours played (0 for unplayed games)
+        min_review: Minimum percentage of positive Steam reviews, 0-100
+        esrb_rating: Highest ESRB rating to include: EC, E, E10+, T, or M (unrated games are left out)
+        on_sale: True for discounted games only, False for full-price games only
+        features: Comma-separated Steam features the game must all have (e.g. "Co-op, Steam Cloud")
+        cursor: nextCursor from the previous page
+        limit: Games per page, 1-200
+        user: Steam user identifier (optional, uses default if not provided)
+    """
+    user_result = await resolve_user_or_elicit(user, ctx)
+    if "error" in user_result:
+        return user_error(user_result)
+    steam_id = user_result["steam_id"]
+
+    if min_review is not None and not 0 <= min_review <= 100:
+        return tool_error("INVALID_PARAMS", "min_review must be between 0 and 100", {"field": "min_review"})
+    esrb_ratings = ["EC", "E", "E10+", "T", "M"]
+    if esrb_rating and esrb_rating.upper() not in esrb_ratings:
+        return tool_error("INVALID_PARAMS", f"Unknown ESRB rating '{esrb_rating}'", {"field": "esrb_rating", "allowed": esrb_ratings})
+
+    filters = {name: value for name, value in (("genre", genre), ("tag", tag), ("min_playtime", min_playtime), ("max_playtime", max_playtime), ("min_review", min_review), ("esrb_rating", esrb_rating), ("on_sale", on_sale)) if value is not None}
+    feature_names = [feature.strip() for feature in features.split(",") if feature.strip()]
+    if feature_names:
+        filters["features"] = feature_names
+
+    def load():
+        with get_db() as session:
+            games_query = session.query(Game, UserGame).join(UserGame, (Game.app_id == UserGame.app_id) & (UserGame.steam_id == steam_id)).filter(UserGame.removed_at.is_(None))
+            if genre:
+                games_query = games_query.filter(Game.genres.any(Genre.genre_name.ilike(genre)))
+            if tag:
+                games_query = games_query.filter(Game.tags.any(Tag.tag_name.ilike(tag)))
+            if min_playtime is not None:
+                games_query = games_query.filter(UserGame.playtime_forever >= min_playtime * 60)
+            if max_playtime is not None:
+                games_query = games_query.filter(UserGame.playtime_forever <= max_playtime * 60)
+            if min_review is not None:
+                games_query = games_query.filter(Game.reviews.has(and_(GameReview.total_reviews > 0, GameReview.positive_reviews * 100 >= min_review * GameReview.total_reviews)))
+            if esrb_rating:
+                games_query = games_query.filter(Game.esrb_rating.in_(get_esrb_ratings_up_to(esrb_rating.upper())))
+            if on_sale is not None:
+                games_query = games_query.filter(Game.discount_percent > 0 if on_sale else or_(Game.discount_percent.is_(None), Game.discount_percent == 0))
+            for feature in feature_names:
+                games_query = games_query.filter(Game.categories.any(Category.category_name.ilike(feature)))
+
+            total = games_query.count()
+            rows, next_cursor = paginate(games_query.options(joinedload(Game.genres), joinedload(Game.reviews)).order_by(UserGame.playtime_forever.desc(), Game.app_id), cursor, page_limit(limit, 50, 200))
+            games = [{"app_id": game.app_id, "name": game.name, "playtime_hours": round(user_game.playtime_forever / 60, 1), "genres": [g.genre_name for g in game.genres], "positive_percentage": round(game.reviews.positive_percentage, 1) if game.reviews and game.reviews.total_reviews else None, "esrb_rating": game.esrb_rating or None, "discount_percent": game.discount_percent or 0} for game, user_game in rows]
+            return total, games, next_cursor
+
+    try:
+        total, games, next_cursor = await asyncio.to_thread(load)
+    except ValueError as e:
+        return tool_error("INVALID_PARAMS", str(e), {"field": "cursor"})
+
+    lines = [f"**{total} games match{' ' + json.dumps(filters) if filters else ''}; showing {len(games)}:**"]
+    for game in games:
+        reviews = f" | {game['positive_percentage']}% positive" if game["positive_percentage"] is not None else ""
+        sale = f" | -{game['discount_percent']}%" if game["discount_percent"] else ""
+        lines.append(f"- **{game['name']}** (App ID {game['app_id']}) - {game['playtime_hours']}h{reviews}{sale}")
+    return page_result(lines, {"total": total, "filters": filters, "games": games}, next_cursor)
+
+
+@mcp.tool(name="library_statistics", title="Library Statistics", description="Library statistics computed in the database: genre distribution, playtime percentiles, achievement completion, estimated spend vs. playtime, and never-played counts", annotations=ToolAnnotations(title="Library Statistics", readOnlyHint=True, idempotentHint=True))
+async def get_library_statistics(ctx: Context | None = None, user: str | None = None) -> CallToolResult:
+    """Aggregate statistics for a library.
+
+    Args:
+        user: Steam user identifier (optional, uses default if not provided)
+    """
+    user_result = await resolve_user_or_elicit(user, ctx)
+    if "error" in user_result:
+        return user_error(user_result)
+    steam_id = user_result["steam_id"]
+
+    def load():
+        with get_db() as session:
+            return library_insights(session, steam_id)
+
+    insights = await asyncio.to_thread(load)
+    percentiles = insights["playtime_percentiles_hours"]
+    completion, value = insights["completion"], insights["value"]
+    currency = f" {value['currency']}" if value["currency"] else ""
+
+    lines = [f"**Library statistics for {steam_id}:**", f"• {insights['total_games']} games, {insights['games_played']} played, {insights['never_played']} never played ({insights['never_played_percent']}%)", f"• {insights['total_playtime_hours']} hours in total, {insights['recent_playtime_hours']} in the last two weeks"]
+    if percentiles:
+        lines.append("• Playtime of played games: " + ", ".join(f"{p} {hours}h" for p, hours in percentiles.items()))
+    if insights["genres"]:
+        lines.append("• Top genres by playtime: " + ", ".join(f"{genre['genre']} {genre['playtime_share']}% ({genre['games']} games)" for genre in insights["genres"][:5]))
+    if completion["games_with_achievements"]:
+        lines.append(f"• Achievements: {completion['average_completion_percent']}% average completion across {completion['games_with_achievements']} games, {completion['perfect_games']} at 100%")
+    if value["priced_games"]:
+        lines.append(f"• Estimated spend {value['estimated_spend']}{currency} at current list prices ({value['unplayed_spend']}{currency} on games never played)" + (f", {value['cost_per_hour']}{currency} per hour played" if value["cost_per_hour"] is not None else ""))
+        if value["best_value"]:
+            lines.append("• Best value: " + ", ".join(f"{game['name']} ({game['cost_per_hour']}{currency}/h)" for game in value["best_value"][:3]))
+
+    return CallToolResult(content=[TextContent(type="text", text="\n".join(lines), annotations=Annotations(audience=["user"], priority=0.8))], structuredContent={"steam_id": steam_id, **insights}, isError=False)
+
+
+async def backlog_page(user: str | None, ctx: Context | None, query, min_review: int | None, filters: dict, cursor: str | None, limit: int, heading: str) -> CallToolResult:
+    """Page through a backlog query for never_played and backlog"""
+    user_result = await resolve_user_or_elicit(user, ctx)
+    if "error" in user_result:
+        return user_error(user_result)
+    steam_id = user_result["steam_id"]
+
+    if min_review is not None and not 0 <= min_review <= 100:
+        return tool_error("INVALID_PARAMS", "min_review must be between 0 and 100", {"field": "min_review"})
+
+    def load():
+        with get_db() as session:
+            games = query(session, steam_id)
+            total = games.count()
+            rows, next_cursor = paginate(games.options(joinedload(Game.genres), joinedload(Game.reviews)), cursor, page_limit(limit, 25, 100))
+            return total, [backlog_entry(game, user_game) for game, user_game in rows], next_cursor
+
+    try:
+        total, games, next_cursor = await asyncio.to_thread(load)
+    except ValueError as e:
+        return tool_error("INVALID_PARAMS", str(e), {"field": "cursor"})
+
+    if not games:
+        return CallToolResult(content=[TextContent(type="text", text=f"No {heading} in this library match {json.dumps(filters)}", annotations=Annotations(audience=["user"], priority=0.7))], structuredContent={"steam_id": steam_id, "total": 0, "filters": filters, "games": []}, isError=False)
+
+    lines = [f"**{total} {heading}, best reviewed first; showing {len(games)}:**"]
+    for game in games:
+        details = [f"{game['positive_percentage']}% positive" if game["positive_percentage"] is not None else "few reviews"]
+        if game["estimated_hours"] is not None:
+            details.append(f"~{game['estimated_hours']}h to play" if not game["playtime_hours"] else f"{game['playtime_hours']}h of ~{game['estimated_hours']}h played")
+        lines.append(f"- **{game['name']}** (App ID {game['app_id']}) - {', '.join(details)}")
+    return page_result(lines, {"steam_id": steam_id, "total": total, "filters": filters, "games": games}, next_cursor)
+
+
+@mcp.tool(name="never_played", title="Never Played Games", description="List owned games that have never been launched, best reviewed first and then shortest by estimated length; pass nextCursor back as cursor for the next page", annotations=ToolAnnotations(title="Never Played Games", readOnlyHint=True, idempotentHint=True))
+async def list_never_played(min_review: int | None = None, max_hours: float | None = None, cursor: str | None = None, limit: int = 25, ctx: Context | None = None, user: str | None = None) -> CallToolResult:
+    """Owned games with no playtime at all.
+
+    Args:
+        min_review: Minimum percentage of positive Steam reviews, 0-100
+        max_hours: Leave out games estimated to take longer than this many hours
+        cursor: nextCursor from the previous page
+        limit: Games per page, 1-100
+        user: Steam user identifier (optional, uses default if not provided)
+    """
+    filters = {name: value for name, value in (("min_review", min_review), ("max_hours", max_hours)) if value is not None}
+    return await backlog_page(user, ctx, lambda session, steam_id: never_played_games(session, steam_id, min_review, max_hours), min_review, filters, cursor, limit, "never-played games")
+
+
+@mcp.tool(name="backlog", title="Backlog", description="List short unfinished games - played or not - that fit within max_hours by estimated length, best reviewed first and then shortest, to help pick what to play next; pass nextCursor back as cursor for the next page", annotations=ToolAnnotations(title="Backlog", readOnlyHint=True, idempotentHint=True))
+async def list_backlog(max_hours: float = 10, min_review: int | None = None, cursor: str | None = None, limit: int = 25, ctx: Context | None = None, user: str | None = None) -> CallToolResult:
+    """Short games the user hasn't finished.
+
+    A game is finished once its playtime reaches its estimated length (SteamSpy's
+    median playtime) or all its achievements are unlocked. Games without an
+    estimated length are left out.
+
+    Args:
+        max_hours: Longest estimated length to include, in hours (default 10)
+        min_review: Minimum percentage of positive Steam reviews, 0-100
+        cursor: nextCursor from the previous page
+        limit: Games per page, 1-100
+        user: Steam user identifier (optional, uses default if not provided)
+    """
+    if max_hours <= 0:
+        return tool_error("INVALID_PARAMS", "max_hours must be greater than 0", {"field": "max_hours"})
+    filters = {name: value for name, value in (("max_hours", max_hours), ("min_review", min_review)) if value is not None}
+    return await backlog_page(user, ctx, lambda session, steam_id: backlog_games(session, steam_id, max_hours, min_review), min_review, filters, cursor, limit, "unfinished short games")
+
+
+@mcp.tool(name="family_friendly_games", title="Family-Friendly Games", description="List library games a child can play by ESRB rating: pass the child's age or a maximum rating, and content descriptors to exclude (e.g. \"Blood, Violence\"); pass nextCursor back as cursor for the next page", annotations=ToolAnnotations(title="Family-Friendly Games", readOnlyHint=True, idempotentHint=True))
+async def list_family_friendly_games(age: int | None = None, max_rating: str | None = None, exclude_descriptors: str = "", include_unrated: bool = False, cursor: str | None = None, limit: int = 25, ctx: Context | None = None, user: str | None = None) -> CallToolResult:
+    """Owned games whose ESRB rating and content descriptors suit a child.
+
+    Args:
+        age: The child's age, mapped to the highest suitable ESRB rating (under 6 EC, under 10 E, under 13 E10+, under 17 T, otherwise M)
+        max_rating: Highest ESRB rating to include instead of an age: EC, E, E10+, T, or M
+        exclude_descriptors: Comma-separated ESRB content descriptors to leave out; a game is excluded when any of its descriptors contains one (e.g. "Violence" also excludes "Fantasy Violence")
+        include_unrated: Also list games with no ESRB rating, whose content can't be checked
+        cursor: nextCursor from the previous page
+        limit: Games per page, 1-100
+        user: Steam user identifier (optional, uses default if not provided)
+    """
+    esrb_ratings = ["EC", "E", "E10+", "T", "M"]
+    if (age is None) == (max_rating is None):
+        return tool_error("INVALID_PARAMS", "Pass either age or max_rating", {"field": "age", "allowed": ["age", "max_rating"]})
+    if age is not None and not 0 < age < 100:
+        return tool_error("INVALID_PARAMS", "age must be between 1 and 99", {"field": "age"})
+    if max_rating is not None and max_rating.upper() not in esrb_ratings:
+        return tool_error("INVALID_PARAMS", f"Unknown ESRB rating '{max_rating}'", {"field": "max_rating", "allowed": esrb_ratings})
+
+    user_result = await resolve_user_or_elicit(user, ctx)
+    if "error" in user_result:
+        return user_error(user_result)
+    steam_id = user_result["steam_id"]
+
+    rating = max_rating.upper() if max_rating else get_max_esrb_for_age(age)
+    excluded = [descriptor.strip() for descriptor in exclude_descriptors.split(",") if descriptor.strip()]
+    filters = {"max_rating": rating, "exclude_descriptors": excluded, "include_unrated": include_unrated}
+    if age is not None:
+        filters["age"] = age
+
+    def load():
+        with get_db() as session:
+            games_query = family_friendly_games(session, steam_id, rating, excluded, include_unrated)
+            total = games_query.count()
+            rows, next_cursor = paginate(games_query.options(joinedload(Game.genres)), cursor, page_limit(limit, 25, 100))
+            games = [{"app_id": game.app_id, "name": game.name, "esrb_rating": game.esrb_rating or None, "esrb_descriptors": esrb_descriptor_list(game.esrb_descriptors), "playtime_hours": round(user_game.playtime_forever / 60, 1), "genres": [g.genre_name for g in game.genres]} for game, user_game in rows]
+            return total, games, next_cursor
+
+    try:
+        total, games, next_cursor = await asyncio.to_thread(load)
+    except ValueError as e:
+        return tool_error("INVALID_PARAMS", str(e), {"field": "cursor"})
+
+    audience = f"a {age}-year-old (ESRB {rating} and below)" if age is not None else f"ESRB {rating} and below"
+    if not games:
+        return CallToolResult(content=[TextContent(type="text", text=f"No games in this library are rated for {audience}" + (f" without {', '.join(excluded)}" if excluded else ""), annotations=Annotations(audience=["user"], priority=0.7))], structuredContent={"steam_id": steam_id, "total": 0, "filters": filters, "games": []}, isError=False)
+
+    lines = [f"**{total} games for {audience}" + (f", leaving out {', '.join(excluded)}" if excluded else "") + f"; showing {len(games)}:**"]
+    for game in games:
+        content = f" - {', '.join(game['esrb_descriptors'])}" if game["esrb_descriptors"] else ""
+        lines.append(f"- **{game['name']}** ({game['esrb_rating'] or 'unrated'}){content}")
+    return page_result(lines, {"steam_id": steam_id, "total": total, "filters": filters, "games": games}, next_cursor)
+
+
+@mcp.tool(name="what_to_play", title="What to Play", description="Suggest library games for the time available, e.g. \"I have 45 minutes\", explaining each pick: games you could finish in that time by typical playtime, and games whose tags suit a session that long", annotations=ToolAnnotations(title="What to Play", readOnlyHint=True, idempotentHint=True))
+async def what_to_play(time_available: str, mood: str | None = None, limit: int = 5, ctx: Context | None = None, user: str | None = None) -> CallToolResult:
+    """Games that fit the time available, with the reasons for each.
+
+    Games whose typical length (SteamSpy's median playtime) leaves less than the
+    available time to play rank highest; otherwise genre and tag heuristics decide
+    what suits a session that long.
+
+    Args:
+        time_available: How long you can play, e.g. "45 minutes", "1.5h", "an hour and a half", or a number of minutes
+        mood: Optional mood to match as well: relaxing, energetic, competitive, social, creative, or story
+        limit: Number of suggestions, 1-20
+        user: Steam user identifier (optional, uses default if not provided)
+    """
+    try:
+        minutes = parse_duration(time_available)
+        session_context = SessionContext.from_params({"minutes": minutes, "mood": mood})
+    except ValueError as e:
+        return tool_error("INVALID_PARAMS", str(e), {"field": "mood" if mood and mood.lower() not in MOODS else "time_available"})
+
+    user_result = await resolve_user_or_elicit(user, ctx)
+    if "error" in user_result:
+        return user_error(user_result)
+
+    recommendations = await asyncio.to_thread(rank_library, user_result["steam_id"], session_context, page_limit(limit, 5, 20))
+    result = {"time_available": time_available, "minutes": minutes, "mood": session_context.mood, "recommendations": [r.to_dict() for r in recommendations]}
+    if not recommendations:
+        return CallToolResult(content=[TextContent(type="text", text=f"Nothing in your library stands out for {minutes} minutes of play", annotations=Annotations(audience=["user"], priority=0.8))], structuredContent=result, isError=False)
+
+    lines = [f"**With {minutes} minutes to play ({session_context.describe()}):**"]
+    for rank, recommendation in enumerate(recommendations, 1):
+        playtime = f"{recommendation.playtime_hours:.1f}h played" if recommendation.playtime_hours > 0 else "unplayed"
+        lines.append(f"\n{rank}. **{recommendation.name}** ({playtime})\n" + "\n".join(f"   - {reason}" for reason in recommendation.reasons))
+    return CallToolResult(content=[TextContent(type="text", text="\n".join(lines), annotations=Annotations(audience=["user"], priority=0.9))], structuredContent=result, isError=False)
+
+
+@mcp.tool(name="find_common_games", title="Find Common Games", description="Find multiplayer and co-op games that every one of several Steam users owns - synced libraries or friends with public game lists - ranked by combined playtime and reviews", annotations=ToolAnnotations(title="Find Common Games", readOnlyHint=True, idempotentHint=True))
+async def find_common_games(users: list[str], multiplayer_only: bool = True, limit: int = 20) -> CallToolResult:
+    """Games everyone in a group owns, for picking something to play together.
+
+    Args:
+        users: Two or more Steam IDs, profile URLs, or persona names of library users or synced friends
+        multiplayer_only: Only include games with a multiplayer or co-op category (default true)
+        limit: Number of games to return, 1-100
+    """
+    if len(users) < 2:
+        return tool_error("INVALID_PARAMS", "Give at least two users to compare", {"field": "users"})
+
+    steam_ids = []
+    for user in users:
+        user_result = resolve_user_for_tool(user)
+        if "error" in user_result:
+            return user_error(user_result)
+        if user_result["steam_id"] not in steam_ids:
+            steam_ids.append(user_result["steam_id"])
+    if len(steam_ids) < 2:
+        return tool_error("INVALID_PARAMS", "The users given are all the same person", {"field": "users"})
+
+    def load():
+        with get_db() as session:
+            names = {profile.steam_id: profile.persona_name or profile.steam_id for profile in session.query(UserProfile).filter(UserProfile.steam_id.in_(steam_ids))}
+            library_counts = dict(session.query(UserGame.steam_id, func.count()).filter(UserGame.steam_id.in_(steam_ids), UserGame.removed_at.is_(None)).group_by(UserGame.steam_id).all())
+            friend_counts = dict(session.query(FriendGame.steam_id, func.count()).filter(FriendGame.steam_id.in_(steam_ids)).group_by(FriendGame.steam_id).all())
+            people = [{"steam_id": steam_id, "name": names.get(steam_id, steam_id), "games_known": max(library_counts.get(steam_id, 0), friend_counts.get(steam_id, 0))} for steam_id in steam_ids]
+            return people, common_games(session, steam_ids, multiplayer_only)
+
+    people, games = await asyncio.to_thread(load)
+    games = games[: page_limit(limit, 20, 100)]
+    result = {"users": people, "multiplayer_only": multiplayer_only, "games": games}
+    group = ", ".join(person["name"] for person in people)
+
+    unknown = [person["name"] for person in people if not person["games_known"]]
+    if unknown:
+        return CallToolResult(content=[TextContent(type="text", text=f"No games are known for {', '.join(unknown)}: sync their library, or their friends' game lists if their profile is public", annotations=Annotations(audience=["user"], priority=0.8))], structuredContent=result, isError=False)
+    if not games:
+        return CallToolResult(content=[TextContent(type="text", text=f"{group} don't all own any {'multiplayer ' if multiplayer_only else ''}games", annotations=Annotations(audience=["user"], priority=0.8))], structuredContent=result, isError=False)
+
+    lines = [f"**{len(games)} {'multiplayer ' if multiplayer_only else ''}games {group} all own:**"]
+    for game in games:
+        details = [f"{game['combined_playtime_hours']}h combined"]
+        if game["positive_percentage"] is not None:
+            details.append(f"{game['positive_percentage']}% positive")
+        if game["multiplayer"]:
+            details.append(", ".join(game["multiplayer"][:3]))
+        lines.append(f"- **{game['name']}** (App ID {game['app_id']}) - {' | '.join(details)}")
+    return CallToolResult(content=[TextContent(type="text", text="\n".join(lines), annotations=Annotations(audience=["user"], priority=0.8))], structuredContent=result, isError=False)
+
+
+@mcp.tool(name="summarize_library", title="Summarize Library", description="Write a short prose summary of a Steam library - its size, favourite genres, most played games, and backlog - using the connected model when the client supports sampling", annotations=ToolAnnotations(title="Summarize Library", readOnlyHint=True, idempotentHint=False))
+async def summarize_library(ctx: Context | None = None, user: str | None = None) -> CallToolResult:
+    """Summarize a user's library in a paragraph.
+
+    Args:
+        user: Steam user identifier (optional, uses default if not provided)
+    """
+    user_result = await resolve_user_or_elicit(user, ctx)
+    if "error" in user_result:
+        return user_error(user_result)
+    steam_id = user_result["steam_id"]
+
+    with get_db() as session:
+        profile = session.query(UserProfile).filter_by(steam_id=steam_id).first()
+        owned = session.query(UserGame).filter(UserGame.steam_id == steam_id, UserGame.removed_at.is_(None))
+        total_games = owned.count()
+        total_minutes = owned.with_entities(func.sum(UserGame.playtime_forever)).scalar() or 0
+        unplayed = owned.filter(UserGame.playtime_forever == 0).count()
+        most_played = [(name, round(minutes / 60, 1)) for name, minutes in session.query(Game.name, UserGame.playtime_forever).join(UserGame, Game.app_id == UserGame.app_id).filter(UserGame.steam_id == steam_id, UserGame.removed_at.is_(None), UserGame.playtime_forever > 0).order_by(UserGame.playtime_forever.desc()).limit(5)]
+        recent = [name for (name,) in session.query(Game.name).join(UserGame, Game.app_id == UserGame.app_id).filter(UserGame.steam_id == steam_id, UserGame.playtime_2weeks > 0).order_by(UserGame.playtime_2weeks.desc()).limit(3)]
+        top_genres = [(name, round((minutes or 0) / 60, 1)) for name, minutes in session.query(Genre.genre_name, func.sum(UserGame.playtime_forever)).select_from(Game).join(Game.genres).join(UserGame, Game.app_id == UserGame.app_id).filter(UserGame.steam_id == steam_id, UserGame.removed_at.is_(None)).group_by(Genre.genre_name).order_by(func.sum(UserGame.playtime_forever).desc()).limit(3)]
+
+    data = {"steam_id": steam_id, "persona_name": profile.persona_name if profile else None, "total_games": total_games, "total_hours": round(total_minutes / 60, 1), "unplayed_games": unplayed, "most_played": [{"name": name, "hours": hours} for name, hours in most_played], "top_genres_by_playtime": [{"genre": name, "hours": hours} for name, hours in top_genres], "recently_played": recent}
+
+    if not total_games:
+        return CallToolResult(content=[TextContent(type="text", text="This library has no games yet; run a sync first.", annotations=Annotations(audience=["user"], priority=0.7))], structuredContent={**data, "summary": None, "generated_by": None}, isError=False)
+
+    prompt = f"""Write a friendly one-paragraph summary of this Steam library for its owner: what they gravitate towards, what they've sunk the most time into, what they're playing lately, and how big the backlog is.
+Use only these facts:
+{json.dumps(data, indent=2)}"""
+    summary = await sample_text(ctx, prompt, max_tokens=400)
+    generated_by = "sampling"
+
+    if not summary:
+        generated_by = "template"
+        summary = f"{data['persona_name'] or steam_id}'s library has {total_games} games with {data['total_hours']} hours played; {unplayed} have never been started."
+        if most_played:
+            summary += " The most played are " + ", ".join(f"{name} ({hours}h)" for name, hours in most_played[:3]) + "."
+        if top_genres:
+            summary += f" Most of the time goes to {', '.join(name for name, _ in top_genres)} games."
+        if recent:
+            summary += f" Lately: {', '.join(recent)}."
+
+    return CallToolResult(content=[TextContent(type="text", text=summary, annotations=Annotations(audience=["user"], priority=0.8))], structuredContent={**data, "summary": summary, "generated_by": generated_by}, isError=False)
+
+
+@mcp.tool(name="search_steam_store", title="Steam Store Search", description="Search the Steam store for games, including ones not in your library, with optional price and platform filters", annotations=ToolAnnotations(title="Steam Store Lookup", readOnlyHint=True, openWorldHint=True))
+async def search_steam_store(query: str, max_price: float | None = None, platforms: str = "", limit: int = 10, user: str | None = None) -> CallToolResult:
+    """Search the Steam store and mark which results are already owned.
+
+    Args:
+        query: Game name or keywords to search for
+        max_price: Maximum price in dollars (optional)
+        platforms: Comma-separated required platforms: windows, mac, linux (optional)
+        limit: Maximum number of results to return (1-25)
+        user: Steam user identifier used to flag owned games (optional)
+    """
+    filters = {}
+    if max_price is not None:
+        filters["max_price"] = int(max_price * 100)
+    if platforms:
+        filters["platforms"] = [p.strip().lower() for p in platforms.split(",") if p.strip()]
+
+    try:
+        results = (await asyncio.to_thread(search_store, query, filters, raise_errors=True))[: max(1, min(limit, 25))]
+    except StoreUnavailable as e:
+        return tool_error("STEAM_API_UNAVAILABLE", f"The Steam store search is unavailable right now ({e}); try again later.")
+
+    if not results:
+        return CallToolResult(content=[TextContent(type="text", text=f"No Steam store results for '{query}'", annotations=Annotations(audience=["user"], priority=0.6))], structuredContent={"query": query, "results": []}, isError=False)
+
+    # Flag games already in the user's library when a user can be resolved
+    owned_ids = set()
+    user_result = resolve_user_for_tool(user, get_default_user_fallback)
+    if "error" not in user_result:
+        with get_db() as session:
+            owned_ids = {app_id for (app_id,) in session.query(UserGame.app_id).filter(UserGame.steam_id == user_result["steam_id"], UserGame.app_id.in_([r["app_id"] for r in results])).all()}
+
+    lines = [f"**Steam store results for '{query}':**\n"]
+    for result in results:
+        result["in_library"] = result["app_id"] in owned_ids
+        price = "Free" if not result["price_final"] else f"${result['price_final'] / 100:.2f}"
+        owned = " ✓ owned" if result["in_library"] else ""
+        metascore = f" | Metacritic: {result['metascore']}" if result["metascore"] else ""
+        lines.append(f"- **{result['name']}** (App ID {result['app_id']}) - {price}{metascore}{owned}")
+
+    return CallToolResult(content=[TextContent(type="text", text="\n".join(lines), annotations=Annotations(audience=["user"], priority=0.8))], structuredContent={"query": query, "filters": filters, "results": results}, isError=False)
+
+
+@mcp.tool(name="sync_all_libraries", title="Sync All Libraries", description="Queue a sync of every Steam library in the database, or check on a batch queued earlier", annotations=ToolAnnotations(title="Sync All Libraries", readOnlyHint=False, destructiveHint=False, idempotentHint=False, openWorldHint=True))
+async def sync_every_library(scope: str = "full", batch_id: int | None = None) -> CallToolResult:
+    """Queue syncs for all libraries, or report a batch's progress.
+
+    Args:
+        scope: What to fetch per game - full|playtime|metadata|reviews
+        batch_id: Report progress of an earlier batch instead of queueing a new one
+    """
+
+    # Estimating every library's requests takes a while; don't hold up other requests meanwhile
+    def start():
+        with get_db() as session:
+            return sync_all_libraries(session, scope=scope)
+
+    if batch_id is not None:
+        batch = sync_batch_status(batch_id)
+        if not batch:
+            return tool_error("NOT_FOUND", f"Sync batch {batch_id} not found", {"batch_id": batch_id})
+    else:
+        try:
+            batch = await asyncio.to_thread(start)
+        except (ValueError, LookupError) as e:
+            return sync_error(e)
+
+    text = f"Sync batch {batch['id']}: {batch['finished']} of {batch['total']} libraries finished ({batch['failed']} failed), {batch['running']} running, {batch['queued']} queued."
+    if batch["skipped"]:
+        text += f" Skipped {len(batch['skipped'])} libraries that were already syncing or would overrun today's Steam request budget."
+    if not batch["done"]:
+        text += f" Call again with batch_id={batch['id']} to check progress."
+    return CallToolResult(content=[TextContent(type="text", text=text, annotations=Annotations(audience=["user"], priority=0.8))], structuredContent=batch, isError=False)
+
+
+def cancellable_libraries() -> list[str]:
+    """Libraries with a running (or paused) sync, or with a queued one when none is running"""
+    status = sync_queue.status()
+    return list(dict.fromkeys(job["steam_id"] for job in status["running"] or status["queued"]))
+
+
+def resumable_libraries() -> list[str]:
+    """Libraries with a paused sync, or with an interrupted sync to resume when none is paused"""
+    paused = [job["steam_id"] for job in sync_queue.status()["running"] if job["state"] == "paused"]
+    if paused:
+        return list(dict.fromkeys(paused))
+    with get_db() as session:
+        return [checkpoint.steam_id for checkpoint in session.query(SyncCheckpoint).order_by(SyncCheckpoint.updated_at.desc()) if checkpoint.resumable]
+
+
+@mcp.tool(name="cancel_sync", title="Cancel Library Sync", description="Stop a running Steam library sync immediately (or drop a queued one); saved progress is kept for resume_sync", annotations=ToolAnnotations(title="Cancel Library Sync", readOnlyHint=False, destructiveHint=False, idempotentHint=False, openWorldHint=False))
+async def cancel_library_sync(user: str | None = None, ctx: Context | None = None) -> CallToolResult:
+    """Cancel a sync started by this server.
+
+    Args:
+        user: Steam user whose sync to cancel (optional; asks which one when several are running, otherwise defaults to the running sync)
+    """
+    steam_id, error = await resolve_sync_user(user, ctx, cancellable_libraries, "Syncs are running for several libraries. Which one should I cancel?")
+    if error:
+        return error
+
+    try:
+        job = await asyncio.to_thread(cancel_sync, steam_id)
+    except LookupError as e:
+        return sync_error(e)
+
+    text = f"Cancelled the sync for {job['steam_id']}." + (" Games saved so far are kept; use resume_sync to continue later." if job["state"] != "finished" else "")
+    return CallToolResult(content=[TextContent(type="text", text=text, annotations=Annotations(audience=["user"], priority=0.8))], structuredContent={"job": job}, isError=False)
+
+
+@mcp.tool(name="pause_sync", title="Pause Library Sync", description="Pause a running Steam library sync before its next API request, keeping all progress; resume_sync continues it", annotations=ToolAnnotations(title="Pause Library Sync", readOnlyHint=False, destructiveHint=False, idempotentHint=False, openWorldHint=False))
+async def pause_library_sync(user: str | None = None, ctx: Context | None = None) -> CallToolResult:
+    """Pause a sync started by this server.
+
+    Args:
+        user: Steam user whose sync to pause (optional; asks which one when several are running, otherwise defaults to the running sync)
+    """
+    steam_id, error = await resolve_sync_user(user, ctx, lambda: [job["steam_id"] for job in sync_queue.status()["running"] if job["state"] == "running"], "Syncs are running for several libraries. Which one should I pause?")
+    if error:
+        return error
+
+    try:
+        job = await asyncio.to_thread(pause_sync, steam_id)
+    except LookupError as e:
+        return sync_error(e)
+
+    text = f"Paused the sync for {job['steam_id']}. Progress so far is kept; use resume_sync to continue."
+    return CallToolResult(content=[TextContent(type="text", text=text, annotations=Annotations(audience=["user"], priority=0.8))], structuredContent={"job": job}, isError=False)
+
+
+@mcp.tool(name="resume_sync", title="Resume Library Sync", description="Continue a paused Steam library sync, or resume an interrupted one from its last checkpoint, skipping games that were already saved", annotations=ToolAnnotations(title="Resume Library Sync", readOnlyHint=False, destructiveHint=False, idempotentHint=False, openWorldHint=True))
+async def resume_library_sync(user: str | None = None, ctx: Context | None = None) -> CallToolResult:
+    """Continue a paused sync or resume an interrupted one in the background.
+
+    Args:
+        user: Steam user whose sync to resume (optional; asks which one when several could be resumed, otherwise defaults to a paused sync, then the most recent sync)
+    """
+    steam_id, error = await resolve_sync_user(user, ctx, resumable_libraries, "Several library syncs can be resumed. Which one should I resume?")
+    if error:
+        return error
+
+    def start():
+        with get_db() as session:
+            return resume_sync(session, steam_id)
+
+    try:
+        result = await asyncio.to_thread(start)
+    except (LookupError, SyncAlreadyRunning, QuotaExceeded) as e:
+        return sync_error(e)
+
+    checkpoint, job = result["checkpoint"], result["job"]
+    if result["unpaused"]:
+        text = f"Unpaused the sync for {job['steam_id']}; it continues where it left off."
+    else:
+        queued = f" It is number {job['position']} in the sync queue." if job.get("position", 1) > 1 else ""
+        if job.get("deferred_until"):
+            queued += " It waits for today's Steam request budget to reset first."
+        text = f"Resumed the library sync for {checkpoint['steam_id']}: {checkpoint['processed_games']} of {checkpoint['total_games'] or 'unknown'} games were already saved and will be skipped. The sync continues in the background.{queued}"
+    return CallToolResult(content=[TextContent(type="text", text=text, annotations=Annotations(audience=["user"], priority=0.8))], structuredContent=result, isError=False)
+
+
+@mcp.tool(name="control_scheduler", title="Control Sync Scheduler", description="Pause or resume automatic scheduled syncs (e.g. during maintenance), or queue every library's next scheduled sync right away", annotations=ToolAnnotations(title="Control Sync Scheduler", readOnlyHint=False, destructiveHint=False, idempotentHint=False, openWorldHint=True))
+async def control_scheduler(action: str) -> CallToolResult:
+    """Pause, resume, or force a pass of the sync scheduler.
+
+    Args:
+        action: pause|resume|run_now
+    """
+    if action == "run_now":
+        results = await asyncio.to_thread(scheduler.run_now)
+        queued = [result for result in results if "job_id" in result]
+        text = f"Queued {len(queued)} scheduled sync(s): " + ", ".join(f"{result['steam_id']} ({result['scope']})" for result in queued) if queued else "No scheduled syncs were queued."
+        if len(queued) < len(results):
+            text += f" Skipped {len(results) - len(queued)} that were already queued or over the request budget."
+        return CallToolResult(content=[TextContent(type="text", text=text, annotations=Annotations(audience=["user"], priority=0.8))], structuredContent={"results": results}, isError=False)
+    if action not in ("pause", "resume"):
+        return tool_error("INVALID_PARAMS", f"Unknown action '{action}': expected pause, resume, or run_now", {"field": "action", "allowed": ["pause", "resume", "run_now"]})
+
+    status = await asyncio.to_thread(scheduler.pause if action == "pause" else scheduler.resume)
+    text = "Paused the sync scheduler; scheduled runs are skipped until it is resumed. Manual syncs still work." if action == "pause" else "Resumed the sync scheduler; syncs run again from their next scheduled time."
+    return CallToolResult(content=[TextContent(type="text", text=text, annotations=Annotations(audience=["user"], priority=0.8))], structuredContent=status, isError=False)
+
+
+@mcp.tool(name="sync_game", title="Refresh One Game", description="Re-fetch store details, reviews, price, and playtime for a single game in the library, ignoring cached data", annotations=ToolAnnotations(title="Refresh One Game", readOnlyHint=False, destructiveHint=False, idempotentHint=False, openWorldHint=True))
+async def sync_single_game(game_id: int, user: str | None = None, scope: str = "full", ctx: Context | None = None) -> CallToolResult:
+    """Refresh one game from Steam and report its updated data.
+
+    Args:
+        game_id: Steam app ID of the game to refresh
+        user: Steam user whose library owns the game (optional; asks which one when several libraries own it, otherwise defaults to the most recently synced library)
+        scope: What to re-fetch - full|playtime|metadata|reviews (playtime is the cheapest)
+    """
+
+    def owners():
+        with get_db() as session:
+            return [steam_id for (steam_id,) in session.query(UserGame.steam_id).filter(UserGame.app_id == game_id, UserGame.removed_at.is_(None)).order_by(UserGame.steam_id)]
+
+    steam_id, error = await resolve_sync_user(user, ctx, owners, f"Several libraries own game {game_id}. Whose copy should I refresh?")
+    if error:
+        return error
+
+    def start():
+        with get_db() as session:
+            return sync_game(session, game_id, steam_id, scope=scope)
+
+    try:
+        job = await asyncio.to_thread(start)
+    except (ValueError, LookupError, SyncAlreadyRunning, QuotaExceeded) as e:
+        return sync_error(e)
+
+    # A single game takes a handful of requests, so wait for it rather than answering with stale data
+    try:
+        exit_code = await asyncio.to_thread(sync_queue.wait, job["id"], 120)
+    except asyncio.CancelledError:
+        # The client cancelled the call (notifications/cancelled); don't leave its refresh running
+        try:
+            sync_queue.cancel(job["id"])
+        except LookupError:
+            pass
+        raise
+    if exit_code is None:
+        job = sync_queue.job(job["id"])
+        waiting = f"waiting behind {job['position'] - 1} other syncs" if job.get("position") else "still refreshing"
+        return CallToolResult(content=[TextContent(type="text", text=f"Game {game_id} is {waiting}; check back shortly.")], structuredContent={"app_id": game_id, "job": job}, isError=False)
+    if exit_code != 0:
+        return tool_error("SYNC_FAILED", f"Could not refresh game {game_id}; it may not be in the library of {job['steam_id']}.", {"app_id": game_id, "job": sync_queue.job(job["id"])})
+
+    def load():
+        with get_db() as session:
+            game = session.query(Game).filter_by(app_id=game_id).first()
+            user_game = session.query(UserGame).filter_by(steam_id=job["steam_id"], app_id=game_id).first()
+            return {"app_id": game_id, "name": game.name if game else None, "playtime_hours": round(user_game.playtime_forever / 60, 1) if user_game else None, "review_summary": game.reviews.review_summary if game and game.reviews else None, "price_final": game.price_final if game else None, "currency": game.currency if game else None}
+
+    data = await asyncio.to_thread(load)
+
+    text = f"Refreshed **{data['name']}** (App ID {game_id}): {data['playtime_hours']} hours played, reviews: {data['review_summary'] or 'Unknown'}."
+    return CallToolResult(content=[TextContent(type="text", text=text, annotations=Annotations(audience=["user"], priority=0.8))], structuredContent=data, isError=False)
+
+
 # Helper functions
 def format_top_items(items: list[tuple], limit: int) -> str:
     """Format top N items from query results."""
@@ -1399,42 +2326,6 @@ def count_category(category_counts: list[tuple], category_name: str) -> int:
     return 0
 
 
-def get_max_esrb_for_age(age: int) -> str:
-    if age < 6:
-        return "EC"
-    elif age < 10:
-        return "E"
-    elif age < 13:
-        return "E10+"
-    elif age < 17:
-        return "T"
-    else:
-        return "M"
-
-
-def get_esrb_ratings_up_to(max_rating: str) -> list[str]:
-    ratings = ["EC", "E", "E10+", "T", "M"]
-    return ratings[: ratings.index(max_rating) + 1]
-
-
-def get_max_pegi_for_age(age: int) -> str:
-    if age < 7:
-        return "3"
-    elif age < 12:
-        return "7"
-    elif age < 16:
-        return "12"
-    elif age < 18:
-        return "16"
-    else:
-        return "18"
-
-
-def get_pegi_ratings_up_to(max_rating: str) -> list[str]:
-    ratings = ["3", "7", "12", "16", "18"]
-    return ratings[: ratings.index(max_rating) + 1]
-
-
 def format_tool_documentation(name: str, doc: dict) -> str:
     """Format tool documentation for display."""
     output = f"# {name} Tool Documentation\n\n"
@@ -1478,7 +2369,7 @@ def format_tool_documentation(name: str, doc: dict) -> str:
     return output
 
 
-@mcp.tool(name="get_tool_help", title="Tool Documentation Helper", description="Get detailed help and examples for MCP tools with comprehensive documentation and usage patterns")
+@mcp.tool(name="get_tool_help", title="Tool Documentation Helper", description="Get detailed help and examples for MCP tools with comprehensive documentation and usage patterns", annotations=ToolAnnotations(title="Tool Documentation Helper", readOnlyHint=True, idempotentHint=True))
 async def get_tool_help(tool_name: str = None) -> CallToolResult:
     """Get detailed help and examples for MCP tools.
 
@@ -1489,7 +2380,7 @@ async def get_tool_help(tool_name: str = None) -> CallToolResult:
         Detailed documentation with examples, parameters, common errors, and usage patterns
     """
 
-    tool_docs = {"smart_search": {"description": "Natural language game search with AI-powered filtering and flexible parameter parsing", "parameters": {"query": "Natural language search query (required) - can be game names, descriptions, or requests", "filters": "Additional filters as JSON or natural language (optional)", "limit": "Number of results to return, 1-50 (default: 10)", "sort_by": "Sort method: relevance, playtime, metacritic, recent, random (default: relevance)", "user": "Steam ID or username (uses default if not specified)"}, "filter_examples": [{"description": "JSON filter for action games rated 80+", "value": '{"genres": ["Action"], "min_rating": 80}'}, {"description": "Natural language filter", "value": "multiplayer games released after 2020"}, {"description": "Combined search with natural language filters", "query": "zombie survival games", "filters": "exclude horror genre, coop multiplayer"}, {"description": "VR games filter", "value": "vr games"}, {"description": "Unplayed games filter", "value": "unplayed indie games"}], "common_errors": {"Invalid filters format": 'Use valid JSON like {"genres": ["Action"]} or natural language like \'action games rated over 80\'', "Multiple users found": "Specify exact Steam ID or username in the user parameter. Use library://users resource to see available users.", "No results found": "Try broader search terms, different genres, or check spelling"}}, "recommend_games": {"description": "AI-powered personalized game recommendations with context-aware filtering and elicitation", "contexts": {"abandoned": "Games you started but haven't finished (1-10 hours played)", "similar_to:[game]": "Find games similar to specified game (e.g., 'similar_to:Portal 2')", "mood:[feeling]": "Games matching a mood (e.g., 'mood:relaxing', 'mood:competitive')", "genre:[type]": "Smart genre-based recommendations (e.g., 'genre:RPG')", "trending": "Popular games being played by many users recently", "hidden_gems": "Highly-rated games with low player counts", "completionist": "Games where you're close to 100% achievements", "weekend": "Games perfect for weekend sessions (20-40 hour campaigns)", "family": "Age-appropriate games (will ask for child's age)", "quick_session": "Games for short sessions (will ask for available time)"}, "parameter_examples": [{"context": "abandoned", "parameters": "focus on games under 20 hours"}, {"context": "mood:relaxing", "parameters": '{"exclude_genres": ["Horror", "Action"], "single_player": true}'}, {"context": "similar_to:Portal 2", "parameters": "no puzzle games"}, {"context": "genre:RPG", "parameters": "highly rated, no multiplayer"}], "common_errors": {"Invalid context": "Use valid contexts like 'abandoned', 'mood:relaxing', or 'similar_to:[game name]'", "Invalid parameters format": "Use JSON, natural language, or simple keywords. Avoid mixing formats.", "Game not found for similar_to": "Check spelling of game name or use partial matches"}}, "get_library_insights": {"description": "Deep analytics and insights about your gaming library and habits with AI interpretation", "parameters": {"analysis_type": "Type of analysis: patterns, gaps, value, social, achievements, trends", "compare_to": "Comparison target (optional): friends, global, genre_average", "time_range": "Period to analyze (default: all): all, recent, last_month", "user": "Steam ID or username (uses default if not specified)"}, "parameter_examples": [{"context": "patterns", "parameters": "Get detailed gaming habit analysis"}, {"context": "gaps", "parameters": "Find popular games in favorite genres you don't own"}, {"context": "value", "parameters": "Analyze cost per hour and game value"}]}, "find_family_games": {"description": "Find age-appropriate games for family gaming using ESRB/PEGI ratings", "parameters": {"child_age": "Age of youngest player (required) - determines appropriate rating limits", "user": "Steam ID or username (uses default if not specified)"}, "parameter_examples": [{"context": "Age 8 child", "parameters": "child_age=8 (allows E and E10+ rated games)"}, {"context": "Age 12 child", "parameters": "child_age=12 (allows up to T rated games)"}]}, "find_quick_session_games": {"description": "Find games perfect for quick gaming sessions with smart tag analysis", "parameters": {"session_length": "Session type: 'short' (5-15min), 'medium' (15-30min), 'long' (30-60min)", "user": "Steam ID or username (uses default if not specified)"}, "parameter_examples": [{"context": "Quick break games", "parameters": "session_length='short' for arcade and puzzle games"}, {"context": "Lunch break gaming", "parameters": "session_length='medium' for balanced quick games"}]}}
+    tool_docs = {"smart_search": {"description": "Natural language game search with AI-powered filtering and flexible parameter parsing", "parameters": {"query": "Natural language search query (required) - can be game names, descriptions, or requests", "filters": "Additional filters as JSON or natural language (optional)", "limit": "Number of results to return, 1-50 (default: 10)", "sort_by": "Sort method: relevance, playtime, metacritic, recent, random (default: relevance)", "user": "Steam ID or username (uses default if not specified)"}, "filter_examples": [{"description": "JSON filter for action games rated 80+", "value": '{"genres": ["Action"], "min_rating": 80}'}, {"description": "Natural language filter", "value": "multiplayer games released after 2020"}, {"description": "Combined search with natural language filters", "query": "zombie survival games", "filters": "exclude horror genre, coop multiplayer"}, {"description": "VR games filter", "value": "vr games"}, {"description": "Unplayed games filter", "value": "unplayed indie games"}], "common_errors": {"Invalid filters format": 'Use valid JSON like {"genres": ["Action"]} or natural language like \'action games rated over 80\'', "Multiple users found": "Specify exact Steam ID or username in the user parameter. Use library://users resource to see available users.", "No results found": "Try broader search terms, different genres, or check spelling"}}, "recommend_games": {"description": "AI-powered personalized game recommendations with context-aware filtering and elicitation", "contexts": {"abandoned": "Games you started but haven't finished (1-10 hours played)", "similar_to:[game]": "Find games similar to specified game (e.g., 'similar_to:Portal 2')", "mood:[feeling]": "Games matching a mood (e.g., 'mood:relaxing', 'mood:competitive')", "genre:[type]": "Smart genre-based recommendations (e.g., 'genre:RPG')", "trending": "Popular games being played by many users recently", "hidden_gems": "Highly-rated games with low player counts", "completionist": "Games where you're close to 100% achievements", "weekend": "Games perfect for weekend sessions (20-40 hour campaigns)", "family": "Age-appropriate games (will ask for child's age)", "quick_session": "Games for short sessions (will ask for available time)", "session": "Ranked picks with reasons for a mood, time available, friends present, and family-friendliness"}, "parameter_examples": [{"context": "abandoned", "parameters": "focus on games under 20 hours"}, {"context": "mood:relaxing", "parameters": '{"exclude_genres": ["Horror", "Action"], "single_player": true}'}, {"context": "similar_to:Portal 2", "parameters": "no puzzle games"}, {"context": "genre:RPG", "parameters": "highly rated, no multiplayer"}, {"context": "session", "parameters": '{"mood": "relaxing", "minutes": 30, "friends_present": 1, "age": 7}'}], "common_errors": {"Invalid context": "Use valid contexts like 'abandoned', 'mood:relaxing', or 'similar_to:[game name]'", "Invalid parameters format": "Use JSON, natural language, or simple keywords. Avoid mixing formats.", "Game not found for similar_to": "Check spelling of game name or use partial matches"}}, "get_library_insights": {"description": "Deep analytics and insights about your gaming library and habits with AI interpretation", "parameters": {"analysis_type": "Type of analysis: patterns, gaps, value, social, achievements, trends", "compare_to": "Comparison target (optional): friends, global, genre_average", "time_range": "Period to analyze (default: all): all, recent, last_month", "user": "Steam ID or username (uses default if not specified)"}, "parameter_examples": [{"context": "patterns", "parameters": "Get detailed gaming habit analysis"}, {"context": "gaps", "parameters": "Find popular games in favorite genres you don't own"}, {"context": "value", "parameters": "Analyze cost per hour and game value"}]}, "find_family_games": {"description": "Find age-appropriate games for family gaming using ESRB/PEGI ratings", "parameters": {"child_age": "Age of youngest player (required) - determines appropriate rating limits", "user": "Steam ID or username (uses default if not specified)"}, "parameter_examples": [{"context": "Age 8 child", "parameters": "child_age=8 (allows E and E10+ rated games)"}, {"context": "Age 12 child", "parameters": "child_age=12 (allows up to T rated games)"}]}, "find_quick_session_games": {"description": "Find games perfect for quick gaming sessions with smart tag analysis", "parameters": {"session_length": "Session type: 'short' (5-15min), 'medium' (15-30min), 'long' (30-60min)", "user": "Steam ID or username (uses default if not specified)"}, "parameter_examples": [{"context": "Quick break games", "parameters": "session_length='short' for arcade and puzzle games"}, {"context": "Lunch break gaming", "parameters": "session_length='medium' for balanced quick games"}]}}
 
     if tool_name:
         if tool_name in tool_docs:
@@ -13,6 +13,7 @@ import re
 # Import shared database utilities
 import sys
 
+from mcp.types import ToolAnnotations
 from sqlalchemy import and_, case, func, or_
 from sqlalchemy.orm import joinedload
 
@@ -30,6 +31,9 @@ from shared.database import (
     resolve_user_for_tool,
 )
 
+# Every tool here only reads the local library database
+READ_ONLY = ToolAnnotations(readOnlyHint=True, idempotentHint=True, openWorldHint=False)
+
 
 def is_natural_language_query(query: str) -> bool:
     """Check if query is natural language vs simple keywords."""
@@ -87,7 +91,7 @@ def parse_natural_language_filters(text: str) -> dict:
 # ============================================================================
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def search_games(query: str, filters: str | None = None, limit: int = 25, user: str | None = None) -> str:
     """
     Search for games using natural language or structured queries.
@@ -163,7 +167,7 @@ async def search_games(query: str, filters: str | None = None, limit: int = 25,
         return json.dumps({"error": f"Search failed: {str(e)}", "help": "Try a simpler query or check if the database is accessible"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_game_details(game_id: int | None = None, game_name: str | None = None, include_reviews: bool = False, include_tags: bool = True, user: str | None = None) -> str:
     """
     Get detailed information about a specific game.
@@ -226,7 +230,7 @@ async def get_game_details(game_id: int | None = None, game_name: str | None = N
         return json.dumps({"error": f"Failed to get game details: {str(e)}", "help": "Check if the game_id is valid or try searching by name first"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def find_similar_games(game_id: int | None = None, game_name: str | None = None, similarity_factors: list[str] = None, limit: int = 10, user: str | None = None) -> str:
     """
     Find games similar to a specified game.
@@ -327,7 +331,7 @@ async def find_similar_games(game_id: int | None = None, game_name: str | None =
 # ============================================================================
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_library_overview(user: str | None = None, include_stats: bool = True) -> str:
     """
     Get a comprehensive overview of the user's Steam library.
@@ -380,7 +384,7 @@ async def get_library_overview(user: str | None = None, include_stats: bool = Tr
         return json.dumps({"error": f"Failed to get library overview: {str(e)}", "help": "Check if user exists and database is accessible"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_user_profile(user_id: str | None = None) -> str:
     """
     Get user profile information and metadata.
@@ -419,7 +423,7 @@ async def get_user_profile(user_id: str | None = None) -> str:
         return json.dumps({"error": f"Failed to get user profile: {str(e)}", "help": "Check database connection and user data availability"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_user_games(user_id: str | None = None, sort_by: str = "name", filter_played: bool | None = None, limit: int = 50) -> str:
     """
     Get a user's complete game collection.
@@ -480,7 +484,7 @@ async def get_user_games(user_id: str | None = None, sort_by: str = "name", filt
         return json.dumps({"error": f"Failed to get user games: {str(e)}", "help": "Check if user exists and has games in the database"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_user_stats(user_id: str | None = None, time_range: str = "all") -> str:
     """
     Get detailed gaming statistics for a user.
@@ -556,7 +560,7 @@ async def get_user_stats(user_id: str | None = None, time_range: str = "all") ->
 # ============================================================================
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_genres(include_counts: bool = True) -> str:
     """
     Get all available genres with optional game counts.
@@ -585,7 +589,7 @@ async def get_genres(include_counts: bool = True) -> str:
         return json.dumps({"error": f"Failed to get genres: {str(e)}", "help": "Check database connection"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_games_by_genre(genre_name: str | None = None, user: str | None = None, sort_by: str = "name", limit: int = 25) -> str:
     """
     Get games in a specific genre.
@@ -657,7 +661,7 @@ async def get_games_by_genre(genre_name: str | None = None, user: str | None = N
         return json.dumps({"error": f"Failed to get games by genre: {str(e)}", "help": "Check if genre name is correct and user has games in this genre"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_categories(include_counts: bool = True, category_type: str = "all") -> str:
     """
     Get all available categories/features.
@@ -703,7 +707,7 @@ async def get_categories(include_counts: bool = True, category_type: str = "all"
         return json.dumps({"error": f"Failed to get categories: {str(e)}", "help": "Check database connection"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_games_by_category(category: str | None = None, user: str | None = None, limit: int = 25) -> str:
     """
     Get games with a specific category/feature.
@@ -756,7 +760,7 @@ async def get_games_by_category(category: str | None = None, user: str | None =
 # ============================================================================
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def recommend_games(context: str | None = "general", preferences: str | None = None, limit: int = 10, user: str | None = None) -> str:
     """
     Get personalized game recommendations based on context and preferences.
@@ -863,7 +867,7 @@ async def recommend_games(context: str | None = "general", preferences: str | No
         return json.dumps({"error": f"Failed to generate recommendations: {str(e)}", "help": "Try with different context or check if user has games in library"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def find_family_games(child_age: int | None = None, content_preferences: list[str] = None, user: str | None = None) -> str:
     """
     Find age-appropriate games for family gaming.
@@ -944,7 +948,7 @@ async def find_family_games(child_age: int | None = None, content_preferences: l
         return json.dumps({"error": f"Failed to find family games: {str(e)}", "help": "Check if user has family-appropriate games in library"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def find_quick_games(session_length: str = "short", genre_preference: str | None = None, user: str | None = None) -> str:
     """
     Find games perfect for quick gaming sessions.
@@ -1037,7 +1041,7 @@ async def find_quick_games(session_length: str = "short", genre_preference: str
         return json.dumps({"error": f"Failed to find quick games: {str(e)}", "help": "Check session_length parameter and user's library"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_unplayed_games(user: str | None = None, sort_by: str = "rating", include_reasons: bool = True) -> str:
     """
     Get games in library that haven't been played yet.
@@ -1133,7 +1137,7 @@ async def get_unplayed_games(user: str | None = None, sort_by: str = "rating", i
 # ============================================================================
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_platform_games(platform: str | None = None, user: str | None = None) -> str:
     """
     Get games available on a specific platform.
@@ -1208,7 +1212,7 @@ async def get_platform_games(platform: str | None = None, user: str | None = Non
         return json.dumps({"error": f"Failed to get platform games: {str(e)}", "help": "Check if platform name is correct"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_multiplayer_games(multiplayer_type: str | None = None, user: str | None = None) -> str:
     """
     Get multiplayer games by type.
@@ -1269,7 +1273,7 @@ async def get_multiplayer_games(multiplayer_type: str | None = None, user: str |
         return json.dumps({"error": f"Failed to get multiplayer games: {str(e)}", "help": "Check if multiplayer_type is correct"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def get_vr_games(vr_type: str = "any", user: str | None = None) -> str:
     """
     Get VR-compatible games.
@@ -1339,7 +1343,7 @@ async def get_vr_games(vr_type: str = "any", user: str | None = None) -> str:
         return json.dumps({"error": f"Failed to get VR games: {str(e)}", "help": "Check if user has VR games in library"}, indent=2)
 
 
-@mcp.tool()
+@mcp.tool(annotations=READ_ONLY)
 async def analyze_gaming_patterns(analysis_type: str = "overview", time_range: str = "all", user: str | None = None) -> str:
     """
     Analyze gaming patterns and provide insights.
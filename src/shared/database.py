@@ -1,3 +1,4 @@
+import json
 import logging
 import os
 import time
@@ -10,6 +11,7 @@ from typing import Any
 from sqlalchemy import (
     Boolean,
     Column,
+    Float,
     ForeignKey,
     Index,
     Integer,
@@ -18,10 +20,14 @@ from sqlalchemy import (
     Text,
     create_engine,
     func,
+    inspect,
+    text,
 )
 from sqlalchemy.exc import DisconnectionError, StatementError, TimeoutError
 from sqlalchemy.orm import Session, declarative_base, relationship, sessionmaker
 
+from .steamid import parse_steam_identifier, vanity_profile_url
+
 logger = logging.getLogger(__name__)
 
 Base = declarative_base()
@@ -102,6 +108,11 @@ def get_db_transaction():
         db.close()
 
 
+# What a library sync fetches per game (the fetcher's --scope): everything, only GetOwnedGames
+# playtime, store metadata without reviews, or only reviews
+SYNC_SCOPES = ("full", "playtime", "metadata", "reviews")
+
+
 # Association tables for many-to-many relationships
 game_genres = Table("game_genres", Base.metadata, Column("app_id", Integer, ForeignKey("games.app_id"), primary_key=True), Column("genre_id", Integer, ForeignKey("genres.genre_id"), primary_key=True))
 
@@ -132,6 +143,20 @@ class UserProfile(Base):
     locstatecode = Column(String)  # State/region code (e.g., "CA")
     xp = Column(Integer)  # Raw XP value
     steam_level = Column(Integer)  # Calculated from XP
+    badge_count = Column(Integer)  # Number of badges earned
+    vac_banned = Column(Boolean, default=False)
+    number_of_vac_bans = Column(Integer, default=0)
+    number_of_game_bans = Column(Integer, default=0)
+    days_since_last_ban = Column(Integer, default=0)
+    community_banned = Column(Boolean, default=False)
+    economy_ban = Column(String)  # "none", "probation", or "banned"
+    store_country = Column(String)  # Per-library store country override (e.g., "gb")
+    store_language = Column(String)  # Per-library store language override (e.g., "german")
+    sync_achievements = Column(Boolean)  # Per-library override for the achievement sync phase (NULL follows --achievements)
+    sync_rate_limit = Column(Float)  # Per-library Steam API requests per second during its sync (NULL follows --rate-limit)
+    sync_game_delay = Column(Float)  # Per-library pause in seconds between games during its sync
+    sync_max_enrichment = Column(Integer)  # Per-library cap on games fetched with fresh store details per sync
+    sync_schedule = Column(String)  # Per-library cron schedules for server-started syncs (NULL follows SYNC_SCHEDULE, "" disables)
     last_updated = Column(Integer, default=lambda: int(datetime.now().timestamp()))
 
     # Relationships
@@ -158,11 +183,23 @@ class Game(Base):
     platforms_linux = Column(Boolean, default=False)
     controller_support = Column(String)
     vr_support = Column(Boolean, default=False)
+    steam_deck_compat = Column(String)  # verified, playable, unsupported, or unknown
+    steamspy_owners = Column(String)  # Owner estimate range, e.g. "1,000,000 .. 2,000,000"
+    steamspy_average_playtime = Column(Integer)  # in minutes, across all owners
+    steamspy_median_playtime = Column(Integer)  # in minutes, across all owners
     esrb_rating = Column(String)
     esrb_descriptors = Column(Text)
     pegi_rating = Column(String)
     pegi_descriptors = Column(Text)
     release_date = Column(String)
+    dlc_app_ids = Column(Text)  # JSON list of the game's DLC app IDs from the store
+    pc_requirements_minimum = Column(Text)  # Windows system requirements as plain text
+    pc_requirements_recommended = Column(Text)
+    price_initial = Column(Integer)  # Undiscounted price in cents
+    price_final = Column(Integer)  # Current price in cents
+    discount_percent = Column(Integer, default=0)
+    currency = Column(String)
+    price_last_updated = Column(Integer)
     last_updated = Column(Integer, default=lambda: int(datetime.now().timestamp()))
 
     # Relationships
@@ -173,6 +210,13 @@ class Game(Base):
     publishers = relationship("Publisher", secondary=game_publishers, back_populates="games")
     categories = relationship("Category", secondary=game_categories, back_populates="games")
     tags = relationship("Tag", secondary=game_tags, back_populates="games")
+    news = relationship("GameNews", back_populates="game", cascade="all, delete-orphan")
+    review_texts = relationship("ReviewText", back_populates="game", cascade="all, delete-orphan")
+    localizations = relationship("GameLocalization", back_populates="game", cascade="all, delete-orphan")
+    price_history = relationship("PriceHistory", back_populates="game", cascade="all, delete-orphan")
+    achievements = relationship("Achievement", back_populates="game", cascade="all, delete-orphan")
+    dlc = relationship("DLC", back_populates="game", cascade="all, delete-orphan")
+    workshop_items = relationship("WorkshopItem", back_populates="game", cascade="all, delete-orphan")
 
     # Indexes for search and filtering
     __table_args__ = (
@@ -188,6 +232,18 @@ class UserGame(Base):
     app_id = Column(Integer, ForeignKey("games.app_id"), primary_key=True)
     playtime_forever = Column(Integer, default=0)  # in minutes
     playtime_2weeks = Column(Integer, default=0)  # in minutes
+    playtime_windows_forever = Column(Integer, default=0)  # in minutes
+    playtime_mac_forever = Column(Integer, default=0)  # in minutes
+    playtime_linux_forever = Column(Integer, default=0)  # in minutes
+    playtime_deck_forever = Column(Integer, default=0)  # in minutes
+    last_played = Column(Integer)  # Unix timestamp (rtime_last_played)
+    shared = Column(Boolean, default=False)  # Available through Family Sharing rather than owned
+    shared_owner_steam_id = Column(String)  # Family member who owns the game when shared
+    achievements_unlocked = Column(Integer, default=0)
+    achievements_total = Column(Integer, default=0)
+    completion_percent = Column(Float)  # Share of the game's achievements unlocked, 0-100
+    achievements_updated = Column(Integer)  # Unix timestamp of the last achievement sync
+    removed_at = Column(Integer)  # Unix timestamp the game stopped appearing in the library (NULL while owned)
 
     # Relationships
     user = relationship("UserProfile", back_populates="games")
@@ -199,6 +255,7 @@ class UserGame(Base):
         Index("idx_user_games_app_id", "app_id"),
         Index("idx_user_games_playtime_forever", "playtime_forever"),
         Index("idx_user_games_playtime_2weeks", "playtime_2weeks"),
+        Index("idx_user_games_last_played", "last_played"),
     )
 
     @property
@@ -211,6 +268,11 @@ class UserGame(Base):
         """Convert recent playtime from minutes to hours"""
         return round(self.playtime_2weeks / 60, 1) if self.playtime_2weeks else 0
 
+    @property
+    def last_played_iso(self):
+        """Last played time as an ISO 8601 string, or None if never played"""
+        return datetime.fromtimestamp(self.last_played).isoformat() if self.last_played else None
+
 
 class Genre(Base):
     __tablename__ = "genres"
@@ -284,9 +346,369 @@ class GameReview(Base):
         return 0
 
 
+class DLC(Base):
+    __tablename__ = "dlc"
+
+    app_id = Column(Integer, primary_key=True)  # The DLC's own app ID
+    parent_app_id = Column(Integer, ForeignKey("games.app_id"), nullable=False)
+    name = Column(String)
+    short_description = Column(Text)
+    header_image = Column(String)
+    release_date = Column(String)
+    is_free = Column(Boolean, default=False)
+    price_final = Column(Integer)  # Current price in cents
+    currency = Column(String)
+    last_updated = Column(Integer, default=lambda: int(datetime.now().timestamp()))
+
+    # Relationships
+    game = relationship("Game", back_populates="dlc")
+
+    __table_args__ = (Index("idx_dlc_parent_app_id", "parent_app_id"),)
+
+
+class FriendGame(Base):
+    """App IDs a friend with a public profile owns, without fetching store details for them"""
+
+    __tablename__ = "friends_games"
+
+    steam_id = Column(String, ForeignKey("user_profile.steam_id"), primary_key=True)  # The friend
+    app_id = Column(Integer, primary_key=True)  # Not necessarily in games
+    name = Column(String)
+    playtime_forever = Column(Integer, default=0)  # in minutes
+    updated_at = Column(Integer, default=lambda: int(datetime.now().timestamp()))
+
+    __table_args__ = (Index("idx_friends_games_app_id", "app_id"),)
+
+
+class WishlistItem(Base):
+    """A game on a user's wishlist with its latest store price"""
+
+    __tablename__ = "wishlist"
+
+    steam_id = Column(String, ForeignKey("user_profile.steam_id"), primary_key=True)
+    app_id = Column(Integer, primary_key=True)  # Not necessarily in games, since the user doesn't own it
+    name = Column(String)
+    priority = Column(Integer, default=0)  # Order on the wishlist, 0 for unranked
+    date_added = Column(Integer)
+    price_initial = Column(Integer)  # In cents
+    price_final = Column(Integer)  # In cents
+    discount_percent = Column(Integer, default=0)
+    formatted_price = Column(String)
+    price_updated_at = Column(Integer)
+    sale_alerted_at = Column(Integer)  # When the current sale crossed the alert threshold, None when not on sale
+
+    __table_args__ = (Index("idx_wishlist_discount_percent", "discount_percent"),)
+
+
+class UserDLC(Base):
+    """DLC a user owns, keyed by the DLC's app ID and linked to its base game"""
+
+    __tablename__ = "user_dlc"
+
+    steam_id = Column(String, ForeignKey("user_profile.steam_id"), primary_key=True)
+    app_id = Column(Integer, primary_key=True)  # The DLC's own app ID (details in dlc when fetched)
+    parent_app_id = Column(Integer, ForeignKey("games.app_id"), nullable=False)
+    first_seen = Column(Integer, default=lambda: int(datetime.now().timestamp()))
+
+    __table_args__ = (Index("idx_user_dlc_parent_app_id", "parent_app_id"),)
+
+
+class Achievement(Base):
+    __tablename__ = "achievements"
+
+    app_id = Column(Integer, ForeignKey("games.app_id"), primary_key=True)
+    api_name = Column(String, primary_key=True)  # Internal name used by the stats API
+    display_name = Column(String)
+    description = Column(Text)
+    icon = Column(String)  # Unlocked icon URL
+    icon_gray = Column(String)  # Locked icon URL
+    hidden = Column(Boolean, default=False)
+    global_percent = Column(Float)  # Percentage of all players who unlocked it
+
+    # Relationships
+    game = relationship("Game", back_populates="achievements")
+
+    @property
+    def rarity(self):
+        """Classify achievement rarity from the global unlock percentage"""
+        if self.global_percent is None:
+            return "unknown"
+        if self.global_percent < 5:
+            return "ultra_rare"
+        if self.global_percent < 20:
+            return "rare"
+        if self.global_percent < 50:
+            return "uncommon"
+        return "common"
+
+
+class UserAchievement(Base):
+    """A user's progress on one achievement, from GetPlayerAchievements"""
+
+    __tablename__ = "user_achievements"
+
+    steam_id = Column(String, ForeignKey("user_profile.steam_id"), primary_key=True)
+    app_id = Column(Integer, ForeignKey("games.app_id"), primary_key=True)
+    api_name = Column(String, primary_key=True)  # Matches achievements.api_name
+    achieved = Column(Boolean, default=False)
+    unlock_time = Column(Integer)  # Unix timestamp, None while locked
+
+    __table_args__ = (Index("idx_user_achievements_steam_id_app_id", "steam_id", "app_id"),)
+
+
+class GameLocalization(Base):
+    """Store text for a game in one language, so libraries with different store languages each see their own"""
+
+    __tablename__ = "game_localizations"
+
+    app_id = Column(Integer, ForeignKey("games.app_id"), primary_key=True)
+    language = Column(String, primary_key=True)  # Steam language name, e.g. "german"
+    name = Column(String)
+    short_description = Column(Text)
+    detailed_description = Column(Text)
+    about_the_game = Column(Text)
+    last_updated = Column(Integer, default=lambda: int(datetime.now().timestamp()))
+
+    # Relationships
+    game = relationship("Game", back_populates="localizations")
+
+
+class ReviewText(Base):
+    __tablename__ = "review_texts"
+
+    recommendation_id = Column(String, primary_key=True)  # Steam review ID
+    app_id = Column(Integer, ForeignKey("games.app_id"), nullable=False)
+    author_steam_id = Column(String)
+    language = Column(String)
+    review = Column(Text)
+    voted_up = Column(Boolean)  # True if the reviewer recommends the game
+    votes_up = Column(Integer, default=0)  # Readers who found the review helpful
+    votes_funny = Column(Integer, default=0)
+    weighted_vote_score = Column(Float)  # Steam's helpfulness score (0-1)
+    playtime_at_review = Column(Integer)  # in minutes
+    timestamp_created = Column(Integer)
+
+    # Relationships
+    game = relationship("Game", back_populates="review_texts")
+
+    __table_args__ = (Index("idx_review_texts_app_id_votes", "app_id", "votes_up"),)
+
+
+class PriceHistory(Base):
+    __tablename__ = "price_history"
+
+    app_id = Column(Integer, ForeignKey("games.app_id"), primary_key=True)
+    currency = Column(String, primary_key=True)
+    recorded_at = Column(Integer, primary_key=True)  # Unix timestamp the price was first seen
+    price_initial = Column(Integer)  # Undiscounted price in cents
+    price_final = Column(Integer)  # Price paid in cents
+    discount_percent = Column(Integer, default=0)
+
+    # Relationships
+    game = relationship("Game", back_populates="price_history")
+
+
+class GameNews(Base):
+    __tablename__ = "game_news"
+
+    gid = Column(String, primary_key=True)  # Steam news item ID
+    app_id = Column(Integer, ForeignKey("games.app_id"), nullable=False)
+    title = Column(String)
+    url = Column(String)
+    author = Column(String)
+    contents = Column(Text)
+    feed_label = Column(String)  # e.g., "Community Announcements"
+    date = Column(Integer)  # Unix timestamp of publication
+
+    # Relationships
+    game = relationship("Game", back_populates="news")
+
+    __table_args__ = (Index("idx_game_news_app_id_date", "app_id", "date"),)
+
+
+class WorkshopItem(Base):
+    __tablename__ = "workshop_items"
+
+    published_file_id = Column(String, primary_key=True)
+    app_id = Column(Integer, ForeignKey("games.app_id"), nullable=False)
+    title = Column(String)
+    short_description = Column(Text)
+    preview_url = Column(String)
+    subscriptions = Column(Integer, default=0)
+    favorited = Column(Integer, default=0)
+    views = Column(Integer, default=0)
+    time_updated = Column(Integer)  # Unix timestamp of last workshop update
+    last_updated = Column(Integer, default=lambda: int(datetime.now().timestamp()))
+
+    # Relationships
+    game = relationship("Game", back_populates="workshop_items")
+
+    __table_args__ = (Index("idx_workshop_items_app_id", "app_id"),)
+
+
+class SteamApp(Base):
+    """Lightweight index of every app on Steam, used to resolve names without store lookups"""
+
+    __tablename__ = "steam_apps"
+
+    app_id = Column(Integer, primary_key=True)
+    name = Column(String, nullable=False)
+    last_modified = Column(Integer, default=0)  # Steam's last modification timestamp
+
+    __table_args__ = (Index("idx_steam_apps_name", "name"),)
+
+
+class ApiCacheEntry(Base):
+    """Persistent cache of Steam API responses, so re-syncs don't re-fetch unchanged data"""
+
+    __tablename__ = "api_cache"
+
+    key = Column(String, primary_key=True)
+    value = Column(Text, nullable=False)  # JSON-encoded response payload
+    expires_at = Column(Integer, nullable=False)  # Unix timestamp
+
+    __table_args__ = (Index("idx_api_cache_expires_at", "expires_at"),)
+
+
+class ApiMetric(Base):
+    """Cumulative Steam API usage counters written by the fetcher and served at /metrics"""
+
+    __tablename__ = "api_metrics"
+
+    name = Column(String, primary_key=True)  # Prometheus metric name
+    labels = Column(String, primary_key=True)  # JSON object of label values
+    value = Column(Float, nullable=False, default=0)
+
+
+class ApiUsage(Base):
+    """Steam requests made per UTC day, counted against STEAM_API_DAILY_BUDGET"""
+
+    __tablename__ = "api_usage"
+
+    day = Column(String, primary_key=True)  # UTC date, YYYY-MM-DD
+    requests = Column(Integer, nullable=False, default=0)
+
+
+def api_usage_day(timestamp: float | None = None) -> str:
+    """The UTC day a request is counted against"""
+    return time.strftime("%Y-%m-%d", time.gmtime(timestamp))
+
+
+class SchedulerState(Base):
+    """The MCP server scheduler's counters and last check time, kept across restarts"""
+
+    __tablename__ = "scheduler_state"
+
+    name = Column(String, primary_key=True)  # last_tick, ticks, queued, skipped, or errors
+    value = Column(Integer, nullable=False, default=0)
+
+
+class SyncBlackout(Base):
+    """A period when the MCP server scheduler queues no syncs, e.g. during a Steam sale"""
+
+    __tablename__ = "sync_blackouts"
+
+    id = Column(Integer, primary_key=True, autoincrement=True)
+    starts_at = Column(Integer, nullable=False)
+    ends_at = Column(Integer, nullable=False)
+    reason = Column(String)
+    created_at = Column(Integer, default=lambda: int(datetime.now().timestamp()))
+
+
+class OneShotSync(Base):
+    """A single sync the MCP server scheduler runs at a set time"""
+
+    __tablename__ = "one_shot_syncs"
+
+    id = Column(Integer, primary_key=True, autoincrement=True)
+    steam_id = Column(String, ForeignKey("user_profile.steam_id"), nullable=False)
+    scope = Column(String, nullable=False, default="full")
+    run_at = Column(Integer, nullable=False)
+    status = Column(String, nullable=False, default="pending")  # pending, queued, skipped, or cancelled
+    job_id = Column(Integer)  # Sync queue job once queued
+    detail = Column(String)  # Why it was skipped, or ran at a narrower scope
+    created_at = Column(Integer, default=lambda: int(datetime.now().timestamp()))
+    queued_at = Column(Integer)
+
+    __table_args__ = (Index("idx_one_shot_syncs_status_run_at", "status", "run_at"),)
+
+
+class ScheduledSync(Base):
+    """The last sync the MCP server scheduler queued for each library"""
+
+    __tablename__ = "scheduled_syncs"
+
+    steam_id = Column(String, ForeignKey("user_profile.steam_id"), primary_key=True)
+    scope = Column(String, nullable=False)
+    queued_at = Column(Integer, nullable=False)
+    job_id = Column(Integer)  # Only meaningful to the server process that queued it
+
+
+class ArchivedUserGame(Base):
+    """Snapshot of a game that left a user's library, kept by the "archive" removed-games policy"""
+
+    __tablename__ = "archived_user_games"
+
+    steam_id = Column(String, primary_key=True)
+    app_id = Column(Integer, primary_key=True)
+    name = Column(String)
+    playtime_forever = Column(Integer, default=0)  # in minutes
+    last_played = Column(Integer)
+    shared = Column(Boolean, default=False)
+    achievements_unlocked = Column(Integer, default=0)
+    removed_at = Column(Integer, nullable=False)
+
+
+class SyncCheckpoint(Base):
+    """Progress of the latest library sync per user, so an interrupted sync can resume"""
+
+    __tablename__ = "sync_checkpoints"
+
+    steam_id = Column(String, primary_key=True)
+    status = Column(String, nullable=False)  # running, paused, interrupted, or completed
+    options = Column(Text)  # JSON list of fetcher command-line arguments, reused on resume
+    processed_app_ids = Column(Text)  # JSON list of app IDs already saved
+    failed_app_ids = Column(Text)  # JSON list of app IDs that could not be saved, retried on resume
+    retry_app_ids = Column(Text)  # JSON list of app IDs that failed or got fallback data, refreshed by the next sync
+    cursor = Column(Integer, default=0)  # Position in the owned games list of the last saved game
+    total_games = Column(Integer, default=0)
+    api_requests = Column(Integer, default=0)  # Steam requests made by this sync so far
+    started_at = Column(Integer)
+    updated_at = Column(Integer)
+
+    @property
+    def processed_count(self) -> int:
+        return len(json.loads(self.processed_app_ids or "[]"))
+
+    @property
+    def failed_count(self) -> int:
+        return len(json.loads(self.failed_app_ids or "[]"))
+
+    @property
+    def resumable(self) -> bool:
+        """A sync that never completed (including one killed while running) can be resumed"""
+        return self.status != "completed"
+
+
 def create_database():
     """Create all tables in the database"""
     Base.metadata.create_all(bind=engine)
+    add_missing_columns()
+
+
+def add_missing_columns():
+    """Add columns introduced after a table was first created (create_all never alters existing tables)"""
+    inspector = inspect(engine)
+    with engine.begin() as conn:
+        for table in Base.metadata.sorted_tables:
+            if not inspector.has_table(table.name):
+                continue
+            existing_columns = {column["name"] for column in inspector.get_columns(table.name)}
+            for column in table.columns:
+                if column.name not in existing_columns:
+                    column_type = column.type.compile(dialect=engine.dialect)
+                    conn.execute(text(f"ALTER TABLE {table.name} ADD COLUMN {column.name} {column_type}"))
+                    logger.info(f"Added missing column {table.name}.{column.name}")
 
 
 def drop_database():
@@ -322,6 +744,36 @@ def bulk_insert_or_update(session: Session, model, data_list, unique_fields):
     session.commit()
 
 
+def lookup_app_name(session: Session, app_id: int) -> str | None:
+    """Resolve an app name from the local Steam app index"""
+    app = session.query(SteamApp).filter_by(app_id=app_id).first()
+    return app.name if app else None
+
+
+def get_price_summary(session: Session, game: Game) -> dict[str, Any] | None:
+    """Compare a game's current price with its recorded history in the same currency"""
+    if not game.currency or game.price_final is None:
+        return None
+
+    history = session.query(PriceHistory).filter_by(app_id=game.app_id, currency=game.currency).order_by(PriceHistory.recorded_at).all()
+    if not history:
+        return None
+
+    lowest = min(history, key=lambda entry: entry.price_final)
+    highest = max(history, key=lambda entry: entry.price_final)
+    return {
+        "currency": game.currency,
+        "current": game.price_final,
+        "lowest": lowest.price_final,
+        "lowest_recorded_at": lowest.recorded_at,
+        "highest": highest.price_final,
+        "tracked_since": history[0].recorded_at,
+        "price_changes": len(history),
+        "is_historical_low": game.price_final <= lowest.price_final,
+        "above_lowest_percent": round((game.price_final - lowest.price_final) / lowest.price_final * 100, 1) if lowest.price_final else None,
+    }
+
+
 # Error handling utilities
 def create_error_response(error_type: str, message: str, details: dict[str, Any] | None = None) -> dict[str, Any]:
     """Create a standardized error response format"""
@@ -355,7 +807,7 @@ def handle_multiple_users(users: list) -> dict[str, Any]:
 
 
 def resolve_user_identifier(user_identifier: str, session: Session | None = None) -> str | None:
-    """Resolve a user identifier (Steam ID or persona name) to a Steam ID"""
+    """Resolve a user identifier (SteamID64/SteamID3/SteamID2, profile URL, vanity name, or persona name) to a Steam ID"""
     if not user_identifier:
         return None
 
@@ -370,6 +822,15 @@ def resolve_user_identifier(user_identifier: str, session: Session | None = None
         if user:
             return user.steam_id
 
+        # Profile URLs and vanity names resolve through the stored community profile URL
+        kind, value = parse_steam_identifier(user_identifier)
+        if kind == "steamid64":
+            user = session.query(UserProfile).filter_by(steam_id=value).first()
+        else:
+            user = session.query(UserProfile).filter(func.lower(UserProfile.profile_url) == func.lower(vanity_profile_url(value))).first()
+        if user:
+            return user.steam_id
+
         # Then try case-insensitive persona name match
         user = session.query(UserProfile).filter(func.lower(UserProfile.persona_name) == func.lower(user_identifier)).first()
         if user:
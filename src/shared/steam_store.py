@@ -0,0 +1,78 @@
+"""Keyless Steam Store API helpers shared by the fetcher and the MCP servers"""
+
+import logging
+
+import requests
+
+logger = logging.getLogger(__name__)
+
+STORE_SEARCH_URL = "https://store.steampowered.com/api/storesearch/"
+
+
+class StoreUnavailable(RuntimeError):
+    """Raised by search_store(raise_errors=True) when the store can't be reached or answers with an error"""
+
+
+def filter_store_results(items: list[dict], filters: dict | None) -> list[dict]:
+    """Apply optional filters to store search results
+
+    Supported filters:
+    - max_price: maximum final price in cents (free games always pass)
+    - min_metascore: minimum Metacritic score
+    - platforms: list of required platforms ("windows", "mac", "linux")
+    """
+    if not filters:
+        return items
+
+    filtered = []
+    for item in items:
+        price = item.get("price") or {}
+        if filters.get("max_price") is not None and price.get("final", 0) > filters["max_price"]:
+            continue
+
+        metascore = int(item.get("metascore") or 0)
+        if filters.get("min_metascore") and metascore < filters["min_metascore"]:
+            continue
+
+        platforms = item.get("platforms") or {}
+        if any(not platforms.get(p) for p in filters.get("platforms", [])):
+            continue
+
+        filtered.append(item)
+
+    return filtered
+
+
+def search_store(query: str, filters: dict | None = None, cc: str = "us", language: str = "english", session: requests.Session | None = None, raise_errors: bool = False) -> list[dict]:
+    """Search the Steam store for apps matching a query
+
+    Returns simplified results with id, name, price (in cents), metascore, and platforms.
+    A failed search returns no results, or raises StoreUnavailable with raise_errors.
+    """
+    http = session or requests
+    params = {"term": query, "cc": cc, "l": language}
+
+    try:
+        response = http.get(STORE_SEARCH_URL, params=params, timeout=30)
+
+        if response.status_code != 200:
+            logger.debug(f"Store search returned {response.status_code} for '{query}'")
+            if raise_errors:
+                raise StoreUnavailable(f"store search returned HTTP {response.status_code}")
+            return []
+
+        items = [item for item in response.json().get("items", []) if item.get("type") == "app"]
+    except StoreUnavailable:
+        raise
+    except Exception as e:
+        logger.debug(f"Error searching store for '{query}': {e}")
+        if raise_errors:
+            raise StoreUnavailable(str(e)) from e
+        return []
+
+    results = []
+    for item in filter_store_results(items, filters):
+        price = item.get("price") or {}
+        results.append({"app_id": item.get("id"), "name": item.get("name", ""), "price_initial": price.get("initial"), "price_final": price.get("final"), "currency": price.get("currency", ""), "metascore": int(item.get("metascore") or 0), "platforms": item.get("platforms") or {}, "tiny_image": item.get("tiny_image", "")})
+
+    return results
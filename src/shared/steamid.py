@@ -0,0 +1,103 @@
+"""Helpers for interpreting user-supplied Steam identifiers (SteamID64/SteamID3/SteamID2, profile URLs, vanity names)"""
+
+import re
+
+# SteamID64 of account ID 0 for an individual account in the public universe
+STEAMID64_BASE = 76561197960265728
+MAX_ACCOUNT_ID = 2**32 - 1
+
+STEAMID64_PATTERN = re.compile(r"^7656119\d{10}$")
+STEAMID2_PATTERN = re.compile(r"^STEAM_[0-5]:([01]):(\d+)$", re.IGNORECASE)
+STEAMID3_PATTERN = re.compile(r"^\[?U:1:(\d+)\]?$", re.IGNORECASE)
+PROFILE_URL_PATTERN = re.compile(r"^(?:https?://)?(?:www\.)?steamcommunity\.com/(profiles|id)/([^/?#]+)/?", re.IGNORECASE)
+
+
+def is_steamid64(value: str) -> bool:
+    """Check if a value looks like a 64-bit SteamID"""
+    return bool(STEAMID64_PATTERN.match(value.strip()))
+
+
+def account_id_to_steamid64(account_id: int) -> str:
+    """Convert a 32-bit account ID to a SteamID64"""
+    if not 0 < account_id <= MAX_ACCOUNT_ID:
+        raise ValueError(f"Invalid Steam account ID: {account_id}")
+    return str(STEAMID64_BASE + account_id)
+
+
+def steamid64_to_account_id(steamid64: str) -> int:
+    """Convert a SteamID64 to its 32-bit account ID"""
+    if not is_steamid64(steamid64):
+        raise ValueError(f"Invalid SteamID64: {steamid64}")
+    return int(steamid64) - STEAMID64_BASE
+
+
+def steamid64_to_steam2(steamid64: str) -> str:
+    """Convert a SteamID64 to the legacy STEAM_0:Y:Z format"""
+    account_id = steamid64_to_account_id(steamid64)
+    return f"STEAM_0:{account_id & 1}:{account_id >> 1}"
+
+
+def steam2_to_steamid64(steam2: str) -> str:
+    """Convert a STEAM_X:Y:Z identifier to a SteamID64"""
+    match = STEAMID2_PATTERN.match(steam2.strip())
+    if not match:
+        raise ValueError(f"Invalid SteamID2: {steam2}")
+    y, z = match.groups()
+    return account_id_to_steamid64(int(z) * 2 + int(y))
+
+
+def steamid64_to_steam3(steamid64: str) -> str:
+    """Convert a SteamID64 to the [U:1:account_id] format"""
+    return f"[U:1:{steamid64_to_account_id(steamid64)}]"
+
+
+def steam3_to_steamid64(steam3: str) -> str:
+    """Convert a [U:1:account_id] identifier to a SteamID64"""
+    match = STEAMID3_PATTERN.match(steam3.strip())
+    if not match:
+        raise ValueError(f"Invalid SteamID3: {steam3}")
+    return account_id_to_steamid64(int(match.group(1)))
+
+
+def normalize_steam_id(identifier: str) -> str | None:
+    """Convert a SteamID64, SteamID3, or SteamID2 to a SteamID64, or None if it is none of these"""
+    identifier = identifier.strip()
+    try:
+        if is_steamid64(identifier):
+            return identifier
+        if STEAMID2_PATTERN.match(identifier):
+            return steam2_to_steamid64(identifier)
+        if STEAMID3_PATTERN.match(identifier):
+            return steam3_to_steamid64(identifier)
+    except ValueError:
+        return None
+    return None
+
+
+def parse_steam_identifier(identifier: str) -> tuple[str, str]:
+    """Classify a Steam identifier
+
+    Returns a (kind, value) tuple where kind is one of:
+    - "steamid64": value is a 64-bit SteamID (SteamID2/SteamID3 input is converted)
+    - "vanity": value is a custom URL name that still needs resolving
+    """
+    identifier = identifier.strip()
+
+    match = PROFILE_URL_PATTERN.match(identifier)
+    if match:
+        path_type, value = match.groups()
+        steamid64 = normalize_steam_id(value) if path_type.lower() == "profiles" else None
+        if steamid64:
+            return "steamid64", steamid64
+        return "vanity", value
+
+    steamid64 = normalize_steam_id(identifier)
+    if steamid64:
+        return "steamid64", steamid64
+
+    return "vanity", identifier
+
+
+def vanity_profile_url(vanity: str) -> str:
+    """Build the canonical community URL for a vanity name"""
+    return f"https://steamcommunity.com/id/{vanity}/"
@@ -0,0 +1,46 @@
+"""Throwaway database for tests that read or write through shared.database
+
+shared.database binds its engine to DATABASE_URL when it's first imported, so a
+test module setting DATABASE_URL only works when it's the first to import it,
+which isn't the case under unittest discover or pytest. Instead, each test module
+that needs a database imports these module fixtures, which rebind the engine and
+SessionLocal to a fresh SQLite file with every table created, and drop the
+tables and restore the previous binding once the module's tests are done:
+
+    from database_fixture import setUpModule, tearDownModule  # noqa: F401
+"""
+
+import os
+import shutil
+import tempfile
+
+from sqlalchemy import create_engine
+
+from shared import database
+
+_previous = []
+
+
+def setUpModule():
+    directory = tempfile.mkdtemp()
+    url = f"sqlite:///{directory}/test.db"
+    _previous.append((database.engine, os.environ.get("DATABASE_URL"), directory))
+
+    database.engine = create_engine(url, connect_args={"check_same_thread": False, "timeout": 30})
+    database.SessionLocal.configure(bind=database.engine)
+    os.environ["DATABASE_URL"] = url
+    database.create_database()
+
+
+def tearDownModule():
+    engine, url, directory = _previous.pop()
+    database.drop_database()
+    database.engine.dispose()
+
+    database.engine = engine
+    database.SessionLocal.configure(bind=engine)
+    if url is None:
+        os.environ.pop("DATABASE_URL", None)
+    else:
+        os.environ["DATABASE_URL"] = url
+    shutil.rmtree(directory, ignore_errors=True)
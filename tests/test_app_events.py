@@ -0,0 +1,79 @@
+#!/usr/bin/env python3
+"""Test the application event bus behind /api/events."""
+
+import asyncio
+import sys
+import unittest
+from pathlib import Path
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from mcp_server.events import EventBus, events, format_sse, matches
+from mcp_server.sync import SyncAlreadyRunning, SyncQueue
+
+
+class TestEventBus(unittest.TestCase):
+    """Test publishing, replay, filtering, and delivery to streams."""
+
+    def test_replay_since_event_id(self):
+        """Kept events after an id are returned oldest first, up to the history size."""
+        bus = EventBus(history=3)
+        for number in range(5):
+            bus.publish("sync.queued", {"number": number})
+        self.assertEqual([event["data"]["number"] for event in bus.since(3)], [3, 4])
+        self.assertEqual([event["id"] for event in bus.since(0)], [3, 4, 5])
+
+    def test_type_filters(self):
+        """Filters name exact types or whole sources."""
+        event = {"type": "sync.failed"}
+        self.assertTrue(matches(event, []))
+        self.assertTrue(matches(event, ["sync"]))
+        self.assertTrue(matches(event, ["scheduler", "sync.failed"]))
+        self.assertFalse(matches(event, ["sync.finished", "sync.fail"]))
+
+    def test_sse_format(self):
+        """Messages carry the id and type for EventSource clients."""
+        message = format_sse({"id": 7, "type": "scheduler.paused", "timestamp": 1.0, "data": {"paused": True}})
+        self.assertTrue(message.startswith("id: 7\nevent: scheduler.paused\ndata: {"))
+        self.assertTrue(message.endswith("\n\n"))
+
+    def test_streams_receive_events_from_threads(self):
+        """Events published from another thread reach a stream's queue until it unsubscribes."""
+        bus = EventBus()
+
+        async def receive():
+            queue = bus.subscribe()
+            await asyncio.to_thread(bus.publish, "sync.started", {"id": 1})
+            event = await asyncio.wait_for(queue.get(), timeout=1)
+            bus.unsubscribe(queue)
+            bus.publish("sync.finished", {"id": 1})
+            await asyncio.sleep(0)
+            return event, queue.empty()
+
+        event, empty = asyncio.run(receive())
+        self.assertEqual(event["type"], "sync.started")
+        self.assertTrue(empty)
+
+
+class TestSyncQueueEvents(unittest.TestCase):
+    """Test the events the sync queue publishes."""
+
+    def test_queue_conflict_and_cancel(self):
+        """Queueing, refusing a duplicate, and cancelling each publish an event."""
+        # No workers, so nothing is ever started
+        sync_queue = SyncQueue(workers=0)
+        last_id = events.since(0)[-1]["id"] if events.since(0) else 0
+
+        job = sync_queue.submit("76561197960287930", ["--scope", "playtime"])
+        with self.assertRaises(SyncAlreadyRunning):
+            sync_queue.submit("76561197960287930", ["--scope", "playtime"])
+        sync_queue.cancel(job["id"])
+
+        published = events.since(last_id)
+        self.assertEqual([event["type"] for event in published], ["sync.queued", "sync.conflict", "sync.cancelled"])
+        self.assertEqual(published[1]["data"]["existing"]["id"], job["id"])
+
+
+if __name__ == "__main__":
+    unittest.main()
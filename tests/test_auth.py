@@ -0,0 +1,87 @@
+#!/usr/bin/env python3
+"""Test token authentication and scopes on the HTTP transport."""
+
+import asyncio
+import json
+import sys
+import unittest
+from pathlib import Path
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from mcp_server.auth import TokenAuthMiddleware, calls_sync_tool, parse_tokens, request_token
+
+
+def request(middleware, method: str, path: str, headers: dict | None = None, body: bytes = b"") -> tuple[int, bytes]:
+    """Send one HTTP request through the middleware, returning the status and the body the app saw or the error"""
+    scope = {"type": "http", "method": method, "path": path, "headers": [(name.lower().encode(), value.encode()) for name, value in (headers or {}).items()]}
+    sent = []
+
+    async def receive():
+        return {"type": "http.request", "body": body, "more_body": False}
+
+    async def send(message):
+        sent.append(message)
+
+    asyncio.run(middleware(scope, receive, send))
+    status = next(message["status"] for message in sent if message["type"] == "http.response.start")
+    return status, b"".join(message.get("body", b"") for message in sent if message["type"] == "http.response.body")
+
+
+async def echo_app(scope, receive, send):
+    """Stand-in app that answers 200 with the request body it received"""
+    message = await receive()
+    await send({"type": "http.response.start", "status": 200, "headers": []})
+    await send({"type": "http.response.body", "body": message.get("body", b"")})
+
+
+class TestTokenAuth(unittest.TestCase):
+    """Test which requests each kind of token may make."""
+
+    def setUp(self):
+        self.middleware = TokenAuthMiddleware(echo_app, parse_tokens("reader=read; admin=sync"))
+
+    def test_parse_tokens(self):
+        """Tokens default to read-only, and unknown scopes are rejected."""
+        self.assertEqual(parse_tokens("a=sync, b"), {"a": "sync", "b": "read"})
+        with self.assertRaises(ValueError):
+            parse_tokens("a=admin")
+
+    def test_token_headers(self):
+        """Tokens come from a Bearer Authorization header or X-API-Key."""
+        self.assertEqual(request_token({"authorization": "Bearer abc"}), "abc")
+        self.assertEqual(request_token({"x-api-key": "abc"}), "abc")
+        self.assertIsNone(request_token({"authorization": "Basic abc"}))
+
+    def test_missing_or_unknown_token(self):
+        """Requests without a known token are refused, except the liveness probe."""
+        self.assertEqual(request(self.middleware, "GET", "/api/sync/status")[0], 401)
+        self.assertEqual(request(self.middleware, "GET", "/api/sync/status", {"Authorization": "Bearer wrong"})[0], 401)
+        self.assertEqual(request(self.middleware, "GET", "/health")[0], 200)
+
+    def test_read_scope(self):
+        """Read-only tokens may read but not change state through the API."""
+        self.assertEqual(request(self.middleware, "GET", "/api/sync/status", {"X-API-Key": "reader"})[0], 200)
+        self.assertEqual(request(self.middleware, "POST", "/api/sync/all", {"X-API-Key": "reader"})[0], 403)
+        self.assertEqual(request(self.middleware, "POST", "/api/sync/all", {"X-API-Key": "admin"})[0], 200)
+
+    def test_read_scope_tool_calls(self):
+        """Read-only tokens can't call sync tools, and other messages reach the app intact."""
+        sync_call = json.dumps({"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "sync_game", "arguments": {"game_id": 620}}}).encode()
+        self.assertEqual(request(self.middleware, "POST", "/mcp", {"Authorization": "Bearer reader"}, sync_call)[0], 403)
+        self.assertEqual(request(self.middleware, "POST", "/mcp", {"Authorization": "Bearer admin"}, sync_call)[0], 200)
+
+        list_call = json.dumps({"jsonrpc": "2.0", "id": 2, "method": "tools/list"}).encode()
+        self.assertEqual(request(self.middleware, "POST", "/mcp", {"Authorization": "Bearer reader"}, list_call), (200, list_call))
+
+    def test_calls_sync_tool(self):
+        """Sync tool calls are found in single messages and batches."""
+        call = {"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "cancel_sync"}}
+        self.assertEqual(calls_sync_tool(json.dumps([{"jsonrpc": "2.0", "method": "ping"}, call]).encode(), {"cancel_sync"}), "cancel_sync")
+        self.assertIsNone(calls_sync_tool(json.dumps(call).encode(), {"sync_game"}))
+        self.assertIsNone(calls_sync_tool(b"not json", {"sync_game"}))
+
+
+if __name__ == "__main__":
+    unittest.main()
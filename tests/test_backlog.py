@@ -0,0 +1,59 @@
+#!/usr/bin/env python3
+"""Test the never-played and backlog queries."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src and the shared test fixtures to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+sys.path.insert(0, str(Path(__file__).parent))
+
+from mcp_server.backlog import backlog_entry, backlog_games, never_played_games
+from shared.database import Game, GameReview, UserGame, UserProfile, get_db, get_db_transaction
+from database_fixture import setUpModule, tearDownModule  # noqa: F401
+
+
+class TestBacklog(unittest.TestCase):
+    """Test which games each list includes and how they're ordered."""
+
+    @classmethod
+    def setUpClass(cls):
+        with get_db_transaction() as session:
+            session.add(UserProfile(steam_id="1", persona_name="Tester"))
+            # (app_id, positive reviews out of 100, estimated minutes, minutes played, achievements unlocked/total, removed)
+            library = [(1, 95, 300, 0, (0, 0), None), (2, 95, 120, 0, (0, 0), None), (3, 70, 600, 60, (1, 10), None), (4, 99, 240, 300, (2, 10), None), (5, 90, 180, 30, (10, 10), None), (6, None, 60, 0, (0, 0), None), (7, 80, 3000, 0, (0, 0), None), (8, 99, 60, 0, (0, 0), 1700000000)]
+            for app_id, positive, estimated, played, (unlocked, total), removed_at in library:
+                session.add(Game(app_id=app_id, name=f"Game {app_id}", steamspy_median_playtime=estimated))
+                if positive is not None:
+                    session.add(GameReview(app_id=app_id, total_reviews=100, positive_reviews=positive, negative_reviews=100 - positive))
+                session.add(UserGame(steam_id="1", app_id=app_id, playtime_forever=played, achievements_unlocked=unlocked, achievements_total=total, removed_at=removed_at))
+
+    def app_ids(self, query) -> list[int]:
+        return [game.app_id for game, _ in query.all()]
+
+    def test_never_played_order(self):
+        """Best reviewed first, the shorter of equally reviewed games first, unreviewed games last."""
+        with get_db() as session:
+            self.assertEqual(self.app_ids(never_played_games(session, "1")), [2, 1, 7, 6])
+
+    def test_never_played_filters(self):
+        """min_review and max_hours narrow the list; games without reviews fail min_review."""
+        with get_db() as session:
+            self.assertEqual(self.app_ids(never_played_games(session, "1", min_review=90, max_hours=4)), [2])
+
+    def test_backlog_leaves_out_finished_and_long_games(self):
+        """Games played past their estimate, fully achieved, or too long aren't in the backlog."""
+        with get_db() as session:
+            self.assertEqual(self.app_ids(backlog_games(session, "1", max_hours=10)), [2, 1, 3, 6])
+
+    def test_backlog_entry(self):
+        """Entries report review score, estimated length, and hours left."""
+        with get_db() as session:
+            game, user_game = backlog_games(session, "1", max_hours=10, min_review=70).filter(Game.app_id == 3).one()
+            entry = backlog_entry(game, user_game)
+        self.assertEqual((entry["positive_percentage"], entry["estimated_hours"], entry["playtime_hours"], entry["remaining_hours"]), (70.0, 10.0, 1.0, 9.0))
+
+
+if __name__ == "__main__":
+    unittest.main()
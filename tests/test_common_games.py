@@ -0,0 +1,58 @@
+#!/usr/bin/env python3
+"""Test finding games a group of users all own."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src and the shared test fixtures to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+sys.path.insert(0, str(Path(__file__).parent))
+
+from mcp_server.recommendations import common_games
+from shared.database import Category, FriendGame, Game, GameReview, UserGame, UserProfile, get_db, get_db_transaction
+from database_fixture import setUpModule, tearDownModule  # noqa: F401
+
+
+class TestCommonGames(unittest.TestCase):
+    """Test ownership across synced libraries and friends' game lists."""
+
+    @classmethod
+    def setUpClass(cls):
+        with get_db_transaction() as session:
+            for steam_id, name in [("1", "Alice"), ("2", "Bob"), ("3", "Carol")]:
+                session.add(UserProfile(steam_id=steam_id, persona_name=name))
+            coop, single = Category(category_name="Co-op"), Category(category_name="Single-player")
+            # (app_id, categories, positive reviews out of 100)
+            for app_id, categories, positive in [(1, [coop], 90), (2, [coop], 50), (3, [single], 95), (4, [coop], 80)]:
+                session.add(Game(app_id=app_id, name=f"Game {app_id}", categories=categories))
+                session.add(GameReview(app_id=app_id, total_reviews=100, positive_reviews=positive, negative_reviews=100 - positive))
+            # Alice and Bob have synced libraries; Carol is only known from a friend's list
+            for steam_id, app_id, minutes in [("1", 1, 60), ("1", 2, 600), ("1", 3, 60), ("1", 4, 60), ("2", 1, 60), ("2", 2, 600), ("2", 3, 60)]:
+                session.add(UserGame(steam_id=steam_id, app_id=app_id, playtime_forever=minutes))
+            for app_id, minutes in [(1, 0), (2, 120), (3, 0)]:
+                session.add(FriendGame(steam_id="3", app_id=app_id, playtime_forever=minutes))
+            # Bob is also on someone's friend list, with older playtime
+            session.add(FriendGame(steam_id="2", app_id=2, playtime_forever=300))
+
+    def test_multiplayer_games_all_own(self):
+        """Only multiplayer games every user owns are returned, ranked by playtime and reviews."""
+        with get_db() as session:
+            games = common_games(session, ["1", "2", "3"])
+        self.assertEqual([game["app_id"] for game in games], [2, 1])
+        self.assertEqual(games[0]["playtime_hours"], {"1": 10.0, "2": 10.0, "3": 2.0})
+        self.assertEqual(games[0]["combined_playtime_hours"], 22.0)
+
+    def test_all_games(self):
+        """Without multiplayer_only, single-player games count too."""
+        with get_db() as session:
+            self.assertEqual({game["app_id"] for game in common_games(session, ["1", "2"], multiplayer_only=False)}, {1, 2, 3})
+
+    def test_nothing_in_common(self):
+        """A user owning nothing in common gives an empty list."""
+        with get_db() as session:
+            self.assertEqual(common_games(session, ["1", "4"]), [])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,62 @@
+#!/usr/bin/env python3
+"""Test prompt argument and resource template completions."""
+
+import asyncio
+import sys
+import unittest
+from pathlib import Path
+
+# Add src and the shared test fixtures to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+sys.path.insert(0, str(Path(__file__).parent))
+
+from mcp.types import CompletionArgument, PromptReference, ResourceTemplateReference
+
+from mcp_server.completions import argument_completions
+from shared.database import Game, Genre, UserGame, UserProfile, get_db_transaction
+from database_fixture import setUpModule, tearDownModule  # noqa: F401
+
+
+def complete(ref, name: str, value: str):
+    return asyncio.run(argument_completions(ref, CompletionArgument(name=name, value=value), None))
+
+
+class TestCompletions(unittest.TestCase):
+    """Test completing arguments from the library database and fixed choices."""
+
+    @classmethod
+    def setUpClass(cls):
+        with get_db_transaction() as session:
+            session.add(UserProfile(steam_id="76561198000000001", persona_name="Tester"))
+            puzzle, platformer = Genre(genre_name="Puzzle"), Genre(genre_name="Platformer")
+            for app_id, name, genres, minutes in [(400, "Portal", [puzzle], 600), (620, "Portal 2", [puzzle], 1200), (105600, "Terraria", [platformer], 30)]:
+                session.add(Game(app_id=app_id, name=name, genres=genres))
+                session.add(UserGame(steam_id="76561198000000001", app_id=app_id, playtime_forever=minutes))
+
+    def test_game_names(self):
+        """Game names match case-insensitively, most played first."""
+        self.assertEqual(complete(PromptReference(type="ref/prompt", name="similar_games"), "game_name", "por").values, ["Portal 2", "Portal"])
+
+    def test_genres(self):
+        """Genres complete with the most common first."""
+        self.assertEqual(complete(PromptReference(type="ref/prompt", name="explore_genre"), "genre_name", "p").values, ["Puzzle", "Platformer"])
+
+    def test_users_in_resource_templates(self):
+        """User ids complete to steam ids and persona names, plus the default alias."""
+        ref = ResourceTemplateReference(type="ref/resource", uri="library://users/{user_id}/games")
+        self.assertEqual(complete(ref, "user_id", "").values, ["default", "76561198000000001", "Tester"])
+        self.assertEqual(complete(ref, "user_id", "te").values, ["Tester"])
+
+    def test_fixed_choices(self):
+        """Arguments with a fixed set of values complete from it."""
+        self.assertEqual(complete(PromptReference(type="ref/prompt", name="mood_games"), "mood", "c").values, ["competitive", "creative"])
+        self.assertEqual(complete(ResourceTemplateReference(type="ref/resource", uri="library://games/platform/{platform}"), "platform", "l").values, ["linux"])
+
+    def test_unknown_arguments(self):
+        """Arguments without a completer, or not in the template, get no completion."""
+        self.assertIsNone(complete(PromptReference(type="ref/prompt", name="unplayed_gems"), "query", ""))
+        self.assertIsNone(complete(ResourceTemplateReference(type="ref/resource", uri="library://games/{game_id}"), "user_id", ""))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,103 @@
+#!/usr/bin/env python3
+"""Test asking the client which library to use when several match."""
+
+import asyncio
+import sys
+import unittest
+from pathlib import Path
+from unittest.mock import patch
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from mcp.shared.exceptions import McpError
+from mcp.types import ElicitResult, ErrorData
+
+from mcp_server.tools import resolve_sync_user, resolve_user_or_elicit
+from shared.database import create_error_response
+
+LIBRARIES = [{"steam_id": "1", "persona_name": "Alice"}, {"steam_id": "2", "persona_name": "Bob"}]
+MULTIPLE_USERS = create_error_response("MULTIPLE_USERS_FOUND", "Multiple users found.", {"available_users": LIBRARIES})
+
+
+class StubSession:
+    """Stands in for the server session, answering elicitation requests with a fixed result"""
+
+    def __init__(self, result: ElicitResult | None):
+        self.result = result
+        self.requests = []
+
+    async def elicit(self, message, requestedSchema, related_request_id=None):
+        self.requests.append({"message": message, "schema": requestedSchema, "related_request_id": related_request_id})
+        if self.result is None:
+            raise McpError(ErrorData(code=-32601, message="Method not found"))
+        return self.result
+
+
+class StubContext:
+    def __init__(self, result: ElicitResult | None):
+        self.session = StubSession(result)
+        self.request_id = "request-1"
+
+
+def resolve(ctx, user=None) -> dict:
+    with patch("mcp_server.tools.resolve_user_for_tool", return_value=MULTIPLE_USERS):
+        return asyncio.run(resolve_user_or_elicit(user, ctx))
+
+
+class TestLibraryElicitation(unittest.TestCase):
+    """Test each answer the client can give."""
+
+    def test_accept(self):
+        """The chosen library is used, and the request offers every library."""
+        ctx = StubContext(ElicitResult(action="accept", content={"steam_id": "2"}))
+        self.assertEqual(resolve(ctx), {"steam_id": "2"})
+        request = ctx.session.requests[0]
+        self.assertEqual(request["schema"]["properties"]["steam_id"]["enum"], ["1", "2"])
+        self.assertEqual(request["related_request_id"], "request-1")
+
+    def test_accept_unknown_library(self):
+        """A library that wasn't offered counts as cancelling."""
+        ctx = StubContext(ElicitResult(action="accept", content={"steam_id": "3"}))
+        self.assertEqual(resolve(ctx)["error_type"], "USER_SELECTION_CANCELLED")
+
+    def test_decline(self):
+        result = resolve(StubContext(ElicitResult(action="decline")))
+        self.assertEqual(result["error_type"], "USER_SELECTION_DECLINED")
+        self.assertEqual(result["details"]["available_users"], LIBRARIES)
+
+    def test_cancel(self):
+        self.assertEqual(resolve(StubContext(ElicitResult(action="cancel")))["error_type"], "USER_SELECTION_CANCELLED")
+
+    def test_client_without_elicitation(self):
+        """Clients that reject elicitation, or calls without a context, get the list of libraries."""
+        self.assertEqual(resolve(StubContext(None)), MULTIPLE_USERS)
+        self.assertEqual(resolve(None), MULTIPLE_USERS)
+
+
+class TestSyncToolElicitation(unittest.TestCase):
+    """Test choosing the library a sync tool acts on."""
+
+    def resolve(self, ctx, steam_ids: list[str]):
+        with patch("mcp_server.tools.library_choices", side_effect=lambda ids: [library for library in LIBRARIES if library["steam_id"] in ids]):
+            return asyncio.run(resolve_sync_user(None, ctx, lambda: steam_ids, "Which one?"))
+
+    def test_single_candidate_is_not_asked(self):
+        ctx = StubContext(ElicitResult(action="accept", content={"steam_id": "1"}))
+        self.assertEqual(self.resolve(ctx, ["1"]), (None, None))
+        self.assertEqual(ctx.session.requests, [])
+
+    def test_several_candidates(self):
+        """The client picks among the candidates; declining is an error result."""
+        self.assertEqual(self.resolve(StubContext(ElicitResult(action="accept", content={"steam_id": "2"})), ["1", "2"]), ("2", None))
+        steam_id, error = self.resolve(StubContext(ElicitResult(action="decline")), ["1", "2"])
+        self.assertIsNone(steam_id)
+        self.assertTrue(error.isError)
+        self.assertEqual(error.structuredContent["error_type"], "USER_SELECTION_DECLINED")
+
+    def test_client_without_elicitation_keeps_default(self):
+        self.assertEqual(self.resolve(StubContext(None), ["1", "2"]), (None, None))
+
+
+if __name__ == "__main__":
+    unittest.main()
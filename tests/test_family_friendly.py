@@ -0,0 +1,57 @@
+#!/usr/bin/env python3
+"""Test filtering a library by ESRB rating and content descriptors."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src and the shared test fixtures to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+sys.path.insert(0, str(Path(__file__).parent))
+
+from mcp_server.recommendations import esrb_descriptor_list, family_friendly_games
+from shared.database import Game, UserGame, UserProfile, get_db, get_db_transaction
+from database_fixture import setUpModule, tearDownModule  # noqa: F401
+
+
+class TestFamilyFriendlyGames(unittest.TestCase):
+    """Test which owned games suit a given ESRB rating."""
+
+    @classmethod
+    def setUpClass(cls):
+        with get_db_transaction() as session:
+            session.add(UserProfile(steam_id="1", persona_name="Tester"))
+            # (app_id, ESRB rating, descriptors, minutes played)
+            library = [(1, "E", "", 600), (2, "E10+", "Fantasy Violence\r\nMild Language", 300), (3, "T", "Blood\r\nViolence", 900), (4, "M", "Blood and Gore, Intense Violence", 1200), (5, "", "", 60)]
+            for app_id, rating, descriptors, minutes in library:
+                session.add(Game(app_id=app_id, name=f"Game {app_id}", esrb_rating=rating, esrb_descriptors=descriptors))
+                session.add(UserGame(steam_id="1", app_id=app_id, playtime_forever=minutes))
+
+    def app_ids(self, *args, **kwargs) -> list[int]:
+        with get_db() as session:
+            return [game.app_id for game, _ in family_friendly_games(session, "1", *args, **kwargs).all()]
+
+    def test_max_rating(self):
+        """Games rated above the maximum are left out, most played first."""
+        self.assertEqual(self.app_ids("E10+"), [1, 2])
+        self.assertEqual(self.app_ids("T"), [3, 1, 2])
+
+    def test_excluded_descriptors_match_substrings(self):
+        """Excluding Violence also excludes Fantasy Violence."""
+        self.assertEqual(self.app_ids("M", ["violence"]), [1])
+        self.assertEqual(self.app_ids("M", ["Blood"]), [1, 2])
+
+    def test_unrated_games_are_opt_in(self):
+        """Unrated games are only listed when asked for."""
+        self.assertNotIn(5, self.app_ids("E"))
+        self.assertEqual(self.app_ids("E", include_unrated=True), [1, 5])
+
+    def test_descriptor_list(self):
+        """Descriptors are split on newlines and commas."""
+        self.assertEqual(esrb_descriptor_list("Blood and Gore, Intense Violence"), ["Blood and Gore", "Intense Violence"])
+        self.assertEqual(esrb_descriptor_list("Fantasy Violence\r\nMild Language"), ["Fantasy Violence", "Mild Language"])
+        self.assertEqual(esrb_descriptor_list(None), [])
+
+
+if __name__ == "__main__":
+    unittest.main()
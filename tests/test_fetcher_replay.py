@@ -0,0 +1,114 @@
+#!/usr/bin/env python3
+"""Test the fetcher's record/replay mode against hand-written fixtures."""
+
+import json
+import sys
+import tempfile
+import unittest
+from pathlib import Path
+
+import requests
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from fetcher.recorder import ReplayMissError, fixture_key
+from fetcher.steam_library_fetcher import SteamLibraryFetcher, iter_json_array_items
+
+OWNED_GAMES_URL = "http://api.steampowered.com/IPlayerService/GetOwnedGames/v0001/"
+STEAM_ID = "76561197960287930"
+
+
+def write_fixture(fixture_dir: Path, url: str, params: dict, body: dict, status_code: int = 200):
+    """Write a fixture the way RecordingAdapter would for a GET request"""
+    request = requests.Request("GET", url, params=params).prepare()
+    name, canonical = fixture_key(request)
+    fixture = {"request": canonical, "status_code": status_code, "headers": {"Content-Type": "application/json"}, "body": json.dumps(body)}
+    (fixture_dir / name).write_text(json.dumps(fixture), encoding="utf-8")
+
+
+class TestFixtureKey(unittest.TestCase):
+    """Test how requests map to fixture files."""
+
+    def test_secrets_are_stripped(self):
+        """API keys and access tokens don't affect or appear in the fixture key."""
+        with_key = requests.Request("GET", OWNED_GAMES_URL, params={"key": "secret", "steamid": STEAM_ID}).prepare()
+        other_key = requests.Request("GET", OWNED_GAMES_URL, params={"key": "other", "steamid": STEAM_ID}).prepare()
+
+        self.assertEqual(fixture_key(with_key), fixture_key(other_key))
+        self.assertNotIn("secret", fixture_key(with_key)[1])
+
+    def test_parameter_order_is_irrelevant(self):
+        """Query parameter order doesn't change the fixture key."""
+        first = requests.Request("GET", OWNED_GAMES_URL, params={"a": "1", "b": "2"}).prepare()
+        second = requests.Request("GET", OWNED_GAMES_URL, params={"b": "2", "a": "1"}).prepare()
+
+        self.assertEqual(fixture_key(first), fixture_key(second))
+
+
+class TestStreamingParser(unittest.TestCase):
+    """Test incremental parsing of JSON arrays split across chunks."""
+
+    def test_items_split_across_chunks(self):
+        """Objects are yielded even when chunk boundaries fall inside them."""
+        body = json.dumps({"response": {"game_count": 3, "games": [{"appid": i, "name": f"Game {i}"} for i in range(3)]}})
+        chunks = [body[i : i + 7] for i in range(0, len(body), 7)]
+        prefix = {}
+
+        items = list(iter_json_array_items(iter(chunks), "games", prefix))
+
+        self.assertEqual([item["appid"] for item in items], [0, 1, 2])
+        self.assertEqual(prefix["game_count"], 3)
+
+    def test_missing_array(self):
+        """A document without the array yields nothing."""
+        self.assertEqual(list(iter_json_array_items(iter(['{"response": {}}']), "games")), [])
+
+
+class TestReplayMode(unittest.TestCase):
+    """Test that a fetcher in replay mode serves fixtures instead of the network."""
+
+    def setUp(self):
+        self.tmp = tempfile.TemporaryDirectory()
+        self.fixture_dir = Path(self.tmp.name)
+        self.fetcher = SteamLibraryFetcher("replay")
+        self.fetcher.enable_vcr("replay", self.tmp.name)
+
+    def tearDown(self):
+        self.tmp.cleanup()
+
+    def test_owned_games_replayed(self):
+        """GetOwnedGames returns the recorded games."""
+        params = {"key": "replay", "steamid": STEAM_ID, "include_appinfo": True, "include_played_free_games": True, "format": "json"}
+        write_fixture(self.fixture_dir, OWNED_GAMES_URL, params, {"response": {"game_count": 1, "games": [{"appid": 620, "name": "Portal 2", "playtime_forever": 600}]}})
+
+        games = self.fetcher.get_owned_games(STEAM_ID)
+
+        self.assertEqual(len(games), 1)
+        self.assertEqual(games[0]["name"], "Portal 2")
+
+    def test_owned_games_streamed(self):
+        """iter_owned_games yields recorded games and reports the library size."""
+        params = {"key": "replay", "steamid": STEAM_ID, "include_appinfo": True, "include_played_free_games": True, "format": "json"}
+        games = [{"appid": 620, "name": "Portal 2"}, {"appid": 400, "name": "Portal"}]
+        write_fixture(self.fixture_dir, OWNED_GAMES_URL, params, {"response": {"game_count": 2, "games": games}})
+
+        seen = []
+        count = self.fetcher.for_each_owned_game(STEAM_ID, seen.append)
+
+        self.assertEqual(count, 2)
+        self.assertEqual(seen, games)
+        self.assertEqual(self.fetcher.owned_game_count, 2)
+
+    def test_missing_fixture_raises(self):
+        """Requests without a fixture fail like a network error."""
+        with self.assertRaises(ReplayMissError):
+            self.fetcher.session.get(OWNED_GAMES_URL, params={"steamid": STEAM_ID}, timeout=30)
+
+    def test_replay_is_not_throttled(self):
+        """Replay mode drops the rate-limit delay."""
+        self.assertEqual(self.fetcher.throttle.delay, 0)
+
+
+if __name__ == "__main__":
+    unittest.main()
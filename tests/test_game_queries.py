@@ -0,0 +1,74 @@
+#!/usr/bin/env python3
+"""Test filtering and sorting game lists."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src and the shared test fixtures to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+sys.path.insert(0, str(Path(__file__).parent))
+
+from mcp_server.game_queries import GameQuery, query_games, sort_by_release_date
+from shared.database import Game, GameReview, Genre, Tag, UserGame, UserProfile, get_db, get_db_transaction
+from database_fixture import setUpModule, tearDownModule  # noqa: F401
+
+
+class TestGameQueries(unittest.TestCase):
+    """Test query options against a small catalogue and one library."""
+
+    @classmethod
+    def setUpClass(cls):
+        with get_db_transaction() as session:
+            session.add(UserProfile(steam_id="1", persona_name="Tester"))
+            rpg, puzzle, coop = Genre(genre_name="RPG"), Genre(genre_name="Puzzle"), Tag(tag_name="Co-op")
+            # (app_id, name, genres, tags, release date, positive reviews out of 100, discount, minutes played, last played)
+            catalogue = [(1, "Beta", [rpg], [coop], "21 Oct, 2008", 90, 0, 600, 1700000300), (2, "Alpha", [puzzle], [], "Oct 1, 2012", 70, 50, 60, 1700000100), (3, "Gamma", [rpg], [], "Coming soon", None, 0, None, None), (4, "Delta", [puzzle], [coop], "2001", 95, 25, 0, 1700000200)]
+            for app_id, name, genres, tags, released, positive, discount, minutes, last_played in catalogue:
+                session.add(Game(app_id=app_id, name=name, genres=genres, tags=tags, release_date=released, discount_percent=discount))
+                if positive is not None:
+                    session.add(GameReview(app_id=app_id, total_reviews=100, positive_reviews=positive, negative_reviews=100 - positive))
+                if minutes is not None:
+                    session.add(UserGame(steam_id="1", app_id=app_id, playtime_forever=minutes, last_played=last_played))
+
+    def app_ids(self, **options) -> list[int]:
+        with get_db() as session:
+            query_options = GameQuery(**options)
+            rows = query_games(session, query_options).all()
+            if query_options.sort_by == "release_date":
+                rows = sort_by_release_date(rows, query_options.order)
+            return [game.app_id for game, _ in rows]
+
+    def test_catalogue_by_name(self):
+        """Without a library every game is listed, by name unless asked otherwise."""
+        self.assertEqual(self.app_ids(), [2, 1, 4, 3])
+        self.assertEqual(self.app_ids(order="desc"), [3, 4, 1, 2])
+
+    def test_filters(self):
+        """Genre, tag, and sale filters narrow the list."""
+        self.assertEqual(self.app_ids(genre="rpg"), [1, 3])
+        self.assertEqual(self.app_ids(tag="Co-op", on_sale=True), [4])
+        self.assertEqual(self.app_ids(on_sale=False), [1, 3])
+
+    def test_library_sorts(self):
+        """A library can be sorted by playtime or last played and filtered by hours played."""
+        self.assertEqual(self.app_ids(steam_id="1", sort_by="playtime", order="desc"), [1, 2, 4])
+        self.assertEqual(self.app_ids(steam_id="1", sort_by="last_played"), [2, 4, 1])
+        self.assertEqual(self.app_ids(steam_id="1", min_playtime=1), [2, 1])
+
+    def test_review_and_release_sorts(self):
+        """Unreviewed games and unknown release dates sort last either way."""
+        self.assertEqual(self.app_ids(sort_by="review_score", order="desc"), [4, 1, 2, 3])
+        self.assertEqual(self.app_ids(sort_by="review_score"), [2, 1, 4, 3])
+        self.assertEqual(self.app_ids(sort_by="release_date"), [4, 1, 2, 3])
+        self.assertEqual(self.app_ids(sort_by="release_date", order="desc"), [2, 1, 4, 3])
+
+    def test_invalid_options(self):
+        """Unknown sorts and library-only options without a library are rejected."""
+        for options in ({"sort_by": "price"}, {"order": "up"}, {"sort_by": "playtime"}, {"min_playtime": 1}):
+            with self.assertRaises(ValueError):
+                self.app_ids(**options)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,71 @@
+#!/usr/bin/env python3
+"""Test full-text game search and its ranking."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src and the shared test fixtures to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+sys.path.insert(0, str(Path(__file__).parent))
+
+from mcp_server.game_search import fts5_query, search_games, search_substrings, search_terms
+from shared.database import Developer, Game, Genre, Publisher, Tag, get_db, get_db_transaction
+from database_fixture import setUpModule, tearDownModule  # noqa: F401
+
+
+class TestGameSearch(unittest.TestCase):
+    """Test which games match a query and in what order."""
+
+    @classmethod
+    def setUpClass(cls):
+        with get_db_transaction() as session:
+            valve, puzzle, farming = Developer(developer_name="Valve"), Genre(genre_name="Puzzle"), Tag(tag_name="Farming Sim")
+            session.add(Game(app_id=400, name="Portal", short_description="A puzzle game of portals and physics", developers=[valve], genres=[puzzle], publishers=[Publisher(publisher_name="Valve Corporation")]))
+            session.add(Game(app_id=70, name="Half-Life", short_description="Gordon Freeman against the Combine, with a portal or two", developers=[valve]))
+            session.add(Game(app_id=413150, name="Stardew Valley", short_description="Inherit your grandfather's old farm", tags=[farming]))
+
+    def app_ids(self, query: str) -> list[int]:
+        with get_db() as session:
+            return [app_id for app_id, _ in search_games(session, query)]
+
+    def test_name_matches_rank_first(self):
+        """A name match outranks the same word in a description."""
+        app_ids = self.app_ids("portal")
+        self.assertEqual(app_ids[0], 400)
+        self.assertEqual(app_ids[-1], 70)
+
+    def test_fields_and_prefixes(self):
+        """Developers, genres, and tags are searched, and words match as prefixes."""
+        self.assertEqual(set(self.app_ids("valve")), {400, 70})
+        self.assertEqual(self.app_ids("puzz"), [400])
+        self.assertEqual(self.app_ids("farming"), [413150])
+
+    def test_every_word_must_match(self):
+        """Each word narrows the results."""
+        self.assertEqual(self.app_ids("valve puzzle"), [400])
+        self.assertEqual(self.app_ids("valve farming"), [])
+
+    def test_index_follows_new_games(self):
+        """Games added after a search are found by the next one."""
+        self.app_ids("portal")
+        with get_db_transaction() as session:
+            session.add(Game(app_id=620, name="Portal 2", short_description="Sequel"))
+        self.assertIn(620, self.app_ids("portal"))
+
+    def test_substring_fallback(self):
+        """The fallback search scores name matches above other fields."""
+        with get_db() as session:
+            self.assertEqual([app_id for app_id, _ in search_substrings(session, ["portal"])][:1], [400])
+            self.assertEqual([app_id for app_id, _ in search_substrings(session, ["valve", "puzzle"])], [400])
+
+    def test_query_parsing(self):
+        """Punctuation is dropped, and queries without words are rejected."""
+        self.assertEqual(search_terms("Half-Life: Alyx!"), ["half", "life", "alyx"])
+        self.assertEqual(fts5_query(["half", "life"]), '"half"* "life"*')
+        with get_db() as session, self.assertRaises(ValueError):
+            search_games(session, "!!")
+
+
+if __name__ == "__main__":
+    unittest.main()
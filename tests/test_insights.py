@@ -0,0 +1,60 @@
+#!/usr/bin/env python3
+"""Test library statistics computed with aggregate queries."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src and the shared test fixtures to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+sys.path.insert(0, str(Path(__file__).parent))
+
+from mcp_server.insights import library_insights
+from shared.database import Game, Genre, UserGame, UserProfile, get_db, get_db_transaction
+from database_fixture import setUpModule, tearDownModule  # noqa: F401
+
+
+class TestLibraryInsights(unittest.TestCase):
+    """Test the figures library_insights reports for a small library."""
+
+    @classmethod
+    def setUpClass(cls):
+        with get_db_transaction() as session:
+            session.add(UserProfile(steam_id="1", persona_name="Tester"))
+            action, puzzle = Genre(genre_name="Action"), Genre(genre_name="Puzzle")
+            # (app_id, genres, price in cents, minutes played, shared, removed)
+            library = [(1, [action], 2000, 6000, False, None), (2, [action, puzzle], 1000, 60, False, None), (3, [puzzle], 1500, 0, False, None), (4, [puzzle], 500, 600, True, None), (5, [action], 3000, 0, False, 1700000000)]
+            for app_id, genres, price, minutes, shared, removed_at in library:
+                session.add(Game(app_id=app_id, name=f"Game {app_id}", genres=genres, price_initial=price, currency="USD"))
+                session.add(UserGame(steam_id="1", app_id=app_id, playtime_forever=minutes, playtime_2weeks=0, shared=shared, removed_at=removed_at, achievements_total=10 if app_id <= 2 else 0, achievements_unlocked=10 if app_id == 1 else 2, completion_percent=100.0 if app_id == 1 else 20.0))
+
+        with get_db() as session:
+            cls.insights = library_insights(session, "1")
+
+    def test_removed_games_are_left_out(self):
+        """Counts cover the games still in the library, played or not."""
+        self.assertEqual((self.insights["total_games"], self.insights["games_played"], self.insights["never_played"]), (4, 3, 1))
+
+    def test_playtime_percentiles(self):
+        """Percentiles are nearest-rank over played games only."""
+        self.assertEqual(self.insights["playtime_percentiles_hours"], {"p25": 1.0, "p50": 10.0, "p75": 100.0, "p90": 100.0})
+
+    def test_genre_distribution(self):
+        """Genres are ordered by playtime, and a game in two genres counts towards both."""
+        action, puzzle = self.insights["genres"]
+        self.assertEqual((action["genre"], action["games"], action["playtime_hours"]), ("Action", 2, 101.0))
+        self.assertEqual((puzzle["genre"], puzzle["games"], puzzle["never_played"]), ("Puzzle", 3, 1))
+
+    def test_completion(self):
+        """Only games with achievements count towards completion."""
+        self.assertEqual(self.insights["completion"], {"games_with_achievements": 2, "average_completion_percent": 60.0, "perfect_games": 1, "games_with_unlocks": 2})
+
+    def test_value_skips_shared_games(self):
+        """Spend counts owned games at list price; the best value game costs least per hour."""
+        value = self.insights["value"]
+        self.assertEqual((value["priced_games"], value["estimated_spend"], value["unplayed_spend"]), (3, 45.0, 15.0))
+        self.assertEqual([game["app_id"] for game in value["best_value"]], [1, 2])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,64 @@
+#!/usr/bin/env python3
+"""Test turning sync events into notifications."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from fetcher.notifications import notification_handler
+
+
+class RecordingNotifier:
+    def __init__(self, fail: bool = False):
+        self.sent = []
+        self.fail = fail
+
+    def send(self, title: str, message: str, level: str = "info", event: dict | None = None):
+        if self.fail:
+            raise OSError("webhook unreachable")
+        self.sent.append((title, level))
+
+
+class TestNotifications(unittest.TestCase):
+    """Test which events notify and how failures are contained."""
+
+    def test_sync_outcomes_notify(self):
+        """Completed, failed, and cancelled syncs notify; progress events (including sync_interrupted) don't."""
+        notifier = RecordingNotifier()
+        notify = notification_handler([notifier])
+
+        notify({"type": "game_processed", "steam_id": "1", "app_id": 620})
+        notify({"type": "sync_completed", "steam_id": "1", "processed_games": 2, "failed_games": 0, "total_games": 2})
+        notify({"type": "sync_interrupted", "steam_id": "1", "processed_games": 1, "total_games": 2})
+        notify({"type": "sync_failed", "steam_id": "1", "error": "database is locked"})
+        notify({"type": "sync_cancelled", "steam_id": "1"})
+
+        self.assertEqual(notifier.sent, [("Library sync completed", "success"), ("Sync failed", "error"), ("Sync cancelled", "info")])
+
+    def test_wishlist_sales_for_selected_types(self):
+        """Wishlist sales notify with their event, and a handler can be limited to some event types."""
+        notifier = RecordingNotifier()
+        sale = {"type": "wishlist_sale", "steam_id": "1", "app_id": 620, "name": "Portal 2", "discount_percent": 75, "formatted_price": "$2.49"}
+
+        notify = notification_handler([notifier], {"wishlist_sale"})
+        notify({"type": "sync_completed", "steam_id": "1"})
+        notify(sale)
+
+        self.assertEqual(notifier.sent, [("Wishlist sale", "info")])
+
+    def test_failing_notifier_does_not_block_others(self):
+        """A notifier that raises is logged and the remaining notifiers still get the message."""
+        working = RecordingNotifier()
+        notify = notification_handler([RecordingNotifier(fail=True), working])
+
+        with self.assertLogs("fetcher.notifications", level="ERROR"):
+            notify({"type": "sync_completed", "steam_id": "1"})
+
+        self.assertEqual(len(working.sent), 1)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,50 @@
+#!/usr/bin/env python3
+"""Test cursor pagination of list responses."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from mcp_server.pagination import decode_cursor, page_limit, paginate
+
+
+class TestPagination(unittest.TestCase):
+    """Test walking a list page by page with nextCursor."""
+
+    def test_pages_cover_every_item_once(self):
+        """Following nextCursor visits every item in order, and the last page has no cursor."""
+        items = list(range(7))
+        seen, cursor, pages = [], None, 0
+        while True:
+            page, cursor = paginate(items, cursor, 3)
+            seen.extend(page)
+            pages += 1
+            if not cursor:
+                break
+        self.assertEqual(seen, items)
+        self.assertEqual(pages, 3)
+
+    def test_exact_fit_has_no_next_page(self):
+        """A list that fills the page exactly doesn't point at an empty next page."""
+        self.assertEqual(paginate([1, 2, 3], None, 3), ([1, 2, 3], None))
+
+    def test_invalid_cursor_rejected(self):
+        """Cursors that weren't produced by paginate raise ValueError."""
+        for cursor in ["not-a-cursor", "eyJvZmZzZXQiOiAtMX0", "WzFd"]:
+            with self.assertRaises(ValueError):
+                decode_cursor(cursor)
+
+    def test_limit_clamped(self):
+        """Limits fall back to the default when missing and stay within 1..maximum."""
+        self.assertEqual(page_limit(None, 20, 50), 20)
+        self.assertEqual(page_limit("500", 20, 50), 50)
+        self.assertEqual(page_limit("0", 20, 50), 1)
+        with self.assertRaises(ValueError):
+            page_limit("ten", 20, 50)
+
+
+if __name__ == "__main__":
+    unittest.main()
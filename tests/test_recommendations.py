@@ -0,0 +1,87 @@
+#!/usr/bin/env python3
+"""Test scoring owned games against a session context."""
+
+import sys
+import unittest
+import zlib
+from pathlib import Path
+from types import SimpleNamespace
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from mcp_server.recommendations import SessionContext, parse_duration, score_game
+
+
+def make_game(name, tags=(), genres=(), categories=(), esrb=None, pegi=None, median_playtime=None):
+    return SimpleNamespace(app_id=zlib.crc32(name.encode()) % 100000, name=name, tags=[SimpleNamespace(tag_name=t) for t in tags], genres=[SimpleNamespace(genre_name=g) for g in genres], categories=[SimpleNamespace(category_name=c) for c in categories], esrb_rating=esrb, pegi_rating=pegi, steamspy_median_playtime=median_playtime, reviews=None)
+
+
+def owned(playtime_forever=0, playtime_2weeks=0):
+    return SimpleNamespace(playtime_forever=playtime_forever, playtime_2weeks=playtime_2weeks)
+
+
+class TestSessionScoring(unittest.TestCase):
+    """Test how each part of the context affects a game's score and reasons."""
+
+    def test_friends_present_requires_multiplayer(self):
+        """Single-player games drop out when friends are playing, and friends who own an online game make up for it not being couch co-op."""
+        context = SessionContext(friends_present=2)
+        self.assertIsNone(score_game(make_game("Solo", categories=["Single-player"]), owned(60), context))
+
+        couch = score_game(make_game("Couch", categories=["Shared/Split Screen Co-op"]), owned(60), context)
+        online = score_game(make_game("Online", categories=["Online Co-op"]), owned(60), context, friends_owning=1)
+        self.assertTrue(couch.reasons[0].startswith("Plays on one screen"))
+        self.assertEqual(online.score, couch.score)
+        self.assertIn("1 of your friends own it", online.reasons)
+
+    def test_family_friendly_filters_by_rating(self):
+        """Games rated above the youngest player's age are excluded; unrated games stay with a caveat."""
+        context = SessionContext(family_friendly=True, age=8, mood="relaxing")
+        self.assertIsNone(score_game(make_game("Mature", tags=["Casual"], esrb="M"), owned(), context))
+
+        unrated = score_game(make_game("Unrated", tags=["Casual"]), owned(), context)
+        self.assertTrue(unrated.reasons[0].startswith("Unrated"))
+
+    def test_mood_and_time_add_reasons(self):
+        """Matching the mood and the session length each add to the score with a reason."""
+        context = SessionContext(mood="relaxing", minutes=15)
+        puzzle = score_game(make_game("Puzzle", tags=["Puzzle", "Casual"]), owned(120), context)
+        self.assertEqual(puzzle.score, 5)
+        self.assertEqual(len(puzzle.reasons), 2)
+
+        self.assertIsNone(score_game(make_game("Epic", tags=["Open World"]), owned(120), context))
+
+    def test_finishable_games_fit_the_session(self):
+        """A game with less than the session left to play by its typical length gets a reason, whatever its tags."""
+        context = SessionContext(minutes=45)
+        nearly_done = score_game(make_game("Nearly Done", tags=["Story Rich"], median_playtime=300), owned(270), context)
+        self.assertEqual(nearly_done.score, 2)
+        self.assertIn("30 more minutes", nearly_done.reasons[0])
+
+        self.assertIsNone(score_game(make_game("Finished", tags=["Story Rich"], median_playtime=300), owned(400), context))
+
+    def test_parse_duration(self):
+        """Free-text lengths of time become minutes."""
+        for text, minutes in [("I have 45 minutes", 45), ("90", 90), ("1.5 hours", 90), ("2h", 120), ("1h30", 90), ("an hour and a half", 90), ("half an hour", 30), ("about an hour", 60), ("I am free for 20 minutes", 20)]:
+            self.assertEqual(parse_duration(text), minutes, text)
+        with self.assertRaises(ValueError):
+            parse_duration("a while")
+
+    def test_unknown_mood_rejected(self):
+        """Parameters with a mood the service doesn't know raise ValueError."""
+        with self.assertRaises(ValueError):
+            SessionContext.from_params({"mood": "sleepy"})
+        self.assertEqual(SessionContext.from_params({"friends_present": True, "age": 10}), SessionContext(friends_present=1, family_friendly=True, age=10))
+
+    def test_family_friendly_flag_parsed(self):
+        """family_friendly given as text means what it says, and other values are rejected."""
+        self.assertFalse(SessionContext.from_params({"family_friendly": "false"}).family_friendly)
+        self.assertTrue(SessionContext.from_params({"family_friendly": "true"}).family_friendly)
+        self.assertTrue(SessionContext.from_params({"family_friendly": 1}).family_friendly)
+        with self.assertRaises(ValueError):
+            SessionContext.from_params({"family_friendly": "sometimes"})
+
+
+if __name__ == "__main__":
+    unittest.main()
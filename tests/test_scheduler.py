@@ -0,0 +1,211 @@
+#!/usr/bin/env python3
+"""Test cron schedule parsing and due-sync selection for the MCP server's sync scheduler."""
+
+import sys
+import unittest
+from datetime import datetime
+from pathlib import Path
+from unittest.mock import patch
+
+# Add src and the shared test fixtures to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+sys.path.insert(0, str(Path(__file__).parent))
+
+from mcp_server.scheduler import Scheduler, parse_schedule, upcoming_runs
+from shared.database import OneShotSync, SyncCheckpoint, get_db, get_db_transaction
+from database_fixture import setUpModule, tearDownModule  # noqa: F401
+
+
+def local_time(hour: int, minute: int) -> float:
+    return datetime(2024, 6, 1, hour, minute).timestamp()
+
+
+class TestScheduleParsing(unittest.TestCase):
+    """Test the CRON=SCOPE schedule format."""
+
+    def test_entries_with_scopes(self):
+        """Entries are split on semicolons, and a bare expression means a full sync."""
+        self.assertEqual(parse_schedule("0 3 * * *=full; */30 * * * *=playtime;; 15 * * * *"), [("0 3 * * *", "full"), ("*/30 * * * *", "playtime"), ("15 * * * *", "full")])
+
+    def test_empty_schedule(self):
+        """An empty schedule has no entries."""
+        self.assertEqual(parse_schedule(""), [])
+
+    def test_invalid_entries_rejected(self):
+        """Unknown scopes and malformed expressions raise ValueError."""
+        with self.assertRaises(ValueError):
+            parse_schedule("0 3 * * *=everything")
+        with self.assertRaises(ValueError):
+            parse_schedule("0 25 * * *=full")
+
+    def test_invalid_default_schedule_ignored(self):
+        """A bad SYNC_SCHEDULE is logged when the scheduler starts instead of failing the import."""
+        scheduler = Scheduler("0 3 * * *=everything")
+        with patch("mcp_server.scheduler.threading.Thread"), self.assertLogs("mcp_server.scheduler", "ERROR"):
+            scheduler.start()
+        self.assertEqual(scheduler.default_schedule, [])
+
+
+class TestDueSyncs(unittest.TestCase):
+    """Test which scope runs when expressions fire."""
+
+    def setUp(self):
+        self.scheduler = Scheduler()
+        self.schedules = parse_schedule("0 3 * * *=full; */30 * * * *=playtime")
+
+    def test_full_scope_wins(self):
+        """When a full and a playtime sync fire in the same minute, only the full sync runs."""
+        self.assertEqual(self.scheduler.due(self.schedules, local_time(2, 59), local_time(3, 0)), ["full"])
+
+    def test_narrow_scope_between_full_syncs(self):
+        """Between full syncs, the playtime expression fires on its own."""
+        self.assertEqual(self.scheduler.due(self.schedules, local_time(3, 29), local_time(3, 30)), ["playtime"])
+
+    def test_narrow_scopes_are_independent(self):
+        """Playtime and reviews syncs firing together both run."""
+        schedules = parse_schedule("0 * * * *=reviews; */30 * * * *=playtime")
+        self.assertEqual(self.scheduler.due(schedules, local_time(3, 59), local_time(4, 0)), ["playtime", "reviews"])
+        self.assertEqual(upcoming_runs(schedules, local_time(3, 45)), {"playtime": local_time(4, 0), "reviews": local_time(4, 0)})
+
+    def test_nothing_due(self):
+        """No expression fires within the window."""
+        self.assertEqual(self.scheduler.due(self.schedules, local_time(3, 1), local_time(3, 2)), [])
+
+    def test_upcoming_skips_times_taken_by_full_syncs(self):
+        """The next incremental run skips the minute a full sync runs."""
+        runs = upcoming_runs(self.schedules, local_time(2, 45))
+        self.assertEqual(runs, {"full": local_time(3, 0), "playtime": local_time(3, 30)})
+
+    def test_upcoming_skips_blackouts(self):
+        """Runs inside a blackout window are skipped until it ends."""
+        runs = upcoming_runs(self.schedules, local_time(2, 45), [(local_time(2, 50), local_time(4, 10))])
+        self.assertEqual(runs["playtime"], local_time(4, 30))
+        self.assertEqual(runs["full"], datetime(2024, 6, 2, 3, 0).timestamp())
+
+
+class TestSchedulerState(unittest.TestCase):
+    """Test restoring the scheduler after a restart."""
+
+    def setUp(self):
+        self.now = local_time(12, 0)
+
+    def save_state(self, last_tick: float):
+        scheduler = Scheduler()
+        scheduler.last_tick = last_tick
+        scheduler.stats["queued"] = 3
+        scheduler.save()
+
+    def test_short_restart_resumes_from_last_check(self):
+        """A restart within the catch-up window picks up where the last check left off, with its counters."""
+        self.save_state(self.now - 600)
+
+        restored = Scheduler(catch_up=3600)
+        restored.load(self.now)
+
+        self.assertEqual(restored.last_tick, self.now - 600)
+        self.assertEqual(restored.stats["queued"], 3)
+
+    def test_long_outage_only_catches_up_recent_runs(self):
+        """After a long outage, runs older than the catch-up window are skipped."""
+        self.save_state(self.now - 86400)
+
+        restored = Scheduler(catch_up=3600)
+        restored.load(self.now)
+
+        self.assertEqual(restored.last_tick, self.now - 3600)
+
+    def test_pause_survives_restart(self):
+        """A scheduler paused for maintenance stays paused after a restart until resumed."""
+        Scheduler().pause()
+
+        restored = Scheduler()
+        restored.load(self.now)
+        self.assertTrue(restored.paused)
+
+        restored.resume()
+        restored.load(self.now)
+        self.assertFalse(restored.paused)
+
+    def test_one_shot_lifecycle(self):
+        """A one-shot sync is listed while pending and drops off the list once cancelled."""
+        scheduler = Scheduler()
+        with self.assertRaises(ValueError):
+            scheduler.schedule_one_shot("a", 1000, "full")
+
+        one_shot = scheduler.schedule_one_shot("a", int(datetime(2999, 1, 1).timestamp()), "metadata")
+        self.assertIn(one_shot["id"], [pending["id"] for pending in scheduler.list_one_shots()])
+
+        self.assertEqual(scheduler.cancel_one_shot(one_shot["id"])["status"], "cancelled")
+        self.assertNotIn(one_shot["id"], [pending["id"] for pending in scheduler.list_one_shots()])
+        with self.assertRaises(LookupError):
+            scheduler.cancel_one_shot(one_shot["id"])
+
+    def test_least_recently_synced_first(self):
+        """Due libraries are ordered by their last completed sync, or the start of an unfinished one."""
+        with get_db_transaction() as session:
+            session.query(SyncCheckpoint).delete()
+            session.add(SyncCheckpoint(steam_id="a", status="completed", started_at=250, updated_at=300))
+            session.add(SyncCheckpoint(steam_id="b", status="interrupted", started_at=100, updated_at=500))
+            session.add(SyncCheckpoint(steam_id="c", status="completed", started_at=150, updated_at=200))
+
+        with get_db() as session:
+            self.assertEqual(Scheduler().by_staleness(session, ["a", "b", "c", "new"]), ["new", "b", "c", "a"])
+
+
+class TestQuotaBudget(unittest.TestCase):
+    """Test fitting scheduled syncs into the request budget left after the manual reserve."""
+
+    def setUp(self):
+        self.scheduler = Scheduler(quota_reserve=20)
+
+    def budget(self, remaining: int, estimates: dict[str, int], budget: int = 1000):
+        """Patch the budget status and request estimates (by scope) the scheduler sees"""
+        status = patch("mcp_server.scheduler.quota_status", return_value={"budget": budget, "remaining": remaining})
+        estimate = patch("mcp_server.scheduler.estimate_requests", side_effect=lambda session, steam_id, args: estimates[args[1] if args else "full"])
+        status.start()
+        estimate.start()
+        self.addCleanup(status.stop)
+        self.addCleanup(estimate.stop)
+
+    def fit(self, scope: str = "full"):
+        with get_db() as session:
+            return self.scheduler.fit_to_budget(session, "a", scope)
+
+    def test_fits_within_budget(self):
+        """A sync that fits in the budget minus the reserve runs as requested, as does any sync without a budget."""
+        self.budget(500, {"full": 300, "playtime": 5})
+        self.assertEqual(self.fit(), ("full", None))
+
+    def test_no_budget(self):
+        self.budget(0, {"full": 300, "playtime": 5}, budget=0)
+        self.assertEqual(self.fit(), ("full", None))
+
+    def test_reserve_downgrades(self):
+        """The reserve is kept back: 450 left with 200 reserved leaves 250, too few for a full sync."""
+        self.budget(450, {"full": 300, "playtime": 5})
+        scope, reason = self.fit()
+        self.assertEqual(scope, "playtime")
+        self.assertIn("only 250 are left", reason)
+
+    def test_skipped_when_nothing_fits(self):
+        self.budget(210, {"full": 300, "playtime": 20})
+        scope, reason = self.fit()
+        self.assertIsNone(scope)
+        self.assertIn("full sync needs about 300 requests", reason)
+
+    def test_one_shot_downgraded(self):
+        """Due one-shots are fitted to the budget too, and the decision is recorded."""
+        self.budget(450, {"full": 300, "playtime": 5})
+        with get_db_transaction() as session:
+            session.add(OneShotSync(steam_id="budget", scope="full", run_at=1000))
+
+        with patch("mcp_server.scheduler.submit_within_budget", return_value=type("Job", (), {"id": 7})()) as submit:
+            results = self.scheduler._run_one_shots(2000)
+
+        self.assertEqual([(result["steam_id"], result["scope"], result.get("job_id")) for result in results], [("budget", "playtime", 7)])
+        self.assertEqual(submit.call_args.args[1:], ("budget", ["--scope", "playtime"]))
+        self.assertEqual(self.scheduler.quota_decisions[-1]["requested"], "full")
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,82 @@
+#!/usr/bin/env python3
+"""Test reading and writing stdio messages in either framing."""
+
+import asyncio
+import io
+import json
+import sys
+import unittest
+from pathlib import Path
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from mcp_server.stdio import Framing
+
+
+class AsyncBytes:
+    """Async readline()/read(n) over bytes, like an anyio-wrapped stdin"""
+
+    def __init__(self, data: bytes):
+        self.buffer = io.BytesIO(data)
+
+    async def readline(self) -> bytes:
+        return self.buffer.readline()
+
+    async def read(self, size: int) -> bytes:
+        return self.buffer.read(size)
+
+
+def read_all(data: bytes) -> tuple[list[bytes], Framing]:
+    framing, stream, bodies = Framing(), AsyncBytes(data), []
+
+    async def read():
+        while (body := await framing.read(stream)) is not None:
+            bodies.append(body)
+
+    asyncio.run(read())
+    return bodies, framing
+
+
+class TestFraming(unittest.TestCase):
+    """Test message boundaries and the framing used for replies."""
+
+    def test_newline_delimited(self):
+        """Each line is a message; blank lines are skipped and replies end with a newline."""
+        bodies, framing = read_all(b'{"id": 1}\n\n{"id": 2}\n')
+        self.assertEqual(bodies, [b'{"id": 1}', b'{"id": 2}'])
+        self.assertEqual(framing.frame(b"{}"), b"{}\n")
+
+    def test_content_length(self):
+        """Bodies are read by length, even when they contain newlines; replies get a header."""
+        first, second = b'{"id": 1,\n"method": "ping"}', b'{"id": 2}'
+        data = b"Content-Length: %d\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n%s" % (len(first), first) + b"content-length: %d\r\n\r\n%s" % (len(second), second)
+        bodies, framing = read_all(data)
+        self.assertEqual(bodies, [first, second])
+        self.assertEqual(framing.frame(b"{}"), b"Content-Length: 2\r\n\r\n{}")
+
+    def test_large_messages(self):
+        """Messages well over 64KB arrive whole in both framings."""
+        body = json.dumps({"id": 1, "params": {"text": "x" * 200_000}}).encode()
+        self.assertEqual(read_all(body + b"\n")[0], [body])
+        self.assertEqual(read_all(b"Content-Length: %d\r\n\r\n" % len(body) + body)[0], [body])
+
+    def test_missing_length(self):
+        """Headers without Content-Length are rejected."""
+        with self.assertRaises(ValueError):
+            read_all(b"Content-Type: application/json\r\n\r\n{}")
+
+    def test_bad_length(self):
+        """A Content-Length that isn't a number is rejected."""
+        with self.assertRaises(ValueError):
+            read_all(b"Content-Length: ten\r\n\r\n{}")
+
+    def test_garbage_line(self):
+        """A stray line that isn't JSON or a header is passed on as a message, and later messages still arrive."""
+        bodies, framing = read_all(b'Welcome to the server\n{"id": 1}\n')
+        self.assertEqual(bodies, [b"Welcome to the server", b'{"id": 1}'])
+        self.assertEqual(framing.frame(b"{}"), b"{}\n")
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,88 @@
+#!/usr/bin/env python3
+"""Test Steam ID format conversion and identifier parsing."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from shared.steamid import (
+    account_id_to_steamid64,
+    normalize_steam_id,
+    parse_steam_identifier,
+    steam2_to_steamid64,
+    steam3_to_steamid64,
+    steamid64_to_account_id,
+    steamid64_to_steam2,
+    steamid64_to_steam3,
+)
+
+# Gabe Newell's well-known public profile
+STEAMID64 = "76561197960287930"
+ACCOUNT_ID = 22202
+STEAM2 = "STEAM_0:0:11101"
+STEAM3 = "[U:1:22202]"
+
+
+class TestSteamIdConversions(unittest.TestCase):
+    """Test conversions between SteamID64, SteamID3, SteamID2, and account IDs."""
+
+    def test_account_id_round_trip(self):
+        """Account IDs convert to SteamID64 and back."""
+        self.assertEqual(account_id_to_steamid64(ACCOUNT_ID), STEAMID64)
+        self.assertEqual(steamid64_to_account_id(STEAMID64), ACCOUNT_ID)
+
+    def test_steam2_round_trip(self):
+        """SteamID2 converts to SteamID64 and back, accepting any universe digit."""
+        self.assertEqual(steamid64_to_steam2(STEAMID64), STEAM2)
+        self.assertEqual(steam2_to_steamid64(STEAM2), STEAMID64)
+        self.assertEqual(steam2_to_steamid64("STEAM_1:0:11101"), STEAMID64)
+        self.assertEqual(steam2_to_steamid64("STEAM_0:1:11101"), account_id_to_steamid64(ACCOUNT_ID + 1))
+
+    def test_steam3_round_trip(self):
+        """SteamID3 converts to SteamID64 and back, with or without brackets."""
+        self.assertEqual(steamid64_to_steam3(STEAMID64), STEAM3)
+        self.assertEqual(steam3_to_steamid64(STEAM3), STEAMID64)
+        self.assertEqual(steam3_to_steamid64("U:1:22202"), STEAMID64)
+
+    def test_invalid_ids_raise(self):
+        """Malformed identifiers raise ValueError."""
+        for func, value in [(steamid64_to_account_id, "12345"), (steam2_to_steamid64, "STEAM_0:2:1"), (steam3_to_steamid64, "[G:1:22202]")]:
+            with self.assertRaises(ValueError):
+                func(value)
+        with self.assertRaises(ValueError):
+            account_id_to_steamid64(0)
+        with self.assertRaises(ValueError):
+            account_id_to_steamid64(2**32)
+
+    def test_normalize_steam_id(self):
+        """Every supported format normalizes to the same SteamID64."""
+        for value in [STEAMID64, STEAM2, STEAM3, f"  {STEAM3}  "]:
+            self.assertEqual(normalize_steam_id(value), STEAMID64)
+        self.assertIsNone(normalize_steam_id("gabelogannewell"))
+        self.assertIsNone(normalize_steam_id("STEAM_0:0:0"))
+
+
+class TestParseSteamIdentifier(unittest.TestCase):
+    """Test classification of user-supplied identifiers."""
+
+    def test_steam_id_formats(self):
+        """All Steam ID formats are classified as steamid64."""
+        for value in [STEAMID64, STEAM2, STEAM3]:
+            self.assertEqual(parse_steam_identifier(value), ("steamid64", STEAMID64))
+
+    def test_profile_urls(self):
+        """Profile URLs yield either the SteamID64 or the vanity name."""
+        self.assertEqual(parse_steam_identifier(f"https://steamcommunity.com/profiles/{STEAMID64}/"), ("steamid64", STEAMID64))
+        self.assertEqual(parse_steam_identifier(f"steamcommunity.com/profiles/{STEAM3}"), ("steamid64", STEAMID64))
+        self.assertEqual(parse_steam_identifier("https://steamcommunity.com/id/gabelogannewell/"), ("vanity", "gabelogannewell"))
+
+    def test_vanity_name(self):
+        """Anything else is treated as a vanity name."""
+        self.assertEqual(parse_steam_identifier("gabelogannewell"), ("vanity", "gabelogannewell"))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,37 @@
+#!/usr/bin/env python3
+"""Test which subscribed resources a finished sync notifies."""
+
+import sys
+import unittest
+from pathlib import Path
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from mcp_server.subscriptions import changed_by_sync
+
+
+class TestChangedBySync(unittest.TestCase):
+    """Test matching resource URIs against a synced library."""
+
+    def test_synced_library_resources(self):
+        """The synced user's resources change, including later pages; other users' don't."""
+        self.assertTrue(changed_by_sync("library://users/1/games", "1"))
+        self.assertTrue(changed_by_sync("library://users/1/games/page/eyJvZmZzZXQiOiAxMDB9", "1"))
+        self.assertTrue(changed_by_sync("library://users/1", "1"))
+        self.assertFalse(changed_by_sync("library://users/12/games", "1"))
+        self.assertFalse(changed_by_sync("library://games/620", "1"))
+
+    def test_default_alias_follows_default_user(self):
+        """library://users/default resources change only when the default user is synced."""
+        self.assertTrue(changed_by_sync("library://users/default/stats", "1", default_steam_id="1"))
+        self.assertFalse(changed_by_sync("library://users/default/stats", "2", default_steam_id="1"))
+
+    def test_all_library_resources(self):
+        """Lists of every library change with any sync."""
+        self.assertTrue(changed_by_sync("library://users", "2"))
+        self.assertTrue(changed_by_sync("library://overview", "2"))
+
+
+if __name__ == "__main__":
+    unittest.main()
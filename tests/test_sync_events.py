@@ -0,0 +1,75 @@
+#!/usr/bin/env python3
+"""Test the fetcher's sync progress event channels."""
+
+import queue
+import sys
+import unittest
+from pathlib import Path
+
+# Add src to path for imports
+sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
+
+from fetcher.events import SyncEvents, follow
+
+
+class TestSyncEvents(unittest.TestCase):
+    """Test publishing to and following subscriber channels."""
+
+    def test_every_subscriber_receives_events(self):
+        """Each channel gets its own copy of every event, then None on close."""
+        events = SyncEvents()
+        first, second = events.subscribe(), events.subscribe()
+
+        events.publish("game_processed", app_id=620)
+        events.close()
+
+        for channel in (first, second):
+            event = channel.get_nowait()
+            self.assertEqual(event["type"], "game_processed")
+            self.assertEqual(event["app_id"], 620)
+            self.assertIn("timestamp", event)
+            self.assertIsNone(channel.get_nowait())
+
+    def test_unsubscribed_channel_gets_nothing(self):
+        """Events published after unsubscribing are not delivered."""
+        events = SyncEvents()
+        channel = events.subscribe()
+        events.unsubscribe(channel)
+
+        events.publish("sync_started")
+
+        with self.assertRaises(queue.Empty):
+            channel.get_nowait()
+
+    def test_slow_subscriber_drops_events(self):
+        """A full channel drops events instead of blocking, and still receives the close sentinel."""
+        events = SyncEvents()
+        channel = events.subscribe(maxsize=2)
+
+        for index in range(5):
+            events.publish("game_processed", index=index)
+        events.close()
+
+        received = []
+        while (event := channel.get_nowait()) is not None:
+            received.append(event["index"])
+        # Events 2-4 were dropped on publish, and event 0 made room for the sentinel
+        self.assertEqual(received, [1])
+
+    def test_follow_calls_handler_until_closed(self):
+        """follow() hands each event to the handler and stops when the channel closes."""
+        events = SyncEvents()
+        seen = []
+        thread = follow(events.subscribe(), lambda event: seen.append(event["type"]))
+
+        events.publish("sync_started")
+        events.publish("sync_completed")
+        events.close()
+        thread.join(timeout=5)
+
+        self.assertFalse(thread.is_alive())
+        self.assertEqual(seen, ["sync_started", "sync_completed"])
+
+
+if __name__ == "__main__":
+    unittest.main()